@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"github.com/HAL-xyz/zoroaster/config"
+	"github.com/HAL-xyz/zoroaster/tokenapi"
 	"github.com/HAL-xyz/zoroaster/trigger"
 	"github.com/HAL-xyz/zoroaster/utils"
 	"github.com/aws/aws-sdk-go/service/ses/sesiface"
@@ -26,7 +27,9 @@ func ProcessActions(
 	actionsString []string,
 	match trigger.IMatch,
 	iEmail sesiface.SESAPI,
-	httpCli IHttpClient) []*trigger.Outcome {
+	httpCli IHttpClient,
+	tokenApi tokenapi.ITokenAPI,
+	conf *config.ZConfiguration) []*trigger.Outcome {
 
 	actions := getActionsFromString(actionsString)
 	outcomes := make([]*trigger.Outcome, len(actions))
@@ -35,17 +38,17 @@ func ProcessActions(
 	for i, a := range actions {
 		switch v := a.Attribute.(type) {
 		case AttributeWebhookPost:
-			out = handleWebHookPost(v, match, httpCli)
+			out = handleWebHookPost(v, match, httpCli, tokenApi)
 		case AttributeEmail:
-			out = handleEmail(v, match, iEmail, a.TemplateVersion)
+			out = handleEmail(v, match, iEmail, tokenApi, a.TemplateVersion)
 		case AttributeSlackBot:
-			out = handleSlackBot(v, match, httpCli, a.TemplateVersion)
+			out = handleSlackBot(v, match, httpCli, tokenApi, a.TemplateVersion)
 		case AttributeTelegramBot:
-			out = handleTelegramBot(v, match, httpCli, a.TemplateVersion)
+			out = handleTelegramBot(v, match, httpCli, tokenApi, a.TemplateVersion)
 		case AttributeTweet:
-			out = handleTweet(v, match, a.TemplateVersion)
+			out = handleTweet(v, match, tokenApi, conf, a.TemplateVersion)
 		case AttributeDiscord:
-			out = handleDiscord(v, match, httpCli, a.TemplateVersion)
+			out = handleDiscord(v, match, httpCli, tokenApi, a.TemplateVersion)
 		default:
 			out = &trigger.Outcome{
 				Payload: "",
@@ -87,14 +90,14 @@ type WebhookResponse struct {
 	Response string
 }
 
-func handleWebHookPost(awp AttributeWebhookPost, match trigger.IMatch, httpCli IHttpClient) *trigger.Outcome {
+func handleWebHookPost(awp AttributeWebhookPost, match trigger.IMatch, httpCli IHttpClient, tokenApi tokenapi.ITokenAPI) *trigger.Outcome {
 
 	matchData, _ := json.Marshal(match.ToPostPayload())
 	var m map[string]interface{}
 	err := json.Unmarshal(matchData, &m)
 
 	if awp.Body != "" {
-		m["Body"] = fillBodyTemplate(awp.Body, match, "v2")
+		m["Body"] = fillBodyTemplate(awp.Body, match, tokenApi, "v2")
 	}
 
 	payload, err := json.Marshal(m)
@@ -130,8 +133,8 @@ type DiscordPayload struct {
 	Content string `json:"content"`
 }
 
-func handleDiscord(discAttr AttributeDiscord, match trigger.IMatch, httpCli IHttpClient, templVersion string) *trigger.Outcome {
-	payload := DiscordPayload{fillBodyTemplate(discAttr.Body, match, templVersion)}
+func handleDiscord(discAttr AttributeDiscord, match trigger.IMatch, httpCli IHttpClient, tokenApi tokenapi.ITokenAPI, templVersion string) *trigger.Outcome {
+	payload := DiscordPayload{fillBodyTemplate(discAttr.Body, match, tokenApi, templVersion)}
 
 	postData, err := json.Marshal(payload)
 	if err != nil {
@@ -164,8 +167,8 @@ type SlackPayload struct {
 	Text string `json:"text"`
 }
 
-func handleSlackBot(slackAttr AttributeSlackBot, match trigger.IMatch, httpCli IHttpClient, templVersion string) *trigger.Outcome {
-	payload := SlackPayload{fillBodyTemplate(slackAttr.Body, match, templVersion)}
+func handleSlackBot(slackAttr AttributeSlackBot, match trigger.IMatch, httpCli IHttpClient, tokenApi tokenapi.ITokenAPI, templVersion string) *trigger.Outcome {
+	payload := SlackPayload{fillBodyTemplate(slackAttr.Body, match, tokenApi, templVersion)}
 
 	postData, err := json.Marshal(payload)
 	if err != nil {
@@ -206,9 +209,9 @@ type TelegramResponse struct {
 	Description string `json:"description"`
 }
 
-func handleTelegramBot(telegramAttr AttributeTelegramBot, match trigger.IMatch, httpCli IHttpClient, templVersion string) *trigger.Outcome {
+func handleTelegramBot(telegramAttr AttributeTelegramBot, match trigger.IMatch, httpCli IHttpClient, tokenApi tokenapi.ITokenAPI, templVersion string) *trigger.Outcome {
 	payload := TelegramPayload{
-		Text:         fillBodyTemplate(telegramAttr.Body, match, templVersion),
+		Text:         fillBodyTemplate(telegramAttr.Body, match, tokenApi, templVersion),
 		ChatId:       telegramAttr.ChatId,
 		Format:       telegramAttr.Format,
 		LinksPreview: telegramAttr.DisableLinksPreview,
@@ -283,14 +286,22 @@ type TwitterPayload struct {
 	Status string
 }
 
-func handleTweet(tweetAttr AttributeTweet, match trigger.IMatch, templVersion string) *trigger.Outcome {
+func handleTweet(tweetAttr AttributeTweet, match trigger.IMatch, tokenApi tokenapi.ITokenAPI, conf *config.ZConfiguration, templVersion string) *trigger.Outcome {
 	payload := TwitterPayload{
-		Status: fillBodyTemplate(tweetAttr.Status, match, templVersion),
+		Status: fillBodyTemplate(tweetAttr.Status, match, tokenApi, templVersion),
 	}
 
 	postData, _ := json.Marshal(payload)
 
-	authconfig := oauth1.NewConfig(config.Zconf.TwitterConsumerKey, config.Zconf.TwitterConsumerSecret)
+	if !conf.TwitterEnabled() {
+		return &trigger.Outcome{
+			Payload: string(postData),
+			Outcome: makeErrorResponse("twitter is not configured"),
+			Success: false,
+		}
+	}
+
+	authconfig := oauth1.NewConfig(conf.TwitterConsumerKey, conf.TwitterConsumerSecret)
 	token := oauth1.NewToken(tweetAttr.Token, tweetAttr.Secret)
 	httpClient := authconfig.Client(oauth1.NoContext, token)
 	twitterClient := twitter.NewClient(httpClient)
@@ -322,11 +333,11 @@ type EmailPayload struct {
 	Subject    string
 }
 
-func handleEmail(email AttributeEmail, match trigger.IMatch, iemail sesiface.SESAPI, templVersion string) *trigger.Outcome {
+func handleEmail(email AttributeEmail, match trigger.IMatch, iemail sesiface.SESAPI, tokenApi tokenapi.ITokenAPI, templVersion string) *trigger.Outcome {
 
-	email.Body = fillBodyTemplate(email.Body, match, templVersion)
-	email.Subject = fillBodyTemplate(email.Subject, match, templVersion)
-	allRecipients := getAllRecipients(email.To, match, templVersion)
+	email.Body = fillBodyTemplate(email.Body, match, tokenApi, templVersion)
+	email.Subject = fillBodyTemplate(email.Subject, match, tokenApi, templVersion)
+	allRecipients := getAllRecipients(email.To, match, tokenApi, templVersion)
 
 	emailPayload := EmailPayload{
 		Recipients: allRecipients,
@@ -358,12 +369,12 @@ func handleEmail(email AttributeEmail, match trigger.IMatch, iemail sesiface.SES
 }
 
 // get extra recipients from the TO field
-func getAllRecipients(emailTo []string, match trigger.IMatch, templVersion string) []string {
+func getAllRecipients(emailTo []string, match trigger.IMatch, tokenApi tokenapi.ITokenAPI, templVersion string) []string {
 	extraRecipients := make([]string, 0)
 	extraRecipients = append(extraRecipients, emailTo...)
 
 	for _, r := range emailTo {
-		templatedString := fillBodyTemplate(r, match, templVersion)
+		templatedString := fillBodyTemplate(r, match, tokenApi, templVersion)
 		cleanString := utils.RemoveCharacters(templatedString, "[]")
 		for _, email := range strings.Split(cleanString, " ") {
 			if !utils.IsIn(email, extraRecipients) {
@@ -3,7 +3,7 @@ package action
 import (
 	"bytes"
 	"encoding/json"
-	"github.com/HAL-xyz/ethrpc"
+	"github.com/HAL-xyz/zoroaster/config"
 	"github.com/HAL-xyz/zoroaster/tokenapi"
 	"github.com/HAL-xyz/zoroaster/trigger"
 	"github.com/HAL-xyz/zoroaster/utils"
@@ -13,6 +13,7 @@ import (
 	"io"
 	"io/ioutil"
 	"net/http"
+	"os"
 	"testing"
 )
 
@@ -58,7 +59,15 @@ type mockETHCli struct {
 }
 
 var mockCli mockETHCli
-var mockTokenApi = tokenapi.New(mockCli)
+var mockTokenApi = tokenapi.MustNew(mockCli)
+
+// templates are rendered against a real node; the rest of the config doesn't depend on the env
+var testConf = &config.ZConfiguration{
+	Stage:   config.TEST,
+	EthNode: os.Getenv("ETH_NODE"),
+	Network: "1_eth_mainnet",
+}
+var templatingApi = tokenapi.MustNew(tokenapi.NewZRPC(testConf.EthNode, "templating test client"), tokenapi.WithConfig(testConf))
 
 func TestHandleWebHookPost(t *testing.T) {
 
@@ -72,12 +81,13 @@ func TestHandleWebHookPost(t *testing.T) {
 		8888,
 		1554828248,
 		"0x",
+		nil,
 		"uuid",
 		[]string{"true"},
 		[]interface{}{"true"},
 	}
 
-	outcome := handleWebHookPost(awp, &cnMatch, mockHttpClient{})
+	outcome := handleWebHookPost(awp, &cnMatch, mockHttpClient{}, templatingApi)
 
 	expectedPayload := `{
    "BlockNumber":8888,
@@ -118,7 +128,7 @@ func TestHandleWebhookPostWithTxMatch(t *testing.T) {
 		DecodedFnArgs:  map[string]interface{}{},
 		Tx:             tx,
 	}
-	outcome := handleWebHookPost(awp, &txMatch, mockHttpClient{})
+	outcome := handleWebHookPost(awp, &txMatch, mockHttpClient{}, templatingApi)
 
 	expectedPayload := `{
   "DecodedData": {
@@ -158,11 +168,12 @@ func TestHandleWebHookWrongStuff(t *testing.T) {
 		8888,
 		1554828248,
 		"0x",
+		nil,
 		"uuid",
 		[]string{"true"},
 		[]interface{}{"true"},
 	}
-	outcome := handleWebHookPost(url, &cnMatch, &http.Client{})
+	outcome := handleWebHookPost(url, &cnMatch, &http.Client{}, templatingApi)
 
 	//notFoundPattern := strings.HasPrefix(outcome.Outcome, `{"error":"Post https://foo.zyusfddsiu:`)
 	//assert.True(t, notFoundPattern)
@@ -179,9 +190,9 @@ func TestHandleWebhookWithEvents(t *testing.T) {
 	assert.NoError(t, err)
 	logs, err := trigger.GetLogsFromFile("../resources/events/logs1.json")
 	assert.NoError(t, err)
-	matches1 := trigger.MatchEvent(tg1, logs, []ethrpc.Transaction{}, mockTokenApi)
+	matches1 := trigger.MatchEvent(tg1, logs, []tokenapi.Transaction{}, mockTokenApi)
 
-	outcome := handleWebHookPost(awp, matches1[0], mockHttpClient{})
+	outcome := handleWebHookPost(awp, matches1[0], mockHttpClient{}, templatingApi)
 
 	expectedPayload := `{
    "ContractAdd":"0xdac17f958d2ee523a2206206994597c13d831ec7",
@@ -248,7 +259,7 @@ func TestHandleEmail1(t *testing.T) {
 		BlockTimestamp: 123,
 		BlockHash:      "0x",
 	}
-	outcome := handleEmail(email, &match, &mockSESClient{}, "")
+	outcome := handleEmail(email, &match, &mockSESClient{}, templatingApi, "")
 	expectedPayload := `{
  "Recipients":[
     "manlio.poltronieri@gmail.com",
@@ -282,7 +293,7 @@ func TestHandleEmail2(t *testing.T) {
 		BlockTimestamp: 123,
 		BlockHash:      "0x",
 	}
-	outcome := handleEmail(email, &match, &mockSESClient{}, "")
+	outcome := handleEmail(email, &match, &mockSESClient{}, templatingApi, "")
 
 	expectedPayload := `{
   "Recipients":[
@@ -318,7 +329,7 @@ func TestHandleEmail3(t *testing.T) {
 		BlockTimestamp: 123,
 		BlockHash:      "0x",
 	}
-	outcome := handleEmail(email, &match, &mockSESClient{}, "")
+	outcome := handleEmail(email, &match, &mockSESClient{}, templatingApi, "")
 	expectedPayload := `{
   "Recipients":[
      "manlio.poltronieri@gmail.com",
@@ -338,7 +349,7 @@ func TestHandleEmailWithEvents(t *testing.T) {
 	assert.NoError(t, err)
 	logs, err := trigger.GetLogsFromFile("../resources/events/logs1.json")
 	assert.NoError(t, err)
-	matches := trigger.MatchEvent(tg1, logs, []ethrpc.Transaction{}, mockTokenApi)
+	matches := trigger.MatchEvent(tg1, logs, []tokenapi.Transaction{}, mockTokenApi)
 
 	matches[0].EventParams["extraAddresses"] = []string{"yes@hal.xyz", "nope@hal.xyz"}
 
@@ -349,7 +360,7 @@ func TestHandleEmailWithEvents(t *testing.T) {
 		Body:    "body",
 	}
 
-	outcome := handleEmail(email, matches[0], &mockSESClient{}, "")
+	outcome := handleEmail(email, matches[0], &mockSESClient{}, templatingApi, "")
 	expPayload := `{ 
    "Recipients":[ 
       "manlio.poltronieri@gmail.com",
@@ -372,7 +383,7 @@ func TestHandleEmailWithEvents(t *testing.T) {
    "Body":"body",
    "Subject":"Event email test"
 }`
-	outcome = handleEmail(email, matches[0], &mockSESClient{}, "")
+	outcome = handleEmail(email, matches[0], &mockSESClient{}, templatingApi, "")
 
 	ok, err = utils.AreEqualJSON(expPayload, outcome.Payload)
 	assert.NoError(t, err)
@@ -398,7 +409,7 @@ func TestHandleDiscord(t *testing.T) {
 		BlockTimestamp: 123,
 		BlockHash:      "0x",
 	}
-	outcome := handleDiscord(discordMsg, &match, &mockHttpClient{}, "")
+	outcome := handleDiscord(discordMsg, &match, &mockHttpClient{}, templatingApi, "")
 
 	expectedPayload := `{"content":"Hello World Test on block 777"}`
 	ok, _ := utils.AreEqualJSON(expectedPayload, outcome.Payload)
@@ -424,7 +435,7 @@ func TestHandleSlackBot(t *testing.T) {
 		BlockTimestamp: 123,
 		BlockHash:      "0x",
 	}
-	outcome := handleSlackBot(slackMsg, &match, &mockHttpClient{}, "")
+	outcome := handleSlackBot(slackMsg, &match, &mockHttpClient{}, templatingApi, "")
 
 	expectedPayload := `{"text":"Hello World Test on block 777"}`
 	ok, _ := utils.AreEqualJSON(expectedPayload, outcome.Payload)
@@ -464,7 +475,7 @@ func TestHandleTelegramBot(t *testing.T) {
 		BlockHash:      "0x",
 	}
 
-	outcome := handleTelegramBot(payload, &match, &mockHttpClient{}, "")
+	outcome := handleTelegramBot(payload, &match, &mockHttpClient{}, templatingApi, "")
 
 	expectedPayload := `
 {
@@ -484,7 +495,7 @@ func TestHandleTelegramBot(t *testing.T) {
 	// test some broken cases
 
 	// 400
-	outcomeBadRequest := handleTelegramBot(payload, &match, &mockHttpClient400{}, "")
+	outcomeBadRequest := handleTelegramBot(payload, &match, &mockHttpClient400{}, templatingApi, "")
 	assert.Equal(t, false, outcomeBadRequest.Success)
 
 	ok, _ = utils.AreEqualJSON(`{"HttpCode":400,"Response":"Bad Request: chat not found"}`, outcomeBadRequest.Outcome)
@@ -497,7 +508,7 @@ func TestHandleTelegramBot(t *testing.T) {
 		ChatId: "wrong", // missing @
 		Format: "HTML",
 	}
-	failedOutcome := handleTelegramBot(brokenChatId, &match, &mockHttpClient{}, "")
+	failedOutcome := handleTelegramBot(brokenChatId, &match, &mockHttpClient{}, templatingApi, "")
 	assert.Equal(t, false, failedOutcome.Success)
 
 	// wrong formatting
@@ -507,7 +518,7 @@ func TestHandleTelegramBot(t *testing.T) {
 		ChatId: "-408369343",
 		Format: "whoops", // wrong formatting option
 	}
-	anotherFail := handleTelegramBot(brokenFormatting, &match, &mockHttpClient{}, "")
+	anotherFail := handleTelegramBot(brokenFormatting, &match, &mockHttpClient{}, templatingApi, "")
 	assert.Equal(t, false, anotherFail.Success)
 }
 
@@ -529,7 +540,7 @@ func TestHandleNewTemplateSystem(t *testing.T) {
 		BlockTimestamp: 123,
 		BlockHash:      "0x",
 	}
-	outcome := handleSlackBot(slackMsg, &match, &mockHttpClient{}, "v2")
+	outcome := handleSlackBot(slackMsg, &match, &mockHttpClient{}, templatingApi, "v2")
 
 	expectedPayload := `{"text":"Hello World Test on block 777"}`
 	ok, _ := utils.AreEqualJSON(expectedPayload, outcome.Payload)
@@ -96,6 +96,16 @@ func NewActionJson(input []byte) (*ActionJson, error) {
 	return &aj, nil
 }
 
+// GetActionType returns the (lower case) type of a json action, e.g. "email";
+// quotas are kept per action type
+func GetActionType(actionString string) string {
+	aj, err := NewActionJson([]byte(actionString))
+	if err != nil {
+		return ""
+	}
+	return strings.ToLower(aj.ActionType)
+}
+
 // converts an ActionJson to an Action
 func (ajs *ActionJson) ToAction() (*Action, error) {
 	action := Action{
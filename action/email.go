@@ -43,6 +43,12 @@ func sendEmail(iemail sesiface.SESAPI, recipient []string, subject, body string)
 	return result, nil
 }
 
+// SendNotice sends a plain email from HAL, e.g. to let users know they're running out of quota
+func SendNotice(iemail sesiface.SESAPI, recipient, subject, body string) error {
+	_, err := sendEmail(iemail, []string{recipient}, subject, body)
+	return err
+}
+
 func assembleEmail(recipients []string, subject, body string) *ses.SendEmailInput {
 
 	toAddresses := make([]*string, len(recipients))
@@ -2,6 +2,7 @@ package action
 
 import (
 	"fmt"
+	"github.com/HAL-xyz/zoroaster/tokenapi"
 	"github.com/HAL-xyz/zoroaster/trigger"
 	"github.com/HAL-xyz/zoroaster/utils"
 	"github.com/sirupsen/logrus"
@@ -16,10 +17,10 @@ import (
 
 var applyAllTemplateConversions = utils.ComposeStringFns(scaleAmounts, fillHumanTime)
 
-func fillBodyTemplate(text string, payload trigger.IMatch, templateVersion string) string {
+func fillBodyTemplate(text string, payload trigger.IMatch, tokenApi tokenapi.ITokenAPI, templateVersion string) string {
 	// new template system
 	if templateVersion == "v2" {
-		rendered, err := RenderTemplateWithData(text, payload.ToTemplateMatch())
+		rendered, err := RenderTemplateWithData(text, payload.ToTemplateMatch(), tokenApi)
 		if err != nil {
 			logrus.Debugf("tg %s had template error %s", payload.GetTriggerUUID(), err)
 		}
@@ -33,6 +34,8 @@ func fillBodyTemplate(text string, payload trigger.IMatch, templateVersion strin
 		return applyAllTemplateConversions(templateContract(text, *m))
 	case *trigger.EventMatch:
 		return applyAllTemplateConversions(templateEvent(text, *m))
+	case *trigger.BlockMatch:
+		return applyAllTemplateConversions(templateBlock(text, *m))
 	default:
 		logrus.Warnf("Invalid match type %T", payload)
 		return text
@@ -128,6 +131,27 @@ func templateEvent(text string, match trigger.EventMatch) string {
 	return text
 }
 
+func templateBlock(text string, match trigger.BlockMatch) string {
+	// standard fields
+	text = strings.ReplaceAll(text, "$BlockNumber$", fmt.Sprintf("%v", match.BlockNumber))
+	text = strings.ReplaceAll(text, "$BlockTimestamp$", fmt.Sprintf("%v", match.BlockTimestamp))
+	text = strings.ReplaceAll(text, "$BlockHash$", match.BlockHash)
+	if match.BaseFeePerGas != nil {
+		text = strings.ReplaceAll(text, "$BaseFeePerGas$", match.BaseFeePerGas.String())
+	}
+
+	// block stats
+	text = strings.ReplaceAll(text, "$Miner$", match.Stats.Miner)
+	text = strings.ReplaceAll(text, "$GasUsed$", fmt.Sprintf("%v", match.Stats.GasUsed))
+	text = strings.ReplaceAll(text, "$GasLimit$", fmt.Sprintf("%v", match.Stats.GasLimit))
+	text = strings.ReplaceAll(text, "$GasUsedRatio$", fmt.Sprintf("%v", match.Stats.GasUsedRatio))
+	text = strings.ReplaceAll(text, "$TimestampGap$", fmt.Sprintf("%v", match.Stats.TimestampGap))
+	text = strings.ReplaceAll(text, "$TxCount$", fmt.Sprintf("%v", match.Stats.TxCount))
+	text = strings.ReplaceAll(text, "$TotalValue$", match.Stats.TotalValue.String())
+	text = strings.ReplaceAll(text, "$ContractCreations$", fmt.Sprintf("%v", match.Stats.ContractCreations))
+	return text
+}
+
 func templateContract(text string, match trigger.CnMatch) string {
 	// standard fields
 	blockNumber := fmt.Sprintf("%v", match.BlockNumber)
@@ -188,8 +212,11 @@ func templateContract(text string, match trigger.CnMatch) string {
 }
 
 func templateTransaction(text string, match trigger.TxMatch) string {
-	// standard fields
-	blockNumber := fmt.Sprintf("%v", *match.Tx.BlockNumber)
+	// standard fields; pending txs don't have a block yet
+	var blockNumber string
+	if match.Tx.BlockNumber != nil {
+		blockNumber = fmt.Sprintf("%v", *match.Tx.BlockNumber)
+	}
 	blockTimestamp := fmt.Sprintf("%v", match.BlockTimestamp)
 	gas := fmt.Sprintf("%v", match.Tx.Gas)
 	gasPrice := fmt.Sprintf("%v", &match.Tx.GasPrice)
@@ -205,6 +232,26 @@ func templateTransaction(text string, match trigger.TxMatch) string {
 	text = strings.ReplaceAll(text, "$Gas$", gas)
 	text = strings.ReplaceAll(text, "$GasPrice$", gasPrice)
 	text = strings.ReplaceAll(text, "$Nonce$", nonce)
+	text = strings.ReplaceAll(text, "$Status$", match.Status)
+	text = strings.ReplaceAll(text, "$Type$", fmt.Sprintf("%v", match.Tx.Type))
+	if match.Tx.MaxFeePerGas != nil {
+		text = strings.ReplaceAll(text, "$MaxFeePerGas$", match.Tx.MaxFeePerGas.String())
+	}
+	if match.Tx.MaxPriorityFeePerGas != nil {
+		text = strings.ReplaceAll(text, "$MaxPriorityFeePerGas$", match.Tx.MaxPriorityFeePerGas.String())
+	}
+	if match.BaseFeePerGas != nil {
+		text = strings.ReplaceAll(text, "$BaseFeePerGas$", match.BaseFeePerGas.String())
+	}
+	// receipts are only fetched for triggers with receipt filters
+	if match.Receipt != nil {
+		text = strings.ReplaceAll(text, "$GasUsed$", fmt.Sprintf("%v", match.Receipt.GasUsed))
+		text = strings.ReplaceAll(text, "$EffectiveGasPrice$", match.Receipt.EffectiveGasPrice.String())
+		text = strings.ReplaceAll(text, "$Fee$", match.Receipt.Fee.String())
+	}
+	if match.Deployment != nil {
+		text = strings.ReplaceAll(text, "$DeployedContract$", match.Deployment.Address)
+	}
 
 	// function name
 	if match.DecodedFnName != nil {
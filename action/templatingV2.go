@@ -5,6 +5,7 @@ import (
 	"encoding/hex"
 	"fmt"
 	"github.com/HAL-xyz/zoroaster/tokenapi"
+	"github.com/HAL-xyz/zoroaster/trigger"
 	"github.com/HAL-xyz/zoroaster/utils"
 	"github.com/leekchan/accounting"
 	"math/big"
@@ -15,7 +16,15 @@ import (
 	"time"
 )
 
-func RenderTemplateWithData(templateText string, data interface{}) (string, error) {
+// RenderTemplateWithData renders a v2 template; tokenApi backs the functions that need the chain
+// or the prices, like symbol, toFiat or ethCall.
+func RenderTemplateWithData(templateText string, data interface{}, tokenApi tokenapi.ITokenAPI) (string, error) {
+
+	// matches are converted at the price of their block, so that rendering them is reproducible
+	toFiat := wrapGetExchangeRate(tokenApi)
+	if m, ok := data.(trigger.TemplateMatch); ok && m.Block.Number != nil {
+		toFiat = wrapGetExchangeRateAtBlock(tokenApi, *m.Block.Number, m.Block.Timestamp)
+	}
 
 	funcMap := template.FuncMap{
 		"upperCase":              strings.ToUpper,
@@ -30,11 +39,17 @@ func RenderTemplateWithData(templateText string, data interface{}) (string, erro
 		"polygonscanTxLink":      polygonscanTxLink,
 		"polygonscanAddressLink": polygonscanAddressLink,
 		"polygonscanTokenLink":   polygonscanTokenLink,
-		"fromWei":                tokenapi.GetTokenAPI().FromWei,
+		"fromWei":                tokenApi.FromWei,
 		"humanTime":              timestampToHumanTime,
-		"symbol":                 tokenapi.GetTokenAPI().Symbol,
-		"decimals":               tokenapi.GetTokenAPI().Decimals,
-		"balanceOf":              tokenapi.GetTokenAPI().BalanceOf,
+		"symbol":                 tokenApi.Symbol,
+		"decimals":               tokenApi.Decimals,
+		"tokenName":              tokenApi.TokenName,
+		"balanceOf":              tokenApi.BalanceOf,
+		"ownerOf":                tokenApi.OwnerOf,
+		"tokenURI":               tokenApi.TokenURI,
+		"collectionName":         tokenApi.CollectionName,
+		"ensName":                tokenApi.LookupENS,
+		"ensResolve":             wrapResolveENS(tokenApi),
 		"add":                    add,
 		"sub":                    sub,
 		"mul":                    mul,
@@ -43,11 +58,11 @@ func RenderTemplateWithData(templateText string, data interface{}) (string, erro
 		"round":                  utils.Round,
 		"pow":                    pow,
 		"formatNumber":           formatNumber,
-		"toFiat":                 wrapGetExchangeRate,
-		"toFiatAt":               wrapGetExchangeRateAtDate,
+		"toFiat":                 toFiat,
+		"toFiatAt":               wrapGetExchangeRateAtDate(tokenApi),
 		"floatToInt":             floatToInt,
-		"ERC20Snapshot":          eRC20Snapshot,
-		"ethCall":                ethCall,
+		"ERC20Snapshot":          eRC20Snapshot(tokenApi),
+		"ethCall":                ethCall(tokenApi),
 	}
 
 	tmpl := template.New("").Funcs(funcMap)
@@ -218,78 +233,112 @@ func floatToInt(i interface{}) int64 {
 	}
 }
 
-func eRC20Snapshot(allBalancesIfc []interface{}) map[string]*big.Int {
-	// balances are already sorted per address because the multicall is ordered;
-	// here we are just converting the multicall output to []*big.Int
+func eRC20Snapshot(tokenApi tokenapi.ITokenAPI) func([]interface{}) map[string]*big.Int {
+	return func(allBalancesIfc []interface{}) map[string]*big.Int {
+		// balances are already sorted per address because the multicall is ordered;
+		// here we are just converting the multicall output to []*big.Int
 
-	if len(allBalancesIfc) != 1 {
-		return map[string]*big.Int{}
-	}
-	balances, ok := allBalancesIfc[0].([]string)
-	if !ok {
-		return map[string]*big.Int{}
-	}
+		if len(allBalancesIfc) != 1 {
+			return map[string]*big.Int{}
+		}
+		balances, ok := allBalancesIfc[0].([]string)
+		if !ok {
+			return map[string]*big.Int{}
+		}
 
-	sortedBalances := make([]*big.Int, len(balances))
-	for i, v := range balances {
-		sortedBalances[i] = utils.MakeBigInt(v)
-	}
+		sortedBalances := make([]*big.Int, len(balances))
+		for i, v := range balances {
+			sortedBalances[i] = utils.MakeBigInt(v)
+		}
 
-	// make a sorted list of all the tokens
-	var i = 0
-	sortedTokenAdds := make([]string, len(tokenapi.GetTokenAPI().GetAllERC20TokensMap()))
-	for k := range tokenapi.GetTokenAPI().GetAllERC20TokensMap() {
-		sortedTokenAdds[i] = k
-		i++
-	}
-	sort.Strings(sortedTokenAdds)
+		// make a sorted list of all the tokens
+		var i = 0
+		tokens := tokenApi.GetAllERC20TokensMap()
+		sortedTokenAdds := make([]string, len(tokens))
+		for k := range tokens {
+			sortedTokenAdds[i] = k
+			i++
+		}
+		sort.Strings(sortedTokenAdds)
+
+		// the token list changed since the multicall was made
+		if len(sortedBalances) != len(sortedTokenAdds) {
+			return map[string]*big.Int{}
+		}
 
-	// So now we have:
-	// an [] of all Balances Sorted
-	// an [] of all Tokens Sorted
+		// So now we have:
+		// an [] of all Balances Sorted
+		// an [] of all Tokens Sorted
 
-	// combine []balances and []tokenAdds to a balance map (tokenAdd -> balance)
-	var balanceMap = map[string]*big.Int{}
+		// combine []balances and []tokenAdds to a balance map (tokenAdd -> balance)
+		var balanceMap = map[string]*big.Int{}
 
-	for i, balance := range sortedBalances {
-		if balance.Cmp(big.NewInt(0)) == 1 {
-			balanceMap[sortedTokenAdds[i]] = balance
+		for i, balance := range sortedBalances {
+			if balance.Cmp(big.NewInt(0)) == 1 {
+				balanceMap[sortedTokenAdds[i]] = balance
+			}
 		}
-	}
 
-	return balanceMap
+		return balanceMap
+	}
 }
 
 // The template system doesn't like functions that return (T, error);
 // in fact, it will abort parsing the template altogether.
 // So we're wrapping the original functions to provide a dummy exchange value in case of errors;
 // this way the result won't make sense, but at least it won't break everything.
-func wrapGetExchangeRate(tokenAddress, fiatCurrency string) float32 {
-	res, err := tokenapi.GetTokenAPI().GetExchangeRate(tokenAddress, fiatCurrency)
-	if err != nil {
-		return 0
+func wrapGetExchangeRate(tokenApi tokenapi.ITokenAPI) func(string, string) float32 {
+	return func(tokenAddress, fiatCurrency string) float32 {
+		res, err := tokenApi.GetExchangeRate(tokenAddress, fiatCurrency)
+		if err != nil {
+			return 0
+		}
+		return res
 	}
-	return res
 }
 
-func wrapGetExchangeRateAtDate(tokenAddress, fiatCurrency, when string) float32 {
-	res, err := tokenapi.GetTokenAPI().GetExchangeRateAtDate(tokenAddress, fiatCurrency, when)
-	if err != nil {
-		return 0
+func wrapGetExchangeRateAtBlock(tokenApi tokenapi.ITokenAPI, blockNo, timestamp int) func(string, string) float32 {
+	return func(tokenAddress, fiatCurrency string) float32 {
+		res, err := tokenApi.GetExchangeRateAtBlock(tokenAddress, fiatCurrency, blockNo, timestamp)
+		if err != nil {
+			return 0
+		}
+		return res
 	}
-	return res
 }
 
-func ethCall(address string, blockNo, returnedPosition int, method string, args ...string) string {
-
-	res, err := tokenapi.GetTokenAPI().EthCall(address, method, "", blockNo, args...)
-	if err != nil {
-		return err.Error()
+func wrapResolveENS(tokenApi tokenapi.ITokenAPI) func(string) string {
+	return func(name string) string {
+		res, err := tokenApi.ResolveENS(name)
+		if err != nil {
+			return ""
+		}
+		return res
 	}
-	if returnedPosition >= len(res) {
-		return fmt.Sprintf("invalid returned position %d", returnedPosition)
+}
+
+func wrapGetExchangeRateAtDate(tokenApi tokenapi.ITokenAPI) func(string, string, string) float32 {
+	return func(tokenAddress, fiatCurrency, when string) float32 {
+		res, err := tokenApi.GetExchangeRateAtDate(tokenAddress, fiatCurrency, when)
+		if err != nil {
+			return 0
+		}
+		return res
 	}
+}
 
-	printableResults := utils.SprintfInterfaces(res)
-	return fmt.Sprintf("%v", printableResults[returnedPosition])
+func ethCall(tokenApi tokenapi.ITokenAPI) func(string, int, int, string, ...string) string {
+	return func(address string, blockNo, returnedPosition int, method string, args ...string) string {
+
+		res, err := tokenApi.EthCall(address, method, "", blockNo, args...)
+		if err != nil {
+			return err.Error()
+		}
+		if returnedPosition >= len(res) {
+			return fmt.Sprintf("invalid returned position %d", returnedPosition)
+		}
+
+		printableResults := utils.SprintfInterfaces(res)
+		return fmt.Sprintf("%v", printableResults[returnedPosition])
+	}
 }
@@ -1,7 +1,7 @@
 package action
 
 import (
-	"github.com/HAL-xyz/ethrpc"
+	"github.com/HAL-xyz/zoroaster/config"
 	"github.com/HAL-xyz/zoroaster/tokenapi"
 	"github.com/HAL-xyz/zoroaster/trigger"
 	"github.com/stretchr/testify/assert"
@@ -16,6 +16,36 @@ func init() {
 	defer gock.Off()
 }
 
+// answers the calls made by the ERC20 and ethCall templates, as mainnet would
+type mockTemplatingCli struct {
+	tokenapi.IEthRpc
+}
+
+func (cli mockTemplatingCli) EthBlockNumber() (int, error) {
+	return 13000000, nil
+}
+
+func (cli mockTemplatingCli) MakeEthRpcCall(cntAddress, data string, blockNumber int) (string, error) {
+	switch data[:10] {
+	case "0x70a08231": // balanceOf(address)
+		return "0x00000000000000000000000000000000000000000000000000005af3107a4000", nil
+	case "0x06fdde03": // name()
+		return "0x0000000000000000000000000000000000000000000000000000000000000020" +
+			"0000000000000000000000000000000000000000000000000000000000000007" +
+			"556e697377617000000000000000000000000000000000000000000000000000", nil
+	}
+	return "0x", nil
+}
+
+// templates that need the token list or the node are rendered against a stub of both
+var stubTokenApi = tokenapi.MustNew(mockTemplatingCli{}, tokenapi.WithConfig(&config.ZConfiguration{
+	Network:      "1_eth_mainnet",
+	TokenList:    "../resources/tokens/tokenlist.json",
+	EtherscanKey: "test",
+}))
+
+const uniABI = `[{"constant":true,"inputs":[],"name":"name","outputs":[{"name":"","type":"string"}],"payable":false,"stateMutability":"view","type":"function"},{"constant":true,"inputs":[{"name":"account","type":"address"}],"name":"balanceOf","outputs":[{"name":"","type":"uint256"}],"payable":false,"stateMutability":"view","type":"function"}]`
+
 func TestTxMatching(t *testing.T) {
 
 	block, _ := trigger.GetBlockFromFile("../resources/blocks/block1.json")
@@ -58,7 +88,7 @@ Transaction hash is: 0x42c8de77ef5d76f36aea6e051b9059ece6e34619d9fb4a1d97f3224d5
 Transaction value is: 0
 Transaction input data is: 0xa9059cbb000000000000000000000000fea2f9433058cd555fd67cdde8efd7e6031e56c00000000000000000000000000000000000000000000000003782dace9d900000
 `
-	rendered, err := RenderTemplateWithData(templateText, matches[0].ToTemplateMatch())
+	rendered, err := RenderTemplateWithData(templateText, matches[0].ToTemplateMatch(), templatingApi)
 	assert.NoError(t, err)
 	assert.Equal(t, expectedOutcome, rendered)
 
@@ -102,7 +132,7 @@ We also support a bunch of handy functions to manipulate different values:
 {{ etherscanAddressLink .Contract.Address }}
 
 `
-	_, err = RenderTemplateWithData(exampleUI, matches[0].ToTemplateMatch())
+	_, err = RenderTemplateWithData(exampleUI, matches[0].ToTemplateMatch(), templatingApi)
 	assert.NoError(t, err)
 }
 
@@ -142,7 +172,7 @@ First returned value of inner array is a
 Testing uppercase function is HELLO
 Out of bound value is `
 
-	rendered, err := RenderTemplateWithData(templateText, cnMatch.ToTemplateMatch())
+	rendered, err := RenderTemplateWithData(templateText, cnMatch.ToTemplateMatch(), templatingApi)
 	assert.Equal(t, expectedOutcome, rendered)
 	assert.Error(t, err) // error isn't nil because of the out of bound indexing
 
@@ -176,7 +206,7 @@ We also support a bunch of handy functions to manipulate different values:
 
 {{ etherscanAddressLink .Contract.Address }}
 `
-	_, err = RenderTemplateWithData(exampleUI, cnMatch.ToTemplateMatch())
+	_, err = RenderTemplateWithData(exampleUI, cnMatch.ToTemplateMatch(), templatingApi)
 	assert.NoError(t, err)
 }
 
@@ -186,7 +216,7 @@ func TestEventMatching(t *testing.T) {
 	assert.NoError(t, err)
 	logs, err := trigger.GetLogsFromFile("../resources/events/logs1.json")
 	assert.NoError(t, err)
-	matches := trigger.MatchEvent(tg1, logs, []ethrpc.Transaction{}, mockTokenApi)
+	matches := trigger.MatchEvent(tg1, logs, []tokenapi.Transaction{}, mockTokenApi)
 
 	matches[0].EventParams["arrayParam"] = []string{"hello", "world", "yo yo"}
 
@@ -208,7 +238,7 @@ First element in array parameter is: hello
 Missing param is: <no value>
 Transaction hash is 0xf44984a4b533ac0e7b608c881a856eff44ee8c17b9f4dcf8b4ee74e9c10c0455
 `
-	rendered, err := RenderTemplateWithData(templateText, matches[0].ToTemplateMatch())
+	rendered, err := RenderTemplateWithData(templateText, matches[0].ToTemplateMatch(), templatingApi)
 	assert.NoError(t, err)
 	assert.Equal(t, expectedOutcome, rendered)
 
@@ -221,7 +251,7 @@ Transaction hash is 0xf44984a4b533ac0e7b608c881a856eff44ee8c17b9f4dcf8b4ee74e9c1
 	{{ end }}
 {{ end }}
 `
-	_, err = RenderTemplateWithData(tmpl, matches[0].ToTemplateMatch())
+	_, err = RenderTemplateWithData(tmpl, matches[0].ToTemplateMatch(), templatingApi)
 	assert.NoError(t, err)
 
 	exampleUI :=
@@ -260,197 +290,197 @@ We also support a bunch of handy functions to manipulate different values:
 
 {{ etherscanAddressLink .Contract.Address }}
 `
-	_, err = RenderTemplateWithData(exampleUI, matches[0].ToTemplateMatch())
+	_, err = RenderTemplateWithData(exampleUI, matches[0].ToTemplateMatch(), templatingApi)
 	assert.NoError(t, err)
 }
 
 func TestTemplateFunctions(t *testing.T) {
 
 	template := "{{ hexToASCII . }}"
-	rendered, err := RenderTemplateWithData(template, "0x4920686176652031303021")
+	rendered, err := RenderTemplateWithData(template, "0x4920686176652031303021", templatingApi)
 	assert.NoError(t, err)
 	assert.Equal(t, "I have 100!", rendered)
 
 	template = "{{ hexToASCII . }}"
-	rendered, err = RenderTemplateWithData(template, "0x534e580000000000000000000000000000000000000000000000000000000000")
+	rendered, err = RenderTemplateWithData(template, "0x534e580000000000000000000000000000000000000000000000000000000000", templatingApi)
 	assert.NoError(t, err)
 	assert.Equal(t, "SNX", rendered)
 
 	template = "{{ hexToInt . }}"
-	rendered, err = RenderTemplateWithData(template, "0xEA")
+	rendered, err = RenderTemplateWithData(template, "0xEA", templatingApi)
 	assert.NoError(t, err)
 	assert.Equal(t, "234", rendered)
 
 	template = "{{ hexToInt . }}"
-	rendered, err = RenderTemplateWithData(template, "100")
+	rendered, err = RenderTemplateWithData(template, "100", templatingApi)
 	assert.NoError(t, err)
 	assert.Equal(t, "100", rendered)
 
 	template = "{{ etherscanTxLink . }}"
-	rendered, err = RenderTemplateWithData(template, "0xfdb96f7387559ebfc41e88e21962414eb527484f578ce87996f8733352ab2ee7")
+	rendered, err = RenderTemplateWithData(template, "0xfdb96f7387559ebfc41e88e21962414eb527484f578ce87996f8733352ab2ee7", templatingApi)
 	assert.NoError(t, err)
 	assert.Equal(t, "https://etherscan.io/tx/0xfdb96f7387559ebfc41e88e21962414eb527484f578ce87996f8733352ab2ee7", rendered)
 
 	template = "{{ etherscanAddressLink . }}"
-	rendered, err = RenderTemplateWithData(template, "0xfdb96f7387559ebfc41e88e21962414eb527484f578ce87996f8733352ab2ee7")
+	rendered, err = RenderTemplateWithData(template, "0xfdb96f7387559ebfc41e88e21962414eb527484f578ce87996f8733352ab2ee7", templatingApi)
 	assert.NoError(t, err)
 	assert.Equal(t, "https://etherscan.io/address/0xfdb96f7387559ebfc41e88e21962414eb527484f578ce87996f8733352ab2ee7", rendered)
 
 	template = "{{ etherscanTokenLink . }}"
-	rendered, err = RenderTemplateWithData(template, "0xfdb96f7387559ebfc41e88e21962414eb527484f578ce87996f8733352ab2ee7")
+	rendered, err = RenderTemplateWithData(template, "0xfdb96f7387559ebfc41e88e21962414eb527484f578ce87996f8733352ab2ee7", templatingApi)
 	assert.NoError(t, err)
 	assert.Equal(t, "https://etherscan.io/token/0xfdb96f7387559ebfc41e88e21962414eb527484f578ce87996f8733352ab2ee7", rendered)
 
 	template = "{{ bscscanTokenLink . }}"
-	rendered, err = RenderTemplateWithData(template, "0xfdb96f7387559ebfc41e88e21962414eb527484f578ce87996f8733352ab2ee7")
+	rendered, err = RenderTemplateWithData(template, "0xfdb96f7387559ebfc41e88e21962414eb527484f578ce87996f8733352ab2ee7", templatingApi)
 	assert.NoError(t, err)
 	assert.Equal(t, "https://bscscan.com/token/0xfdb96f7387559ebfc41e88e21962414eb527484f578ce87996f8733352ab2ee7", rendered)
 
 	template = "{{ bscscanTxLink . }}"
-	rendered, err = RenderTemplateWithData(template, "0xfdb96f7387559ebfc41e88e21962414eb527484f578ce87996f8733352ab2ee7")
+	rendered, err = RenderTemplateWithData(template, "0xfdb96f7387559ebfc41e88e21962414eb527484f578ce87996f8733352ab2ee7", templatingApi)
 	assert.NoError(t, err)
 	assert.Equal(t, "https://bscscan.com/tx/0xfdb96f7387559ebfc41e88e21962414eb527484f578ce87996f8733352ab2ee7", rendered)
 
 	template = "{{ bscscanAddressLink . }}"
-	rendered, err = RenderTemplateWithData(template, "0xfdb96f7387559ebfc41e88e21962414eb527484f578ce87996f8733352ab2ee7")
+	rendered, err = RenderTemplateWithData(template, "0xfdb96f7387559ebfc41e88e21962414eb527484f578ce87996f8733352ab2ee7", templatingApi)
 	assert.NoError(t, err)
 	assert.Equal(t, "https://bscscan.com/address/0xfdb96f7387559ebfc41e88e21962414eb527484f578ce87996f8733352ab2ee7", rendered)
 
 	template = "{{ polygonscanTokenLink . }}"
-	rendered, err = RenderTemplateWithData(template, "0xfdb96f7387559ebfc41e88e21962414eb527484f578ce87996f8733352ab2ee7")
+	rendered, err = RenderTemplateWithData(template, "0xfdb96f7387559ebfc41e88e21962414eb527484f578ce87996f8733352ab2ee7", templatingApi)
 	assert.NoError(t, err)
 	assert.Equal(t, "https://polygonscan.com/token/0xfdb96f7387559ebfc41e88e21962414eb527484f578ce87996f8733352ab2ee7", rendered)
 
 	template = "{{ polygonscanTxLink . }}"
-	rendered, err = RenderTemplateWithData(template, "0xfdb96f7387559ebfc41e88e21962414eb527484f578ce87996f8733352ab2ee7")
+	rendered, err = RenderTemplateWithData(template, "0xfdb96f7387559ebfc41e88e21962414eb527484f578ce87996f8733352ab2ee7", templatingApi)
 	assert.NoError(t, err)
 	assert.Equal(t, "https://polygonscan.com/tx/0xfdb96f7387559ebfc41e88e21962414eb527484f578ce87996f8733352ab2ee7", rendered)
 
 	template = "{{ polygonscanAddressLink . }}"
-	rendered, err = RenderTemplateWithData(template, "0xfdb96f7387559ebfc41e88e21962414eb527484f578ce87996f8733352ab2ee7")
+	rendered, err = RenderTemplateWithData(template, "0xfdb96f7387559ebfc41e88e21962414eb527484f578ce87996f8733352ab2ee7", templatingApi)
 	assert.NoError(t, err)
 	assert.Equal(t, "https://polygonscan.com/address/0xfdb96f7387559ebfc41e88e21962414eb527484f578ce87996f8733352ab2ee7", rendered)
 
 	template = "{{ fromWei . 18 }}"
-	rendered, err = RenderTemplateWithData(template, "629700000000000000")
+	rendered, err = RenderTemplateWithData(template, "629700000000000000", templatingApi)
 	assert.NoError(t, err)
 	assert.Equal(t, "0.6297", rendered)
 
 	template = "{{ fromWei . 6 }}"
-	rendered, err = RenderTemplateWithData(template, "629000000000000000")
+	rendered, err = RenderTemplateWithData(template, "629000000000000000", templatingApi)
 	assert.NoError(t, err)
 	assert.Equal(t, "629000000000", rendered)
 
 	template = "{{ fromWei . 6 }}"
-	rendered, err = RenderTemplateWithData(template, big.NewInt(629000000000000000))
+	rendered, err = RenderTemplateWithData(template, big.NewInt(629000000000000000), templatingApi)
 	assert.NoError(t, err)
 	assert.Equal(t, "629000000000", rendered)
 
 	template = "{{ fromWei . 6 }}"
-	rendered, err = RenderTemplateWithData(template, 629000000000000000)
+	rendered, err = RenderTemplateWithData(template, 629000000000000000, templatingApi)
 	assert.NoError(t, err)
 	assert.Equal(t, "629000000000", rendered)
 
 	template = `{{ fromWei . "6" }}`
-	rendered, err = RenderTemplateWithData(template, 629000000000000000)
+	rendered, err = RenderTemplateWithData(template, 629000000000000000, templatingApi)
 	assert.NoError(t, err)
 	assert.Equal(t, "629000000000", rendered)
 
 	template = "{{ humanTime . }}"
-	rendered, err = RenderTemplateWithData(template, "1602631929")
+	rendered, err = RenderTemplateWithData(template, "1602631929", templatingApi)
 	assert.NoError(t, err)
 	assert.Equal(t, "13 Oct 20 23:32 UTC", rendered)
 
 	template = "{{ humanTime . }}"
-	rendered, err = RenderTemplateWithData(template, 1602631929)
+	rendered, err = RenderTemplateWithData(template, 1602631929, templatingApi)
 	assert.NoError(t, err)
 	assert.Equal(t, "13 Oct 20 23:32 UTC", rendered)
 
 	template = `{{ humanTime . "3:04:05 PM" }}`
-	rendered, err = RenderTemplateWithData(template, 1602631929)
+	rendered, err = RenderTemplateWithData(template, 1602631929, templatingApi)
 	assert.NoError(t, err)
 	assert.Equal(t, "11:32:09 PM", rendered)
 
 	template = `{{ formatNumber "10000" 2 }}`
-	rendered, err = RenderTemplateWithData(template, nil)
+	rendered, err = RenderTemplateWithData(template, nil, templatingApi)
 	assert.NoError(t, err)
 	assert.Equal(t, "10,000.00", rendered)
 
 	// stringified floating point numbers are converted in a strange way so that this happens:
 	template = `{{ if ge "100" "100.0" }} GE {{ else }} Not-GE {{ end }}`
-	rendered, err = RenderTemplateWithData(template, nil)
+	rendered, err = RenderTemplateWithData(template, nil, templatingApi)
 	assert.NoError(t, err)
 	assert.Equal(t, " Not-GE ", rendered)
 	// we use floatToInt to truncate floats and compare correctly
 	template = `{{ if ge 100 (floatToInt "100.0") }} GE {{ else }} Not-GE {{ end }}`
-	rendered, err = RenderTemplateWithData(template, nil)
+	rendered, err = RenderTemplateWithData(template, nil, templatingApi)
 	assert.NoError(t, err)
 	assert.Equal(t, " GE ", rendered)
 }
 
 func TestMathFunctions(t *testing.T) {
 	template := `{{ add 10 "20" 30 }}`
-	rendered, err := RenderTemplateWithData(template, nil)
+	rendered, err := RenderTemplateWithData(template, nil, templatingApi)
 	assert.NoError(t, err)
 	assert.Equal(t, "60", rendered)
 
 	template = `{{ sub 100 "20" }}`
-	rendered, err = RenderTemplateWithData(template, nil)
+	rendered, err = RenderTemplateWithData(template, nil, templatingApi)
 	assert.NoError(t, err)
 	assert.Equal(t, "80", rendered)
 
 	template = `{{ mul 10.55 "4" }}`
-	rendered, err = RenderTemplateWithData(template, nil)
+	rendered, err = RenderTemplateWithData(template, nil, templatingApi)
 	assert.NoError(t, err)
 	assert.Equal(t, "42.2", rendered)
 
 	template = `{{ div 2 "3" }}`
-	rendered, err = RenderTemplateWithData(template, nil)
+	rendered, err = RenderTemplateWithData(template, nil, templatingApi)
 	assert.NoError(t, err)
 	assert.Equal(t, "0.6666666666666666667", rendered)
 
 	template = `{{ round (div 2 "3") 2 }}`
-	rendered, err = RenderTemplateWithData(template, nil)
+	rendered, err = RenderTemplateWithData(template, nil, templatingApi)
 	assert.NoError(t, err)
 	assert.Equal(t, "0.67", rendered)
 
 	template = `{{ round (mul (div 2 3) 100) 3 }}`
-	rendered, err = RenderTemplateWithData(template, nil)
+	rendered, err = RenderTemplateWithData(template, nil, templatingApi)
 	assert.NoError(t, err)
 	assert.Equal(t, "66.667", rendered)
 
 	template = `{{ pow 2 8 }}`
-	rendered, err = RenderTemplateWithData(template, nil)
+	rendered, err = RenderTemplateWithData(template, nil, templatingApi)
 	assert.NoError(t, err)
 	assert.Equal(t, "256", rendered)
 
 	template = `{{ pow "2" "8" }}`
-	rendered, err = RenderTemplateWithData(template, nil)
+	rendered, err = RenderTemplateWithData(template, nil, templatingApi)
 	assert.NoError(t, err)
 	assert.Equal(t, "256", rendered)
 
 	template = `{{ pow 1.0000560291 355 }}`
-	rendered, err = RenderTemplateWithData(template, nil)
+	rendered, err = RenderTemplateWithData(template, nil, templatingApi)
 	assert.NoError(t, err)
 	assert.Equal(t, "1.02008889279936419637271196253370647336257590813508215370058066207976589819699", rendered)
 
 	template = `{{ round (mul (sub (pow (add (mul (div 9727274683 1000000000000000000) 5760) 1) 364) 1) 100) 2 }}`
-	rendered, err = RenderTemplateWithData(template, nil)
+	rendered, err = RenderTemplateWithData(template, nil, templatingApi)
 	assert.NoError(t, err)
 	assert.Equal(t, "2.06", rendered)
 
 	template = `{{ percentageVariation 200 100 }}`
-	rendered, err = RenderTemplateWithData(template, nil)
+	rendered, err = RenderTemplateWithData(template, nil, templatingApi)
 	assert.NoError(t, err)
 	assert.Equal(t, "100.00%", rendered)
 
 	template = `{{ percentageVariation 100 150 }}`
-	rendered, err = RenderTemplateWithData(template, nil)
+	rendered, err = RenderTemplateWithData(template, nil, templatingApi)
 	assert.NoError(t, err)
 	assert.Equal(t, "-33.33%", rendered)
 
 	template = `{{ percentageVariation 100 0 }}`
-	rendered, err = RenderTemplateWithData(template, nil)
+	rendered, err = RenderTemplateWithData(template, nil, templatingApi)
 	assert.NoError(t, err)
 	assert.Equal(t, "0%", rendered)
 }
@@ -458,44 +488,44 @@ func TestMathFunctions(t *testing.T) {
 func TestERC20Functions(t *testing.T) {
 
 	template := "{{ symbol . }}"
-	rendered, err := RenderTemplateWithData(template, "0x6b175474e89094c44da98b954eedeac495271d0f")
+	rendered, err := RenderTemplateWithData(template, "0x6b175474e89094c44da98b954eedeac495271d0f", stubTokenApi)
 	assert.NoError(t, err)
 	assert.Equal(t, "DAI", rendered)
 
 	template = "{{ symbol . }}"
-	rendered, err = RenderTemplateWithData(template, "0x0000000000000000000000000000000000000000")
+	rendered, err = RenderTemplateWithData(template, "0x0000000000000000000000000000000000000000", stubTokenApi)
 	assert.NoError(t, err)
 	assert.Equal(t, "ETH", rendered)
 
 	template = "{{ symbol . }}"
-	rendered, err = RenderTemplateWithData(template, "0xeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeee")
+	rendered, err = RenderTemplateWithData(template, "0xeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeee", stubTokenApi)
 	assert.NoError(t, err)
 	assert.Equal(t, "ETH", rendered)
 
 	template = "{{ decimals . }}"
-	rendered, err = RenderTemplateWithData(template, "0x6b175474e89094c44da98b954eedeac495271d0f")
+	rendered, err = RenderTemplateWithData(template, "0x6b175474e89094c44da98b954eedeac495271d0f", stubTokenApi)
 	assert.NoError(t, err)
 	assert.Equal(t, "18", rendered)
 
 	template = "{{ decimals . }}"
-	rendered, err = RenderTemplateWithData(template, "0x0000000000000000000000000000000000000000")
+	rendered, err = RenderTemplateWithData(template, "0x0000000000000000000000000000000000000000", stubTokenApi)
 	assert.NoError(t, err)
 	assert.Equal(t, "18", rendered)
 
 	template = "{{ decimals . }}"
 	assert.NoError(t, err)
-	rendered, err = RenderTemplateWithData(template, "0xeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeee")
+	rendered, err = RenderTemplateWithData(template, "0xeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeee", stubTokenApi)
 	assert.Equal(t, "18", rendered)
 
 	template = `{{ balanceOf "0x9f8f72aa9304c8b593d555f12ef6589cc3a579a2" "0x6b175474e89094c44da98b954eedeac495271d0f" }}`
-	rendered, err = RenderTemplateWithData(template, nil)
+	rendered, err = RenderTemplateWithData(template, nil, stubTokenApi)
 	assert.NoError(t, err)
 	assert.Equal(t, "100000000000000", rendered)
 }
 
 func TestConversionRates(t *testing.T) {
 	template := `this should not {{ toFiat "0x" "usd"}} completely break the parsing`
-	rendered, err := RenderTemplateWithData(template, nil)
+	rendered, err := RenderTemplateWithData(template, nil, templatingApi)
 	assert.NoError(t, err) // we've hidden the error
 	assert.Equal(t, "this should not 0 completely break the parsing", rendered)
 }
@@ -505,29 +535,43 @@ func TestERC20Snapshot(t *testing.T) {
 	template := `{{ ERC20Snapshot . }}`
 	data := []interface{}{[]string{"100", "0", "99"}}
 
-	rendered, err := RenderTemplateWithData(template, data)
+	// balances are in the order of the token list's addresses
+	rendered, err := RenderTemplateWithData(template, data, stubTokenApi)
 	assert.NoError(t, err)
-	assert.Equal(t, "map[0x0000000000000000000000000000000000000000:100 0x0000000000004946c0e9f43f4dee607b0ef1fa1c:99]", rendered)
+	assert.Equal(t, "map[0x0000000000000000000000000000000000000000:100 0x6b175474e89094c44da98b954eedeac495271d0f:99]", rendered)
 
 	template = `{{ index (ERC20Snapshot .) "0x0000000000000000000000000000000000000000" }}`
-	rendered, err = RenderTemplateWithData(template, data)
+	rendered, err = RenderTemplateWithData(template, data, stubTokenApi)
 	assert.NoError(t, err)
 	assert.Equal(t, "100", rendered)
+
+	// balances that don't match the token list are ignored
+	rendered, err = RenderTemplateWithData(`{{ ERC20Snapshot . }}`, []interface{}{[]string{"100", "0", "99", "1"}}, stubTokenApi)
+	assert.NoError(t, err)
+	assert.Equal(t, "map[]", rendered)
 }
 
 func TestEthCall(t *testing.T) {
-	blockNo, err := tokenapi.GetTokenAPI().GetRPCCli().EthBlockNumber()
-	assert.NoError(t, err)
+	defer gock.Off()
+
+	// the ABI comes from Etherscan
+	gock.New("https://api.etherscan.io").
+		Get("/api").
+		MatchParam("address", "0x1f9840a85d5af5bf1d1762f925bdaddc4201f984").
+		Times(2).
+		Reply(200).
+		JSON(map[string]string{"status": "1", "message": "OK", "result": uniABI})
 
 	template := `{{ ethCall "0x1f9840a85d5af5bf1d1762f925bdaddc4201f984" . 0 "balanceOf" "0x1f9840a85d5af5bf1d1762f925bdaddc4201f984" }}`
-	rendered, err := RenderTemplateWithData(template, blockNo)
+	rendered, err := RenderTemplateWithData(template, 13000000, stubTokenApi)
 	assert.NoError(t, err)
-	assert.NotEqual(t, "0", rendered)
+	assert.Equal(t, "100000000000000", rendered)
 
 	template = `{{ ethCall "0x1f9840a85d5af5bf1d1762f925bdaddc4201f984" . 0 "name" }}`
-	rendered, err = RenderTemplateWithData(template, blockNo)
+	rendered, err = RenderTemplateWithData(template, 13000000, stubTokenApi)
 	assert.NoError(t, err)
 	assert.Equal(t, "Uniswap", rendered)
+	assert.True(t, gock.IsDone())
 }
 
 func setupGock(filename, url, path string) error {
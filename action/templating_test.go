@@ -1,7 +1,7 @@
 package action
 
 import (
-	"github.com/HAL-xyz/ethrpc"
+	"github.com/HAL-xyz/zoroaster/tokenapi"
 	"github.com/HAL-xyz/zoroaster/trigger"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/stretchr/testify/assert"
@@ -21,7 +21,7 @@ func TestTemplateWithAllConversions(t *testing.T) {
 	assert.NoError(t, err)
 	logs, err := trigger.GetLogsFromFile("../resources/events/logs1.json")
 	assert.NoError(t, err)
-	matches := trigger.MatchEvent(tg1, logs, []ethrpc.Transaction{}, mockTokenApi)
+	matches := trigger.MatchEvent(tg1, logs, []tokenapi.Transaction{}, mockTokenApi)
 
 	matches[0].EventParams["someBigNumber"] = "629000000000000000"
 	matches[0].EventParams["unixTimestamp"] = "1602631929"
@@ -29,7 +29,7 @@ func TestTemplateWithAllConversions(t *testing.T) {
 
 	template := `the first is: decAmount(!someBigNumber); The second is: humanTime(!unixTimestamp); the third is: octAmount(!someOtherNumber); then hexAmount(!someOtherNumber)`
 
-	body := fillBodyTemplate(template, matches[0], "")
+	body := fillBodyTemplate(template, matches[0], templatingApi, "")
 
 	assert.Equal(t, "the first is: 0.629; The second is: 13 Oct 20 23:32 UTC; the third is: 16.0264; then 1602.632", body)
 }
@@ -40,14 +40,14 @@ func TestTemplateWithDecConversion(t *testing.T) {
 	assert.NoError(t, err)
 	logs, err := trigger.GetLogsFromFile("../resources/events/logs1.json")
 	assert.NoError(t, err)
-	matches := trigger.MatchEvent(tg1, logs, []ethrpc.Transaction{}, mockTokenApi)
+	matches := trigger.MatchEvent(tg1, logs, []tokenapi.Transaction{}, mockTokenApi)
 
 	matches[0].EventParams["someBigNumber"] = "629000000000000000"
 	matches[0].EventParams["smallerNumber"] = "21000000000000"
 
 	template := `the first is: decAmount(!someBigNumber); The second is: decAmount(!smallerNumber)`
 
-	body := fillBodyTemplate(template, matches[0], "")
+	body := fillBodyTemplate(template, matches[0], templatingApi, "")
 
 	assert.Equal(t, "the first is: 0.629; The second is: 0.0001", body)
 }
@@ -90,10 +90,10 @@ func TestFillTemplate1(t *testing.T) {
 	tx, err := trigger.JsonToTransaction([]byte(input))
 	assert.NoError(t, err)
 
-	tg, err := trigger.NewTriggerFromJson(trig)
+	tg, err := trigger.NewTriggerFromJson(trig, nil)
 	assert.NoError(t, err)
 
-	b := ethrpc.Block{}
+	b := tokenapi.Block{}
 	b.Timestamp = 1554828248
 	b.Transactions = append(b.Transactions, *tx)
 
@@ -102,7 +102,7 @@ func TestFillTemplate1(t *testing.T) {
 	template, err := ioutil.ReadFile("../resources/emails/1-wat-templ.txt")
 	assert.NoError(t, err)
 
-	body := fillBodyTemplate(string(template), matches[0], "")
+	body := fillBodyTemplate(string(template), matches[0], templatingApi, "")
 	expected, err := ioutil.ReadFile("../resources/emails/1-wat-exp.txt")
 
 	assert.NoError(t, err)
@@ -131,23 +131,23 @@ func TestFillTemplate2(t *testing.T) {
 	cnMatch.AllValues = []interface{}{"4", "8", "12"}
 
 	template := "$ReturnedValues$"
-	body := fillBodyTemplate(template, &cnMatch, "")
+	body := fillBodyTemplate(template, &cnMatch, templatingApi, "")
 	assert.Equal(t, "[4 8 12]", body)
 
 	template = "$ReturnedValues[0]$"
-	body = fillBodyTemplate(template, &cnMatch, "")
+	body = fillBodyTemplate(template, &cnMatch, templatingApi, "")
 	assert.Equal(t, "4", body)
 
 	template = "$ReturnedValues[2]$"
-	body = fillBodyTemplate(template, &cnMatch, "")
+	body = fillBodyTemplate(template, &cnMatch, templatingApi, "")
 	assert.Equal(t, "12", body)
 
 	template = "found: $ReturnedValues[1]$; not found: $ReturnedValues[33]$"
-	body = fillBodyTemplate(template, &cnMatch, "")
+	body = fillBodyTemplate(template, &cnMatch, templatingApi, "")
 	assert.Equal(t, "found: 8; not found: $ReturnedValues[33]$", body)
 
 	template = "$MatchedValue$"
-	body = fillBodyTemplate(template, &cnMatch, "")
+	body = fillBodyTemplate(template, &cnMatch, templatingApi, "")
 	assert.Equal(t, "4", body)
 }
 
@@ -156,19 +156,19 @@ func TestFillTemplate3(t *testing.T) {
 	cnMatch.AllValues = []interface{}{"4", "sailor", "moon"}
 
 	template := "$ReturnedValues$"
-	body := fillBodyTemplate(template, &cnMatch, "")
+	body := fillBodyTemplate(template, &cnMatch, templatingApi, "")
 	assert.Equal(t, "[4 sailor moon]", body)
 
 	template = "$ReturnedValues[0]$"
-	body = fillBodyTemplate(template, &cnMatch, "")
+	body = fillBodyTemplate(template, &cnMatch, templatingApi, "")
 	assert.Equal(t, "4", body)
 
 	template = "$ReturnedValues[2]$"
-	body = fillBodyTemplate(template, &cnMatch, "")
+	body = fillBodyTemplate(template, &cnMatch, templatingApi, "")
 	assert.Equal(t, "moon", body)
 
 	template = "$ReturnedValues[0]$, $ReturnedValues[1]$, $ReturnedValues[2]$"
-	body = fillBodyTemplate(template, &cnMatch, "")
+	body = fillBodyTemplate(template, &cnMatch, templatingApi, "")
 	assert.Equal(t, "4, sailor, moon", body)
 }
 
@@ -177,7 +177,7 @@ func TestFillTemplate4(t *testing.T) {
 	cnMatch.AllValues = []interface{}{"0x4a574510c7014e4ae985403536074abe582adfc8", "0xffffffffffffffffffffffffffffffffffffffff"}
 
 	template := "$ReturnedValues[0]$"
-	body := fillBodyTemplate(template, &cnMatch, "")
+	body := fillBodyTemplate(template, &cnMatch, templatingApi, "")
 	assert.Equal(t, "0x4a574510c7014e4ae985403536074abe582adfc8", body)
 }
 
@@ -190,15 +190,15 @@ func TestFillTemplateAdd(t *testing.T) {
 		}}
 
 	template := "$ReturnedValues[0]$"
-	body := fillBodyTemplate(template, &cnMatch, "")
+	body := fillBodyTemplate(template, &cnMatch, templatingApi, "")
 	assert.Equal(t, "0x4a574510c7014e4ae985403536074abe582adfc8", body)
 
 	template = "$ReturnedValues[1]$"
-	body = fillBodyTemplate(template, &cnMatch, "")
+	body = fillBodyTemplate(template, &cnMatch, templatingApi, "")
 	assert.Equal(t, "0xffffffffffffffffffffffffffffffffffffffff", body)
 
 	template = "$ReturnedValues[2]$"
-	body = fillBodyTemplate(template, &cnMatch, "")
+	body = fillBodyTemplate(template, &cnMatch, templatingApi, "")
 	assert.Equal(t, "$ReturnedValues[2]$", body)
 }
 
@@ -215,19 +215,19 @@ func TestFillTemplate6(t *testing.T) {
 		}}
 
 	template := "$ReturnedValues[3]$"
-	body := fillBodyTemplate(template, &cnMatch, "")
+	body := fillBodyTemplate(template, &cnMatch, templatingApi, "")
 	assert.Equal(t, "[0x4a574510c7014e4ae985403536074abe582adfc8 0xffffffffffffffffffffffffffffffffffffffff]", body)
 
 	template = "$ReturnedValues[3][0]$"
-	body = fillBodyTemplate(template, &cnMatch, "")
+	body = fillBodyTemplate(template, &cnMatch, templatingApi, "")
 	assert.Equal(t, "0x4a574510c7014e4ae985403536074abe582adfc8", body)
 
 	template = "$ReturnedValues[3][1]$"
-	body = fillBodyTemplate(template, &cnMatch, "")
+	body = fillBodyTemplate(template, &cnMatch, templatingApi, "")
 	assert.Equal(t, "0xffffffffffffffffffffffffffffffffffffffff", body)
 
 	template = "$ReturnedValues[3][2]$"
-	body = fillBodyTemplate(template, &cnMatch, "")
+	body = fillBodyTemplate(template, &cnMatch, templatingApi, "")
 	assert.Equal(t, "$ReturnedValues[3][2]$", body)
 }
 
@@ -241,39 +241,39 @@ func TestFillTemplate5(t *testing.T) {
 		[]string{"one", "two", "three"}}
 
 	template := "$ReturnedValues$"
-	body := fillBodyTemplate(template, &cnMatch, "")
+	body := fillBodyTemplate(template, &cnMatch, templatingApi, "")
 	assert.Equal(t, "[4 sailor moon [one two three]]", body)
 
 	template = "$ReturnedValues[3][0]$"
-	body = fillBodyTemplate(template, &cnMatch, "")
+	body = fillBodyTemplate(template, &cnMatch, templatingApi, "")
 	assert.Equal(t, "one", body)
 
 	template = "$ReturnedValues[3][1]$"
-	body = fillBodyTemplate(template, &cnMatch, "")
+	body = fillBodyTemplate(template, &cnMatch, templatingApi, "")
 	assert.Equal(t, "two", body)
 
 	template = "$ReturnedValues[3][9]$"
-	body = fillBodyTemplate(template, &cnMatch, "")
+	body = fillBodyTemplate(template, &cnMatch, templatingApi, "")
 	assert.Equal(t, "$ReturnedValues[3][9]$", body)
 
 	template = "$ReturnedValues[3]$"
-	body = fillBodyTemplate(template, &cnMatch, "")
+	body = fillBodyTemplate(template, &cnMatch, templatingApi, "")
 	assert.Equal(t, "[one two three]", body)
 
 	template = "$ReturnedValues[1]$"
-	body = fillBodyTemplate(template, &cnMatch, "")
+	body = fillBodyTemplate(template, &cnMatch, templatingApi, "")
 	assert.Equal(t, "sailor", body)
 
 	template = "$ReturnedValues[10]$"
-	body = fillBodyTemplate(template, &cnMatch, "")
+	body = fillBodyTemplate(template, &cnMatch, templatingApi, "")
 	assert.Equal(t, "$ReturnedValues[10]$", body)
 
 	template = "sailor: $ReturnedValues[1]$ and moon: $ReturnedValues[2]$"
-	body = fillBodyTemplate(template, &cnMatch, "")
+	body = fillBodyTemplate(template, &cnMatch, templatingApi, "")
 	assert.Equal(t, "sailor: sailor and moon: moon", body)
 
 	template = "sailor: $ReturnedValues[1]$ and one: $ReturnedValues[3][0]$"
-	body = fillBodyTemplate(template, &cnMatch, "")
+	body = fillBodyTemplate(template, &cnMatch, templatingApi, "")
 	assert.Equal(t, "sailor: sailor and one: one", body)
 }
 
@@ -288,7 +288,7 @@ func TestFillTemplate7(t *testing.T) {
 	template, err := ioutil.ReadFile("../resources/emails/2-wac-templ.txt")
 	assert.NoError(t, err)
 
-	body := fillBodyTemplate(string(template), &cnMatch, "")
+	body := fillBodyTemplate(string(template), &cnMatch, templatingApi, "")
 
 	expected, err := ioutil.ReadFile("../resources/emails/2-wac-exp.txt")
 	assert.NoError(t, err)
@@ -302,7 +302,7 @@ func TestEmailTemplateEvent(t *testing.T) {
 	assert.NoError(t, err)
 	logs, err := trigger.GetLogsFromFile("../resources/events/logs1.json")
 	assert.NoError(t, err)
-	matches := trigger.MatchEvent(tg1, logs, []ethrpc.Transaction{}, mockTokenApi)
+	matches := trigger.MatchEvent(tg1, logs, []tokenapi.Transaction{}, mockTokenApi)
 
 	matches[0].EventParams["arrayParam"] = []string{"hello", "world", "yo yo"}
 
@@ -313,7 +313,7 @@ func TestEmailTemplateEvent(t *testing.T) {
 	assert.NoError(t, err)
 
 	matches[0].BlockTimestamp = 1572344236
-	body := fillBodyTemplate(string(template), matches[0], "")
+	body := fillBodyTemplate(string(template), matches[0], templatingApi, "")
 
 	expected, err := ioutil.ReadFile("../resources/emails/3-wae-exp.txt")
 	assert.NoError(t, err)
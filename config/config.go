@@ -1,13 +1,14 @@
 package config
 
 import (
+	"fmt"
 	log "github.com/sirupsen/logrus"
 	"os"
 	"strconv"
+	"strings"
+	"time"
 )
 
-var Zconf = NewConfig() // Global conf
-
 type ZConfiguration struct {
 	Stage                 Stage
 	LogLevel              log.Level
@@ -18,24 +19,32 @@ type ZConfiguration struct {
 	BlocksDelay           int
 	PollingInterval       int
 	BlocksInterval        int
+	MempoolInterval       int // seconds; 0 means we don't watch the mempool
+	MempoolDropAfter      int // seconds a pending tx can be missing from the mempool before it's dropped, see PendingTxTimeout
 	TwitterConsumerKey    string
 	TwitterConsumerSecret string
 	EtherscanKey          string
 	Network               string
+	PriceSources          []string // in order of priority; empty means the network's default
+	TokenList             string   // URL or path of the ERC20 token list
+	TokenCache            string   // file where on-chain token metadata is kept; empty means memory only
 }
 
 type ZoroDB struct {
-	TableTriggers string
-	TableMatches  string
-	TableOutcomes string
-	TableState    string
-	TableActions  string
-	TableUsers    string
-	Host          string
-	User          string
-	Name          string
-	Port          int
-	Password      string
+	TableTriggers    string
+	TableMatches     string
+	TableOutcomes    string
+	TableState       string
+	TableActions     string
+	TableUsers       string
+	TableQuotaLimits string
+	TableQuotaUsage  string
+	Host             string
+	User             string
+	Name             string
+	Port             int
+	Password         string
+	Migrate          bool // apply pending schema migrations on startup
 }
 
 type Stage int
@@ -50,41 +59,83 @@ func (s Stage) String() string {
 	return [...]string{"TEST", "STAGING", "PROD"}[s]
 }
 
-// ENV variables
-const (
-	blocksDelay           = "BLOCKS_DELAY"
-	dbHost                = "DB_HOST"
-	dbName                = "DB_NAME"
-	dbUsr                 = "DB_USR"
-	dbPwd                 = "DB_PWD"
-	ethNode               = "ETH_NODE"
-	backupNode            = "BACKUP_NODE"
-	rinkebyNode           = "RINKEBY_NODE"
-	twitterConsumerKey    = "TWITTER_CONSUMER_KEY"
-	twitterConsumerSecret = "TWITTER_CONSUMER_SECRET"
-	network               = "NETWORK"
-	pollingInterval       = "POLLING_INTERVAL"
-	blocksInterval        = "BLOCKS_INTERVAL"
-	etherscanKey          = "ETHERSCAN_KEY"
-)
-
 // DB tables
 const (
-	dbPort        = 5432
-	tableTriggers = "triggers"
-	tableMatches  = "matches"
-	tableOutcomes = "outcomes"
-	tableState    = "state"
-	tableActions  = "actions"
-	tableUsers    = "users"
+	tableTriggers    = "triggers"
+	tableMatches     = "matches"
+	tableOutcomes    = "outcomes"
+	tableState       = "state"
+	tableActions     = "actions"
+	tableUsers       = "users"
+	tableQuotaLimits = "quota_limits"
+	tableQuotaUsage  = "quota_usage"
 )
 
+// NewConfig loads the configuration from $CONFIG_FILE and the env, and exits if it isn't valid
 func NewConfig() *ZConfiguration {
+	zconfig, err := Load(FilePath())
+	if err != nil {
+		log.Fatal(err)
+	}
+	return zconfig
+}
 
-	zconfig := ZConfiguration{}
+// FilePath is the config file to use, if any
+func FilePath() string {
+	return os.Getenv(configFile)
+}
+
+// Load reads the settings (see LoadSettings) and validates them
+func Load(path string) (*ZConfiguration, error) {
+	s, err := LoadSettings(path)
+	if err != nil {
+		return &ZConfiguration{LogLevel: log.InfoLevel}, err
+	}
+	return s.Parse()
+}
+
+// LoadDatabase is like Load, but only the stage and the database settings are read and checked
+func LoadDatabase(path string) (*ZConfiguration, error) {
+	s, err := LoadSettings(path)
+	if err != nil {
+		return &ZConfiguration{LogLevel: log.InfoLevel}, err
+	}
+	return s.ParseDatabase()
+}
+
+// Errors are all the problems found in the configuration
+type Errors []string
+
+func (e Errors) Error() string {
+	return "invalid configuration: " + strings.Join(e, "; ")
+}
+
+// collects all the errors found while parsing the settings
+type parser struct {
+	*Settings
+	errs Errors
+}
+
+func (p *parser) required(name string) string {
+	v := p.get(name)
+	if v == "" {
+		p.errs = append(p.errs, fmt.Sprintf("%s is required", name))
+	}
+	return v
+}
+
+// non negative ints
+func (p *parser) number(name string) int {
+	n, err := strconv.Atoi(p.get(name))
+	if err != nil || n < 0 {
+		p.errs = append(p.errs, fmt.Sprintf("%s must be a number >= 0, not %q", name, p.get(name)))
+	}
+	return n
+}
 
-	stage := os.Getenv("STAGE")
-	switch stage {
+func (p *parser) stage(zconfig *ZConfiguration) {
+	switch p.required(stage) {
+	case "":
 	case "TEST":
 		zconfig.Stage = TEST
 		zconfig.LogLevel = log.DebugLevel
@@ -95,99 +146,134 @@ func NewConfig() *ZConfiguration {
 		zconfig.Stage = PROD
 		zconfig.LogLevel = log.InfoLevel
 	default:
-		log.Fatal("local env STAGING must be TEST, STAGING or PROD")
+		p.errs = append(p.errs, fmt.Sprintf("%s must be TEST, STAGING or PROD, not %q", stage, p.get(stage)))
 	}
+}
 
+// the stage must be parsed first
+func (p *parser) database(zconfig *ZConfiguration) {
 	zconfig.Database.TableTriggers = tableTriggers
 	zconfig.Database.TableMatches = tableMatches
 	zconfig.Database.TableOutcomes = tableOutcomes
 	zconfig.Database.TableState = tableState
 	zconfig.Database.TableActions = tableActions
 	zconfig.Database.TableUsers = tableUsers
-	zconfig.Database.Port = dbPort
-
-	delay := os.Getenv(blocksDelay)
-	intDelay, err := strconv.Atoi(delay)
-	if delay == "" || err != nil {
-		log.Fatalf("cannot use %s as block delay", delay)
+	zconfig.Database.TableQuotaLimits = tableQuotaLimits
+	zconfig.Database.TableQuotaUsage = tableQuotaUsage
+	zconfig.Database.Host = p.required(dbHost)
+	// the in-memory db doesn't need anything else
+	if zconfig.Database.Host != "memory" {
+		zconfig.Database.Port = p.number(dbPort)
+		zconfig.Database.Name = p.required(dbName)
+		zconfig.Database.User = p.required(dbUsr)
+		zconfig.Database.Password = p.required(dbPwd)
+		if zconfig.Stage == TEST && zconfig.Database.Name != "" && zconfig.Database.Name != "hal_test" {
+			p.errs = append(p.errs, fmt.Sprintf("cannot use db %s with stage set to TEST", zconfig.Database.Name))
+		}
 	}
-	zconfig.BlocksDelay = intDelay
-
-	zconfig.Database.Host = os.Getenv(dbHost)
-	if zconfig.Database.Host == "" {
-		log.Fatal("no db host set in local env ", dbHost)
+	switch p.get(dbMigrate) {
+	case "true":
+		zconfig.Database.Migrate = true
+	case "false":
+	default:
+		p.errs = append(p.errs, fmt.Sprintf("%s must be true or false, not %q", dbMigrate, p.get(dbMigrate)))
 	}
+}
 
-	zconfig.Database.Name = os.Getenv(dbName)
-	if zconfig.Database.Name == "" {
-		log.Fatal("no db name set in local env ", dbName)
+func (p *parser) result(zconfig *ZConfiguration) (*ZConfiguration, error) {
+	if len(p.errs) > 0 {
+		return zconfig, p.errs
 	}
+	return zconfig, nil
+}
 
-	if zconfig.Stage == TEST && zconfig.Database.Name != "hal_test" {
-		log.Fatalf("cannot use db %s with stage set to TEST\n", zconfig.Database.Name)
-	}
+// ParseDatabase only reads and checks the stage and the database settings, e.g. for migrations
+func (s *Settings) ParseDatabase() (*ZConfiguration, error) {
+	p := &parser{Settings: s}
+	zconfig := ZConfiguration{LogLevel: log.InfoLevel}
+	p.stage(&zconfig)
+	p.database(&zconfig)
+	return p.result(&zconfig)
+}
 
-	zconfig.Database.User = os.Getenv(dbUsr)
-	if zconfig.Database.User == "" {
-		log.Fatal("no db user set in local env ", dbUsr)
+// Parse turns the settings into a configuration, checking all of them;
+// the configuration is returned even if it's invalid, along with all the errors found.
+func (s *Settings) Parse() (*ZConfiguration, error) {
+	p := &parser{Settings: s}
+	zconfig := ZConfiguration{LogLevel: log.InfoLevel}
+	required, number := p.required, p.number
+
+	p.stage(&zconfig)
+
+	zconfig.Network = required(network)
+	zconfig.EthNode = required(ethNode)
+	zconfig.BackupNode = s.get(backupNode)
+	// only used by tests
+	zconfig.RinkebyNode = s.get(rinkebyNode)
+
+	p.database(&zconfig)
+
+	zconfig.BlocksDelay = number(blocksDelay)
+	zconfig.PollingInterval = number(pollingInterval)
+	zconfig.BlocksInterval = number(blocksInterval)
+	zconfig.MempoolInterval = number(mempoolInterval)
+	zconfig.MempoolDropAfter = number(mempoolDropAfter)
+	if zconfig.PollingInterval == 0 {
+		p.errs = append(p.errs, fmt.Sprintf("%s must be at least 1 second", pollingInterval))
 	}
-
-	zconfig.Database.Password = os.Getenv(dbPwd)
-	if zconfig.Database.Password == "" {
-		log.Fatal("no db password set in local env ", dbPwd)
+	if zconfig.BlocksInterval == 0 {
+		p.errs = append(p.errs, fmt.Sprintf("%s must be at least 1 block", blocksInterval))
 	}
 
-	zconfig.Network = os.Getenv(network)
-	if zconfig.Network == "" {
-		log.Fatal("no network set in local env ", network)
+	// e.g. "chainlink,coingecko"
+	if sources := s.get(priceSources); sources != "" {
+		zconfig.PriceSources = strings.Split(strings.ReplaceAll(sources, " ", ""), ",")
 	}
-
-	zconfig.EthNode = os.Getenv(ethNode)
-	if zconfig.EthNode == "" {
-		log.Fatal("no eth node set in local env ", ethNode)
+	zconfig.TokenList = s.get(tokenList)
+	zconfig.TokenCache = s.get(tokenCache)
+
+	// optional integrations
+	zconfig.TwitterConsumerKey = s.get(twitterConsumerKey)
+	zconfig.TwitterConsumerSecret = s.get(twitterConsumerSecret)
+	if (zconfig.TwitterConsumerKey == "") != (zconfig.TwitterConsumerSecret == "") {
+		p.errs = append(p.errs, fmt.Sprintf("set both %s and %s to enable tweets, or neither", twitterConsumerKey, twitterConsumerSecret))
 	}
+	zconfig.EtherscanKey = s.get(etherscanKey)
 
-	zconfig.BackupNode = os.Getenv(backupNode)
-	if zconfig.BackupNode == "" {
-		log.Fatal("no backup node set in local env ", backupNode)
-	}
-
-	// Rinkeby node is only required for tests
-	zconfig.RinkebyNode = os.Getenv(rinkebyNode)
-	if zconfig.Stage == TEST && zconfig.EthNode == "" {
-		log.Error("no Rinkeby node set in local env ", rinkebyNode)
-	}
+	return p.result(&zconfig)
+}
 
-	zconfig.TwitterConsumerKey = os.Getenv(twitterConsumerKey)
-	if zconfig.TwitterConsumerKey == "" {
-		log.Fatal("no twitter consumer key set in local env ", twitterConsumerKey)
-	}
+// TwitterEnabled tells if tweet actions can be run
+func (c ZConfiguration) TwitterEnabled() bool {
+	return c.TwitterConsumerKey != "" && c.TwitterConsumerSecret != ""
+}
 
-	zconfig.TwitterConsumerSecret = os.Getenv(twitterConsumerSecret)
-	if zconfig.TwitterConsumerSecret == "" {
-		log.Fatal("no twitter consumer secret set in local env ", twitterConsumerSecret)
-	}
+// EtherscanEnabled tells if missing ABIs can be fetched from Etherscan
+func (c ZConfiguration) EtherscanEnabled() bool {
+	return c.EtherscanKey != ""
+}
 
-	zconfig.EtherscanKey = os.Getenv(etherscanKey)
-	if zconfig.EtherscanKey == "" {
-		log.Fatal("no etherscan key set in local env ", etherscanKey)
-	}
+// the average block time of each network
+var blockTimes = map[string]time.Duration{
+	"1_eth_mainnet":     13 * time.Second,
+	"3_xdai_mainnet":    5 * time.Second,
+	"4_binance_mainnet": 3 * time.Second,
+	"5_polygon_mainnet": 2 * time.Second,
+}
 
-	interval := os.Getenv(pollingInterval)
-	intervalSeconds, err := strconv.Atoi(interval)
-	if interval == "" || err != nil {
-		log.Fatalf("cannot use %s as polling interval", interval)
+// PendingTxTimeout is how long a pending tx can be missing from the mempool before it's dropped.
+// Blocks reach the matchers BlocksDelay blocks late, so it's never shorter than that:
+// otherwise mined txs would be dropped before their block is processed.
+func (c ZConfiguration) PendingTxTimeout() time.Duration {
+	blockTime, ok := blockTimes[c.Network]
+	if !ok {
+		blockTime = blockTimes["1_eth_mainnet"]
 	}
-	zconfig.PollingInterval = intervalSeconds
-
-	blocksInterval := os.Getenv(blocksInterval)
-	blocksIntervalSeconds, err := strconv.Atoi(blocksInterval)
-	if blocksInterval == "" || err != nil {
-		log.Fatalf("cannot use %s as blocks interval", blocksInterval)
+	min := time.Duration(c.BlocksDelay+1)*blockTime + 2*time.Duration(c.PollingInterval)*time.Second
+	if timeout := time.Duration(c.MempoolDropAfter) * time.Second; timeout > min {
+		return timeout
 	}
-	zconfig.BlocksInterval = blocksIntervalSeconds
-
-	return &zconfig
+	return min
 }
 
 func (c ZConfiguration) IsNetworkETHMainnet() bool {
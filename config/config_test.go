@@ -0,0 +1,142 @@
+package config
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func writeConfigFile(t *testing.T, content string) string {
+	dir, err := ioutil.TempDir("", "zoroaster")
+	assert.NoError(t, err)
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	path := filepath.Join(dir, "zoroaster.yml")
+	assert.NoError(t, ioutil.WriteFile(path, []byte(content), 0600))
+	return path
+}
+
+func TestLoadSettings(t *testing.T) {
+	path := writeConfigFile(t, `
+stage: PROD
+mempool_polling_interval: 3
+price_sources: [chainlink, coingecko]
+etherscan_key:
+`)
+	// nothing but what's set here comes from the env
+	for _, st := range settings {
+		t.Setenv(st.name, "")
+	}
+	t.Setenv(stage, "TEST")
+	t.Setenv(ethNode, "http://localhost:8545")
+	t.Setenv(dbHost, "memory")
+
+	s, err := LoadSettings(path)
+	assert.NoError(t, err)
+
+	// the env wins over the file, the file over the defaults
+	assert.Equal(t, "TEST", s.get(stage))
+	assert.Equal(t, "env", s.sources[stage])
+	assert.Equal(t, "3", s.get(mempoolInterval))
+	assert.Equal(t, path, s.sources[mempoolInterval])
+	assert.Equal(t, "chainlink,coingecko", s.get(priceSources))
+	assert.Equal(t, DefaultTokenList, s.get(tokenList))
+	assert.Equal(t, "default", s.sources[tokenList])
+
+	conf, err := s.Parse()
+	assert.NoError(t, err)
+	assert.Equal(t, 3, conf.MempoolInterval)
+	assert.Equal(t, []string{"chainlink", "coingecko"}, conf.PriceSources)
+
+	// typos don't go unnoticed
+	path = writeConfigFile(t, "eth_nod: http://localhost:8545\n")
+	_, err = LoadSettings(path)
+	assert.EqualError(t, err, "unknown setting(s) in config file "+path+": eth_nod")
+}
+
+func TestParse(t *testing.T) {
+	s := defaultSettings()
+	s.set(stage, "TEST", "env")
+	s.set(dbHost, "localhost", "env")
+	s.set(dbName, "hal", "env")
+	s.set(blocksInterval, "-1", "env")
+	s.set(twitterConsumerKey, "key", "env")
+
+	// every error is reported
+	_, err := s.Parse()
+	assert.Equal(t, Errors{
+		"ETH_NODE is required",
+		"DB_USR is required",
+		"DB_PWD is required",
+		"cannot use db hal with stage set to TEST",
+		`BLOCKS_INTERVAL must be a number >= 0, not "-1"`,
+		"set both TWITTER_CONSUMER_KEY and TWITTER_CONSUMER_SECRET to enable tweets, or neither",
+	}, err)
+
+	// the in-memory db needs no credentials, and integrations are optional
+	s = defaultSettings()
+	s.set(stage, "STAGING", "env")
+	s.set(dbHost, "memory", "env")
+	s.set(ethNode, "http://localhost:8545", "env")
+	conf, err := s.Parse()
+	assert.NoError(t, err)
+	assert.False(t, conf.TwitterEnabled())
+	assert.False(t, conf.EtherscanEnabled())
+	assert.Equal(t, 5, conf.PollingInterval)
+}
+
+func TestParseDatabase(t *testing.T) {
+	// migrations don't need a node, or anything but the db
+	s := defaultSettings()
+	s.set(stage, "PROD", "env")
+	s.set(dbHost, "localhost", "env")
+	s.set(dbName, "hal", "env")
+	s.set(dbUsr, "hal", "env")
+	s.set(dbPwd, "hal", "env")
+	s.set(pollingInterval, "0", "env")
+	conf, err := s.ParseDatabase()
+	assert.NoError(t, err)
+	assert.Equal(t, "hal", conf.Database.Name)
+	assert.Equal(t, "triggers", conf.Database.TableTriggers)
+
+	// but the db settings are still checked
+	s.set(stage, "TEST", "env")
+	s.set(dbPwd, "", "env")
+	_, err = s.ParseDatabase()
+	assert.Equal(t, Errors{
+		"DB_PWD is required",
+		"cannot use db hal with stage set to TEST",
+	}, err)
+}
+
+func TestPendingTxTimeout(t *testing.T) {
+	conf := ZConfiguration{Network: "1_eth_mainnet", MempoolDropAfter: 600, PollingInterval: 5}
+	assert.Equal(t, 10*time.Minute, conf.PendingTxTimeout())
+
+	// never shorter than it takes a mined block to be processed
+	conf.BlocksDelay = 100
+	assert.Equal(t, 101*13*time.Second+10*time.Second, conf.PendingTxTimeout())
+	conf.Network, conf.MempoolDropAfter = "5_polygon_mainnet", 0
+	assert.Equal(t, 101*2*time.Second+10*time.Second, conf.PendingTxTimeout())
+	conf.BlocksDelay = 0
+	assert.Equal(t, 12*time.Second, conf.PendingTxTimeout())
+}
+
+func TestPrintSettings(t *testing.T) {
+	s := &Settings{values: map[string]string{}, sources: map[string]string{}}
+	s.set(ethNode, "https://mainnet.infura.io/v3/0123456789abcdef", "env")
+	s.set(backupNode, "http://localhost:8545", "default")
+	s.set(dbPwd, "hunter2", "zoroaster.yml")
+
+	var out bytes.Buffer
+	s.Print(&out)
+	assert.Contains(t, out.String(), "ETH_NODE                  https://mainnet.infura.io/<redacted> (env)\n")
+	assert.Contains(t, out.String(), "BACKUP_NODE               http://localhost:8545 (default)\n")
+	assert.Contains(t, out.String(), "DB_PWD                    <redacted> (zoroaster.yml)\n")
+	assert.Contains(t, out.String(), "ETHERSCAN_KEY             (not set)\n")
+	assert.NotContains(t, out.String(), "hunter2")
+	assert.NotContains(t, out.String(), "0123456789abcdef")
+}
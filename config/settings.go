@@ -0,0 +1,208 @@
+package config
+
+import (
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+)
+
+// Every setting can be set in an (optional) YAML file, and overridden by an env variable.
+// Keys in the file are the env variables in lower case, e.g.
+//   eth_node: https://mainnet.infura.io/v3/...
+//   polling_interval: 5
+
+// ENV variables
+const (
+	configFile            = "CONFIG_FILE"
+	stage                 = "STAGE"
+	blocksDelay           = "BLOCKS_DELAY"
+	dbHost                = "DB_HOST"
+	dbPort                = "DB_PORT"
+	dbName                = "DB_NAME"
+	dbUsr                 = "DB_USR"
+	dbPwd                 = "DB_PWD"
+	dbMigrate             = "DB_MIGRATE"
+	ethNode               = "ETH_NODE"
+	backupNode            = "BACKUP_NODE"
+	rinkebyNode           = "RINKEBY_NODE"
+	twitterConsumerKey    = "TWITTER_CONSUMER_KEY"
+	twitterConsumerSecret = "TWITTER_CONSUMER_SECRET"
+	network               = "NETWORK"
+	pollingInterval       = "POLLING_INTERVAL"
+	blocksInterval        = "BLOCKS_INTERVAL"
+	mempoolInterval       = "MEMPOOL_POLLING_INTERVAL"
+	mempoolDropAfter      = "MEMPOOL_DROP_AFTER"
+	etherscanKey          = "ETHERSCAN_KEY"
+	priceSources          = "PRICE_SOURCES"
+	tokenList             = "TOKEN_LIST"
+	tokenCache            = "TOKEN_CACHE_FILE"
+)
+
+// DefaultTokenList is Uniswap's default token list; it replaced HAL's own token endpoint,
+// and only has the more popular tokens: the others are looked up on-chain.
+const DefaultTokenList = "https://tokens.uniswap.org"
+
+type settingKind int
+
+const (
+	plain  settingKind = iota
+	secret             // never printed
+	node               // a URL whose path and query might hold an API key
+)
+
+type setting struct {
+	name string
+	def  string
+	kind settingKind
+}
+
+var settings = []setting{
+	{name: stage},
+	{name: network, def: "1_eth_mainnet"},
+	{name: ethNode, kind: node},
+	{name: backupNode, kind: node}, // defaults to ETH_NODE, see LoadSettings
+	{name: rinkebyNode, kind: node},
+	{name: dbHost},
+	{name: dbPort, def: "5432"},
+	{name: dbName},
+	{name: dbUsr},
+	{name: dbPwd, kind: secret},
+	{name: dbMigrate, def: "false"},
+	{name: blocksDelay, def: "0"},
+	{name: pollingInterval, def: "5"},
+	{name: blocksInterval, def: "1"},
+	{name: mempoolInterval, def: "0"},
+	{name: mempoolDropAfter, def: "600"},
+	{name: priceSources},
+	{name: tokenList, def: DefaultTokenList},
+	{name: tokenCache},
+	{name: twitterConsumerKey, kind: secret},
+	{name: twitterConsumerSecret, kind: secret},
+	{name: etherscanKey, kind: secret},
+}
+
+// Settings are the raw values of all the settings, and where they come from
+type Settings struct {
+	values  map[string]string
+	sources map[string]string // "default", the file name, or "env"
+}
+
+// LoadSettings layers the env over the config file (if any) over the defaults;
+// an empty env variable counts as not set.
+func LoadSettings(path string) (*Settings, error) {
+	s := defaultSettings()
+	if path != "" {
+		if err := s.loadFile(path); err != nil {
+			return nil, err
+		}
+	}
+	for _, st := range settings {
+		if v := os.Getenv(st.name); v != "" {
+			s.set(st.name, v, "env")
+		}
+	}
+	if _, ok := s.values[backupNode]; !ok && s.get(ethNode) != "" {
+		s.set(backupNode, s.get(ethNode), ethNode)
+	}
+	return s, nil
+}
+
+func defaultSettings() *Settings {
+	s := &Settings{values: map[string]string{}, sources: map[string]string{}}
+	for _, st := range settings {
+		if st.def != "" {
+			s.set(st.name, st.def, "default")
+		}
+	}
+	return s
+}
+
+func (s *Settings) set(name, value, source string) {
+	s.values[name], s.sources[name] = value, source
+}
+
+func (s *Settings) get(name string) string {
+	return s.values[name]
+}
+
+func (s *Settings) loadFile(path string) error {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("cannot read config file: %s", err)
+	}
+	file := map[string]interface{}{}
+	if err = yaml.Unmarshal(content, &file); err != nil {
+		return fmt.Errorf("cannot parse config file %s: %s", path, err)
+	}
+
+	var unknown []string
+	for key, value := range file {
+		name := strings.ToUpper(key)
+		if !isSetting(name) {
+			unknown = append(unknown, key)
+			continue
+		}
+		switch v := value.(type) {
+		case nil:
+			continue
+		case []interface{}:
+			// e.g. price_sources: [chainlink, coingecko]
+			items := make([]string, len(v))
+			for i := range v {
+				items[i] = fmt.Sprint(v[i])
+			}
+			s.set(name, strings.Join(items, ","), path)
+		default:
+			s.set(name, fmt.Sprint(v), path)
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return fmt.Errorf("unknown setting(s) in config file %s: %s", path, strings.Join(unknown, ", "))
+	}
+	return nil
+}
+
+func isSetting(name string) bool {
+	for _, st := range settings {
+		if st.name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Print writes every setting with its value and source; secrets are redacted
+func (s *Settings) Print(w io.Writer) {
+	for _, st := range settings {
+		value, ok := s.values[st.name]
+		if !ok {
+			fmt.Fprintf(w, "%-25s (not set)\n", st.name)
+			continue
+		}
+		fmt.Fprintf(w, "%-25s %s (%s)\n", st.name, redact(st.kind, value), s.sources[st.name])
+	}
+}
+
+func redact(kind settingKind, value string) string {
+	switch kind {
+	case secret:
+		return "<redacted>"
+	case node:
+		u, err := url.Parse(value)
+		if err != nil || u.Host == "" {
+			return "<redacted>"
+		}
+		redacted := u.Scheme + "://" + u.Host
+		if u.User != nil || (u.Path != "" && u.Path != "/") || u.RawQuery != "" {
+			redacted += "/<redacted>"
+		}
+		return redacted
+	}
+	return value
+}
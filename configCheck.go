@@ -0,0 +1,39 @@
+package main
+
+import (
+	"fmt"
+	"github.com/HAL-xyz/zoroaster/config"
+	"os"
+)
+
+const configUsage = `usage: zoroaster config check [FILE]
+  check [FILE]    print the effective configuration, with secrets redacted, and validate it;
+                  FILE defaults to $CONFIG_FILE`
+
+// runConfig handles the `config` subcommand
+func runConfig(args []string) error {
+	if len(args) == 0 || len(args) > 2 || args[0] != "check" {
+		return fmt.Errorf(configUsage)
+	}
+	path := config.FilePath()
+	if len(args) == 2 {
+		path = args[1]
+	}
+	settings, err := config.LoadSettings(path)
+	if err != nil {
+		return err
+	}
+	settings.Print(os.Stdout)
+	if _, err = settings.Parse(); err != nil {
+		if errs, ok := err.(config.Errors); ok {
+			fmt.Println()
+			for _, e := range errs {
+				fmt.Println("error:", e)
+			}
+			return fmt.Errorf("invalid configuration, %d error(s)", len(errs))
+		}
+		return err
+	}
+	fmt.Println("configuration OK")
+	return nil
+}
@@ -1,14 +1,11 @@
 package db
 
 import (
-	"github.com/HAL-xyz/zoroaster/config"
 	"github.com/HAL-xyz/zoroaster/trigger"
 	"time"
 )
 
 type IDB interface {
-	initDB(c *config.ZConfiguration)
-
 	Close()
 
 	LoadTriggersFromDB(tgType trigger.TgType) ([]*trigger.Trigger, error)
@@ -29,9 +26,11 @@ type IDB interface {
 
 	GetSilentButMatchingTriggers(triggerUUIDs []string) ([]string, error)
 
-	ReadSavedMonth() (int, error)
+	UpdateLastFired(tgUUID string, now time.Time) error
+
+	ReserveQuota(userUUID, actionType string, now time.Time) (*QuotaReservation, error)
 
-	UpdateSavedMonth(newMonth int) error
+	ReleaseQuota(userUUID, actionType string, now time.Time) error
 
-	UpdateLastFired(tgUUID string, now time.Time) error
+	GetUserEmail(userUUID string) (string, error)
 }
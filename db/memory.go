@@ -0,0 +1,494 @@
+package db
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"github.com/HAL-xyz/zoroaster/tokenapi"
+	"github.com/HAL-xyz/zoroaster/trigger"
+	log "github.com/sirupsen/logrus"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MemoryClient is an IDB that keeps everything in memory, with the same semantics
+// as PostgresClient; it's meant for tests and local development.
+type MemoryClient struct {
+	network    string
+	tokenApi   tokenapi.ITokenAPI // resolves ENS names and expands macros in triggers
+	users      map[string]*memUser
+	triggers   map[string]*memTrigger
+	actions    []*memAction
+	matches    []*MemMatch
+	outcomes   []*MemOutcome
+	states     map[string]map[string]int // network -> "wat" -> last block processed
+	quotaUsage map[memUsageKey]*memUsage
+	seq        int // keeps triggers in insertion order
+	sync.Mutex
+}
+
+type memUser struct {
+	email             string
+	actionsMonthlyCap int
+	quotaLimits       []QuotaLimit
+}
+
+type memUsageKey struct {
+	userUUID string
+	quotaKey
+	periodStart time.Time
+}
+
+type memUsage struct {
+	used   int
+	warned bool
+}
+
+type memTrigger struct {
+	seq         int
+	triggerData string
+	isActive    bool
+	triggered   bool
+	userUUID    string
+	network     string
+	lastFired   *time.Time
+}
+
+type memAction struct {
+	triggerUUID string
+	actionData  string
+	isActive    bool
+}
+
+// A match as it would be stored in the matches table
+type MemMatch struct {
+	UUID        string
+	TriggerUUID string
+	MatchData   string
+	CreatedAt   time.Time
+}
+
+// An outcome as it would be stored in the outcomes table
+type MemOutcome struct {
+	MatchUUID string
+	trigger.Outcome
+	CreatedAt time.Time
+}
+
+// NewMemoryClient returns an empty db, where the state of the given network is already set up
+func NewMemoryClient(network string, tokenApi tokenapi.ITokenAPI) *MemoryClient {
+	cli := &MemoryClient{network: network, tokenApi: tokenApi}
+	cli.Reset()
+	return cli
+}
+
+// Reset deletes everything, like truncating all the tables
+func (cli *MemoryClient) Reset() {
+	cli.Lock()
+	defer cli.Unlock()
+	cli.users = map[string]*memUser{}
+	cli.triggers = map[string]*memTrigger{}
+	cli.actions = nil
+	cli.matches = nil
+	cli.outcomes = nil
+	cli.states = map[string]map[string]int{cli.network: {}}
+	cli.quotaUsage = map[memUsageKey]*memUsage{}
+}
+
+func (cli *MemoryClient) Close() {
+	// void
+}
+
+func (cli *MemoryClient) LoadTriggersFromDB(tgType trigger.TgType) ([]*trigger.Trigger, error) {
+	month := PeriodStart(MonthlyQuota, time.Now())
+	triggers, _ := cli.loadTriggers(func(uuid string, tg *memTrigger) bool {
+		if _, ok := cli.users[tg.userUUID]; !ok || cli.overCap(tg.userUUID, month) {
+			return false
+		}
+		return tg.isActive && tg.network == cli.network && triggerType(tg.triggerData) == trigger.TgTypeToString(tgType)
+	})
+	return triggers, nil
+}
+
+func (cli *MemoryClient) LoadActiveTriggersFromDB() ([]*trigger.Trigger, []string, error) {
+	triggers, unresolved := cli.loadTriggers(func(uuid string, tg *memTrigger) bool {
+		return tg.isActive && tg.network == cli.network
+	})
+	return triggers, unresolvedUUIDs(unresolved), nil
+}
+
+func (cli *MemoryClient) LoadActiveTriggerFromDB(tgUUID string) (*trigger.Trigger, error) {
+	triggers, unresolved := cli.loadTriggers(func(uuid string, tg *memTrigger) bool {
+		return uuid == tgUUID && tg.isActive && tg.network == cli.network
+	})
+	if err, ok := unresolved[tgUUID]; ok {
+		return nil, err
+	}
+	if len(triggers) == 0 {
+		return nil, nil
+	}
+	return triggers[0], nil
+}
+
+func (cli *MemoryClient) LoadUsersOverCap() ([]string, error) {
+	cli.Lock()
+	defer cli.Unlock()
+	month := PeriodStart(MonthlyQuota, time.Now())
+	uuids := make([]string, 0)
+	for uuid := range cli.users {
+		if cli.overCap(uuid, month) {
+			uuids = append(uuids, uuid)
+		}
+	}
+	return uuids, nil
+}
+
+// overCap tells if a user has run all the actions they're allowed in the month starting on month
+func (cli *MemoryClient) overCap(userUUID string, month time.Time) bool {
+	user := cli.users[userUUID]
+	key := quotaKey{AnyAction, MonthlyQuota}
+	usage, ok := cli.quotaUsage[memUsageKey{userUUID, key, month}]
+	return ok && usage.used >= effectiveLimits(user.quotaLimits, user.actionsMonthlyCap)[key].Hard
+}
+
+// loadTriggers returns the triggers selected by keep, in insertion order,
+// and apart the errors of the ones whose ENS names can't be resolved
+func (cli *MemoryClient) loadTriggers(keep func(uuid string, tg *memTrigger) bool) ([]*trigger.Trigger, map[string]error) {
+	cli.Lock()
+	defer cli.Unlock()
+
+	uuids := make([]string, 0)
+	for uuid, tg := range cli.triggers {
+		if keep(uuid, tg) {
+			uuids = append(uuids, uuid)
+		}
+	}
+	sort.Slice(uuids, func(i, j int) bool {
+		return cli.triggers[uuids[i]].seq < cli.triggers[uuids[j]].seq
+	})
+
+	triggers := make([]*trigger.Trigger, 0)
+	unresolved := make(map[string]error)
+	for _, uuid := range uuids {
+		tg := cli.triggers[uuid]
+		trig, err := trigger.NewTriggerFromJson(tg.triggerData, cli.tokenApi)
+		if err != nil {
+			log.Warnf("trigger uuid %s: %v", uuid, err)
+			if trigger.IsENSError(err) {
+				unresolved[uuid] = err
+			}
+			continue
+		}
+		trig.TriggerUUID, trig.UserUUID = uuid, tg.userUUID
+		trig.LastFired = time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+		if tg.lastFired != nil {
+			trig.LastFired = *tg.lastFired
+		}
+		triggers = append(triggers, trig)
+	}
+	return triggers, unresolved
+}
+
+func (cli *MemoryClient) LogOutcome(outcome *trigger.Outcome, matchUUID string) error {
+	cli.Lock()
+	defer cli.Unlock()
+	cli.outcomes = append(cli.outcomes, &MemOutcome{MatchUUID: matchUUID, Outcome: *outcome, CreatedAt: time.Now()})
+	return nil
+}
+
+func (cli *MemoryClient) GetActions(tgUUID string, userUUID string) ([]string, error) {
+	cli.Lock()
+	defer cli.Unlock()
+	actionsRet := make([]string, 0)
+	tg, ok := cli.triggers[tgUUID]
+	if !ok || tg.userUUID != userUUID {
+		return actionsRet, nil
+	}
+	for _, a := range cli.actions {
+		if a.triggerUUID == tgUUID && a.isActive {
+			actionsRet = append(actionsRet, a.actionData)
+		}
+	}
+	return actionsRet, nil
+}
+
+func (cli *MemoryClient) ReadLastBlockProcessed(tgType trigger.TgType) (int, error) {
+	cli.Lock()
+	defer cli.Unlock()
+	state, ok := cli.states[cli.network]
+	if !ok || trigger.TgTypeToPrefix(tgType) == "" {
+		return 0, fmt.Errorf("cannot read last block processed: no state for %s", cli.network)
+	}
+	return state[trigger.TgTypeToPrefix(tgType)], nil
+}
+
+func (cli *MemoryClient) SetLastBlockProcessed(blockNo int, tgType trigger.TgType) error {
+	cli.Lock()
+	defer cli.Unlock()
+	if trigger.TgTypeToPrefix(tgType) == "" {
+		return fmt.Errorf("cannot set last block processed: unknown trigger type %d", tgType)
+	}
+	// like an UPDATE, this is a no-op if there's no state for the network
+	if state, ok := cli.states[cli.network]; ok {
+		state[trigger.TgTypeToPrefix(tgType)] = blockNo
+	}
+	return nil
+}
+
+func (cli *MemoryClient) LogMatch(match trigger.IMatch) error {
+	matchData, err := json.Marshal(match.ToPersistent())
+	if err != nil {
+		return err
+	}
+	cli.Lock()
+	defer cli.Unlock()
+	m := &MemMatch{
+		UUID:        newUUID(),
+		TriggerUUID: match.GetTriggerUUID(),
+		MatchData:   strings.ReplaceAll(string(matchData), "\\u0000", ""),
+		CreatedAt:   time.Now(),
+	}
+	cli.matches = append(cli.matches, m)
+	match.SetMatchUUID(m.UUID)
+	return nil
+}
+
+func (cli *MemoryClient) UpdateMatchingTriggers(triggerIds []string) {
+	cli.setTriggered(triggerIds, true)
+}
+
+func (cli *MemoryClient) UpdateNonMatchingTriggers(triggerIds []string) {
+	cli.setTriggered(triggerIds, false)
+}
+
+func (cli *MemoryClient) setTriggered(triggerIds []string, triggered bool) {
+	cli.Lock()
+	defer cli.Unlock()
+	for _, uuid := range triggerIds {
+		if tg, ok := cli.triggers[uuid]; ok {
+			tg.triggered = triggered
+		}
+	}
+}
+
+func (cli *MemoryClient) GetSilentButMatchingTriggers(triggerUUIDs []string) ([]string, error) {
+	cli.Lock()
+	defer cli.Unlock()
+	uuidsRet := make([]string, 0)
+	for _, uuid := range triggerUUIDs {
+		if tg, ok := cli.triggers[uuid]; ok && !tg.triggered {
+			uuidsRet = append(uuidsRet, uuid)
+		}
+	}
+	return uuidsRet, nil
+}
+
+func (cli *MemoryClient) UpdateLastFired(tgUUID string, now time.Time) error {
+	cli.Lock()
+	defer cli.Unlock()
+	if tg, ok := cli.triggers[tgUUID]; ok {
+		lastFired := now.UTC()
+		tg.lastFired = &lastFired
+	}
+	return nil
+}
+
+func (cli *MemoryClient) ReserveQuota(userUUID, actionType string, now time.Time) (*QuotaReservation, error) {
+	cli.Lock()
+	defer cli.Unlock()
+	user, ok := cli.users[userUUID]
+	if !ok {
+		return nil, fmt.Errorf("cannot reserve quota: no user with uuid %s", userUUID)
+	}
+	limits := effectiveLimits(user.quotaLimits, user.actionsMonthlyCap)
+
+	// like a transaction, nothing is counted unless every limit allows it
+	reservation := &QuotaReservation{Allowed: true}
+	for _, key := range quotaKeys(actionType) {
+		limit, limited := limits[key]
+		usage := QuotaUsage{QuotaLimit: limit, PeriodStart: PeriodStart(key.window, now)}
+		usage.ActionType, usage.Window = key.actionType, key.window
+		if current, ok := cli.quotaUsage[memUsageKey{userUUID, key, usage.PeriodStart}]; ok {
+			usage.Used = current.used
+		}
+		if limited && usage.Used >= limit.Hard {
+			reservation.Allowed, reservation.Exceeded = false, &usage
+			return reservation, nil
+		}
+	}
+	for _, key := range quotaKeys(actionType) {
+		limit, limited := limits[key]
+		usageKey := memUsageKey{userUUID, key, PeriodStart(key.window, now)}
+		current, ok := cli.quotaUsage[usageKey]
+		if !ok {
+			current = &memUsage{}
+			cli.quotaUsage[usageKey] = current
+		}
+		current.used++
+		if limited && limit.Soft > 0 && current.used >= limit.Soft && !current.warned {
+			current.warned = true
+			usage := QuotaUsage{QuotaLimit: limit, PeriodStart: usageKey.periodStart, Used: current.used}
+			reservation.Warnings = append(reservation.Warnings, usage)
+		}
+	}
+	return reservation, nil
+}
+
+func (cli *MemoryClient) ReleaseQuota(userUUID, actionType string, now time.Time) error {
+	cli.Lock()
+	defer cli.Unlock()
+	for _, key := range quotaKeys(actionType) {
+		if current, ok := cli.quotaUsage[memUsageKey{userUUID, key, PeriodStart(key.window, now)}]; ok && current.used > 0 {
+			current.used--
+		}
+	}
+	return nil
+}
+
+func (cli *MemoryClient) GetUserEmail(userUUID string) (string, error) {
+	cli.Lock()
+	defer cli.Unlock()
+	user, ok := cli.users[userUUID]
+	if !ok {
+		return "", fmt.Errorf("cannot get email of user %s: no such user", userUUID)
+	}
+	return user.email, nil
+}
+
+// Helper functions, mirroring the ones of PostgresClient
+
+func (cli *MemoryClient) SaveUser(actionsCap int) (string, error) {
+	cli.Lock()
+	defer cli.Unlock()
+	uuid := newUUID()
+	cli.users[uuid] = &memUser{email: "email@lol.com", actionsMonthlyCap: actionsCap}
+	return uuid, nil
+}
+
+// SetQuotaLimit adds a limit to a user's quota, or replaces the one for the same action type and window
+func (cli *MemoryClient) SetQuotaLimit(userUUID string, limit QuotaLimit) error {
+	cli.Lock()
+	defer cli.Unlock()
+	user, ok := cli.users[userUUID]
+	if !ok {
+		return fmt.Errorf("no user with uuid %s", userUUID)
+	}
+	limits := make([]QuotaLimit, 0, len(user.quotaLimits)+1)
+	for _, l := range user.quotaLimits {
+		if l.ActionType != limit.ActionType || l.Window != limit.Window {
+			limits = append(limits, l)
+		}
+	}
+	user.quotaLimits = append(limits, limit)
+	return nil
+}
+
+func (cli *MemoryClient) SaveTrigger(triggerData string, isActive, triggered bool, userId string, network string) (string, error) {
+	cli.Lock()
+	defer cli.Unlock()
+	if _, ok := cli.users[userId]; !ok {
+		return "", fmt.Errorf("no user with uuid %s", userId)
+	}
+	if !json.Valid([]byte(triggerData)) {
+		return "", fmt.Errorf("invalid trigger data")
+	}
+	cli.seq++
+	uuid := newUUID()
+	cli.triggers[uuid] = &memTrigger{
+		seq:         cli.seq,
+		triggerData: triggerData,
+		isActive:    isActive,
+		triggered:   triggered,
+		userUUID:    userId,
+		network:     network,
+	}
+	return uuid, nil
+}
+
+func (cli *MemoryClient) SaveAction(triggerUUID string) (string, error) {
+	actionData := `{
+  "ActionType": "webhook_post",
+  "Attributes": {
+    "URI": "https://webhook.site/3e94a980-cc28-4fb3-8733-8e398e20c066"
+  }
+}`
+	return cli.SaveActionData(triggerUUID, actionData, true)
+}
+
+func (cli *MemoryClient) SaveActionData(triggerUUID, actionData string, isActive bool) (string, error) {
+	cli.Lock()
+	defer cli.Unlock()
+	if _, ok := cli.triggers[triggerUUID]; !ok {
+		return "", fmt.Errorf("no trigger with uuid %s", triggerUUID)
+	}
+	cli.actions = append(cli.actions, &memAction{triggerUUID: triggerUUID, actionData: actionData, isActive: isActive})
+	return newUUID(), nil
+}
+
+// SaveState sets up the state of another network
+func (cli *MemoryClient) SaveState(network string) {
+	cli.Lock()
+	defer cli.Unlock()
+	if _, ok := cli.states[network]; !ok {
+		cli.states[network] = map[string]int{}
+	}
+}
+
+func (cli *MemoryClient) IsTriggered(tgUUID string) (bool, error) {
+	cli.Lock()
+	defer cli.Unlock()
+	tg, ok := cli.triggers[tgUUID]
+	if !ok {
+		return false, fmt.Errorf("no trigger with uuid %s", tgUUID)
+	}
+	return tg.triggered, nil
+}
+
+// QuotaUsed is how many actions of the given type a user has run in the window including now
+func (cli *MemoryClient) QuotaUsed(userUUID, actionType string, window QuotaWindow, now time.Time) int {
+	cli.Lock()
+	defer cli.Unlock()
+	if current, ok := cli.quotaUsage[memUsageKey{userUUID, quotaKey{actionType, window}, PeriodStart(window, now)}]; ok {
+		return current.used
+	}
+	return 0
+}
+
+func (cli *MemoryClient) Matches() []MemMatch {
+	cli.Lock()
+	defer cli.Unlock()
+	matches := make([]MemMatch, len(cli.matches))
+	for i, m := range cli.matches {
+		matches[i] = *m
+	}
+	return matches
+}
+
+func (cli *MemoryClient) Outcomes() []MemOutcome {
+	cli.Lock()
+	defer cli.Unlock()
+	outcomes := make([]MemOutcome, len(cli.outcomes))
+	for i, o := range cli.outcomes {
+		outcomes[i] = *o
+	}
+	return outcomes
+}
+
+func triggerType(triggerData string) string {
+	var tg struct {
+		TriggerType string
+	}
+	_ = json.Unmarshal([]byte(triggerData), &tg)
+	return tg.TriggerType
+}
+
+// newUUID returns a random (version 4) UUID
+func newUUID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
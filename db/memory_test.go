@@ -0,0 +1,144 @@
+package db
+
+import (
+	"github.com/HAL-xyz/zoroaster/trigger"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"testing"
+	"time"
+)
+
+func TestMemoryClient_All(t *testing.T) {
+
+	memClient := NewMemoryClient("1_eth_mainnet", nil)
+	defer memClient.Close()
+
+	// load a User
+	userUUID, err := memClient.SaveUser(1000)
+	assert.NoError(t, err)
+	assert.Len(t, userUUID, 36)
+
+	// load two Triggers, one in 1_eth_mainnet (default network), one in 2_eth_rinkeby
+	triggerSrc, err := ioutil.ReadFile("../resources/triggers/wac-uniswap.json")
+	assert.NoError(t, err)
+	triggerUUID, err := memClient.SaveTrigger(string(triggerSrc), true, false, userUUID, "1_eth_mainnet")
+	assert.NoError(t, err)
+	_, err = memClient.SaveTrigger(string(triggerSrc), true, false, userUUID, "2_eth_rinkeby")
+	assert.NoError(t, err)
+	_, err = memClient.SaveTrigger(string(triggerSrc), false, false, userUUID, "1_eth_mainnet")
+	assert.NoError(t, err)
+
+	// Load all the active triggers
+	tgs, err := memClient.LoadTriggersFromDB(trigger.WaC)
+	assert.NoError(t, err)
+	assert.Len(t, tgs, 1)
+	assert.Equal(t, "2000-01-01 00:00:00 +0000 UTC", tgs[0].LastFired.String())
+	tgs, err = memClient.LoadTriggersFromDB(trigger.WaT)
+	assert.NoError(t, err)
+	assert.Len(t, tgs, 0)
+
+	// Update last fired
+	err = memClient.UpdateLastFired(triggerUUID, time.Date(2020, time.Month(3), 15, 0, 0, 0, 0, time.UTC))
+	assert.NoError(t, err)
+	tgs, err = memClient.LoadTriggersFromDB(trigger.WaC)
+	assert.Equal(t, "2020-03-15 00:00:00 +0000 UTC", tgs[0].LastFired.String())
+
+	// load two Actions, and one that isn't active
+	_, err = memClient.SaveAction(triggerUUID)
+	_, err = memClient.SaveAction(triggerUUID)
+	assert.NoError(t, err)
+	_, err = memClient.SaveActionData(triggerUUID, `{"ActionType": "email"}`, false)
+	assert.NoError(t, err)
+
+	actions, err := memClient.GetActions(triggerUUID, userUUID)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(actions))
+
+	// Log a Match
+	cnMatch := trigger.CnMatch{
+		Trigger:        tgs[0],
+		BlockNumber:    1,
+		BlockTimestamp: 888888,
+		BlockHash:      "0x",
+		MatchedValues:  []string{},
+	}
+	err = memClient.LogMatch(&cnMatch)
+	assert.NoError(t, err)
+	assert.Len(t, cnMatch.MatchUUID, 36)
+	assert.Len(t, memClient.Matches(), 1)
+	assert.Equal(t, triggerUUID, memClient.Matches()[0].TriggerUUID)
+
+	// matches don't count towards the quota
+	assert.Equal(t, 0, memClient.QuotaUsed(userUUID, AnyAction, MonthlyQuota, time.Now()))
+
+	// Update Matching Triggers: set triggered=true
+	memClient.UpdateMatchingTriggers([]string{triggerUUID})
+	triggered, err := memClient.IsTriggered(triggerUUID)
+	assert.NoError(t, err)
+	assert.True(t, triggered)
+
+	silent, err := memClient.GetSilentButMatchingTriggers([]string{triggerUUID, "not-a-trigger"})
+	assert.NoError(t, err)
+	assert.Len(t, silent, 0)
+
+	// Update Non-Matching Triggers: set triggered=false
+	memClient.UpdateNonMatchingTriggers([]string{triggerUUID})
+	triggered, err = memClient.IsTriggered(triggerUUID)
+	assert.NoError(t, err)
+	assert.False(t, triggered)
+
+	silent, err = memClient.GetSilentButMatchingTriggers([]string{triggerUUID})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{triggerUUID}, silent)
+
+	// Log Outcomes
+	err = memClient.LogOutcome(&trigger.Outcome{Payload: "{}", Outcome: `{"HttpCode":200}`, Success: true}, cnMatch.MatchUUID)
+	assert.NoError(t, err)
+	assert.Equal(t, cnMatch.MatchUUID, memClient.Outcomes()[0].MatchUUID)
+
+	// Set and read app state
+	err = memClient.SetLastBlockProcessed(99, trigger.WaT)
+	assert.NoError(t, err)
+	blockNo, err := memClient.ReadLastBlockProcessed(trigger.WaT)
+	assert.NoError(t, err)
+	assert.Equal(t, 99, blockNo)
+	blockNo, err = memClient.ReadLastBlockProcessed(trigger.WaE)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, blockNo)
+
+	_, err = memClient.ReadLastBlockProcessed(trigger.CronT)
+	assert.Error(t, err)
+
+	/* Test user limits */
+
+	batmanUUID, err := memClient.SaveUser(1)
+	assert.NoError(t, err)
+	batmanTriggerUUID, err := memClient.SaveTrigger(string(triggerSrc), true, false, batmanUUID, "1_eth_mainnet")
+	assert.NoError(t, err)
+
+	tgs, err = memClient.LoadTriggersFromDB(trigger.WaC)
+	assert.NoError(t, err)
+	assert.Len(t, tgs, 2)
+	assert.Equal(t, batmanTriggerUUID, tgs[1].TriggerUUID)
+
+	// when batman runs an action, batman's quota is used up
+	reservation, err := memClient.ReserveQuota(batmanUUID, "webhook_post", time.Now())
+	assert.NoError(t, err)
+	assert.True(t, reservation.Allowed)
+
+	// now this trigger should not be loaded anymore
+	tgs, err = memClient.LoadTriggersFromDB(trigger.WaC)
+	assert.NoError(t, err)
+	assert.Len(t, tgs, 1)
+	assert.Equal(t, triggerUUID, tgs[0].TriggerUUID)
+	overCap, err := memClient.LoadUsersOverCap()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{batmanUUID}, overCap)
+
+	// unless the action failed
+	err = memClient.ReleaseQuota(batmanUUID, "webhook_post", time.Now())
+	assert.NoError(t, err)
+	tgs, err = memClient.LoadTriggersFromDB(trigger.WaC)
+	assert.NoError(t, err)
+	assert.Len(t, tgs, 2)
+}
@@ -0,0 +1,249 @@
+package db
+
+import (
+	"database/sql"
+	"embed"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+)
+
+// The schema is versioned with the files in migrations/, which are compiled into the binary.
+// Versions are kept in the same table used by golang-migrate, so dbs migrated
+// with db/migrate.sh are picked up where they left off.
+
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+const schemaMigrationsTable = "schema_migrations"
+
+// the baseline tables default their uuids to uuid_generate_v4(), which has to be there
+// before the first migration runs; migration 17 records it for the dbs set up by hand
+const baselinePrerequisites = `CREATE EXTENSION IF NOT EXISTS "uuid-ossp"`
+
+// e.g. 000004_add_network_to_trigger.up.sql
+var migrationName = regexp.MustCompile(`^(\d+)_(.+)\.(up|down)\.sql$`)
+
+type migration struct {
+	version int
+	name    string
+	up      string
+	down    string
+}
+
+// loadMigrations returns all the embedded migrations, sorted by version
+func loadMigrations() ([]migration, error) {
+	files, err := migrationFiles.ReadDir("migrations")
+	if err != nil {
+		return nil, err
+	}
+	byVersion := map[int]*migration{}
+	for _, f := range files {
+		parts := migrationName.FindStringSubmatch(f.Name())
+		if parts == nil {
+			return nil, fmt.Errorf("invalid migration file name %s", f.Name())
+		}
+		version, _ := strconv.Atoi(parts[1])
+		body, err := migrationFiles.ReadFile(path.Join("migrations", f.Name()))
+		if err != nil {
+			return nil, err
+		}
+		m, ok := byVersion[version]
+		if !ok {
+			m = &migration{version: version, name: parts[2]}
+			byVersion[version] = m
+		}
+		if m.name != parts[2] {
+			return nil, fmt.Errorf("migration %d has two names: %s and %s", version, m.name, parts[2])
+		}
+		if parts[3] == "up" {
+			m.up = string(body)
+		} else {
+			m.down = string(body)
+		}
+	}
+
+	migrations := make([]migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.up == "" || m.down == "" {
+			return nil, fmt.Errorf("migration %d_%s needs both an up and a down file", m.version, m.name)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].version < migrations[j].version
+	})
+	return migrations, nil
+}
+
+// LatestSchemaVersion is the schema version this binary expects
+func LatestSchemaVersion() int {
+	migrations, err := loadMigrations()
+	if err != nil || len(migrations) == 0 {
+		return 0
+	}
+	return migrations[len(migrations)-1].version
+}
+
+// SchemaVersion returns the version of the db schema, and whether the last migration failed half-way;
+// a db that has never been migrated is at version 0.
+func (cli PostgresClient) SchemaVersion() (int, bool, error) {
+	if err := cli.createMigrationsTable(); err != nil {
+		return 0, false, err
+	}
+	var version int
+	var dirty bool
+	q := fmt.Sprintf(`SELECT version, dirty FROM %s LIMIT 1`, schemaMigrationsTable)
+	err := cli.db.QueryRow(q).Scan(&version, &dirty)
+	if err == sql.ErrNoRows {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, fmt.Errorf("cannot read schema version: %s", err)
+	}
+	return version, dirty, nil
+}
+
+// CheckSchemaVersion returns an error unless the db schema is exactly the one this binary expects
+func (cli PostgresClient) CheckSchemaVersion() error {
+	version, dirty, err := cli.SchemaVersion()
+	if err != nil {
+		return err
+	}
+	if dirty {
+		return fmt.Errorf("schema version %d is dirty: fix the db by hand, then force the version", version)
+	}
+	if latest := LatestSchemaVersion(); version != latest {
+		return fmt.Errorf("schema version is %d, but %d is required: run `zoroaster migrate up`", version, latest)
+	}
+	return nil
+}
+
+// MigrateUp applies the next n pending migrations, or all of them if n <= 0
+func (cli PostgresClient) MigrateUp(n int) error {
+	migrations, err := loadMigrations()
+	if err != nil {
+		return err
+	}
+	version, err := cli.cleanVersion()
+	if err != nil {
+		return err
+	}
+	if version == 0 {
+		if _, err = cli.db.Exec(baselinePrerequisites); err != nil {
+			return fmt.Errorf("cannot set up the baseline prerequisites: %s", err)
+		}
+	}
+	applied := 0
+	for _, m := range migrations {
+		if m.version <= version {
+			continue
+		}
+		if n > 0 && applied == n {
+			break
+		}
+		log.Infof("migrating up to %d_%s", m.version, m.name)
+		if err = cli.runMigration(m.version, m.up, m.version); err != nil {
+			return fmt.Errorf("cannot apply migration %d_%s: %s", m.version, m.name, err)
+		}
+		applied++
+	}
+	log.Infof("%d migration(s) applied", applied)
+	return nil
+}
+
+// MigrateDown reverts the last n applied migrations, or all of them if n <= 0
+func (cli PostgresClient) MigrateDown(n int) error {
+	migrations, err := loadMigrations()
+	if err != nil {
+		return err
+	}
+	version, err := cli.cleanVersion()
+	if err != nil {
+		return err
+	}
+	reverted := 0
+	for i := len(migrations) - 1; i >= 0; i-- {
+		m := migrations[i]
+		if m.version > version {
+			continue
+		}
+		if n > 0 && reverted == n {
+			break
+		}
+		previous := 0
+		if i > 0 {
+			previous = migrations[i-1].version
+		}
+		log.Infof("migrating down from %d_%s", m.version, m.name)
+		if err = cli.runMigration(m.version, m.down, previous); err != nil {
+			return fmt.Errorf("cannot revert migration %d_%s: %s", m.version, m.name, err)
+		}
+		reverted++
+	}
+	log.Infof("%d migration(s) reverted", reverted)
+	return nil
+}
+
+// ForceSchemaVersion sets the schema version without running anything, and clears the dirty flag
+func (cli PostgresClient) ForceSchemaVersion(version int) error {
+	if err := cli.createMigrationsTable(); err != nil {
+		return err
+	}
+	return cli.setSchemaVersion(version, false)
+}
+
+func (cli PostgresClient) cleanVersion() (int, error) {
+	version, dirty, err := cli.SchemaVersion()
+	if err != nil {
+		return 0, err
+	}
+	if dirty {
+		return 0, fmt.Errorf("schema version %d is dirty: fix the db by hand, then force the version", version)
+	}
+	return version, nil
+}
+
+// runMigration marks the schema as dirty while the migration runs, so that
+// a failure half-way through doesn't go unnoticed.
+// Migration files handle their own transactions.
+func (cli PostgresClient) runMigration(version int, body string, newVersion int) error {
+	if err := cli.setSchemaVersion(version, true); err != nil {
+		return err
+	}
+	if _, err := cli.db.Exec(body); err != nil {
+		return err
+	}
+	return cli.setSchemaVersion(newVersion, false)
+}
+
+func (cli PostgresClient) createMigrationsTable() error {
+	q := fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (version bigint NOT NULL PRIMARY KEY, dirty boolean NOT NULL)`, schemaMigrationsTable)
+	if _, err := cli.db.Exec(q); err != nil {
+		return fmt.Errorf("cannot create %s table: %s", schemaMigrationsTable, err)
+	}
+	return nil
+}
+
+// version 0 means no migrations at all
+func (cli PostgresClient) setSchemaVersion(version int, dirty bool) error {
+	tx, err := cli.db.Begin()
+	if err != nil {
+		return err
+	}
+	if _, err = tx.Exec(fmt.Sprintf(`DELETE FROM %s`, schemaMigrationsTable)); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("cannot set schema version: %s", err)
+	}
+	if version > 0 || dirty {
+		q := fmt.Sprintf(`INSERT INTO %s (version, dirty) VALUES ($1, $2)`, schemaMigrationsTable)
+		if _, err = tx.Exec(q, version, dirty); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("cannot set schema version: %s", err)
+		}
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,6 @@
+BEGIN;
+
+ALTER TABLE state DROP COLUMN wab_last_block_processed;
+ALTER TABLE state DROP COLUMN wab_date;
+
+COMMIT;
\ No newline at end of file
@@ -0,0 +1,6 @@
+BEGIN;
+
+ALTER TABLE state ADD COLUMN wab_last_block_processed integer DEFAULT 0;
+ALTER TABLE state ADD COLUMN wab_date timestamp with time zone;
+
+COMMIT;
\ No newline at end of file
@@ -0,0 +1,17 @@
+BEGIN;
+
+-- only what the up could have added goes away: state rows that have never been polled,
+-- and networks nothing refers to anymore. Anything in use is left alone.
+DELETE FROM state
+WHERE network_id IN ('2_eth_rinkeby', '3_xdai_mainnet', '4_binance_mainnet', '5_polygon_mainnet')
+  AND COALESCE(wat_last_block_processed, 0) = 0
+  AND COALESCE(wac_last_block_processed, 0) = 0
+  AND COALESCE(wae_last_block_processed, 0) = 0
+  AND COALESCE(wab_last_block_processed, 0) = 0;
+
+DELETE FROM networks
+WHERE network_id_name IN ('2_eth_rinkeby', '3_xdai_mainnet', '4_binance_mainnet', '5_polygon_mainnet')
+  AND network_id_name NOT IN (SELECT network_id FROM state)
+  AND network_id_name NOT IN (SELECT network_id FROM triggers);
+
+COMMIT;
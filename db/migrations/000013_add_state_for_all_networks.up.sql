@@ -0,0 +1,17 @@
+BEGIN;
+
+INSERT INTO networks(network_id_name, friendly_name, technology, network_name, endpoint)
+VALUES ('2_eth_rinkeby', 'Ethereum Rinkeby', 'ETH', 'Rinkeby', 'foobar'),
+       ('3_xdai_mainnet', 'xDai Mainnet', 'XDAI', 'Mainnet', 'foobar'),
+       ('4_binance_mainnet', 'Binance Smart Chain Mainnet', 'BSC', 'Mainnet', 'foobar'),
+       ('5_polygon_mainnet', 'Polygon Mainnet', 'MATIC', 'Mainnet', 'foobar')
+ON CONFLICT (network_id_name) DO NOTHING;
+
+-- one state row per network, so every network can be polled from a fresh db
+INSERT INTO state (id, wat_last_block_processed, wac_last_block_processed, wae_last_block_processed, current_month, network_id)
+SELECT (SELECT COALESCE(MAX(id), 0) FROM state) + ROW_NUMBER() OVER (ORDER BY network_id_name), 0, 0, 0,
+       (SELECT COALESCE(MAX(current_month), 0) FROM state), network_id_name
+FROM networks
+WHERE network_id_name NOT IN (SELECT network_id FROM state);
+
+COMMIT;
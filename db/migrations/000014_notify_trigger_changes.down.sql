@@ -0,0 +1,9 @@
+BEGIN;
+
+DROP TRIGGER IF EXISTS users_notify_cap ON users;
+DROP TRIGGER IF EXISTS triggers_notify_insert_delete ON triggers;
+DROP TRIGGER IF EXISTS triggers_notify_update ON triggers;
+DROP FUNCTION IF EXISTS notify_user_cap_change();
+DROP FUNCTION IF EXISTS notify_trigger_change();
+
+COMMIT;
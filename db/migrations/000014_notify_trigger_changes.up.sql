@@ -0,0 +1,50 @@
+BEGIN;
+
+-- zoroaster keeps the triggers in memory, and refreshes them when they change
+
+CREATE OR REPLACE FUNCTION notify_trigger_change() RETURNS trigger
+AS $notify_trigger_change$
+BEGIN
+IF TG_OP = 'DELETE' THEN
+    PERFORM pg_notify('trigger_changes', OLD.uuid::text);
+ELSE
+    PERFORM pg_notify('trigger_changes', NEW.uuid::text);
+END IF;
+RETURN NULL;
+END;
+$notify_trigger_change$ LANGUAGE plpgsql;
+
+-- the triggered flag and match_c change all the time and don't matter
+CREATE TRIGGER triggers_notify_update
+    AFTER UPDATE ON triggers
+    FOR EACH ROW
+    WHEN (OLD.trigger_data IS DISTINCT FROM NEW.trigger_data
+        OR OLD.is_active IS DISTINCT FROM NEW.is_active
+        OR OLD.network_id IS DISTINCT FROM NEW.network_id
+        OR OLD.user_uuid IS DISTINCT FROM NEW.user_uuid
+        OR OLD.last_fired IS DISTINCT FROM NEW.last_fired)
+    EXECUTE PROCEDURE notify_trigger_change();
+
+CREATE TRIGGER triggers_notify_insert_delete
+    AFTER INSERT OR DELETE ON triggers
+    FOR EACH ROW EXECUTE PROCEDURE notify_trigger_change();
+
+-- users running out of (or getting back) their monthly matches
+CREATE OR REPLACE FUNCTION notify_user_cap_change() RETURNS trigger
+AS $notify_user_cap_change$
+BEGIN
+PERFORM pg_notify('user_changes', json_build_object(
+    'uuid', NEW.uuid,
+    'over_cap', NEW.counter_current_month >= NEW.actions_monthly_cap)::text);
+RETURN NULL;
+END;
+$notify_user_cap_change$ LANGUAGE plpgsql;
+
+CREATE TRIGGER users_notify_cap
+    AFTER UPDATE ON users
+    FOR EACH ROW
+    WHEN ((OLD.counter_current_month >= OLD.actions_monthly_cap)
+        IS DISTINCT FROM (NEW.counter_current_month >= NEW.actions_monthly_cap))
+    EXECUTE PROCEDURE notify_user_cap_change();
+
+COMMIT;
@@ -0,0 +1,18 @@
+BEGIN;
+
+DROP TRIGGER IF EXISTS quota_usage_notify_cap ON quota_usage;
+DROP FUNCTION IF EXISTS notify_quota_cap_change();
+
+DROP TABLE IF EXISTS quota_usage;
+DROP TABLE IF EXISTS quota_limits;
+
+ALTER TABLE users ALTER COLUMN counter_current_month DROP DEFAULT;
+
+CREATE TRIGGER users_notify_cap
+    AFTER UPDATE ON users
+    FOR EACH ROW
+    WHEN ((OLD.counter_current_month >= OLD.actions_monthly_cap)
+        IS DISTINCT FROM (NEW.counter_current_month >= NEW.actions_monthly_cap))
+    EXECUTE PROCEDURE notify_user_cap_change();
+
+COMMIT;
@@ -0,0 +1,65 @@
+BEGIN;
+
+-- quotas count successful actions, per user, action type and calendar window.
+-- users.actions_monthly_cap is the default limit for all the actions of a month ('*', 'month');
+-- everything else is set here.
+CREATE TABLE IF NOT EXISTS quota_limits (
+    user_uuid uuid NOT NULL REFERENCES users (uuid) ON DELETE CASCADE,
+    action_type text NOT NULL,
+    span text NOT NULL CHECK (span IN ('day', 'month')),
+    hard_limit integer NOT NULL,
+    soft_limit integer,
+    PRIMARY KEY (user_uuid, action_type, span)
+);
+
+-- period_start is the (UTC) day, or first day of the month, the window is about;
+-- a new window simply starts with a new row, so nothing has to be reset.
+CREATE TABLE IF NOT EXISTS quota_usage (
+    user_uuid uuid NOT NULL REFERENCES users (uuid) ON DELETE CASCADE,
+    action_type text NOT NULL,
+    span text NOT NULL CHECK (span IN ('day', 'month')),
+    period_start date NOT NULL,
+    used integer NOT NULL DEFAULT 0,
+    warned boolean NOT NULL DEFAULT false,
+    PRIMARY KEY (user_uuid, action_type, span, period_start)
+);
+
+-- carry over what's been used this month
+INSERT INTO quota_usage (user_uuid, action_type, span, period_start, used)
+SELECT uuid, '*', 'month', date_trunc('month', NOW() AT TIME ZONE 'UTC')::date, counter_current_month
+FROM users
+WHERE counter_current_month > 0;
+
+-- counter_current_month and state.current_month aren't used anymore
+ALTER TABLE users ALTER COLUMN counter_current_month SET DEFAULT 0;
+
+-- users running out of (or getting back) their monthly actions
+DROP TRIGGER IF EXISTS users_notify_cap ON users;
+
+CREATE OR REPLACE FUNCTION notify_quota_cap_change() RETURNS trigger
+AS $notify_quota_cap_change$
+DECLARE
+    cap integer;
+BEGIN
+SELECT COALESCE(ql.hard_limit, u.actions_monthly_cap) INTO cap
+    FROM users AS u
+    LEFT JOIN quota_limits AS ql ON ql.user_uuid = u.uuid AND ql.action_type = '*' AND ql.span = 'month'
+    WHERE u.uuid = NEW.user_uuid;
+IF TG_OP = 'INSERT' THEN
+    IF NEW.used >= cap THEN
+        PERFORM pg_notify('user_changes', json_build_object('uuid', NEW.user_uuid, 'over_cap', true)::text);
+    END IF;
+ELSIF (OLD.used >= cap) IS DISTINCT FROM (NEW.used >= cap) THEN
+    PERFORM pg_notify('user_changes', json_build_object('uuid', NEW.user_uuid, 'over_cap', NEW.used >= cap)::text);
+END IF;
+RETURN NULL;
+END;
+$notify_quota_cap_change$ LANGUAGE plpgsql;
+
+CREATE TRIGGER quota_usage_notify_cap
+    AFTER INSERT OR UPDATE ON quota_usage
+    FOR EACH ROW
+    WHEN (NEW.action_type = '*' AND NEW.span = 'month')
+    EXECUTE PROCEDURE notify_quota_cap_change();
+
+COMMIT;
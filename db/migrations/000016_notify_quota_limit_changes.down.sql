@@ -0,0 +1,12 @@
+BEGIN;
+
+DROP TRIGGER IF EXISTS users_notify_monthly_cap ON users;
+DROP TRIGGER IF EXISTS quota_limits_notify_delete ON quota_limits;
+DROP TRIGGER IF EXISTS quota_limits_notify_update ON quota_limits;
+DROP TRIGGER IF EXISTS quota_limits_notify_insert ON quota_limits;
+
+DROP FUNCTION IF EXISTS notify_monthly_cap_change();
+DROP FUNCTION IF EXISTS notify_quota_limit_change();
+DROP FUNCTION IF EXISTS notify_user_over_cap(uuid);
+
+COMMIT;
@@ -0,0 +1,66 @@
+BEGIN;
+
+-- users also go over (or back under) their monthly cap when the cap itself changes:
+-- the '*' monthly limit in quota_limits, or actions_monthly_cap if there's none
+
+CREATE OR REPLACE FUNCTION notify_user_over_cap(user_id uuid) RETURNS void
+AS $notify_user_over_cap$
+BEGIN
+PERFORM pg_notify('user_changes', json_build_object(
+    'uuid', u.uuid,
+    'over_cap', COALESCE(qu.used, 0) >= COALESCE(ql.hard_limit, u.actions_monthly_cap))::text)
+    FROM users AS u
+    LEFT JOIN quota_limits AS ql ON ql.user_uuid = u.uuid AND ql.action_type = '*' AND ql.span = 'month'
+    LEFT JOIN quota_usage AS qu ON qu.user_uuid = u.uuid AND qu.action_type = '*' AND qu.span = 'month'
+        AND qu.period_start = date_trunc('month', NOW() AT TIME ZONE 'UTC')::date
+    WHERE u.uuid = user_id;
+END;
+$notify_user_over_cap$ LANGUAGE plpgsql;
+
+CREATE OR REPLACE FUNCTION notify_quota_limit_change() RETURNS trigger
+AS $notify_quota_limit_change$
+BEGIN
+IF TG_OP IN ('UPDATE', 'DELETE') THEN
+    PERFORM notify_user_over_cap(OLD.user_uuid);
+END IF;
+IF TG_OP = 'INSERT' OR (TG_OP = 'UPDATE' AND OLD.user_uuid IS DISTINCT FROM NEW.user_uuid) THEN
+    PERFORM notify_user_over_cap(NEW.user_uuid);
+END IF;
+RETURN NULL;
+END;
+$notify_quota_limit_change$ LANGUAGE plpgsql;
+
+CREATE OR REPLACE FUNCTION notify_monthly_cap_change() RETURNS trigger
+AS $notify_monthly_cap_change$
+BEGIN
+PERFORM notify_user_over_cap(NEW.uuid);
+RETURN NULL;
+END;
+$notify_monthly_cap_change$ LANGUAGE plpgsql;
+
+-- a user's rows go away with the user, and there's nobody left to notify then
+CREATE TRIGGER quota_limits_notify_insert
+    AFTER INSERT ON quota_limits
+    FOR EACH ROW
+    WHEN (NEW.action_type = '*' AND NEW.span = 'month')
+    EXECUTE PROCEDURE notify_quota_limit_change();
+
+CREATE TRIGGER quota_limits_notify_update
+    AFTER UPDATE ON quota_limits
+    FOR EACH ROW
+    WHEN ((OLD.action_type = '*' AND OLD.span = 'month') OR (NEW.action_type = '*' AND NEW.span = 'month'))
+    EXECUTE PROCEDURE notify_quota_limit_change();
+
+CREATE TRIGGER quota_limits_notify_delete
+    AFTER DELETE ON quota_limits
+    FOR EACH ROW
+    WHEN (OLD.action_type = '*' AND OLD.span = 'month')
+    EXECUTE PROCEDURE notify_quota_limit_change();
+
+CREATE TRIGGER users_notify_monthly_cap
+    AFTER UPDATE ON users
+    FOR EACH ROW
+    WHEN (OLD.actions_monthly_cap IS DISTINCT FROM NEW.actions_monthly_cap)
+    EXECUTE PROCEDURE notify_monthly_cap_change();
+
+COMMIT;
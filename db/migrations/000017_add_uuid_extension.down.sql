@@ -0,0 +1,5 @@
+BEGIN;
+
+-- the extension stays: the uuid columns of the baseline tables default to uuid_generate_v4()
+
+COMMIT;
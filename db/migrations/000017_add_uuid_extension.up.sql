@@ -0,0 +1,8 @@
+BEGIN;
+
+-- uuid_generate_v4() lives here; the baseline tables rely on it, so fresh dbs get it
+-- before the first migration runs (see MigrateUp), and older ones had it set up by hand
+
+CREATE EXTENSION IF NOT EXISTS "uuid-ossp";
+
+COMMIT;
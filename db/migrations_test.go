@@ -0,0 +1,23 @@
+package db
+
+import (
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+func TestLoadMigrations(t *testing.T) {
+	migrations, err := loadMigrations()
+	assert.NoError(t, err)
+	assert.True(t, len(migrations) >= 13)
+
+	// versions are sorted, without gaps
+	for i, m := range migrations {
+		assert.Equal(t, i+1, m.version)
+		assert.NotEmpty(t, m.up)
+		assert.NotEmpty(t, m.down)
+	}
+	assert.Equal(t, "baseline_tables", migrations[0].name)
+	assert.True(t, strings.Contains(migrations[0].up, "CREATE TABLE IF NOT EXISTS public.triggers"))
+	assert.Equal(t, migrations[len(migrations)-1].version, LatestSchemaVersion())
+}
@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"github.com/HAL-xyz/zoroaster/config"
+	"github.com/HAL-xyz/zoroaster/tokenapi"
 	"github.com/HAL-xyz/zoroaster/trigger"
 	"github.com/lib/pq"
 	_ "github.com/lib/pq"
@@ -13,61 +14,50 @@ import (
 	"time"
 )
 
-var db *sql.DB
-
 type PostgresClient struct {
-	conf    *config.ZoroDB
-	network string
+	db       *sql.DB
+	conf     *config.ZoroDB
+	network  string
+	tokenApi tokenapi.ITokenAPI // resolves ENS names and expands macros in triggers
 }
 
-func NewPostgresClient(c *config.ZConfiguration) *PostgresClient {
-	client := PostgresClient{}
+func NewPostgresClient(c *config.ZConfiguration, tokenApi tokenapi.ITokenAPI) *PostgresClient {
+	client := PostgresClient{tokenApi: tokenApi}
 	client.initDB(c)
 	return &client
 }
 
-func (cli PostgresClient) UpdateLastFired(tgUUID string, now time.Time) error {
-	q := fmt.Sprintf(`UPDATE "%s" SET last_fired = $1 WHERE uuid = $2`, cli.conf.TableTriggers)
-	_, err := db.Exec(q, now.UTC(), tgUUID)
-	if err != nil {
-		return fmt.Errorf("cannot set last run date to %s for trigger: %s: %s", now, tgUUID, err)
-	}
-	return nil
+// table returns a table name, quoted so that it can be safely used in a query
+func table(name string) string {
+	return pq.QuoteIdentifier(name)
 }
 
-func (cli PostgresClient) UpdateSavedMonth(newMonth int) error {
-	// update current month
-	q := fmt.Sprintf(`UPDATE "%s" SET current_month = $1`, cli.conf.TableState)
-	_, err := db.Exec(q, newMonth)
-	if err != nil {
-		return fmt.Errorf("cannot update current month: %s", err)
+// stateColumns returns the quoted names of the state columns holding
+// the last block processed by a trigger type, and the time it was processed
+func stateColumns(tgType trigger.TgType) (string, string, error) {
+	prefix := trigger.TgTypeToPrefix(tgType)
+	if prefix == "" {
+		return "", "", fmt.Errorf("no state for trigger type %s", trigger.TgTypeToString(tgType))
 	}
-	// reset counter_current_month for all users
-	userQ := fmt.Sprintf(`UPDATE "%s" SET counter_current_month = 0`, cli.conf.TableUsers)
-	_, err = db.Exec(userQ)
-	if err != nil {
-		return fmt.Errorf("cannot reset user counter_current_month: %s", err)
-	}
-	return nil
+	return pq.QuoteIdentifier(prefix + "_last_block_processed"), pq.QuoteIdentifier(prefix + "_date"), nil
 }
 
-func (cli PostgresClient) ReadSavedMonth() (int, error) {
-	var currentMonth int
-	q := fmt.Sprintf(`SELECT current_month FROM %s`, cli.conf.TableState)
-	err := db.QueryRow(q).Scan(&currentMonth)
+func (cli PostgresClient) UpdateLastFired(tgUUID string, now time.Time) error {
+	q := fmt.Sprintf(`UPDATE %s SET last_fired = $1 WHERE uuid = $2`, table(cli.conf.TableTriggers))
+	_, err := cli.db.Exec(q, now.UTC(), tgUUID)
 	if err != nil {
-		return 0, err
+		return fmt.Errorf("cannot set last run date to %s for trigger: %s: %s", now, tgUUID, err)
 	}
-	return currentMonth, nil
+	return nil
 }
 
 func (cli PostgresClient) GetSilentButMatchingTriggers(triggerUUIDs []string) ([]string, error) {
 	q := fmt.Sprintf(
 		`SELECT uuid FROM %s
 			WHERE uuid = ANY($1) 
-			AND triggered = false`, cli.conf.TableTriggers)
+			AND triggered = false`, table(cli.conf.TableTriggers))
 
-	rows, err := db.Query(q, pq.Array(triggerUUIDs))
+	rows, err := cli.db.Query(q, pq.Array(triggerUUIDs))
 	if err != nil {
 		return []string{}, err
 	}
@@ -92,9 +82,9 @@ func (cli PostgresClient) UpdateNonMatchingTriggers(triggerUUIDs []string) {
 	q := fmt.Sprintf(
 		`UPDATE %s
 			SET triggered = false
-			WHERE uuid = ANY($1) AND (triggered = true OR triggered IS NULL)`, cli.conf.TableTriggers)
+			WHERE uuid = ANY($1) AND (triggered = true OR triggered IS NULL)`, table(cli.conf.TableTriggers))
 
-	_, err := db.Exec(q, pq.Array(triggerUUIDs))
+	_, err := cli.db.Exec(q, pq.Array(triggerUUIDs))
 
 	if err != nil {
 		log.Errorf("cannot update non-matching triggers: %s", err)
@@ -105,9 +95,9 @@ func (cli PostgresClient) UpdateMatchingTriggers(triggerUUIDs []string) {
 	q := fmt.Sprintf(
 		`UPDATE %s
 			SET triggered = true
-			WHERE uuid = ANY($1) AND (triggered = false OR triggered IS NULL)`, cli.conf.TableTriggers)
+			WHERE uuid = ANY($1) AND (triggered = false OR triggered IS NULL)`, table(cli.conf.TableTriggers))
 
-	_, err := db.Exec(q, pq.Array(triggerUUIDs))
+	_, err := cli.db.Exec(q, pq.Array(triggerUUIDs))
 
 	if err != nil {
 		log.Errorf("cannot update matching triggers: %s", err)
@@ -121,9 +111,9 @@ func (cli PostgresClient) LogOutcome(outcome *trigger.Outcome, matchUUID string)
 			"payload_data",
 			"outcome_data",
 			"created_at",
-			"success") VALUES ($1::uuid, $2, $3, $4, $5)`, cli.conf.TableOutcomes)
+			"success") VALUES ($1::uuid, $2, $3, $4, $5)`, table(cli.conf.TableOutcomes))
 
-	_, err := db.Exec(q, matchUUID, outcome.Payload, outcome.Outcome, time.Now(), outcome.Success)
+	_, err := cli.db.Exec(q, matchUUID, outcome.Payload, outcome.Outcome, time.Now(), outcome.Success)
 	if err != nil {
 		return fmt.Errorf("cannot log outcome with payload: %s; outcome: %s; error: %s", outcome.Payload, outcome.Outcome, err)
 	}
@@ -138,8 +128,8 @@ func (cli PostgresClient) GetActions(tgUUID string, userUUID string) ([]string,
 				AND tg_table.uuid = act_table.trigger_uuid
 				AND tg_table.uuid = $2::uuid
 				AND act_table.is_active = true`,
-		cli.conf.TableTriggers, cli.conf.TableActions)
-	rows, err := db.Query(q, userUUID, tgUUID)
+		table(cli.conf.TableTriggers), table(cli.conf.TableActions))
+	rows, err := cli.db.Query(q, userUUID, tgUUID)
 	if err != nil {
 		return nil, err
 	}
@@ -161,12 +151,16 @@ func (cli PostgresClient) GetActions(tgUUID string, userUUID string) ([]string,
 }
 
 func (cli PostgresClient) ReadLastBlockProcessed(tgType trigger.TgType) (int, error) {
+	blockCol, _, err := stateColumns(tgType)
+	if err != nil {
+		return 0, fmt.Errorf("cannot read last block processed: %s", err)
+	}
 	var blockNo int
 	q := fmt.Sprintf(
-		`SELECT %s_last_block_processed
+		`SELECT %s
 			FROM %s
-		    WHERE network_id = '%s'`, trigger.TgTypeToPrefix(tgType), cli.conf.TableState, cli.network)
-	err := db.QueryRow(q).Scan(&blockNo)
+		    WHERE network_id = $1`, blockCol, table(cli.conf.TableState))
+	err = cli.db.QueryRow(q, cli.network).Scan(&blockNo)
 	if err != nil {
 		return 0, fmt.Errorf("cannot read last block processed: %s", err)
 	}
@@ -174,11 +168,14 @@ func (cli PostgresClient) ReadLastBlockProcessed(tgType trigger.TgType) (int, er
 }
 
 func (cli PostgresClient) SetLastBlockProcessed(blockNo int, tgType trigger.TgType) error {
-	stringTgType := trigger.TgTypeToPrefix(tgType)
-	q := fmt.Sprintf(`UPDATE "%s" 
-		SET %s_last_block_processed = $1, %s_date = $2
-	    WHERE network_id = '%s'`, cli.conf.TableState, stringTgType, stringTgType, cli.network)
-	_, err := db.Exec(q, blockNo, time.Now())
+	blockCol, dateCol, err := stateColumns(tgType)
+	if err != nil {
+		return fmt.Errorf("cannot set last block processed: %s", err)
+	}
+	q := fmt.Sprintf(`UPDATE %s
+		SET %s = $1, %s = $2
+	    WHERE network_id = $3`, table(cli.conf.TableState), blockCol, dateCol)
+	_, err = cli.db.Exec(q, blockNo, time.Now(), cli.network)
 	if err != nil {
 		return fmt.Errorf("cannot set last block processed: %s", err)
 	}
@@ -190,77 +187,292 @@ func (cli PostgresClient) LogMatch(match trigger.IMatch) error {
 	if err != nil {
 		return err
 	}
+	// matches don't count towards quotas, successful actions do (see ReserveQuota)
 	q := fmt.Sprintf(
-		`INSERT INTO "%s" (
+		`INSERT INTO %s (
 			"trigger_uuid", "match_data", "created_at")
-			VALUES ($1, $2, $3) RETURNING uuid`, cli.conf.TableMatches)
+			VALUES ($1, $2, $3) RETURNING uuid`, table(cli.conf.TableMatches))
 	var lastUUID string
-	err = db.QueryRow(q, match.GetTriggerUUID(), strings.ReplaceAll(string(matchData), "\\u0000", ""), time.Now()).Scan(&lastUUID)
+	err = cli.db.QueryRow(q, match.GetTriggerUUID(), strings.ReplaceAll(string(matchData), "\\u0000", ""), time.Now()).Scan(&lastUUID)
 	if err != nil {
 		return err
 	}
 	match.SetMatchUUID(lastUUID)
-	// also update user's counter
-	upQ := fmt.Sprintf(`UPDATE "%s"
-                SET counter_current_month = counter_current_month + 1 
-				WHERE uuid = '%s' `, cli.conf.TableUsers, match.GetUserUUID())
-	_, err = db.Exec(upQ)
-	if err != nil {
-		return err
-	}
 	return nil
 }
 
 func (cli PostgresClient) LoadTriggersFromDB(tgType trigger.TgType) ([]*trigger.Trigger, error) {
+	overCapQ, overCapArgs := cli.usersOverCapQuery(3)
 	q := fmt.Sprintf(
 		`SELECT tg_table.uuid, trigger_data, user_uuid, COALESCE(last_fired, '2000-01-01 00:00:00+00')
-				FROM %s AS tg_table, %s AS usr_table
-				WHERE (tg_table.trigger_data ->> 'TriggerType')::text = '%s'
-				AND tg_table.user_uuid = usr_table.uuid
-				AND counter_current_month < actions_monthly_cap
+				FROM %s AS tg_table
+				WHERE (tg_table.trigger_data ->> 'TriggerType')::text = $1
+				AND tg_table.user_uuid NOT IN (%s)
 				AND tg_table.is_active = true
-                AND tg_table.network_id = '%s'`, cli.conf.TableTriggers, cli.conf.TableUsers, trigger.TgTypeToString(tgType), cli.network)
-	rows, err := db.Query(q)
+                AND tg_table.network_id = $2`, table(cli.conf.TableTriggers), overCapQ)
+	args := append([]interface{}{trigger.TgTypeToString(tgType), cli.network}, overCapArgs...)
+	triggers, _, err := cli.queryTriggers(q, args...)
+	return triggers, err
+}
+
+// LoadActiveTriggersFromDB loads the active triggers of every type, including the ones of users over their cap;
+// the uuids of the ones whose ENS names can't be resolved right now are returned separately
+func (cli PostgresClient) LoadActiveTriggersFromDB() ([]*trigger.Trigger, []string, error) {
+	q := fmt.Sprintf(
+		`SELECT uuid, trigger_data, user_uuid, COALESCE(last_fired, '2000-01-01 00:00:00+00')
+				FROM %s
+				WHERE is_active = true
+				AND network_id = $1`, table(cli.conf.TableTriggers))
+	triggers, unresolved, err := cli.queryTriggers(q, cli.network)
+	if err != nil {
+		return nil, nil, err
+	}
+	return triggers, unresolvedUUIDs(unresolved), nil
+}
+
+// LoadActiveTriggerFromDB loads a single trigger, if it's (still) active; nil otherwise.
+// A trigger.ENSError is returned if its ENS names can't be resolved right now.
+func (cli PostgresClient) LoadActiveTriggerFromDB(tgUUID string) (*trigger.Trigger, error) {
+	q := fmt.Sprintf(
+		`SELECT uuid, trigger_data, user_uuid, COALESCE(last_fired, '2000-01-01 00:00:00+00')
+				FROM %s
+				WHERE is_active = true
+				AND network_id = $1
+				AND uuid = $2::uuid`, table(cli.conf.TableTriggers))
+	triggers, unresolved, err := cli.queryTriggers(q, cli.network, tgUUID)
+	if err != nil {
+		return nil, err
+	}
+	if err, ok := unresolved[tgUUID]; ok {
+		return nil, err
+	}
+	if len(triggers) == 0 {
+		return nil, nil
+	}
+	return triggers[0], nil
+}
+
+// LoadUsersOverCap returns the users who have run all the actions they're allowed this month
+func (cli PostgresClient) LoadUsersOverCap() ([]string, error) {
+	q, args := cli.usersOverCapQuery(1)
+	rows, err := cli.db.Query(q, args...)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
+	uuids := make([]string, 0)
+	for rows.Next() {
+		var uuid string
+		if err = rows.Scan(&uuid); err != nil {
+			return nil, err
+		}
+		uuids = append(uuids, uuid)
+	}
+	return uuids, rows.Err()
+}
+
+// usersOverCapQuery selects the uuids of the users over their monthly cap this month;
+// its arguments are numbered from argNo on, and come after the ones of the enclosing query
+func (cli PostgresClient) usersOverCapQuery(argNo int) (string, []interface{}) {
+	q := fmt.Sprintf(
+		`SELECT usr.uuid
+				FROM %s AS usr
+				JOIN %s AS qu ON qu.user_uuid = usr.uuid
+					AND qu.action_type = $%d AND qu.span = $%d AND qu.period_start = $%d
+				LEFT JOIN %s AS ql ON ql.user_uuid = usr.uuid
+					AND ql.action_type = qu.action_type AND ql.span = qu.span
+				WHERE qu.used >= COALESCE(ql.hard_limit, usr.actions_monthly_cap)`,
+		table(cli.conf.TableUsers), table(cli.conf.TableQuotaUsage), argNo, argNo+1, argNo+2,
+		table(cli.conf.TableQuotaLimits))
+	return q, []interface{}{AnyAction, string(MonthlyQuota), PeriodStart(MonthlyQuota, time.Now())}
+}
+
+// ReserveQuota counts one more action of the given type, unless that would take the user over one of their limits.
+// Counters are bumped by conditional upserts in a single transaction, so limits hold
+// even when many zoroaster instances are reserving at the same time.
+func (cli PostgresClient) ReserveQuota(userUUID, actionType string, now time.Time) (*QuotaReservation, error) {
+	tx, err := cli.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("cannot reserve quota: %s", err)
+	}
+	reservation, err := cli.reserveQuota(tx, userUUID, actionType, now)
+	if err != nil || !reservation.Allowed {
+		tx.Rollback()
+		return reservation, err
+	}
+	if err = tx.Commit(); err != nil {
+		return nil, fmt.Errorf("cannot reserve quota: %s", err)
+	}
+	return reservation, nil
+}
+
+func (cli PostgresClient) reserveQuota(tx *sql.Tx, userUUID, actionType string, now time.Time) (*QuotaReservation, error) {
+	limits, err := cli.quotaLimits(tx, userUUID, actionType)
+	if err != nil {
+		return nil, fmt.Errorf("cannot reserve quota: %s", err)
+	}
+	// counters without a limit are bumped all the same
+	upsertQ := fmt.Sprintf(
+		`INSERT INTO %s AS qu (user_uuid, action_type, span, period_start, used)
+				VALUES ($1, $2, $3, $4, 1)
+				ON CONFLICT (user_uuid, action_type, span, period_start)
+				DO UPDATE SET used = qu.used + 1
+				WHERE $5::integer IS NULL OR qu.used < $5
+				RETURNING used, warned`, table(cli.conf.TableQuotaUsage))
+	warnQ := fmt.Sprintf(
+		`UPDATE %s SET warned = true
+				WHERE user_uuid = $1 AND action_type = $2 AND span = $3 AND period_start = $4`, table(cli.conf.TableQuotaUsage))
+
+	reservation := &QuotaReservation{Allowed: true}
+	for _, key := range quotaKeys(actionType) {
+		limit, limited := limits[key]
+		usage := QuotaUsage{QuotaLimit: limit, PeriodStart: PeriodStart(key.window, now)}
+		usage.ActionType, usage.Window = key.actionType, key.window
+		var hard interface{}
+		if limited {
+			if limit.Hard <= 0 {
+				reservation.Allowed, reservation.Exceeded = false, &usage
+				return reservation, nil
+			}
+			hard = limit.Hard
+		}
+		var warned bool
+		err = tx.QueryRow(upsertQ, userUUID, key.actionType, string(key.window), usage.PeriodStart, hard).Scan(&usage.Used, &warned)
+		if err == sql.ErrNoRows {
+			// the row is there, and it's already at the limit
+			usage.Used = limit.Hard
+			reservation.Allowed, reservation.Exceeded = false, &usage
+			return reservation, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("cannot reserve quota: %s", err)
+		}
+		// the upsert locks the row, so only one reservation can see warned = false
+		if limited && limit.Soft > 0 && usage.Used >= limit.Soft && !warned {
+			if _, err = tx.Exec(warnQ, userUUID, key.actionType, string(key.window), usage.PeriodStart); err != nil {
+				return nil, fmt.Errorf("cannot reserve quota: %s", err)
+			}
+			reservation.Warnings = append(reservation.Warnings, usage)
+		}
+	}
+	return reservation, nil
+}
+
+// quotaLimits returns the limits that apply to an action type, including the default monthly cap
+func (cli PostgresClient) quotaLimits(tx *sql.Tx, userUUID, actionType string) (map[quotaKey]QuotaLimit, error) {
+	var monthlyCap int
+	capQ := fmt.Sprintf(`SELECT actions_monthly_cap FROM %s WHERE uuid = $1`, table(cli.conf.TableUsers))
+	err := tx.QueryRow(capQ, userUUID).Scan(&monthlyCap)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("no user with uuid %s", userUUID)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	q := fmt.Sprintf(
+		`SELECT action_type, span, hard_limit, COALESCE(soft_limit, 0)
+				FROM %s
+				WHERE user_uuid = $1 AND action_type = ANY($2)`, table(cli.conf.TableQuotaLimits))
+	rows, err := tx.Query(q, userUUID, pq.Array([]string{actionType, AnyAction}))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	limits := make([]QuotaLimit, 0)
+	for rows.Next() {
+		var l QuotaLimit
+		if err = rows.Scan(&l.ActionType, &l.Window, &l.Hard, &l.Soft); err != nil {
+			return nil, err
+		}
+		limits = append(limits, l)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return effectiveLimits(limits, monthlyCap), nil
+}
+
+// ReleaseQuota gives back what ReserveQuota took, when the action didn't go through;
+// now must be the same time the quota was reserved at.
+func (cli PostgresClient) ReleaseQuota(userUUID, actionType string, now time.Time) error {
+	q := fmt.Sprintf(
+		`UPDATE %s SET used = used - 1
+				WHERE user_uuid = $1
+				AND action_type = ANY($2)
+				AND ((span = $3 AND period_start = $4) OR (span = $5 AND period_start = $6))
+				AND used > 0`, table(cli.conf.TableQuotaUsage))
+	_, err := cli.db.Exec(q, userUUID, pq.Array([]string{actionType, AnyAction}),
+		string(DailyQuota), PeriodStart(DailyQuota, now), string(MonthlyQuota), PeriodStart(MonthlyQuota, now))
+	if err != nil {
+		return fmt.Errorf("cannot release quota: %s", err)
+	}
+	return nil
+}
+
+func (cli PostgresClient) GetUserEmail(userUUID string) (string, error) {
+	var email string
+	q := fmt.Sprintf(`SELECT email FROM %s WHERE uuid = $1`, table(cli.conf.TableUsers))
+	if err := cli.db.QueryRow(q, userUUID).Scan(&email); err != nil {
+		return "", fmt.Errorf("cannot get email of user %s: %s", userUUID, err)
+	}
+	return email, nil
+}
+
+// queryTriggers expects the rows to be made of uuid, trigger_data, user_uuid, last_fired;
+// triggers that can't be parsed are skipped, the ones whose ENS names can't be resolved
+// are returned apart so they can be retried
+func (cli *PostgresClient) queryTriggers(q string, args ...interface{}) ([]*trigger.Trigger, map[string]error, error) {
+	rows, err := cli.db.Query(q, args...)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
 	triggers := make([]*trigger.Trigger, 0)
+	unresolved := make(map[string]error)
 	for rows.Next() {
 		var triggerUUID, tg, userUUID string
 		var lastFired time.Time
 		err = rows.Scan(&triggerUUID, &tg, &userUUID, &lastFired)
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
-		trig, err := trigger.NewTriggerFromJson(tg)
+		trig, err := trigger.NewTriggerFromJson(tg, cli.tokenApi)
 		if err != nil {
 			log.Warnf("trigger uuid %s: %v", triggerUUID, err)
+			if trigger.IsENSError(err) {
+				unresolved[triggerUUID] = err
+			}
 		} else {
 			trig.TriggerUUID, trig.UserUUID, trig.LastFired = triggerUUID, userUUID, lastFired
 			triggers = append(triggers, trig)
 		}
 	}
 	if err = rows.Err(); err != nil {
-		return nil, err
+		return nil, nil, err
 	}
-	return triggers, nil
+	return triggers, unresolved, nil
 }
 
 func (cli PostgresClient) Close() {
-	err := db.Close()
+	err := cli.db.Close()
 	if err != nil {
 		log.Error(err)
 	}
 }
 
+func dataSourceName(c *config.ZoroDB) string {
+	return fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=disable",
+		c.Host, c.Port, c.User, c.Password, c.Name)
+}
+
 func (cli *PostgresClient) initDB(c *config.ZConfiguration) {
-	psqlInfo := fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=disable",
-		c.Database.Host, c.Database.Port, c.Database.User, c.Database.Password, c.Database.Name)
+	psqlInfo := dataSourceName(&c.Database)
 
-	var err error
-	db, err = sql.Open("postgres", psqlInfo)
+	db, err := sql.Open("postgres", psqlInfo)
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -271,6 +483,43 @@ func (cli *PostgresClient) initDB(c *config.ZConfiguration) {
 		log.Fatal("cannot connect to the DB -> ", err)
 	}
 
+	cli.db = db
 	cli.conf = &c.Database
 	cli.network = c.Network
 }
+
+// ListenForChanges sends a Change every time a trigger is inserted, updated or deleted,
+// or a user goes over (or back under) their monthly cap.
+// If the connection drops, some notifications might be lost, so a full Resync is asked for.
+func (cli PostgresClient) ListenForChanges() (<-chan Change, error) {
+	listener := pq.NewListener(dataSourceName(cli.conf), 10*time.Second, time.Minute, func(ev pq.ListenerEventType, err error) {
+		if err != nil {
+			log.Warnf("db listener: %s", err)
+		}
+	})
+	for _, channel := range []string{triggerChangesChannel, userChangesChannel} {
+		if err := listener.Listen(channel); err != nil {
+			listener.Close()
+			return nil, fmt.Errorf("cannot listen to %s: %s", channel, err)
+		}
+	}
+
+	changes := make(chan Change, 1000)
+	go func() {
+		for n := range listener.Notify {
+			// pq sends nil after a reconnection
+			if n == nil {
+				changes <- Change{Resync: true}
+				continue
+			}
+			change, err := parseNotification(n.Channel, n.Extra)
+			if err != nil {
+				log.Warnf("db listener: %s", err)
+				changes <- Change{Resync: true}
+				continue
+			}
+			changes <- change
+		}
+	}()
+	return changes, nil
+}
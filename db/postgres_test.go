@@ -12,11 +12,13 @@ import (
 	"time"
 )
 
+var testConf = config.NewConfig()
+
 func init() {
-	if config.Zconf.Stage != config.TEST {
+	if testConf.Stage != config.TEST {
 		log.Fatal("$STAGE must be TEST to run db tests")
 	}
-	if !config.Zconf.IsNetworkETHMainnet() {
+	if !testConf.IsNetworkETHMainnet() {
 		log.Fatal("$NETWORK must be 1_eth_mainnet to run tests ")
 	}
 }
@@ -26,7 +28,7 @@ func TestPostgresClient_All(t *testing.T) {
 	// for now I can't be bothered and I'll fit everything in one test,
 	// closing the connection only once, at the end.
 
-	var psqlClient = NewPostgresClient(config.Zconf)
+	var psqlClient = NewPostgresClient(testConf, nil)
 	defer psqlClient.Close()
 
 	// clear up the database
@@ -43,7 +45,7 @@ func TestPostgresClient_All(t *testing.T) {
 	err = psqlClient.SetString("INSERT INTO state(id, network_id, wat_last_block_processed) VALUES (2, '2_eth_rinkeby', 10)")
 
 	// load a User
-	userUUID, err := psqlClient.SaveUser(1000, 1)
+	userUUID, err := psqlClient.SaveUser(1000)
 	assert.NoError(t, err)
 
 	// load two Triggers, one in 1_eth_mainnet (default network), one in 2_eth_rinkeby
@@ -182,7 +184,7 @@ func TestPostgresClient_All(t *testing.T) {
 	/* Test user limits */
 
 	// load a User
-	batmanUUID, err := psqlClient.SaveUser(1, 0)
+	batmanUUID, err := psqlClient.SaveUser(1)
 	assert.NoError(t, err)
 
 	// load a Trigger
@@ -211,7 +213,7 @@ func TestPostgresClient_All(t *testing.T) {
 
 	assert.NotNil(t, batmanTrigger.UserUUID)
 
-	// when logging a match for batmanTriggerUUID, the batman's counter should ++
+	// logging a match for batmanTriggerUUID doesn't use batman's quota
 	batmanMatch := trigger.CnMatch{
 		Trigger:        batmanTrigger,
 		BlockNumber:    1,
@@ -224,9 +226,14 @@ func TestPostgresClient_All(t *testing.T) {
 	err = psqlClient.LogMatch(&batmanMatch)
 	assert.NoError(t, err)
 
-	newCounter, err := psqlClient.ReadString(fmt.Sprintf("SELECT counter_current_month FROM users WHERE uuid = '%s'", batmanTrigger.UserUUID))
+	// running an action does
+	reservation, err := psqlClient.ReserveQuota(batmanUUID, "webhook_post", time.Now())
+	assert.NoError(t, err)
+	assert.True(t, reservation.Allowed)
+
+	used, err := psqlClient.ReadString(fmt.Sprintf("SELECT used FROM quota_usage WHERE user_uuid = '%s' AND action_type = '*' AND span = 'month'", batmanUUID))
 	assert.NoError(t, err)
-	assert.Equal(t, "1", newCounter)
+	assert.Equal(t, "1", used)
 
 	// now this trigger should not be loaded anymore
 	activeTriggers, err = psqlClient.LoadTriggersFromDB(trigger.WaC)
@@ -239,3 +246,60 @@ func TestPostgresClient_All(t *testing.T) {
 	}
 	assert.False(t, utils.IsIn(batmanTriggerUUID, uuids))
 }
+
+func TestPostgresClient_Quota(t *testing.T) {
+	var psqlClient = NewPostgresClient(testConf, nil)
+	defer psqlClient.Close()
+
+	err := psqlClient.TruncateTables([]string{"users"})
+	assert.NoError(t, err)
+
+	testQuota(t, psqlClient)
+}
+
+func TestPostgresClient_CapNotifications(t *testing.T) {
+	var psqlClient = NewPostgresClient(testConf, nil)
+	defer psqlClient.Close()
+
+	err := psqlClient.TruncateTables([]string{"users"})
+	assert.NoError(t, err)
+	changes, err := psqlClient.ListenForChanges()
+	assert.NoError(t, err)
+	userUUID, err := psqlClient.SaveUser(5)
+	assert.NoError(t, err)
+
+	next := func() Change {
+		select {
+		case change := <-changes:
+			return change
+		case <-time.After(5 * time.Second):
+			return Change{}
+		}
+	}
+
+	// the cap changes when the monthly limit for all actions is set...
+	assert.NoError(t, psqlClient.SetQuotaLimit(userUUID, QuotaLimit{ActionType: AnyAction, Window: MonthlyQuota, Hard: 0}))
+	assert.Equal(t, Change{UserUUID: userUUID, OverCap: true}, next())
+
+	// ...removed, so that actions_monthly_cap applies again...
+	_, err = psqlClient.db.Exec(`DELETE FROM quota_limits WHERE user_uuid = $1`, userUUID)
+	assert.NoError(t, err)
+	assert.Equal(t, Change{UserUUID: userUUID, OverCap: false}, next())
+
+	// ...or when actions_monthly_cap changes
+	_, err = psqlClient.db.Exec(`UPDATE users SET actions_monthly_cap = 0 WHERE uuid = $1`, userUUID)
+	assert.NoError(t, err)
+	assert.Equal(t, Change{UserUUID: userUUID, OverCap: true}, next())
+}
+
+func TestStateColumns(t *testing.T) {
+	blockCol, dateCol, err := stateColumns(trigger.WaE)
+	assert.NoError(t, err)
+	assert.Equal(t, `"wae_last_block_processed"`, blockCol)
+	assert.Equal(t, `"wae_date"`, dateCol)
+
+	_, _, err = stateColumns(trigger.CronT)
+	assert.Error(t, err)
+
+	assert.Equal(t, `"users"" WHERE true; --"`, table(`users" WHERE true; --`))
+}
@@ -8,7 +8,7 @@ import (
 // Helper functions used for tests only
 
 func (cli PostgresClient) SetString(query string) error {
-	_, err := db.Exec(query)
+	_, err := cli.db.Exec(query)
 	if err != nil {
 		return fmt.Errorf("cannot set string: %s", err)
 	}
@@ -17,27 +17,40 @@ func (cli PostgresClient) SetString(query string) error {
 
 func (cli PostgresClient) ReadString(query string) (string, error) {
 	var output string
-	err := db.QueryRow(query).Scan(&output)
+	err := cli.db.QueryRow(query).Scan(&output)
 	if err != nil {
 		return "", fmt.Errorf("cannot read string: %s", err)
 	}
 	return output, nil
 }
 
-func (cli PostgresClient) SaveUser(actionsCap int, counterCurrent int) (string, error) {
+func (cli PostgresClient) SaveUser(actionsCap int) (string, error) {
 	q := fmt.Sprintf(
 		`INSERT INTO users (
 			"display_name", 
 			"email", 
 			"actions_monthly_cap",
 			"user_type",
-			"created_at",
-			"counter_current_month") VALUES ($1, $2, $3, $4, $5, $6) RETURNING uuid`)
+			"created_at") VALUES ($1, $2, $3, $4, $5) RETURNING uuid`)
 	var lastUUID string
-	err := db.QueryRow(q, "batman", "email@lol.com", actionsCap, "admin", time.Now(), counterCurrent).Scan(&lastUUID)
+	err := cli.db.QueryRow(q, "batman", "email@lol.com", actionsCap, "admin", time.Now()).Scan(&lastUUID)
 	return lastUUID, err
 }
 
+func (cli PostgresClient) SetQuotaLimit(userUUID string, limit QuotaLimit) error {
+	q := fmt.Sprintf(
+		`INSERT INTO quota_limits (
+			"user_uuid",
+			"action_type",
+			"span",
+			"hard_limit",
+			"soft_limit") VALUES ($1, $2, $3, $4, NULLIF($5, 0))
+			ON CONFLICT (user_uuid, action_type, span)
+			DO UPDATE SET hard_limit = EXCLUDED.hard_limit, soft_limit = EXCLUDED.soft_limit`)
+	_, err := cli.db.Exec(q, userUUID, limit.ActionType, string(limit.Window), limit.Hard, limit.Soft)
+	return err
+}
+
 func (cli PostgresClient) SaveTrigger(triggerData string, isActive, triggered bool, userId string, network string) (string, error) {
 	q := fmt.Sprintf(
 		`INSERT INTO triggers (
@@ -49,7 +62,7 @@ func (cli PostgresClient) SaveTrigger(triggerData string, isActive, triggered bo
 			"user_uuid",
             "network_id") VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING uuid`)
 	var lastUUID string
-	err := db.QueryRow(q, triggerData, isActive, time.Now(), time.Now(), triggered, userId, network).Scan(&lastUUID)
+	err := cli.db.QueryRow(q, triggerData, isActive, time.Now(), time.Now(), triggered, userId, network).Scan(&lastUUID)
 	return lastUUID, err
 }
 
@@ -68,14 +81,14 @@ func (cli PostgresClient) SaveAction(triggerUUID string) (string, error) {
     "URI": "https://webhook.site/3e94a980-cc28-4fb3-8733-8e398e20c066"
   }
 }`
-	err := db.QueryRow(q, actionData, true, triggerUUID, time.Now(), time.Now()).Scan(&lastUUID)
+	err := cli.db.QueryRow(q, actionData, true, triggerUUID, time.Now(), time.Now()).Scan(&lastUUID)
 	return lastUUID, err
 }
 
 func (cli PostgresClient) TruncateTables(tables []string) error {
 	for _, t := range tables {
-		q := fmt.Sprintf(`TRUNCATE table %s CASCADE`, t)
-		_, err := db.Exec(q)
+		q := fmt.Sprintf(`TRUNCATE table %s CASCADE`, table(t))
+		_, err := cli.db.Exec(q)
 		if err != nil {
 			return err
 		}
@@ -0,0 +1,90 @@
+package db
+
+import (
+	"fmt"
+	"time"
+)
+
+// Quotas limit how many actions a user can run, per action type and calendar window.
+// Only successful actions count: a quota is reserved before an action runs,
+// and released if the action fails.
+// The default limit is the user's actions_monthly_cap for all the actions of a month;
+// everything else is set in the quota_limits table (see migration 15).
+
+type QuotaWindow string
+
+const (
+	DailyQuota   QuotaWindow = "day"
+	MonthlyQuota QuotaWindow = "month"
+)
+
+// AnyAction is the action type of the limits that count every action together
+const AnyAction = "*"
+
+// users are warned when they reach this % of their monthly cap, unless a soft limit is set
+const defaultSoftLimitPct = 80
+
+type QuotaLimit struct {
+	ActionType string
+	Window     QuotaWindow
+	Hard       int // actions above this are not run
+	Soft       int // the user is warned once this is reached; 0 means never
+}
+
+// QuotaUsage is how much of a limit has been used in a window
+type QuotaUsage struct {
+	QuotaLimit
+	PeriodStart time.Time
+	Used        int
+}
+
+func (u QuotaUsage) String() string {
+	return fmt.Sprintf("%d/%d %s actions this %s (since %s)",
+		u.Used, u.Hard, u.ActionType, u.Window, u.PeriodStart.Format("2006-01-02"))
+}
+
+type QuotaReservation struct {
+	Allowed  bool
+	Exceeded *QuotaUsage  // the limit that was hit, when not allowed
+	Warnings []QuotaUsage // soft limits reached for the first time in their window
+}
+
+// PeriodStart is the (UTC) calendar date a window starts on: the day itself, or the first of the month
+func PeriodStart(window QuotaWindow, t time.Time) time.Time {
+	t = t.UTC()
+	if window == MonthlyQuota {
+		return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+	}
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+type quotaKey struct {
+	actionType string
+	window     QuotaWindow
+}
+
+// quotaKeys are the counters an action ticks: its own type and any action, daily and monthly
+func quotaKeys(actionType string) []quotaKey {
+	return []quotaKey{
+		{actionType, DailyQuota},
+		{actionType, MonthlyQuota},
+		{AnyAction, DailyQuota},
+		{AnyAction, MonthlyQuota},
+	}
+}
+
+// effectiveLimits adds the default monthly limit to the ones set for a user
+func effectiveLimits(limits []QuotaLimit, monthlyCap int) map[quotaKey]QuotaLimit {
+	byKey := map[quotaKey]QuotaLimit{
+		{AnyAction, MonthlyQuota}: {
+			ActionType: AnyAction,
+			Window:     MonthlyQuota,
+			Hard:       monthlyCap,
+			Soft:       monthlyCap * defaultSoftLimitPct / 100,
+		},
+	}
+	for _, l := range limits {
+		byKey[quotaKey{l.ActionType, l.Window}] = l
+	}
+	return byKey
+}
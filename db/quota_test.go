@@ -0,0 +1,132 @@
+package db
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestPeriodStart(t *testing.T) {
+	newYearsEve := time.Date(2020, 12, 31, 23, 59, 59, 0, time.UTC)
+	assert.Equal(t, time.Date(2020, 12, 31, 0, 0, 0, 0, time.UTC), PeriodStart(DailyQuota, newYearsEve))
+	assert.Equal(t, time.Date(2020, 12, 1, 0, 0, 0, 0, time.UTC), PeriodStart(MonthlyQuota, newYearsEve))
+
+	newYear := newYearsEve.Add(time.Second)
+	assert.Equal(t, time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC), PeriodStart(DailyQuota, newYear))
+	assert.Equal(t, time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC), PeriodStart(MonthlyQuota, newYear))
+
+	// windows are UTC calendar dates, whatever the time zone
+	rome := time.FixedZone("CET", 3600)
+	assert.Equal(t, time.Date(2020, 12, 31, 0, 0, 0, 0, time.UTC), PeriodStart(DailyQuota, time.Date(2021, 1, 1, 0, 30, 0, 0, rome)))
+}
+
+func TestEffectiveLimits(t *testing.T) {
+	limits := effectiveLimits(nil, 100)
+	assert.Equal(t, QuotaLimit{ActionType: AnyAction, Window: MonthlyQuota, Hard: 100, Soft: 80}, limits[quotaKey{AnyAction, MonthlyQuota}])
+
+	limits = effectiveLimits([]QuotaLimit{
+		{ActionType: AnyAction, Window: MonthlyQuota, Hard: 10},
+		{ActionType: "email", Window: DailyQuota, Hard: 5, Soft: 4},
+	}, 100)
+	assert.Len(t, limits, 2)
+	assert.Equal(t, 10, limits[quotaKey{AnyAction, MonthlyQuota}].Hard)
+	assert.Equal(t, 0, limits[quotaKey{AnyAction, MonthlyQuota}].Soft)
+	assert.Equal(t, 5, limits[quotaKey{"email", DailyQuota}].Hard)
+}
+
+// the IDB and helpers needed to test quotas on both clients
+type quotaTestDB interface {
+	IDB
+	SaveUser(actionsCap int) (string, error)
+	SetQuotaLimit(userUUID string, limit QuotaLimit) error
+}
+
+func TestMemoryClient_Quota(t *testing.T) {
+	testQuota(t, NewMemoryClient("1_eth_mainnet", nil))
+}
+
+func testQuota(t *testing.T, cli quotaTestDB) {
+	userUUID, err := cli.SaveUser(5)
+	assert.NoError(t, err)
+	assert.NoError(t, cli.SetQuotaLimit(userUUID, QuotaLimit{ActionType: "email", Window: DailyQuota, Hard: 2, Soft: 2}))
+
+	email, err := cli.GetUserEmail(userUUID)
+	assert.NoError(t, err)
+	assert.Equal(t, "email@lol.com", email)
+
+	day := time.Date(2020, 12, 31, 12, 0, 0, 0, time.UTC)
+
+	// 2 emails a day, with a warning at the second one
+	r, err := cli.ReserveQuota(userUUID, "email", day)
+	assert.NoError(t, err)
+	assert.True(t, r.Allowed)
+	assert.Len(t, r.Warnings, 0)
+
+	r, err = cli.ReserveQuota(userUUID, "email", day)
+	assert.NoError(t, err)
+	assert.True(t, r.Allowed)
+	assert.Len(t, r.Warnings, 1)
+	assert.Equal(t, "email", r.Warnings[0].ActionType)
+	assert.Equal(t, DailyQuota, r.Warnings[0].Window)
+	assert.Equal(t, 2, r.Warnings[0].Used)
+
+	r, err = cli.ReserveQuota(userUUID, "email", day)
+	assert.NoError(t, err)
+	assert.False(t, r.Allowed)
+	assert.Equal(t, "email", r.Exceeded.ActionType)
+	assert.Equal(t, DailyQuota, r.Exceeded.Window)
+
+	// webhooks have their own limits
+	r, err = cli.ReserveQuota(userUUID, "webhook_post", day)
+	assert.NoError(t, err)
+	assert.True(t, r.Allowed)
+
+	// a failed action gives its quota back
+	assert.NoError(t, cli.ReleaseQuota(userUUID, "email", day))
+	r, err = cli.ReserveQuota(userUUID, "email", day)
+	assert.NoError(t, err)
+	assert.True(t, r.Allowed)
+	assert.Len(t, r.Warnings, 0) // only once per window
+
+	// the next day is a new window, even across years
+	nextDay := day.Add(12 * time.Hour)
+	r, err = cli.ReserveQuota(userUUID, "email", nextDay)
+	assert.NoError(t, err)
+	assert.True(t, r.Allowed)
+	assert.Len(t, r.Warnings, 0)
+
+	// back on the 31st, emails are still used up
+	r, err = cli.ReserveQuota(userUUID, "email", day)
+	assert.NoError(t, err)
+	assert.False(t, r.Allowed)
+
+	// and 4 of the 5 December actions are, which is worth a warning
+	r, err = cli.ReserveQuota(userUUID, "webhook_post", day)
+	assert.NoError(t, err)
+	assert.True(t, r.Allowed)
+	assert.Len(t, r.Warnings, 1)
+	assert.Equal(t, AnyAction, r.Warnings[0].ActionType)
+	assert.Equal(t, MonthlyQuota, r.Warnings[0].Window)
+	assert.Equal(t, 4, r.Warnings[0].Used)
+
+	r, err = cli.ReserveQuota(userUUID, "webhook_post", day)
+	assert.NoError(t, err)
+	assert.True(t, r.Allowed)
+
+	// 5 actions in December: that's the monthly cap
+	r, err = cli.ReserveQuota(userUUID, "webhook_post", day)
+	assert.NoError(t, err)
+	assert.False(t, r.Allowed)
+	assert.Equal(t, AnyAction, r.Exceeded.ActionType)
+	assert.Equal(t, MonthlyQuota, r.Exceeded.Window)
+
+	// a limit of 0 means none at all
+	assert.NoError(t, cli.SetQuotaLimit(userUUID, QuotaLimit{ActionType: "twitter", Window: MonthlyQuota, Hard: 0}))
+	r, err = cli.ReserveQuota(userUUID, "twitter", nextDay)
+	assert.NoError(t, err)
+	assert.False(t, r.Allowed)
+	assert.Equal(t, "twitter", r.Exceeded.ActionType)
+
+	_, err = cli.ReserveQuota("4c2a6b5e-0b1e-4c8e-9d59-3b8f1b3c2a11", "email", day)
+	assert.Error(t, err)
+}
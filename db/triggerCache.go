@@ -0,0 +1,391 @@
+package db
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/HAL-xyz/zoroaster/tokenapi"
+	"github.com/HAL-xyz/zoroaster/trigger"
+	log "github.com/sirupsen/logrus"
+	"sort"
+	"sync"
+	"time"
+)
+
+// see migrations 14, 15 and 16
+const (
+	triggerChangesChannel = "trigger_changes"
+	userChangesChannel    = "user_changes"
+)
+
+// triggers whose ENS names can't be resolved are tried again after this long
+const unresolvedRetryInterval = time.Minute
+
+// A Change tells the TriggerCache what has to be refreshed
+type Change struct {
+	TriggerUUID string // a trigger was inserted, updated or deleted
+	UserUUID    string // a user went over, or back under, their monthly cap
+	OverCap     bool
+	Resync      bool // we might have missed something: reload everything
+}
+
+func parseNotification(channel, payload string) (Change, error) {
+	switch channel {
+	case triggerChangesChannel:
+		return Change{TriggerUUID: payload}, nil
+	case userChangesChannel:
+		var user struct {
+			UUID    string `json:"uuid"`
+			OverCap bool   `json:"over_cap"`
+		}
+		if err := json.Unmarshal([]byte(payload), &user); err != nil {
+			return Change{}, fmt.Errorf("cannot decode %s notification %s: %s", channel, payload, err)
+		}
+		return Change{UserUUID: user.UUID, OverCap: user.OverCap}, nil
+	}
+	return Change{}, fmt.Errorf("unknown channel %s", channel)
+}
+
+// The IDB methods the TriggerCache needs on top of the usual ones
+type ITriggerSource interface {
+	IDB
+
+	// also returns the uuids of the triggers whose ENS names can't be resolved right now
+	LoadActiveTriggersFromDB() ([]*trigger.Trigger, []string, error)
+
+	// returns a trigger.ENSError if the trigger's ENS names can't be resolved right now
+	LoadActiveTriggerFromDB(tgUUID string) (*trigger.Trigger, error)
+
+	LoadUsersOverCap() ([]string, error)
+}
+
+// An ITriggerIndexes keeps the triggers indexed between blocks, see TriggerCache
+type ITriggerIndexes interface {
+	LoadTxIndex() (*trigger.TxIndex, error)
+
+	LoadEventIndex() (*trigger.EventIndex, error)
+}
+
+// LoadTxIndex returns the WaT triggers, indexed; the index is reused if idb keeps one
+func LoadTxIndex(idb IDB) (*trigger.TxIndex, error) {
+	if indexes, ok := idb.(ITriggerIndexes); ok {
+		return indexes.LoadTxIndex()
+	}
+	tgs, err := idb.LoadTriggersFromDB(trigger.WaT)
+	if err != nil {
+		return nil, err
+	}
+	return trigger.NewTxIndex(tgs), nil
+}
+
+// LoadEventIndex returns the WaE triggers, indexed; the index is reused if idb keeps one
+func LoadEventIndex(idb IDB) (*trigger.EventIndex, error) {
+	if indexes, ok := idb.(ITriggerIndexes); ok {
+		return indexes.LoadEventIndex()
+	}
+	tgs, err := idb.LoadTriggersFromDB(trigger.WaE)
+	if err != nil {
+		return nil, err
+	}
+	return trigger.NewEventIndex(tgs), nil
+}
+
+// TriggerCache is an IDB that keeps the active triggers in memory, already parsed,
+// instead of loading them from the db for every block.
+// It's kept up to date by the Changes sent to Run; everything but LoadTriggersFromDB
+// goes straight to the underlying db.
+type TriggerCache struct {
+	ITriggerSource
+	triggers     map[string]*trigger.Trigger
+	unresolved   map[string]bool // ENS names couldn't be resolved: the last good version is kept, if any
+	usersOverCap map[string]bool
+	month        time.Time // the users over cap are the ones of this month
+	loaded       bool
+	version      int                 // bumped on every change, so indexes built in the meantime are thrown away
+	txIndex      *trigger.TxIndex    // built on first use after a change
+	eventIndex   *trigger.EventIndex // same
+	sync.RWMutex
+}
+
+func NewTriggerCache(source ITriggerSource) *TriggerCache {
+	return &TriggerCache{
+		ITriggerSource: source,
+		triggers:       map[string]*trigger.Trigger{},
+		unresolved:     map[string]bool{},
+		usersOverCap:   map[string]bool{},
+	}
+}
+
+// Run applies the changes as they come, and reloads everything every resyncInterval
+// just in case some were lost.
+// Triggers with ENS names are reloaded as often as the names are looked up again,
+// and the ones that couldn't be resolved are retried every unresolvedRetryInterval.
+func (c *TriggerCache) Run(changes <-chan Change, resyncInterval time.Duration) {
+	ticker := time.NewTicker(resyncInterval)
+	defer ticker.Stop()
+	retryTicker := time.NewTicker(unresolvedRetryInterval)
+	defer retryTicker.Stop()
+	ensTicker := time.NewTicker(tokenapi.ENSRefreshInterval)
+	defer ensTicker.Stop()
+	for {
+		select {
+		case change, ok := <-changes:
+			if !ok {
+				return
+			}
+			if err := c.Apply(change); err != nil {
+				log.Errorf("cannot refresh trigger cache: %s", err)
+			}
+		case <-ticker.C:
+			if err := c.Resync(); err != nil {
+				log.Errorf("cannot resync trigger cache: %s", err)
+			}
+		case <-retryTicker.C:
+			c.retryUnresolved()
+		case <-ensTicker.C:
+			c.refreshENS()
+		}
+	}
+}
+
+// Resync reloads all the triggers and users
+func (c *TriggerCache) Resync() error {
+	tgs, unresolvedUUIDs, err := c.ITriggerSource.LoadActiveTriggersFromDB()
+	if err != nil {
+		return err
+	}
+	users, err := c.ITriggerSource.LoadUsersOverCap()
+	if err != nil {
+		return err
+	}
+	triggers := make(map[string]*trigger.Trigger, len(tgs))
+	for _, tg := range tgs {
+		parseABI(tg)
+		triggers[tg.TriggerUUID] = tg
+	}
+	usersOverCap := make(map[string]bool, len(users))
+	for _, u := range users {
+		usersOverCap[u] = true
+	}
+
+	c.Lock()
+	defer c.Unlock()
+	unresolved := make(map[string]bool, len(unresolvedUUIDs))
+	for _, uuid := range unresolvedUUIDs {
+		unresolved[uuid] = true
+		if tg, ok := c.triggers[uuid]; ok {
+			triggers[uuid] = tg
+		}
+	}
+	c.triggers, c.unresolved, c.usersOverCap, c.loaded = triggers, unresolved, usersOverCap, true
+	c.month = PeriodStart(MonthlyQuota, time.Now())
+	c.changed()
+	log.Debugf("trigger cache: loaded %d triggers", len(triggers))
+	return nil
+}
+
+// Apply refreshes what a single Change is about
+func (c *TriggerCache) Apply(change Change) error {
+	if change.Resync {
+		return c.Resync()
+	}
+	if change.UserUUID != "" {
+		c.Lock()
+		if change.OverCap {
+			c.usersOverCap[change.UserUUID] = true
+		} else {
+			delete(c.usersOverCap, change.UserUUID)
+		}
+		c.changed()
+		c.Unlock()
+	}
+	if change.TriggerUUID != "" {
+		tg, err := c.ITriggerSource.LoadActiveTriggerFromDB(change.TriggerUUID)
+		if trigger.IsENSError(err) {
+			// keep the last good version until the names can be resolved again
+			c.Lock()
+			c.unresolved[change.TriggerUUID] = true
+			c.Unlock()
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		c.Lock()
+		delete(c.unresolved, change.TriggerUUID)
+		old := c.triggers[change.TriggerUUID]
+		if tg == nil {
+			// deleted, disabled, or moved to another network
+			delete(c.triggers, change.TriggerUUID)
+		} else {
+			parseABI(tg)
+			c.triggers[tg.TriggerUUID] = tg
+		}
+		if isIndexed(old) || isIndexed(tg) {
+			c.changed()
+		}
+		c.Unlock()
+	}
+	return nil
+}
+
+// retryUnresolved reloads the triggers whose ENS names couldn't be resolved
+func (c *TriggerCache) retryUnresolved() {
+	c.RLock()
+	uuids := make([]string, 0, len(c.unresolved))
+	for uuid := range c.unresolved {
+		uuids = append(uuids, uuid)
+	}
+	c.RUnlock()
+	c.reload(uuids)
+}
+
+// refreshENS reloads the triggers with ENS names, as they might point somewhere else by now
+func (c *TriggerCache) refreshENS() {
+	c.RLock()
+	uuids := make([]string, 0)
+	for uuid, tg := range c.triggers {
+		if len(tg.ENSNames()) > 0 {
+			uuids = append(uuids, uuid)
+		}
+	}
+	c.RUnlock()
+	c.reload(uuids)
+}
+
+func (c *TriggerCache) reload(uuids []string) {
+	for _, uuid := range uuids {
+		if err := c.Apply(Change{TriggerUUID: uuid}); err != nil {
+			log.Errorf("cannot reload trigger %s: %s", uuid, err)
+		}
+	}
+}
+
+// drops the indexes; the caller holds the lock
+func (c *TriggerCache) changed() {
+	c.version++
+	c.txIndex, c.eventIndex = nil, nil
+}
+
+// a new month starts with everyone under their cap
+func (c *TriggerCache) resyncIfStale() error {
+	c.RLock()
+	stale := !c.loaded || !c.month.Equal(PeriodStart(MonthlyQuota, time.Now()))
+	c.RUnlock()
+	if stale {
+		return c.Resync()
+	}
+	return nil
+}
+
+// LoadTriggersFromDB returns the cached triggers, with the same filters as PostgresClient
+func (c *TriggerCache) LoadTriggersFromDB(tgType trigger.TgType) ([]*trigger.Trigger, error) {
+	if err := c.resyncIfStale(); err != nil {
+		return nil, err
+	}
+
+	c.RLock()
+	defer c.RUnlock()
+	triggers := make([]*trigger.Trigger, 0)
+	for _, tg := range c.triggers {
+		if tg.TriggerType != trigger.TgTypeToString(tgType) || c.usersOverCap[tg.UserUUID] {
+			continue
+		}
+		// matchers get their own copy, the parsed ABI is shared
+		tgCopy := *tg
+		triggers = append(triggers, &tgCopy)
+	}
+	sort.Slice(triggers, func(i, j int) bool {
+		return triggers[i].TriggerUUID < triggers[j].TriggerUUID
+	})
+	return triggers, nil
+}
+
+// LoadTxIndex returns the WaT triggers of LoadTriggersFromDB, indexed;
+// the index is only rebuilt when the triggers change.
+func (c *TriggerCache) LoadTxIndex() (*trigger.TxIndex, error) {
+	if err := c.resyncIfStale(); err != nil {
+		return nil, err
+	}
+	c.RLock()
+	idx, version := c.txIndex, c.version
+	c.RUnlock()
+	if idx != nil {
+		return idx, nil
+	}
+
+	tgs, err := c.LoadTriggersFromDB(trigger.WaT)
+	if err != nil {
+		return nil, err
+	}
+	idx = trigger.NewTxIndex(tgs)
+	c.Lock()
+	if c.version == version {
+		c.txIndex = idx
+	}
+	c.Unlock()
+	return idx, nil
+}
+
+// LoadEventIndex returns the WaE triggers of LoadTriggersFromDB, indexed;
+// the index is only rebuilt when the triggers change.
+func (c *TriggerCache) LoadEventIndex() (*trigger.EventIndex, error) {
+	if err := c.resyncIfStale(); err != nil {
+		return nil, err
+	}
+	c.RLock()
+	idx, version := c.eventIndex, c.version
+	c.RUnlock()
+	if idx != nil {
+		return idx, nil
+	}
+
+	tgs, err := c.LoadTriggersFromDB(trigger.WaE)
+	if err != nil {
+		return nil, err
+	}
+	idx = trigger.NewEventIndex(tgs)
+	c.Lock()
+	if c.version == version {
+		c.eventIndex = idx
+	}
+	c.Unlock()
+	return idx, nil
+}
+
+// UpdateLastFired updates the cache right away, so that cron triggers
+// don't fire twice while the notification is on its way.
+// Matchers only ever get copies of the cached triggers, and the indexes don't
+// look at LastFired, so the trigger is updated in place and the indexes are kept.
+func (c *TriggerCache) UpdateLastFired(tgUUID string, now time.Time) error {
+	if err := c.ITriggerSource.UpdateLastFired(tgUUID, now); err != nil {
+		return err
+	}
+	c.Lock()
+	defer c.Unlock()
+	if tg, ok := c.triggers[tgUUID]; ok {
+		tg.LastFired = now.UTC()
+	}
+	return nil
+}
+
+// only WaT and WaE triggers end up in the indexes
+func isIndexed(tg *trigger.Trigger) bool {
+	return tg != nil && (tg.TriggerType == trigger.TgTypeToString(trigger.WaT) || tg.TriggerType == trigger.TgTypeToString(trigger.WaE))
+}
+
+// not every trigger has an ABI, so errors are left to the matchers
+func parseABI(tg *trigger.Trigger) {
+	if tg.ContractABI != "" || tg.ContractAdd != "" {
+		_ = tg.ParseABI()
+	}
+}
+
+// the uuids of the triggers that failed to load, sorted
+func unresolvedUUIDs(unresolved map[string]error) []string {
+	uuids := make([]string, 0, len(unresolved))
+	for uuid := range unresolved {
+		uuids = append(uuids, uuid)
+	}
+	sort.Strings(uuids)
+	return uuids
+}
@@ -0,0 +1,240 @@
+package db
+
+import (
+	"fmt"
+	"github.com/HAL-xyz/zoroaster/tokenapi"
+	"github.com/HAL-xyz/zoroaster/trigger"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestTriggerCache(t *testing.T) {
+
+	memClient := NewMemoryClient("1_eth_mainnet", nil)
+	cache := NewTriggerCache(memClient)
+
+	wacSrc, err := ioutil.ReadFile("../resources/triggers/wac-uniswap.json")
+	assert.NoError(t, err)
+	waeSrc, err := ioutil.ReadFile("../resources/triggers/ev1.json")
+	assert.NoError(t, err)
+
+	userUUID, err := memClient.SaveUser(2)
+	assert.NoError(t, err)
+	wacUUID, err := memClient.SaveTrigger(string(wacSrc), true, false, userUUID, "1_eth_mainnet")
+	assert.NoError(t, err)
+
+	// loaded on first use
+	tgs, err := cache.LoadTriggersFromDB(trigger.WaC)
+	assert.NoError(t, err)
+	assert.Len(t, tgs, 1)
+
+	// the db isn't asked again until something changes
+	waeUUID, err := memClient.SaveTrigger(string(waeSrc), true, false, userUUID, "1_eth_mainnet")
+	assert.NoError(t, err)
+	tgs, err = cache.LoadTriggersFromDB(trigger.WaE)
+	assert.NoError(t, err)
+	assert.Len(t, tgs, 0)
+
+	assert.NoError(t, cache.Apply(Change{TriggerUUID: waeUUID}))
+	tgs, err = cache.LoadTriggersFromDB(trigger.WaE)
+	assert.NoError(t, err)
+	assert.Len(t, tgs, 1)
+	assert.Equal(t, waeUUID, tgs[0].TriggerUUID)
+
+	// users over their cap
+	assert.NoError(t, cache.Apply(Change{UserUUID: userUUID, OverCap: true}))
+	tgs, err = cache.LoadTriggersFromDB(trigger.WaC)
+	assert.NoError(t, err)
+	assert.Len(t, tgs, 0)
+	assert.NoError(t, cache.Apply(Change{UserUUID: userUUID, OverCap: false}))
+	tgs, err = cache.LoadTriggersFromDB(trigger.WaC)
+	assert.NoError(t, err)
+	assert.Len(t, tgs, 1)
+
+	// last fired is updated right away, in the db too
+	lastFired := time.Date(2021, time.Month(6), 1, 0, 0, 0, 0, time.UTC)
+	assert.NoError(t, cache.UpdateLastFired(wacUUID, lastFired))
+	tgs, err = cache.LoadTriggersFromDB(trigger.WaC)
+	assert.NoError(t, err)
+	assert.Equal(t, lastFired, tgs[0].LastFired)
+	tgs, err = memClient.LoadTriggersFromDB(trigger.WaC)
+	assert.NoError(t, err)
+	assert.Equal(t, lastFired, tgs[0].LastFired)
+
+	// deleted triggers
+	memClient.Lock()
+	memClient.triggers[wacUUID].isActive = false
+	memClient.Unlock()
+	assert.NoError(t, cache.Apply(Change{TriggerUUID: wacUUID}))
+	tgs, err = cache.LoadTriggersFromDB(trigger.WaC)
+	assert.NoError(t, err)
+	assert.Len(t, tgs, 0)
+
+	// a full resync
+	memClient.Reset()
+	changes := make(chan Change)
+	go cache.Run(changes, time.Hour)
+	changes <- Change{Resync: true}
+	close(changes)
+	assert.Eventually(t, func() bool {
+		tgs, err := cache.LoadTriggersFromDB(trigger.WaE)
+		return err == nil && len(tgs) == 0
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestTriggerCacheIndexes(t *testing.T) {
+
+	memClient := NewMemoryClient("1_eth_mainnet", nil)
+	cache := NewTriggerCache(memClient)
+
+	waeSrc, err := ioutil.ReadFile("../resources/triggers/ev1.json")
+	assert.NoError(t, err)
+	watSrc, err := ioutil.ReadFile("../resources/triggers/t1.json")
+	assert.NoError(t, err)
+	userUUID, err := memClient.SaveUser(2)
+	assert.NoError(t, err)
+	_, err = memClient.SaveTrigger(string(waeSrc), true, false, userUUID, "1_eth_mainnet")
+	assert.NoError(t, err)
+	_, err = memClient.SaveTrigger(string(watSrc), true, false, userUUID, "1_eth_mainnet")
+	assert.NoError(t, err)
+	txIdx, err := LoadTxIndex(cache)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, txIdx.Len())
+
+	// built once, and reused until the triggers change
+	idx, err := LoadEventIndex(cache)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, idx.Len())
+	again, err := LoadEventIndex(cache)
+	assert.NoError(t, err)
+	assert.Same(t, idx, again)
+
+	waeUUID, err := memClient.SaveTrigger(string(waeSrc), true, false, userUUID, "1_eth_mainnet")
+	assert.NoError(t, err)
+	assert.NoError(t, cache.Apply(Change{TriggerUUID: waeUUID}))
+	again, err = LoadEventIndex(cache)
+	assert.NoError(t, err)
+	assert.NotSame(t, idx, again)
+	assert.Equal(t, 2, again.Len())
+	txAgain, err := LoadTxIndex(cache)
+	assert.NoError(t, err)
+	assert.NotSame(t, txIdx, txAgain)
+
+	// firing a trigger, or changing one that isn't indexed, doesn't throw the indexes away
+	wacSrc, err := ioutil.ReadFile("../resources/triggers/wac-uniswap.json")
+	assert.NoError(t, err)
+	wacUUID, err := memClient.SaveTrigger(string(wacSrc), true, false, userUUID, "1_eth_mainnet")
+	assert.NoError(t, err)
+	assert.NoError(t, cache.Apply(Change{TriggerUUID: wacUUID}))
+	assert.NoError(t, cache.UpdateLastFired(waeUUID, time.Now()))
+	assert.NoError(t, cache.UpdateLastFired(wacUUID, time.Now()))
+	idx, err = LoadEventIndex(cache)
+	assert.NoError(t, err)
+	assert.Same(t, again, idx)
+
+	// without a cache it's built every time
+	idx, err = LoadEventIndex(memClient)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, idx.Len())
+}
+
+// resolves every ENS name to address, or fails with err
+type mockENSTokenAPI struct {
+	tokenapi.ITokenAPI
+	address string
+	err     error
+	sync.Mutex
+}
+
+func (m *mockENSTokenAPI) ResolveENS(name string) (string, error) {
+	m.Lock()
+	defer m.Unlock()
+	return m.address, m.err
+}
+
+func (m *mockENSTokenAPI) set(address string, err error) {
+	m.Lock()
+	defer m.Unlock()
+	m.address, m.err = address, err
+}
+
+func TestTriggerCacheENS(t *testing.T) {
+
+	tokenApi := &mockENSTokenAPI{address: "0xd8da6bf26964af9d7eed9e03e53415d37aa96045"}
+	memClient := NewMemoryClient("1_eth_mainnet", tokenApi)
+	cache := NewTriggerCache(memClient)
+
+	src := `{
+  "TriggerName": "from vitalik.eth",
+  "TriggerType": "WatchTransactions",
+  "Filters": [
+    {
+      "FilterType": "BasicFilter",
+      "ParameterName": "From",
+      "Condition": {"Predicate": "Eq", "Attribute": "vitalik.eth"}
+    }
+  ]
+}`
+	from := func(tgs []*trigger.Trigger) string {
+		return tgs[0].Filters[0].Condition.(trigger.ConditionFrom).Attribute
+	}
+
+	userUUID, err := memClient.SaveUser(2)
+	assert.NoError(t, err)
+	tgUUID, err := memClient.SaveTrigger(src, true, false, userUUID, "1_eth_mainnet")
+	assert.NoError(t, err)
+	tgs, err := cache.LoadTriggersFromDB(trigger.WaT)
+	assert.NoError(t, err)
+	assert.Len(t, tgs, 1)
+	assert.Equal(t, "0xd8da6bf26964af9d7eed9e03e53415d37aa96045", from(tgs))
+
+	// the last good version is kept while the names can't be resolved
+	tokenApi.set("", fmt.Errorf("connection refused"))
+	cache.refreshENS()
+	assert.NoError(t, cache.Resync())
+	tgs, err = cache.LoadTriggersFromDB(trigger.WaT)
+	assert.NoError(t, err)
+	assert.Len(t, tgs, 1)
+	assert.Equal(t, map[string]bool{tgUUID: true}, cache.unresolved)
+
+	// new triggers show up once they can be resolved
+	newUUID, err := memClient.SaveTrigger(src, true, false, userUUID, "1_eth_mainnet")
+	assert.NoError(t, err)
+	assert.NoError(t, cache.Apply(Change{TriggerUUID: newUUID}))
+	tgs, err = cache.LoadTriggersFromDB(trigger.WaT)
+	assert.NoError(t, err)
+	assert.Len(t, tgs, 1)
+
+	tokenApi.set("0x983110309620d911731ac0932219af06091b6744", nil)
+	cache.retryUnresolved()
+	tgs, err = cache.LoadTriggersFromDB(trigger.WaT)
+	assert.NoError(t, err)
+	assert.Len(t, tgs, 2)
+	assert.Equal(t, "0x983110309620d911731ac0932219af06091b6744", from(tgs))
+	assert.Empty(t, cache.unresolved)
+
+	// and follow the names when they're refreshed
+	tokenApi.set("0xb8c2c29ee19d8307cb7255e1cd9cbde883a267d5", nil)
+	cache.refreshENS()
+	tgs, err = cache.LoadTriggersFromDB(trigger.WaT)
+	assert.NoError(t, err)
+	assert.Equal(t, "0xb8c2c29ee19d8307cb7255e1cd9cbde883a267d5", from(tgs))
+}
+
+func TestParseNotification(t *testing.T) {
+	change, err := parseNotification(triggerChangesChannel, "d8ff5a9c-7e2e-4f2b-8c63-8e7e0c1d2b3a")
+	assert.NoError(t, err)
+	assert.Equal(t, Change{TriggerUUID: "d8ff5a9c-7e2e-4f2b-8c63-8e7e0c1d2b3a"}, change)
+
+	change, err = parseNotification(userChangesChannel, `{"uuid" : "d8ff5a9c-7e2e-4f2b-8c63-8e7e0c1d2b3a", "over_cap" : true}`)
+	assert.NoError(t, err)
+	assert.Equal(t, Change{UserUUID: "d8ff5a9c-7e2e-4f2b-8c63-8e7e0c1d2b3a", OverCap: true}, change)
+
+	_, err = parseNotification(userChangesChannel, "nope")
+	assert.Error(t, err)
+	_, err = parseNotification("nope", "")
+	assert.Error(t, err)
+}
@@ -0,0 +1,32 @@
+package engine
+
+import (
+	"github.com/HAL-xyz/zoroaster/tokenapi"
+	"github.com/HAL-xyz/zoroaster/trigger"
+	log "github.com/sirupsen/logrus"
+)
+
+// the code of a new contract is only fetched for matches of triggers watching deployments,
+// which by then have been narrowed down to contract creations by the trigger's other filters
+func filterTxMatchesByDeployment(block *tokenapi.Block, matches []*trigger.TxMatch, api tokenapi.ITokenAPI) []*trigger.TxMatch {
+	var validMatches []*trigger.TxMatch
+	for _, m := range matches {
+		if !m.Tg.WatchesDeployments() {
+			validMatches = append(validMatches, m)
+			continue
+		}
+		if m.Deployment == nil {
+			continue
+		}
+		code, err := api.GetRPCCli().EthGetCode(m.Deployment.Address, block.Number)
+		if err != nil {
+			log.Warnf("cannot fetch code of contract %s: %s", m.Deployment.Address, err)
+			continue
+		}
+		m.Deployment.SetCode(code)
+		if m.Tg.ValidateDeployment(m.Deployment, block.Number, api.GetRPCCli()) {
+			validMatches = append(validMatches, m)
+		}
+	}
+	return validMatches
+}
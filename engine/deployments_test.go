@@ -0,0 +1,81 @@
+package engine
+
+import (
+	"github.com/HAL-xyz/zoroaster/tokenapi"
+	"github.com/HAL-xyz/zoroaster/trigger"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+// ETHRPC Client mock, every tx in the block created a contract
+type mockDeploymentsCli struct {
+	tokenapi.IEthRpc
+	codes map[string]string
+}
+
+func (cli mockDeploymentsCli) EthGetBlockReceipts(block *tokenapi.Block, txHashes []string) ([]tokenapi.TxReceipt, error) {
+	var receipts []tokenapi.TxReceipt
+	for _, hash := range txHashes {
+		receipts = append(receipts, tokenapi.TxReceipt{TransactionHash: hash, Status: 1, ContractAddress: "0xc0" + hash[4:42]})
+	}
+	return receipts, nil
+}
+
+func (cli mockDeploymentsCli) EthGetCode(address string, blockNo int) (string, error) {
+	if code, ok := cli.codes[address]; ok {
+		return code, nil
+	}
+	return "0x", nil
+}
+
+func TestFilterTxMatchesByDeployment(t *testing.T) {
+
+	block, err := trigger.GetBlockFromFile("../resources/blocks/block1.json")
+	assert.NoError(t, err)
+
+	// two contract creations from the same deployer
+	creator := block.Transactions[0].From
+	block.Transactions[0].To = ""
+	block.Transactions[1].To, block.Transactions[1].From = "", creator
+	deployed0 := "0xc0" + block.Transactions[0].Hash[4:42]
+	deployed1 := "0xc0" + block.Transactions[1].Hash[4:42]
+
+	tg, err := trigger.NewTriggerFromJson(`
+{
+  "TriggerName": "transferFrom() deployed by a watched deployer",
+  "TriggerType": "WatchTransactions",
+  "Filters": [
+    {
+      "FilterType": "DeploymentFilter",
+      "ParameterName": "Creator",
+      "Condition": {"Predicate": "Eq", "Attribute": "`+creator+`"}
+    },
+    {
+      "FilterType": "DeploymentFilter",
+      "ParameterName": "ContainsSelector",
+      "Condition": {"Predicate": "Eq", "Attribute": "0x23b872dd"}
+    }
+  ]
+}`, nil)
+	assert.NoError(t, err)
+
+	// the second contract is gone by the end of the block
+	api := tokenapi.MustNew(mockDeploymentsCli{codes: map[string]string{deployed0: "0x608060406323b872dd14"}})
+	matches := trigger.MatchTransaction(tg, block, api)
+	assert.Len(t, matches, 2)
+
+	matches = filterTxMatchesByReceipt(block, matches, api)
+	assert.Len(t, matches, 2)
+	assert.Equal(t, deployed1, matches[1].Deployment.Address)
+
+	matches = filterTxMatchesByDeployment(block, matches, api)
+	assert.Len(t, matches, 1)
+	assert.Equal(t, deployed0, matches[0].Deployment.Address)
+	assert.Equal(t, creator, matches[0].Deployment.Creator)
+	assert.NotEmpty(t, matches[0].Deployment.BytecodeHash)
+
+	// other triggers aren't affected
+	t2, err := trigger.GetTriggerFromFile("../resources/triggers/t2.json")
+	assert.NoError(t, err)
+	assert.Len(t, filterTxMatchesByDeployment(block, trigger.MatchTransaction(t2, block, api), api), 2)
+}
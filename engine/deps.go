@@ -0,0 +1,28 @@
+package engine
+
+import "github.com/HAL-xyz/zoroaster/tokenapi"
+
+// TokenAPI is all the engine needs from the outside world: the node (see RPC), token metadata,
+// ENS names and fiat prices (see PriceSource). tokenapi.New builds one on top of an RPC,
+// and tokenapi.WithPriceSources replaces the default price sources; bad options are returned
+// as errors, nothing in the engine exits the process.
+type TokenAPI = tokenapi.ITokenAPI
+
+// RPC is the node a TokenAPI reads from; tokenapi.NewZRPC is the JSON-RPC implementation.
+// Evaluate only uses:
+//   - MakeEthRpcCall, for WaC triggers and for token metadata and on-chain prices
+//   - EthGetBlockReceipts, for WaT and WaE triggers with receipt filters; without receipts, those don't match
+//   - EthGetCode, for WaT triggers with deployment filters
+//   - DebugTraceBlock, for WaT triggers that include internal txs
+//
+// The other methods can be left unimplemented.
+type RPC = tokenapi.IEthRpc
+
+// Block is what triggers are evaluated against: RPC.EthGetBlockByNumber returns one, tokenapi.DecodeBlock
+// decodes one from a JSON-RPC response, and tokenapi.NewBlock wraps an ethrpc.Block.
+// Blocks built by hand must set the base fee and the typed fields of their txs, or fee filters won't match them.
+type Block = tokenapi.Block
+
+// PriceSource gives the fiat price of a token, e.g. to evaluate currency conditions or toFiat in templates;
+// sources are tried in order until one knows the price.
+type PriceSource = tokenapi.PriceSource
@@ -0,0 +1,129 @@
+// Package engine evaluates zoroaster triggers without the rest of zoroaster: no poller, no Postgres,
+// no SES and no init-time side effects, so that other services (e.g. an indexer) can embed it.
+//
+//	api, err := tokenapi.New(tokenapi.NewZRPC(node, "indexer"))
+//	tg, err := engine.ParseTrigger(triggerJson, api)
+//	matches, err := engine.Evaluate(tg, block, logs, api)
+//	for _, m := range matches {
+//		text, err := engine.Render("{{ .Tx.Hash }} matched", m, api)
+//	}
+//
+// Everything that reaches the chain or the prices goes through a TokenAPI; see deps.go.
+package engine
+
+import (
+	"fmt"
+	"github.com/HAL-xyz/ethrpc"
+	"github.com/HAL-xyz/zoroaster/action"
+	"github.com/HAL-xyz/zoroaster/tokenapi"
+	"github.com/HAL-xyz/zoroaster/trigger"
+	log "github.com/sirupsen/logrus"
+)
+
+// ParseTrigger reads a trigger from its JSON; api resolves ENS names and expands macros
+// like $all_erc20_tokens, and can be nil if the trigger uses neither.
+func ParseTrigger(json string, api TokenAPI) (*trigger.Trigger, error) {
+	return trigger.NewTriggerFromJson(json, api)
+}
+
+// Evaluate matches a WaT, WaE or WaC trigger against a block, at the prices of that block.
+// WaT needs the block's txs, and WaE the block's logs; logs are ignored by the other types.
+// Every call is stateless: unlike the zoroaster service, a WaC trigger matches on every block
+// its condition holds, and it's up to the caller to check how often it's due (see Trigger.IsDue).
+func Evaluate(tg *trigger.Trigger, block *Block, logs []ethrpc.Log, api TokenAPI) ([]trigger.IMatch, error) {
+	var matches []trigger.IMatch
+	switch tg.TriggerType {
+	case trigger.TgTypeToString(trigger.WaT):
+		for _, m := range MatchTransactions(trigger.NewTxIndex([]*trigger.Trigger{tg}), block, api) {
+			matches = append(matches, m)
+		}
+	case trigger.TgTypeToString(trigger.WaE):
+		for _, m := range MatchEvents(trigger.NewEventIndex([]*trigger.Trigger{tg}), block, logs, api) {
+			matches = append(matches, m)
+		}
+	case trigger.TgTypeToString(trigger.WaC):
+		m, err := MatchContract(tg, block, api)
+		if err != nil {
+			return nil, err
+		}
+		if m != nil {
+			matches = append(matches, m)
+		}
+	default:
+		return nil, fmt.Errorf("cannot evaluate %s triggers", tg.TriggerType)
+	}
+	return matches, nil
+}
+
+// Render renders a (v2) template with the data of a match, the same way actions are rendered
+func Render(template string, match trigger.IMatch, api TokenAPI) (string, error) {
+	return action.RenderTemplateWithData(template, match.ToTemplateMatch(), api)
+}
+
+// MatchTransactions matches the txs of a block, and their internal txs for the triggers that want them,
+// against the indexed WaT triggers; receipt and deployment filters are checked too.
+// The index only changes with the triggers, so it's built once and reused for every block.
+func MatchTransactions(index *trigger.TxIndex, block *tokenapi.Block, api tokenapi.ITokenAPI) []*trigger.TxMatch {
+	traces := traceBlockIfNeeded(index.Triggers(), block, api)
+
+	// currency conditions use the prices at this block
+	blockApi := tokenapi.AtBlock(api, block.Number, block.Timestamp)
+	// every tx only goes to the triggers that might match it
+	matches := index.MatchTransactions(block, blockApi)
+	if traces != nil {
+		for _, tg := range index.Triggers() {
+			if tg.IncludeInternalTxs {
+				matches = append(matches, trigger.MatchInternalTransactions(tg, block, traces, blockApi)...)
+			}
+		}
+	}
+	matches = filterTxMatchesByReceipt(block, matches, api)
+	return filterTxMatchesByDeployment(block, matches, api)
+}
+
+// MatchEvents matches the logs of a block against the indexed WaE triggers; receipt filters are checked too.
+// The index only changes with the triggers, so it's built once and reused for every block.
+func MatchEvents(index *trigger.EventIndex, block *tokenapi.Block, logs []ethrpc.Log, api tokenapi.ITokenAPI) []*trigger.EventMatch {
+	// currency conditions use the prices at this block
+	blockApi := tokenapi.AtBlock(api, block.Number, block.Timestamp)
+	// every log only goes to the triggers watching its contract and event
+	matches := index.MatchEvents(logs, block.Transactions, blockApi)
+	matches = filterEventMatchesByReceipt(block, matches, api)
+	for _, m := range matches {
+		m.BlockTimestamp = block.Timestamp
+		m.BaseFeePerGas = block.BaseFeePerGas
+	}
+	return matches
+}
+
+// MatchContract calls a WaC trigger's contract at a block; the match is nil if the outputs don't match
+func MatchContract(tg *trigger.Trigger, block *tokenapi.Block, api tokenapi.ITokenAPI) (*trigger.CnMatch, error) {
+	// currency conditions use the prices at this block
+	match, err := trigger.MatchContract(tokenapi.AtBlock(api, block.Number, block.Timestamp), tg, block.Number)
+	if err != nil {
+		return nil, err
+	}
+	if match != nil {
+		match.BlockNumber = block.Number
+		match.BlockTimestamp = block.Timestamp
+		match.BlockHash = block.Hash
+		match.BaseFeePerGas = block.BaseFeePerGas
+	}
+	return match, nil
+}
+
+// tracing a block is expensive, so we only do it if at least one trigger needs internal txs;
+// if the node can't trace the block we carry on with top-level txs only
+func traceBlockIfNeeded(triggers []*trigger.Trigger, block *tokenapi.Block, api tokenapi.ITokenAPI) []tokenapi.CallFrame {
+	for _, tg := range triggers {
+		if tg.IncludeInternalTxs {
+			traces, err := api.GetRPCCli().DebugTraceBlock(block.Number)
+			if err != nil {
+				log.Warnf("cannot trace block %d: %s", block.Number, err)
+				return nil
+			}
+			return traces
+		}
+	}
+	return nil
+}
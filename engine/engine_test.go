@@ -0,0 +1,97 @@
+package engine
+
+import (
+	"github.com/HAL-xyz/zoroaster/tokenapi"
+	"github.com/HAL-xyz/zoroaster/trigger"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"math/big"
+	"testing"
+)
+
+// ETHRPC Client mock, a node that only knows about the DAO
+type mockEngineCli struct {
+	mockReceiptsCli
+}
+
+func (cli mockEngineCli) MakeEthRpcCall(cntAddress, data string, blockNumber int) (string, error) {
+	return "0x0000000000000000000000004a574510c7014e4ae985403536074abe582adfc8", nil
+}
+
+func parseTriggerFile(t *testing.T, path string) *trigger.Trigger {
+	js, err := ioutil.ReadFile(path)
+	assert.NoError(t, err)
+	tg, err := ParseTrigger(string(js), nil)
+	assert.NoError(t, err)
+	return tg
+}
+
+func TestEvaluate(t *testing.T) {
+	api := tokenapi.MustNew(mockEngineCli{})
+
+	block, err := trigger.GetBlockFromFile("../resources/blocks/block1.json")
+	assert.NoError(t, err)
+	logs, err := trigger.GetLogsFromFile("../resources/events/logs1.json")
+	assert.NoError(t, err)
+
+	// WaT
+	matches, err := Evaluate(parseTriggerFile(t, "../resources/triggers/t2.json"), block, nil, api)
+	assert.NoError(t, err)
+	assert.Len(t, matches, 2)
+	rendered, err := Render("{{ .Tx.Hash }} in block {{ .Block.Number }}", matches[0], api)
+	assert.NoError(t, err)
+	assert.Equal(t, matches[0].(*trigger.TxMatch).Tx.Hash+" in block 7535077", rendered)
+
+	// WaE
+	matches, err = Evaluate(parseTriggerFile(t, "../resources/triggers/ev1.json"), block, logs, api)
+	assert.NoError(t, err)
+	assert.Len(t, matches, 1)
+	rendered, err = Render(`{{ index .Contract.EventParameters "value" }} at {{ .Block.Timestamp }}`, matches[0], api)
+	assert.NoError(t, err)
+	assert.Equal(t, "677420000 at 1554828248", rendered)
+
+	// WaC
+	matches, err = Evaluate(parseTriggerFile(t, "../resources/triggers/wac1.json"), block, nil, api)
+	assert.NoError(t, err)
+	assert.Len(t, matches, 1)
+	assert.Equal(t, 7535077, matches[0].(*trigger.CnMatch).BlockNumber)
+	assert.Equal(t, block.Hash, matches[0].(*trigger.CnMatch).BlockHash)
+
+	// other trigger types are up to the caller
+	_, err = Evaluate(&trigger.Trigger{TriggerType: "CronTrigger"}, block, nil, api)
+	assert.Error(t, err)
+}
+
+func TestParseTrigger(t *testing.T) {
+	// ENS names need a TokenAPI to be resolved
+	_, err := ParseTrigger(`
+{
+  "TriggerName": "transfers from vitalik.eth",
+  "TriggerType": "WatchTransactions",
+  "Filters": [
+    {
+      "FilterType": "BasicFilter",
+      "ParameterName": "From",
+      "Condition": {"Predicate": "Eq", "Attribute": "vitalik.eth"}
+    }
+  ]
+}`, nil)
+	assert.Error(t, err)
+
+	_, err = ParseTrigger("def not json", nil)
+	assert.Error(t, err)
+}
+
+// blocks can be built by hand, e.g. from an indexer's own data
+func TestEvaluateWithoutReceipts(t *testing.T) {
+	api := tokenapi.MustNew(mockReceiptsCli{fail: true})
+	logs, err := trigger.GetLogsFromFile("../resources/events/logs1.json")
+	assert.NoError(t, err)
+
+	block := &tokenapi.Block{Number: logs[0].BlockNumber, Hash: logs[0].BlockHash, Timestamp: 1600000000, BaseFeePerGas: big.NewInt(42)}
+	matches, err := Evaluate(parseTriggerFile(t, "../resources/triggers/ev1.json"), block, logs, api)
+	assert.NoError(t, err)
+	assert.Len(t, matches, 1)
+	assert.Equal(t, 1600000000, matches[0].(*trigger.EventMatch).BlockTimestamp)
+	assert.Equal(t, "42", matches[0].ToTemplateMatch().Block.BaseFeePerGas.String())
+}
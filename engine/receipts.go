@@ -0,0 +1,97 @@
+package engine
+
+import (
+	"github.com/HAL-xyz/zoroaster/tokenapi"
+	"github.com/HAL-xyz/zoroaster/trigger"
+	log "github.com/sirupsen/logrus"
+)
+
+// getReceipts maps the given tx hashes to their receipts;
+// it returns nil if there's nothing to fetch, or if the node can't give us the receipts.
+// Receipts the node doesn't have (yet) are left out, so matches that need them are skipped.
+func getReceipts(block *tokenapi.Block, txHashes []string, api tokenapi.ITokenAPI) map[string]*trigger.Receipt {
+	if len(txHashes) == 0 {
+		return nil
+	}
+	receipts, err := api.GetRPCCli().EthGetBlockReceipts(block, txHashes)
+	if err != nil {
+		log.Warnf("cannot fetch receipts for block %d: %s", block.Number, err)
+		return nil
+	}
+	txs := make(map[string]*tokenapi.Transaction, len(block.Transactions))
+	for i := range block.Transactions {
+		txs[block.Transactions[i].Hash] = &block.Transactions[i]
+	}
+	receiptsByHash := make(map[string]*trigger.Receipt, len(receipts))
+	for _, r := range receipts {
+		if tx, ok := txs[r.TransactionHash]; ok {
+			receiptsByHash[r.TransactionHash] = trigger.NewReceipt(r, tx)
+		}
+	}
+	return receiptsByHash
+}
+
+// distinct hashes, in order
+type txHashes struct {
+	seen   map[string]bool
+	hashes []string
+}
+
+func (h *txHashes) add(hash string) {
+	if h.seen == nil {
+		h.seen = make(map[string]bool)
+	}
+	if !h.seen[hash] {
+		h.seen[hash] = true
+		h.hashes = append(h.hashes, hash)
+	}
+}
+
+// receipts are only fetched for the matches of triggers with receipt filters,
+// and for top-level contract creations, which only get their address from the receipt;
+// matches whose tx doesn't satisfy the trigger's receipt filters are dropped
+func filterTxMatchesByReceipt(block *tokenapi.Block, matches []*trigger.TxMatch, api tokenapi.ITokenAPI) []*trigger.TxMatch {
+	var wanted txHashes
+	for _, m := range matches {
+		if m.Tg.NeedsReceipt() || isContractCreation(m) {
+			wanted.add(m.Tx.Hash)
+		}
+	}
+	receipts := getReceipts(block, wanted.hashes, api)
+
+	var validMatches []*trigger.TxMatch
+	for _, m := range matches {
+		m.Receipt = receipts[m.Tx.Hash]
+		if isContractCreation(m) && m.Receipt != nil && m.Receipt.ContractAddress != "" {
+			m.Deployment = &trigger.Deployment{Address: m.Receipt.ContractAddress, Creator: m.Tx.From}
+		}
+		if m.Tg.ValidateReceipt(m.Receipt) {
+			validMatches = append(validMatches, m)
+		}
+	}
+	return validMatches
+}
+
+func isContractCreation(m *trigger.TxMatch) bool {
+	return m.CallDepth == 0 && m.Tx.To == ""
+}
+
+// as above, receipts are only fetched for the matches of triggers with receipt filters
+func filterEventMatchesByReceipt(block *tokenapi.Block, matches []*trigger.EventMatch, api tokenapi.ITokenAPI) []*trigger.EventMatch {
+	var wanted txHashes
+	for _, m := range matches {
+		if m.Tg.NeedsReceipt() {
+			wanted.add(m.Log.TransactionHash)
+		}
+	}
+	receipts := getReceipts(block, wanted.hashes, api)
+
+	var validMatches []*trigger.EventMatch
+	for _, m := range matches {
+		m.Receipt = receipts[m.Log.TransactionHash]
+		if m.Tg.ValidateReceipt(m.Receipt) {
+			validMatches = append(validMatches, m)
+		}
+	}
+	return validMatches
+}
@@ -0,0 +1,79 @@
+package engine
+
+import (
+	"fmt"
+	"github.com/HAL-xyz/zoroaster/tokenapi"
+	"github.com/HAL-xyz/zoroaster/trigger"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+// ETHRPC Client mock, every tx in the block reverted
+type mockReceiptsCli struct {
+	tokenapi.IEthRpc
+	fail      bool
+	requested *[]string
+}
+
+func (cli mockReceiptsCli) EthGetBlockReceipts(block *tokenapi.Block, txHashes []string) ([]tokenapi.TxReceipt, error) {
+	if cli.requested != nil {
+		*cli.requested = append(*cli.requested, txHashes...)
+	}
+	if cli.fail {
+		return nil, fmt.Errorf("receipts not available")
+	}
+	var receipts []tokenapi.TxReceipt
+	for _, hash := range txHashes {
+		receipts = append(receipts, tokenapi.TxReceipt{TransactionHash: hash, Status: 0, GasUsed: 21000})
+	}
+	return receipts, nil
+}
+
+func TestFilterTxMatchesByReceipt(t *testing.T) {
+
+	block, err := trigger.GetBlockFromFile("../resources/blocks/block1.json")
+	assert.NoError(t, err)
+	tg, err := trigger.GetTriggerFromFile("../resources/triggers/t2.json")
+	assert.NoError(t, err)
+
+	var requested []string
+	api := tokenapi.MustNew(mockReceiptsCli{requested: &requested})
+	matches := trigger.MatchTransaction(tg, block, api)
+	assert.Len(t, matches, 2)
+
+	// no receipt filters: no receipts are fetched
+	matches = filterTxMatchesByReceipt(block, matches, api)
+	assert.Len(t, matches, 2)
+	assert.Nil(t, matches[0].Receipt)
+	assert.Len(t, requested, 0)
+
+	// only successful txs: we only ask for the receipts of the matching txs
+	successOnly := *tg
+	successOnly.Filters = append(successOnly.Filters, trigger.Filter{
+		FilterType:    "BasicFilter",
+		ParameterName: "Status",
+		Condition:     trigger.ConditionStatus{Predicate: trigger.Eq, Attribute: 1},
+	})
+	matches = filterTxMatchesByReceipt(block, trigger.MatchTransaction(&successOnly, block, api), api)
+	assert.Len(t, matches, 0)
+	assert.Equal(t, []string{block.Transactions[6].Hash, block.Transactions[8].Hash}, requested)
+
+	// reverted txs
+	revertedOnly := *tg
+	revertedOnly.Filters = append(revertedOnly.Filters, trigger.Filter{
+		FilterType:    "BasicFilter",
+		ParameterName: "Status",
+		Condition:     trigger.ConditionStatus{Predicate: trigger.Eq, Attribute: 0},
+	})
+	matches = filterTxMatchesByReceipt(block, trigger.MatchTransaction(&revertedOnly, block, api), api)
+	assert.Len(t, matches, 2)
+	assert.Equal(t, 21000, matches[0].Receipt.GasUsed)
+
+	// without receipts we can't tell
+	api = tokenapi.MustNew(mockReceiptsCli{fail: true})
+	matches = filterTxMatchesByReceipt(block, trigger.MatchTransaction(tg, block, api), api)
+	assert.Len(t, matches, 2)
+	assert.Nil(t, matches[0].Receipt)
+	matches = filterTxMatchesByReceipt(block, trigger.MatchTransaction(&successOnly, block, api), api)
+	assert.Len(t, matches, 0)
+}
@@ -1,6 +1,6 @@
 module github.com/HAL-xyz/zoroaster
 
-go 1.12
+go 1.16
 
 require (
 	github.com/HAL-xyz/ethrpc v1.0.2
@@ -19,4 +19,5 @@ require (
 	golang.org/x/net v0.0.0-20210331060903-cb1fcc7394e5 // indirect
 	golang.org/x/text v0.3.5 // indirect
 	gopkg.in/h2non/gock.v1 v1.0.16
+	gopkg.in/yaml.v3 v3.0.0-20200313102051-9f266ea9e77c
 )
@@ -1,7 +1,6 @@
 package main
 
 import (
-	"github.com/HAL-xyz/ethrpc"
 	"github.com/HAL-xyz/zoroaster/config"
 	"github.com/HAL-xyz/zoroaster/db"
 	"github.com/HAL-xyz/zoroaster/matcher"
@@ -11,58 +10,119 @@ import (
 	log "github.com/sirupsen/logrus"
 	"net/http"
 	"os"
+	"time"
 )
 
 func main() {
 
+	log.SetOutput(os.Stdout)
+
+	// `config check` reports the configuration errors itself
+	if len(os.Args) > 1 && os.Args[1] == "config" {
+		if err := runConfig(os.Args[2:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	// `migrate` only needs the database settings
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		conf, err := config.LoadDatabase(config.FilePath())
+		if err != nil {
+			log.Fatal(err)
+		}
+		log.SetLevel(conf.LogLevel)
+		if err := runMigrate(conf, os.Args[2:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	conf := config.NewConfig()
+	log.SetLevel(conf.LogLevel)
+
 	// Load AWS SES session
 	sesSession := config.GetSESSession()
 
-	log.SetLevel(config.Zconf.LogLevel)
-	log.SetOutput(os.Stdout)
+	log.Infof("Starting up Zoroaster, stage = %s, network = %s\n", conf.Stage, conf.Network)
 
-	log.Infof("Starting up Zoroaster, stage = %s, network = %s\n", config.Zconf.Stage, config.Zconf.Network)
+	// Templating, shared by all the actions and by the db clients to parse triggers
+	templatingApi := newTokenApi(tokenapi.NewZRPC(conf.EthNode, "templating client"), conf)
+	// the token list is downloaded once, and shared by all the TokenAPIs
+	tokens := tokenapi.WithTokenMap(templatingApi.GetAllERC20TokensMap())
 
-	// Postgres DB client
-	psqlClient := db.NewPostgresClient(config.Zconf)
+	// Postgres DB client, or an in-memory one for local development
+	var psqlClient db.IDB
+	if conf.Database.Host == "memory" {
+		psqlClient = db.NewMemoryClient(conf.Network, templatingApi)
+	} else {
+		pgClient := db.NewPostgresClient(conf, templatingApi)
+		setupSchema(conf, pgClient)
+		// triggers are kept in memory, and refreshed when they change
+		changes, err := pgClient.ListenForChanges()
+		if err != nil {
+			log.Fatal(err)
+		}
+		triggerCache := db.NewTriggerCache(pgClient)
+		go triggerCache.Run(changes, 10*time.Minute)
+		psqlClient = triggerCache
+	}
 
 	// HTTP client
 	httpClient := http.Client{}
 
-	// Run monthly matches update
-	go db.MatchesMonthlyUpdate(psqlClient)
-
 	// Channels are buffered so the poller doesn't stop queueing blocks
 	// if one of the Matcher isn't up (during tests) of if WaC is very slow (which it is)
 	// Another solution would be to have three different pollers, but for now this should do.
-	txBlocksChan := make(chan *ethrpc.Block, 10000)
-	cnBlocksChan := make(chan *ethrpc.Block, 10000)
-	evBlocksChan := make(chan *ethrpc.Block, 10000)
+	txBlocksChan := make(chan *tokenapi.Block, 10000)
+	cnBlocksChan := make(chan *tokenapi.Block, 10000)
+	evBlocksChan := make(chan *tokenapi.Block, 10000)
+	blBlocksChan := make(chan *tokenapi.Block, 10000)
 	matchesChan := make(chan trigger.IMatch)
 
 	// Poll ETH node
-	pollerCli := tokenapi.NewZRPC(config.Zconf.EthNode, "BlocksPoller", tokenapi.WithRetries(4))
-	go poller.BlocksPoller(txBlocksChan, cnBlocksChan, evBlocksChan, pollerCli, psqlClient, config.Zconf.BlocksDelay)
+	pollerCli := tokenapi.NewZRPC(conf.EthNode, "BlocksPoller", tokenapi.WithRetries(4))
+	go poller.BlocksPoller(txBlocksChan, cnBlocksChan, evBlocksChan, blBlocksChan, pollerCli, templatingApi, psqlClient, conf.BlocksDelay, conf.PollingInterval)
 
 	// Watch a Transaction
-	watApi := tokenapi.New(tokenapi.NewZRPC(config.Zconf.EthNode, "Watch a Transaction", tokenapi.WithRetries(4)))
-	go matcher.TxMatcher(txBlocksChan, matchesChan, psqlClient, watApi)
+	watApi := newTokenApi(tokenapi.NewZRPC(conf.EthNode, "Watch a Transaction", tokenapi.WithRetries(4)), conf, tokens)
+	var pendingTxs *matcher.PendingTxs
+	if conf.MempoolInterval > 0 {
+		// pending txs are dropped if they're neither in the mempool nor mined for a while
+		pendingTxs = matcher.NewPendingTxs(conf.PendingTxTimeout())
+		mempoolCli := tokenapi.NewZRPC(conf.EthNode, "Mempool")
+		go matcher.MempoolMatcher(mempoolCli, matchesChan, psqlClient, watApi, pendingTxs, time.Duration(conf.MempoolInterval)*time.Second)
+	}
+	go matcher.TxMatcher(txBlocksChan, matchesChan, psqlClient, watApi, pendingTxs)
 
 	// Watch a Contract
-	wacApi := tokenapi.New(tokenapi.NewZRPC(config.Zconf.EthNode, "Watch a Contract", tokenapi.WithRetries(4)))
-	go matcher.ContractMatcher(cnBlocksChan, matchesChan, psqlClient, wacApi)
+	wacApi := newTokenApi(tokenapi.NewZRPC(conf.EthNode, "Watch a Contract", tokenapi.WithRetries(4)), conf, tokens)
+	go matcher.ContractMatcher(cnBlocksChan, matchesChan, psqlClient, wacApi, conf)
 
 	// Watch an Event
-	waeApi := tokenapi.New(tokenapi.NewZRPC(config.Zconf.EthNode, "Watch an Event", tokenapi.WithRetries(4)))
+	waeApi := newTokenApi(tokenapi.NewZRPC(conf.EthNode, "Watch an Event", tokenapi.WithRetries(4)), conf, tokens)
 	go matcher.EventMatcher(evBlocksChan, matchesChan, psqlClient, waeApi)
 
+	// Watch Blocks
+	wabApi := newTokenApi(tokenapi.NewZRPC(conf.EthNode, "Watch Blocks", tokenapi.WithRetries(4)), conf, tokens)
+	go matcher.BlockMatcher(blBlocksChan, matchesChan, psqlClient, wabApi)
+
 	// Cron Triggers
-	cronApi := tokenapi.New(tokenapi.NewZRPC(config.Zconf.BackupNode, "Cron Trig", tokenapi.WithRetries(4)))
+	cronApi := newTokenApi(tokenapi.NewZRPC(conf.BackupNode, "Cron Trig", tokenapi.WithRetries(4)), conf, tokens)
 	go matcher.CronScheduler(psqlClient, cronApi, matchesChan)
 
 	// Main routine - process matches
 	for {
 		match := <-matchesChan
-		go matcher.ProcessMatch(match, psqlClient, sesSession, &httpClient)
+		go matcher.ProcessMatch(match, psqlClient, sesSession, &httpClient, templatingApi, conf)
+	}
+}
+
+// every TokenAPI is set up with the configured network, token list and price sources
+func newTokenApi(cli tokenapi.IEthRpc, conf *config.ZConfiguration, options ...func(t *tokenapi.TokenAPI)) *tokenapi.TokenAPI {
+	api, err := tokenapi.New(cli, append([]func(t *tokenapi.TokenAPI){tokenapi.WithConfig(conf)}, options...)...)
+	if err != nil {
+		log.Fatal(err)
 	}
+	return api
 }
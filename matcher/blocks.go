@@ -0,0 +1,60 @@
+package matcher
+
+import (
+	"github.com/HAL-xyz/zoroaster/db"
+	"github.com/HAL-xyz/zoroaster/tokenapi"
+	"github.com/HAL-xyz/zoroaster/trigger"
+	log "github.com/sirupsen/logrus"
+	"time"
+)
+
+func BlockMatcher(blocksChan chan *tokenapi.Block, matchesChan chan trigger.IMatch, idb db.IDB, api tokenapi.ITokenAPI) {
+
+	var parent *tokenapi.Block
+	for {
+		block := <-blocksChan
+		start := time.Now()
+
+		triggers, err := idb.LoadTriggersFromDB(trigger.WaB)
+		if err != nil {
+			log.Fatal(err)
+		}
+		parent = getParentBlock(block, parent, api)
+
+		for _, m := range matchBlock(triggers, block, parent) {
+			if err = idb.LogMatch(m); err != nil {
+				log.Fatal(err)
+			}
+			matchesChan <- m
+		}
+		if err = idb.SetLastBlockProcessed(block.Number, trigger.WaB); err != nil {
+			log.Fatal(err)
+		}
+		parent = block
+		log.Infof("BLOCKS: Processed %d triggers in %s from block %d", len(triggers), time.Since(start), block.Number)
+	}
+}
+
+// blocks come in order, so the parent is usually the block we've just processed;
+// we only go to the node on startup or after a reorg
+func getParentBlock(block, lastBlock *tokenapi.Block, api tokenapi.ITokenAPI) *tokenapi.Block {
+	if lastBlock != nil && lastBlock.Hash == block.ParentHash {
+		return lastBlock
+	}
+	parent, err := api.GetRPCCli().EthGetBlockByNumber(block.Number-1, false)
+	if err != nil || parent == nil {
+		log.Fatalf("cannot fetch parent of block %d: %v", block.Number, err)
+	}
+	return parent
+}
+
+func matchBlock(triggers []*trigger.Trigger, block, parent *tokenapi.Block) []*trigger.BlockMatch {
+	var matches []*trigger.BlockMatch
+	stats := trigger.NewBlockStats(block, parent)
+	for _, tg := range triggers {
+		if m := trigger.MatchBlock(tg, block, stats); m != nil {
+			matches = append(matches, m)
+		}
+	}
+	return matches
+}
@@ -0,0 +1,65 @@
+package matcher
+
+import (
+	"github.com/HAL-xyz/zoroaster/tokenapi"
+	"github.com/HAL-xyz/zoroaster/trigger"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+// returns a block for every number, counting the calls
+type mockBlocksCli struct {
+	tokenapi.IEthRpc
+	calls *int
+}
+
+func (cli mockBlocksCli) EthGetBlockByNumber(number int, withTransactions bool) (*tokenapi.Block, error) {
+	*cli.calls++
+	return &tokenapi.Block{Number: number, Hash: "fetched", Timestamp: 1000}, nil
+}
+
+func TestGetParentBlock(t *testing.T) {
+	var calls int
+	api := tokenapi.MustNew(mockBlocksCli{calls: &calls})
+
+	last := &tokenapi.Block{Number: 9, Hash: "0x9", Timestamp: 987}
+	block := &tokenapi.Block{Number: 10, Hash: "0x10", ParentHash: "0x9", Timestamp: 1000}
+
+	// the last block we processed is the parent
+	assert.Equal(t, last, getParentBlock(block, last, api))
+	assert.Equal(t, 0, calls)
+
+	// on startup, or after a reorg, we ask the node
+	parent := getParentBlock(block, nil, api)
+	assert.Equal(t, 9, parent.Number)
+	assert.Equal(t, 1, calls)
+
+	parent = getParentBlock(block, &tokenapi.Block{Number: 9, Hash: "0xuncle"}, api)
+	assert.Equal(t, "fetched", parent.Hash)
+	assert.Equal(t, 2, calls)
+}
+
+func TestMatchBlock(t *testing.T) {
+	block, err := trigger.GetBlockFromFile("../resources/blocks/block1.json")
+	assert.NoError(t, err)
+	parent := &tokenapi.Block{Timestamp: block.Timestamp - 20}
+
+	busy, err := trigger.NewTriggerFromJson(`{
+  "TriggerName": "busy blocks",
+  "TriggerType": "WatchBlocks",
+  "Filters": [{"FilterType": "BlockFilter", "ParameterName": "TxCount", "Condition": {"Predicate": "BiggerThan", "Attribute": "5"}}]
+}`, nil)
+	assert.NoError(t, err)
+	slow, err := trigger.NewTriggerFromJson(`{
+  "TriggerName": "slow blocks",
+  "TriggerType": "WatchBlocks",
+  "Filters": [{"FilterType": "BlockFilter", "ParameterName": "TimestampGap", "Condition": {"Predicate": "BiggerThan", "Attribute": "60"}}]
+}`, nil)
+	assert.NoError(t, err)
+
+	matches := matchBlock([]*trigger.Trigger{busy, slow}, block, parent)
+	assert.Len(t, matches, 1)
+	assert.Equal(t, busy, matches[0].Tg)
+	assert.Equal(t, block.Number, matches[0].BlockNumber)
+	assert.Equal(t, 20, matches[0].Stats.TimestampGap)
+}
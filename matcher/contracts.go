@@ -1,7 +1,6 @@
 package matcher
 
 import (
-	"github.com/HAL-xyz/ethrpc"
 	"github.com/HAL-xyz/zoroaster/config"
 	"github.com/HAL-xyz/zoroaster/db"
 	"github.com/HAL-xyz/zoroaster/tokenapi"
@@ -13,66 +12,62 @@ import (
 )
 
 func ContractMatcher(
-	blocksChan chan *ethrpc.Block,
+	blocksChan chan *tokenapi.Block,
 	matchesChan chan trigger.IMatch,
 	idb db.IDB,
 	tokenApi tokenapi.ITokenAPI,
+	conf *config.ZConfiguration,
 ) {
 
+	// use multicall on every network where we know a multicall contract;
+	// the multicaller is kept across blocks, so the node limits are only discovered once
+	var mc *trigger.Multicaller
+	if mcAddress, ok := trigger.MulticallAddress(conf.Network); ok {
+		mc = trigger.NewMulticaller(mcAddress)
+	}
+
 	for {
 		block := <-blocksChan
 		tokenApi.GetRPCCli().ResetCounterAndLogStats(block.Number - 1)
 		tokenApi.LogFiatStatsAndReset(block.Number - 1)
 
-		if block.Number%config.Zconf.BlocksInterval != 0 {
-			continue
-		}
-
 		start := time.Now()
 
-		triggers, err := idb.LoadTriggersFromDB(trigger.WaC)
-		if err != nil {
-			log.Fatal(err)
-		}
-
+		// every trigger decides how often it's checked, so we go through every block
 		var matches []*trigger.CnMatch
-		// multicall is only supported on eth mainnet atm
-		if config.Zconf.IsNetworkETHMainnet() {
-			matches = matchContractsForBlockMulti(block.Number, idb, tokenApi)
+		if mc != nil {
+			matches = matchContractsForBlockMulti(block.Number, idb, tokenApi, mc, conf.BlocksInterval)
 		} else {
-			matches = matchContractsForBlock(block.Number, idb, tokenApi)
+			matches = matchContractsForBlock(block.Number, idb, tokenApi, conf.BlocksInterval)
 		}
 
-		setBlocksMetadata(matches, block.Number, block.Timestamp, block.Hash)
+		setBlocksMetadata(matches, block)
 		for _, m := range matches {
-			if err = idb.LogMatch(m); err != nil {
+			if err := idb.LogMatch(m); err != nil {
 				log.Fatal(err)
 			}
 			log.Debug("logged one match with id ", m.MatchUUID)
 			matchesChan <- m
 		}
-		if err = idb.SetLastBlockProcessed(block.Number, trigger.WaC); err != nil {
+		if err := idb.SetLastBlockProcessed(block.Number, trigger.WaC); err != nil {
 			log.Fatal(err)
 		}
-		log.Infof("CN: Processed %d triggers in %s from block %d", len(triggers), time.Since(start), block.Number)
+		log.Infof("CN: Processed block %d in %s", block.Number, time.Since(start))
 	}
 }
 
-func matchContractsForBlockMulti(blockNo int, idb db.IDB, api tokenapi.ITokenAPI) []*trigger.CnMatch {
+func matchContractsForBlockMulti(blockNo int, idb db.IDB, api tokenapi.ITokenAPI, mc *trigger.Multicaller, blocksInterval int) []*trigger.CnMatch {
 
 	start := time.Now()
-	tgs, err := idb.LoadTriggersFromDB(trigger.WaC)
-	if err != nil {
-		log.Fatal(err)
-	}
-
-	matches, tgsWithErrors, err := trigger.MatchTriggersMulti(tgs, api, blockNo)
-
-	if err != nil {
-		log.Errorf("mc failed on #%d (%s) - doing nothing", blockNo, err)
+	tgs := loadDueTriggers(idb, blockNo, blocksInterval)
+	if len(tgs) == 0 {
 		return []*trigger.CnMatch{}
 	}
 
+	// currency conditions use the prices at this block
+	// failing calls don't stop the others, they just end up in tgsWithErrors
+	matches, tgsWithErrors := trigger.MatchTriggersMulti(tgs, tokenapi.AtBlock(api, blockNo, 0), blockNo, mc)
+
 	matchesToActUpon := getMatchesToActUpon(idb, matches)
 
 	updateStatusForMatchingTriggers(idb, matches)
@@ -82,13 +77,16 @@ func matchContractsForBlockMulti(blockNo int, idb db.IDB, api tokenapi.ITokenAPI
 	return matchesToActUpon
 }
 
-func matchContractsForBlock(blockNo int, idb db.IDB, tokenApi tokenapi.ITokenAPI) []*trigger.CnMatch {
+func matchContractsForBlock(blockNo int, idb db.IDB, tokenApi tokenapi.ITokenAPI, blocksInterval int) []*trigger.CnMatch {
 
-	allTriggers, err := idb.LoadTriggersFromDB(trigger.WaC)
-	if err != nil {
-		log.Fatal(err)
+	allTriggers := loadDueTriggers(idb, blockNo, blocksInterval)
+	if len(allTriggers) == 0 {
+		return []*trigger.CnMatch{}
 	}
 
+	// currency conditions use the prices at this block
+	blockApi := tokenapi.AtBlock(tokenApi, blockNo, 0)
+
 	const MAX = 3
 	sem := make(chan int, MAX)
 	mu := &sync.Mutex{}
@@ -116,7 +114,7 @@ func matchContractsForBlock(blockNo int, idb db.IDB, tokenApi tokenapi.ITokenAPI
 				log.Debugf("\tCN: Trigger %s matched on block %d\n", tg.TriggerUUID, bNo)
 			}
 			<-sem
-		}(tokenApi, trig, blockNo)
+		}(blockApi, trig, blockNo)
 	}
 	wg.Wait()
 
@@ -129,11 +127,22 @@ func matchContractsForBlock(blockNo int, idb db.IDB, tokenApi tokenapi.ITokenAPI
 	return matchesToActUpon
 }
 
-func setBlocksMetadata(matches []*trigger.CnMatch, blockNo, blockTimestamp int, blockHash string) {
+// only the triggers due on blockNo are matched (and have their status updated);
+// triggers sharing the same key are still resolved with a single call
+func loadDueTriggers(idb db.IDB, blockNo, blocksInterval int) []*trigger.Trigger {
+	tgs, err := idb.LoadTriggersFromDB(trigger.WaC)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return trigger.DueTriggers(tgs, blockNo, blocksInterval)
+}
+
+func setBlocksMetadata(matches []*trigger.CnMatch, block *tokenapi.Block) {
 	for i := range matches {
-		matches[i].BlockNumber = blockNo
-		matches[i].BlockTimestamp = blockTimestamp
-		matches[i].BlockHash = blockHash
+		matches[i].BlockNumber = block.Number
+		matches[i].BlockTimestamp = block.Timestamp
+		matches[i].BlockHash = block.Hash
+		matches[i].BaseFeePerGas = block.BaseFeePerGas
 	}
 }
 
@@ -9,16 +9,23 @@ import (
 	log "github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/assert"
 	"io/ioutil"
+	"os"
 	"testing"
 )
 
-var psqlClient = db.NewPostgresClient(config.Zconf)
+// tests don't depend on the env, except for the node templates are rendered against
+var testConf = &config.ZConfiguration{
+	Stage:          config.TEST,
+	LogLevel:       log.DebugLevel,
+	EthNode:        os.Getenv("ETH_NODE"),
+	Network:        "1_eth_mainnet",
+	BlocksInterval: 1,
+}
+var memDB = db.NewMemoryClient(testConf.Network, nil)
+var templatingApi = tokenapi.MustNew(tokenapi.NewZRPC(testConf.EthNode, "templating test client"), tokenapi.WithConfig(testConf))
 
 func init() {
-	if config.Zconf.Stage != config.TEST {
-		log.Fatal("$STAGE must be TEST to run tests")
-	}
-	log.SetLevel(log.DebugLevel)
+	log.SetLevel(testConf.LogLevel)
 }
 
 type mockDB struct {
@@ -50,16 +57,22 @@ func (db mockDB) GetSilentButMatchingTriggers(triggerUUIDs []string) ([]string,
 
 func TestMatchContractsForBlock(t *testing.T) {
 
-	var api = tokenapi.New(tokenapi.NewZRPC(config.Zconf.EthNode, "mainnet test client"))
+	var api = tokenapi.MustNew(mockDAOCli{})
 
-	lastBlock, err := api.GetRPCCli().EthBlockNumber()
-	assert.NoError(t, err)
-
-	cnMatches := matchContractsForBlock(lastBlock, mockDB{}, api)
+	cnMatches := matchContractsForBlock(12000000, mockDB{}, api, testConf.BlocksInterval)
 
 	assert.Equal(t, 1, len(cnMatches))
 }
 
+// ETHRPC Client mock, returns the DAO creator
+type mockDAOCli struct {
+	tokenapi.IEthRpc
+}
+
+func (cli mockDAOCli) MakeEthRpcCall(cntAddress, data string, blockNumber int) (string, error) {
+	return "0x0000000000000000000000004a574510c7014e4ae985403536074abe582adfc8", nil
+}
+
 // ETHRPC Client mock, returns 189
 type mockETHCli struct {
 	tokenapi.IEthRpc
@@ -87,109 +100,108 @@ func (cli mockETHCliWithError) MakeEthRpcCall(cntAddress, data string, blockNumb
 	return "0x0", fmt.Errorf("some nasty error")
 }
 
-func TestMatchContractsWithRealDB(t *testing.T) {
+func TestMatchContractsWithDB(t *testing.T) {
 
 	// clear up the database
-	err := psqlClient.TruncateTables([]string{"triggers", "matches"})
-	assert.NoError(t, err)
+	memDB.Reset()
 
 	// load a User
-	userUUID, err := psqlClient.SaveUser(100, 0)
+	userUUID, err := memDB.SaveUser(100)
 	assert.NoError(t, err)
 
 	// load two Trigger, different networks
 	triggerSrc, err := ioutil.ReadFile("../resources/triggers/wac-uniswap.json")
 	assert.NoError(t, err)
-	triggerUUID, err := psqlClient.SaveTrigger(string(triggerSrc), true, false, userUUID, "1_eth_mainnet")
+	triggerUUID, err := memDB.SaveTrigger(string(triggerSrc), true, false, userUUID, "1_eth_mainnet")
 	assert.NoError(t, err)
-	rinkebyUUID, err := psqlClient.SaveTrigger(string(triggerSrc), true, false, userUUID, "2_eth_rinkeby")
+	rinkebyUUID, err := memDB.SaveTrigger(string(triggerSrc), true, false, userUUID, "2_eth_rinkeby")
 	assert.NoError(t, err)
 
 	// at creation, triggered=false
-	status, err := psqlClient.ReadString(fmt.Sprintf("SELECT triggered FROM triggers WHERE uuid = '%s'", triggerUUID))
+	triggered, err := memDB.IsTriggered(triggerUUID)
 	assert.NoError(t, err)
-	assert.Equal(t, "false", status)
+	assert.False(t, triggered)
 
 	ethSuccessMock := mockETHCli{}
-	mockTokenApiSuccess := tokenapi.New(ethSuccessMock)
+	mockTokenApiSuccess := tokenapi.MustNew(ethSuccessMock)
 
 	// success
-	cnMatches := matchContractsForBlock(0000, psqlClient, mockTokenApiSuccess)
+	cnMatches := matchContractsForBlock(0000, memDB, mockTokenApiSuccess, testConf.BlocksInterval)
 	assert.Equal(t, 1, len(cnMatches))
 
 	// now trigger status will be triggered=true
-	status, err = psqlClient.ReadString(fmt.Sprintf("SELECT triggered FROM triggers WHERE uuid = '%s'", triggerUUID))
+	triggered, err = memDB.IsTriggered(triggerUUID)
 	assert.NoError(t, err)
-	assert.Equal(t, "true", status)
+	assert.True(t, triggered)
 
 	// ... but only for the trigger on Mainnet; the trigger on Rinkeby should still be false
-	status, err = psqlClient.ReadString(fmt.Sprintf("SELECT triggered FROM triggers WHERE uuid = '%s'", rinkebyUUID))
+	triggered, err = memDB.IsTriggered(rinkebyUUID)
 	assert.NoError(t, err)
-	assert.Equal(t, "false", status)
+	assert.False(t, triggered)
 
 	// just write the matches to the db
 	for _, m := range cnMatches {
-		err := psqlClient.LogMatch(m)
+		err := memDB.LogMatch(m)
 		assert.NoError(t, err)
 		assert.Len(t, m.MatchUUID, 36)
 	}
 
 	// subsequent calls won't match, because triggered is set to true
-	cnMatches = matchContractsForBlock(0000, psqlClient, mockTokenApiSuccess)
+	cnMatches = matchContractsForBlock(0000, memDB, mockTokenApiSuccess, testConf.BlocksInterval)
 	assert.Equal(t, 0, len(cnMatches))
 
 	// trigger is still set to true
-	status, err = psqlClient.ReadString(fmt.Sprintf("SELECT triggered FROM triggers WHERE uuid = '%s'", triggerUUID))
+	triggered, err = memDB.IsTriggered(triggerUUID)
 	assert.NoError(t, err)
-	assert.Equal(t, "true", status)
+	assert.True(t, triggered)
 
 	// ... and the Rinkeby trigger is still set to false
-	status, err = psqlClient.ReadString(fmt.Sprintf("SELECT triggered FROM triggers WHERE uuid = '%s'", rinkebyUUID))
+	triggered, err = memDB.IsTriggered(rinkebyUUID)
 	assert.NoError(t, err)
-	assert.Equal(t, "false", status)
+	assert.False(t, triggered)
 
 	// the rpc call fails and MatchContracts() returns an error; triggered remains true
 	ethErrorMock := mockETHCliWithError{}
-	mockTokenApiError := tokenapi.New(ethErrorMock)
+	mockTokenApiError := tokenapi.MustNew(ethErrorMock)
 
-	cnMatches = matchContractsForBlock(0000, psqlClient, mockTokenApiError)
+	cnMatches = matchContractsForBlock(0000, memDB, mockTokenApiError, testConf.BlocksInterval)
 	assert.Equal(t, 0, len(cnMatches))
 
-	status, err = psqlClient.ReadString(fmt.Sprintf("SELECT triggered FROM triggers WHERE uuid = '%s'", triggerUUID))
+	triggered, err = memDB.IsTriggered(triggerUUID)
 	assert.NoError(t, err)
-	assert.Equal(t, "true", status)
+	assert.True(t, triggered)
 
 	// ... and the Rinkeby trigger is still set to false
-	status, err = psqlClient.ReadString(fmt.Sprintf("SELECT triggered FROM triggers WHERE uuid = '%s'", rinkebyUUID))
+	triggered, err = memDB.IsTriggered(rinkebyUUID)
 	assert.NoError(t, err)
-	assert.Equal(t, "false", status)
+	assert.False(t, triggered)
 
 	// now the eth cli returns a non-matching value, matches should be zero, triggered=false
 	ethNoMatchMock := mockETHCliNoMatch{}
-	mockTokenApiNoMatch := tokenapi.New(ethNoMatchMock)
+	mockTokenApiNoMatch := tokenapi.MustNew(ethNoMatchMock)
 
-	cnMatches = matchContractsForBlock(0000, psqlClient, mockTokenApiNoMatch)
+	cnMatches = matchContractsForBlock(0000, memDB, mockTokenApiNoMatch, testConf.BlocksInterval)
 	assert.Equal(t, 0, len(cnMatches))
 
-	status, err = psqlClient.ReadString(fmt.Sprintf("SELECT triggered FROM triggers WHERE uuid = '%s'", triggerUUID))
+	triggered, err = memDB.IsTriggered(triggerUUID)
 	assert.NoError(t, err)
-	assert.Equal(t, "false", status)
+	assert.False(t, triggered)
 
 	// ... and the Rinkeby trigger is still set to false
-	status, err = psqlClient.ReadString(fmt.Sprintf("SELECT triggered FROM triggers WHERE uuid = '%s'", rinkebyUUID))
+	triggered, err = memDB.IsTriggered(rinkebyUUID)
 	assert.NoError(t, err)
-	assert.Equal(t, "false", status)
+	assert.False(t, triggered)
 
 	// back to success, matches=1, triggered=true
-	cnMatches = matchContractsForBlock(0000, psqlClient, mockTokenApiSuccess)
+	cnMatches = matchContractsForBlock(0000, memDB, mockTokenApiSuccess, testConf.BlocksInterval)
 	assert.Equal(t, 1, len(cnMatches))
 
-	status, err = psqlClient.ReadString(fmt.Sprintf("SELECT triggered FROM triggers WHERE uuid = '%s'", triggerUUID))
+	triggered, err = memDB.IsTriggered(triggerUUID)
 	assert.NoError(t, err)
-	assert.Equal(t, "true", status)
+	assert.True(t, triggered)
 
 	// ... and the Rinkeby trigger is still set to false
-	status, err = psqlClient.ReadString(fmt.Sprintf("SELECT triggered FROM triggers WHERE uuid = '%s'", rinkebyUUID))
+	triggered, err = memDB.IsTriggered(rinkebyUUID)
 	assert.NoError(t, err)
-	assert.Equal(t, "false", status)
+	assert.False(t, triggered)
 }
@@ -2,7 +2,6 @@ package matcher
 
 import (
 	"fmt"
-	"github.com/HAL-xyz/ethrpc"
 	"github.com/HAL-xyz/zoroaster/db"
 	"github.com/HAL-xyz/zoroaster/tokenapi"
 	"github.com/HAL-xyz/zoroaster/trigger"
@@ -42,7 +41,7 @@ func CronExecutor(idb db.IDB, now time.Time, api tokenapi.ITokenAPI, matchesChan
 			logrus.Warnf("cannot exec cron trig %s: %s", tg.TriggerUUID, err)
 			continue
 		}
-		m.BlockTimestamp, m.BlockHash = lastBlock.Timestamp, lastBlock.Hash
+		m.BlockTimestamp, m.BlockHash, m.BaseFeePerGas = lastBlock.Timestamp, lastBlock.Hash, lastBlock.BaseFeePerGas
 
 		if err = idb.LogMatch(m); err != nil {
 			logrus.Fatal(err)
@@ -91,7 +90,7 @@ func RunCronTgAgainstBlock(tg *trigger.Trigger, blockNo int, api tokenapi.IToken
 	return m, nil
 }
 
-func fetchLastBlock(api tokenapi.ITokenAPI) *ethrpc.Block {
+func fetchLastBlock(api tokenapi.ITokenAPI) *tokenapi.Block {
 	lastBlock, err := api.GetRPCCli().EthBlockNumber()
 	if err != nil {
 		logrus.Fatal(err)
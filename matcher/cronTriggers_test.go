@@ -1,22 +1,15 @@
 package matcher
 
 import (
-	"github.com/HAL-xyz/zoroaster/config"
 	"github.com/HAL-xyz/zoroaster/tokenapi"
 	"github.com/HAL-xyz/zoroaster/trigger"
-	log "github.com/sirupsen/logrus"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
 	"github.com/stretchr/testify/assert"
 	"testing"
 	"time"
 )
 
-func init() {
-	if config.Zconf.Stage != config.TEST {
-		log.Fatal("$STAGE must be TEST to run tests")
-	}
-	log.SetLevel(log.DebugLevel)
-}
-
 func newDateWithTime(day, month, year, hour, min int) time.Time {
 	return time.Date(year, time.Month(month), day, hour, min, 0, 0, time.UTC)
 }
@@ -25,16 +18,36 @@ func newDateWithSeconds(day, month, year, hour, min, sec int) time.Time {
 	return time.Date(year, time.Month(month), day, hour, min, sec, 0, time.UTC)
 }
 
-var api = tokenapi.New(tokenapi.NewZRPC(config.Zconf.EthNode, "mainnet test client"))
+// calls symbol() on DAI and UNI
+type mockCronCli struct {
+	tokenapi.IEthRpc
+}
+
+func (cli mockCronCli) EthBlockNumber() (int, error) {
+	return 12000000, nil
+}
+
+func (cli mockCronCli) EthGetBlockByNumber(number int, withTransactions bool) (*tokenapi.Block, error) {
+	return &tokenapi.Block{Number: number, Hash: "0x6f9b", Timestamp: 1618000000}, nil
+}
+
+func (cli mockCronCli) MakeEthRpcCall(cntAddress, data string, blockNumber int) (string, error) {
+	symbols := map[string]string{
+		"0x6b175474e89094c44da98b954eedeac495271d0f": "DAI",
+		"0x1f9840a85d5af5bf1d1762f925bdaddc4201f984": "UNI",
+	}
+	stringType, _ := abi.NewType("string", "", nil)
+	out, err := abi.Arguments{{Type: stringType}}.Pack(symbols[cntAddress])
+	return "0x" + common.Bytes2Hex(out), err
+}
 
 func TestCronExecutor(t *testing.T) {
 
 	// clear up the database
-	err := psqlClient.TruncateTables([]string{"triggers", "matches"})
-	assert.NoError(t, err)
+	memDB.Reset()
 
 	// load a User
-	userUUID, err := psqlClient.SaveUser(100, 0)
+	userUUID, err := memDB.SaveUser(100)
 	assert.NoError(t, err)
 
 	tg1 := `
@@ -67,17 +80,18 @@ func TestCronExecutor(t *testing.T) {
 		}
 	`
 
-	uuid1, err := psqlClient.SaveTrigger(tg1, true, false, userUUID, "1_eth_mainnet")
-	uuid2, err := psqlClient.SaveTrigger(tg2, true, false, userUUID, "1_eth_mainnet")
+	uuid1, err := memDB.SaveTrigger(tg1, true, false, userUUID, "1_eth_mainnet")
+	uuid2, err := memDB.SaveTrigger(tg2, true, false, userUUID, "1_eth_mainnet")
 
+	api := tokenapi.MustNew(mockCronCli{})
 	ch := make(chan trigger.IMatch, 2)
 	assert.Equal(t, 0, len(ch))
 
 	// Exec at 15:00
 	// default date is 1/1/2000 00:00:00 so only the */5 trigger should fire
-	CronExecutor(psqlClient, newDateWithTime(1, 1, 2000, 15, 00), api, ch)
+	CronExecutor(memDB, newDateWithTime(1, 1, 2000, 15, 00), api, ch)
 
-	tgs, err := psqlClient.LoadTriggersFromDB(trigger.CronT)
+	tgs, err := memDB.LoadTriggersFromDB(trigger.CronT)
 	assert.NoError(t, err)
 
 	tm := tgsToMap(tgs)
@@ -91,9 +105,9 @@ func TestCronExecutor(t *testing.T) {
 
 	// Exec at 15:10
 	// now both should be executed
-	CronExecutor(psqlClient, newDateWithTime(1, 1, 2000, 15, 10), api, ch)
+	CronExecutor(memDB, newDateWithTime(1, 1, 2000, 15, 10), api, ch)
 
-	tgs, err = psqlClient.LoadTriggersFromDB(trigger.CronT)
+	tgs, err = memDB.LoadTriggersFromDB(trigger.CronT)
 	assert.NoError(t, err)
 
 	tm = tgsToMap(tgs)
@@ -102,14 +116,15 @@ func TestCronExecutor(t *testing.T) {
 	assert.Equal(t, "2000-01-01 15:10:00 +0000 UTC", tm[uuid2].LastFired.String()) // updated
 
 	assert.Equal(t, 2, len(ch))
-	m2, m1 := <-ch, <-ch
-	assert.Equal(t, "DAI", m1.ToTemplateMatch().Contract.ReturnedValues[0])
-	assert.Equal(t, "UNI", m2.ToTemplateMatch().Contract.ReturnedValues[0])
+	// triggers are loaded in no particular order
+	m1, m2 := <-ch, <-ch
+	symbols := []interface{}{m1.ToTemplateMatch().Contract.ReturnedValues[0], m2.ToTemplateMatch().Contract.ReturnedValues[0]}
+	assert.ElementsMatch(t, []interface{}{"DAI", "UNI"}, symbols)
 
 	// after 5 minutes, only the */5 will fire again
-	CronExecutor(psqlClient, newDateWithTime(1, 1, 2000, 15, 15), api, ch)
+	CronExecutor(memDB, newDateWithTime(1, 1, 2000, 15, 15), api, ch)
 
-	tgs, err = psqlClient.LoadTriggersFromDB(trigger.CronT)
+	tgs, err = memDB.LoadTriggersFromDB(trigger.CronT)
 	assert.NoError(t, err)
 
 	tm = tgsToMap(tgs)
@@ -3,6 +3,7 @@ package matcher
 import (
 	"github.com/HAL-xyz/ethrpc"
 	"github.com/HAL-xyz/zoroaster/db"
+	"github.com/HAL-xyz/zoroaster/engine"
 	"github.com/HAL-xyz/zoroaster/tokenapi"
 	"github.com/HAL-xyz/zoroaster/trigger"
 	"github.com/sirupsen/logrus"
@@ -10,7 +11,7 @@ import (
 )
 
 func EventMatcher(
-	blocksChan chan *ethrpc.Block,
+	blocksChan chan *tokenapi.Block,
 	matchesChan chan trigger.IMatch,
 	idb db.IDB,
 	tokenApi tokenapi.ITokenAPI) {
@@ -22,7 +23,7 @@ func EventMatcher(
 
 		start := time.Now()
 
-		triggers, err := idb.LoadTriggersFromDB(trigger.WaE)
+		index, err := db.LoadEventIndex(idb)
 		if err != nil {
 			logrus.Fatal(err)
 		}
@@ -33,21 +34,18 @@ func EventMatcher(
 		}
 		// fmt.Println(utils.GimmePrettyJson(logs))
 
-		for _, tg := range triggers {
-			matchingEvents := trigger.MatchEvent(tg, logs, block.Transactions, tokenApi)
-			for _, match := range matchingEvents {
-				match.BlockTimestamp = block.Timestamp
-				if err = idb.LogMatch(match); err != nil {
-					logrus.Fatal(err)
-				}
-				logrus.Debug("\tlogged one event with id ", match.MatchUUID)
-				matchesChan <- match
+		matches := engine.MatchEvents(index, block, logs, tokenApi)
+		for _, match := range matches {
+			if err = idb.LogMatch(match); err != nil {
+				logrus.Fatal(err)
 			}
+			logrus.Debug("\tlogged one event with id ", match.MatchUUID)
+			matchesChan <- match
 		}
 		if err = idb.SetLastBlockProcessed(block.Number, trigger.WaE); err != nil {
 			logrus.Fatal(err)
 		}
-		logrus.Infof("Events: Processed %d triggers in %s from block %d", len(triggers), time.Since(start), block.Number)
+		logrus.Infof("Events: Processed %d triggers in %s from block %d", index.Len(), time.Since(start), block.Number)
 	}
 }
 
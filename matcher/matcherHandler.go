@@ -1,8 +1,9 @@
 package matcher
 
 import (
-	"github.com/HAL-xyz/zoroaster/action"
+	"github.com/HAL-xyz/zoroaster/config"
 	"github.com/HAL-xyz/zoroaster/db"
+	"github.com/HAL-xyz/zoroaster/tokenapi"
 	"github.com/HAL-xyz/zoroaster/trigger"
 	"github.com/aws/aws-sdk-go/service/ses/sesiface"
 	log "github.com/sirupsen/logrus"
@@ -14,7 +15,14 @@ type IHttpClient interface {
 	Post(url, contentType string, body io.Reader) (resp *http.Response, err error)
 }
 
-func ProcessMatch(match trigger.IMatch, idb db.IDB, iEmail sesiface.SESAPI, httpCli IHttpClient) []*trigger.Outcome {
+// ProcessMatch runs the actions of a match; tokenApi and conf are used to render and send them
+func ProcessMatch(
+	match trigger.IMatch,
+	idb db.IDB,
+	iEmail sesiface.SESAPI,
+	httpCli IHttpClient,
+	tokenApi tokenapi.ITokenAPI,
+	conf *config.ZConfiguration) []*trigger.Outcome {
 
 	acts, err := idb.GetActions(match.GetTriggerUUID(), match.GetUserUUID())
 	if err != nil {
@@ -22,9 +30,9 @@ func ProcessMatch(match trigger.IMatch, idb db.IDB, iEmail sesiface.SESAPI, http
 	}
 	log.Debugf("tg %s matched %d actions", match.GetTriggerUUID(), len(acts))
 
-	outcomes := action.ProcessActions(acts, match, iEmail, httpCli)
-	if len(outcomes) != len(acts) {
-		log.Warnf("match %s had %d actions but only %d outcomes", match.GetMatchUUID(), len(acts), len(outcomes))
+	outcomes := make([]*trigger.Outcome, len(acts))
+	for i, act := range acts {
+		outcomes[i] = runWithQuota(act, match, idb, iEmail, httpCli, tokenApi, conf)
 	}
 	for _, out := range outcomes {
 		if err := idb.LogOutcome(out, match.GetMatchUUID()); err != nil {
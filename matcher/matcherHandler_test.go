@@ -2,6 +2,7 @@ package matcher
 
 import (
 	"bytes"
+	"fmt"
 	"github.com/HAL-xyz/zoroaster/db"
 	"github.com/HAL-xyz/zoroaster/trigger"
 	"github.com/HAL-xyz/zoroaster/utils"
@@ -12,6 +13,7 @@ import (
 	"io/ioutil"
 	"net/http"
 	"testing"
+	"time"
 )
 import log "github.com/sirupsen/logrus"
 
@@ -49,6 +51,10 @@ func (mockDB2) LogOutcome(outcome *trigger.Outcome, matchUUID string) error {
 	return nil
 }
 
+func (mockDB2) ReserveQuota(userUUID, actionType string, now time.Time) (*db.QuotaReservation, error) {
+	return &db.QuotaReservation{Allowed: true}, nil
+}
+
 func (mockDB2) GetActions(tgUUID string, userUUID string) ([]string, error) {
 	a1 := `
 	{
@@ -88,7 +94,7 @@ func TestProcessMatch(t *testing.T) {
 		BlockHash:      "0x",
 	}
 
-	outcomes := ProcessMatch(&match, mockDB2{}, &mockSESClient{}, &mockHttpClient{})
+	outcomes := ProcessMatch(&match, mockDB2{}, &mockSESClient{}, &mockHttpClient{}, templatingApi, testConf)
 
 	// web hook
 	expPayload := `{
@@ -118,3 +124,76 @@ func TestProcessMatch(t *testing.T) {
 	assert.Equal(t, expEmailPayload, outcomes[1].Payload)
 	assert.Equal(t, expEmailOutcome, outcomes[1].Outcome)
 }
+
+// SESAPI mock that remembers what's been sent
+type recordingSESClient struct {
+	sesiface.SESAPI
+	subjects []string
+}
+
+func (m *recordingSESClient) SendEmail(input *ses.SendEmailInput) (*ses.SendEmailOutput, error) {
+	m.subjects = append(m.subjects, *input.Message.Subject.Data)
+	msg := "mock email success"
+	return &ses.SendEmailOutput{MessageId: &msg}, nil
+}
+
+// HTTP Client mock that always fails
+type failingHttpClient struct{}
+
+func (m failingHttpClient) Post(url, contentType string, body io.Reader) (*http.Response, error) {
+	return nil, fmt.Errorf("connection refused")
+}
+
+func TestProcessMatchWithQuota(t *testing.T) {
+
+	memDB.Reset()
+	userUUID, err := memDB.SaveUser(100)
+	assert.NoError(t, err)
+	err = memDB.SetQuotaLimit(userUUID, db.QuotaLimit{ActionType: "email", Window: db.DailyQuota, Hard: 2, Soft: 2})
+	assert.NoError(t, err)
+
+	tgSrc, err := ioutil.ReadFile("../resources/triggers/wac1.json")
+	assert.NoError(t, err)
+	tgUUID, err := memDB.SaveTrigger(string(tgSrc), true, false, userUUID, "1_eth_mainnet")
+	assert.NoError(t, err)
+	_, err = memDB.SaveActionData(tgUUID, `{"ActionType": "email", "Attributes": {"To": ["hello@gmail.com"], "Body": "body", "Subject": "subj"}}`, true)
+	assert.NoError(t, err)
+	_, err = memDB.SaveActionData(tgUUID, `{"ActionType": "webhook_post", "Attributes": {"URI": "https://example.com"}}`, true)
+	assert.NoError(t, err)
+
+	tgs, err := memDB.LoadTriggersFromDB(trigger.WaC)
+	assert.NoError(t, err)
+	match := trigger.CnMatch{Trigger: tgs[0], MatchedValues: []string{}}
+	sesCli := &recordingSESClient{}
+
+	// failed webhooks don't count
+	outcomes := ProcessMatch(&match, memDB, sesCli, failingHttpClient{}, templatingApi, testConf)
+	assert.True(t, outcomes[0].Success)
+	assert.False(t, outcomes[1].Success)
+	assert.Equal(t, 0, memDB.QuotaUsed(userUUID, "webhook_post", db.MonthlyQuota, time.Now()))
+	assert.Equal(t, 1, memDB.QuotaUsed(userUUID, db.AnyAction, db.MonthlyQuota, time.Now()))
+
+	// the second email is the last one for today, and the user is told so
+	outcomes = ProcessMatch(&match, memDB, sesCli, mockHttpClient{}, templatingApi, testConf)
+	assert.True(t, outcomes[0].Success)
+	assert.True(t, outcomes[1].Success)
+	assert.Equal(t, []string{"subj", "subj", "You've used 2 of the 2 email actions you can run today"}, sesCli.subjects)
+
+	// the third isn't sent, and the outcome says why
+	outcomes = ProcessMatch(&match, memDB, sesCli, mockHttpClient{}, templatingApi, testConf)
+	assert.False(t, outcomes[0].Success)
+	assert.Contains(t, outcomes[0].Outcome, "quota exceeded")
+	assert.True(t, outcomes[1].Success)
+	assert.Len(t, sesCli.subjects, 3)
+	assert.Len(t, memDB.Outcomes(), 6)
+
+	// the follow-ups of a pending tx were paid for by the pending match
+	block, err := trigger.GetBlockFromFile("../resources/blocks/block1.json")
+	assert.NoError(t, err)
+	followUp := trigger.TxMatch{Tg: tgs[0], Tx: &block.Transactions[0], Status: trigger.TxStatusDropped, PendingMatchUUID: "pending-uuid"}
+	outcomes = ProcessMatch(&followUp, memDB, sesCli, mockHttpClient{}, templatingApi, testConf)
+	assert.True(t, outcomes[0].Success)
+	assert.True(t, outcomes[1].Success)
+	assert.Len(t, sesCli.subjects, 4)
+	assert.Equal(t, 2, memDB.QuotaUsed(userUUID, "email", db.DailyQuota, time.Now()))
+}
@@ -0,0 +1,190 @@
+package matcher
+
+import (
+	"github.com/HAL-xyz/zoroaster/db"
+	"github.com/HAL-xyz/zoroaster/tokenapi"
+	"github.com/HAL-xyz/zoroaster/trigger"
+	log "github.com/sirupsen/logrus"
+	"sync"
+	"time"
+)
+
+// PendingTxs keeps track of the txs we've seen in the mempool,
+// so that every pending match can be followed up once its tx is mined or dropped.
+type PendingTxs struct {
+	sync.Mutex
+	seen      map[string]time.Time          // tx hash -> last time we saw it in the mempool
+	matches   map[string][]*trigger.TxMatch // tx hash -> pending matches
+	mined     map[string]time.Time          // tx hash -> last poll it was known to be mined at
+	lastPoll  time.Time
+	dropAfter time.Duration // how long a tx can be missing from the mempool before we call it dropped
+}
+
+func NewPendingTxs(dropAfter time.Duration) *PendingTxs {
+	return &PendingTxs{
+		seen:      make(map[string]time.Time),
+		matches:   make(map[string][]*trigger.TxMatch),
+		mined:     make(map[string]time.Time),
+		dropAfter: dropAfter,
+	}
+}
+
+// observe returns the txs we've never seen before, and refreshes the others.
+// Nodes can keep a mined tx in the mempool for a while: those aren't new.
+func (p *PendingTxs) observe(txs []tokenapi.Transaction, now time.Time) []tokenapi.Transaction {
+	p.Lock()
+	defer p.Unlock()
+
+	p.lastPoll = now
+	var newTxs []tokenapi.Transaction
+	for _, tx := range txs {
+		if _, ok := p.mined[tx.Hash]; ok {
+			p.mined[tx.Hash] = now
+			continue
+		}
+		if _, ok := p.seen[tx.Hash]; !ok {
+			newTxs = append(newTxs, tx)
+		}
+		p.seen[tx.Hash] = now
+	}
+	return newTxs
+}
+
+func (p *PendingTxs) add(m *trigger.TxMatch) {
+	p.Lock()
+	defer p.Unlock()
+	p.matches[m.Tx.Hash] = append(p.matches[m.Tx.Hash], m)
+}
+
+// Reconcile marks the matches of a mined block whose tx was already matched while pending,
+// and stops tracking every tx in the block; mined txs are remembered for dropAfter after they leave the mempool. Pending matches whose tx was mined but doesn't match anymore
+// (e.g. it reverted, or the state it depended on changed) are returned as failed matches.
+func (p *PendingTxs) Reconcile(block *tokenapi.Block, matches []*trigger.TxMatch) []*trigger.TxMatch {
+	if p == nil {
+		return nil
+	}
+	p.Lock()
+	defer p.Unlock()
+
+	reconciled := make(map[*trigger.TxMatch]bool)
+	for _, m := range matches {
+		// internal calls were never pending on their own
+		if m.CallDepth > 0 {
+			continue
+		}
+		for _, pm := range p.matches[m.Tx.Hash] {
+			if pm.Tg.TriggerUUID == m.Tg.TriggerUUID {
+				m.Status = trigger.TxStatusMined
+				m.PendingMatchUUID = pm.MatchUUID
+				reconciled[pm] = true
+			}
+		}
+	}
+	var failed []*trigger.TxMatch
+	for i, tx := range block.Transactions {
+		for _, pm := range p.matches[tx.Hash] {
+			if reconciled[pm] {
+				continue
+			}
+			m := *pm
+			m.MatchUUID = ""
+			m.Status = trigger.TxStatusFailed
+			m.PendingMatchUUID = pm.MatchUUID
+			m.Tx = &block.Transactions[i]
+			m.BlockTimestamp = block.Timestamp
+			failed = append(failed, &m)
+		}
+		delete(p.matches, tx.Hash)
+		delete(p.seen, tx.Hash)
+		p.mined[tx.Hash] = p.lastPoll
+	}
+	return failed
+}
+
+// expire forgets about the txs that left the mempool more than dropAfter ago without being mined,
+// and returns a dropped match for each of their pending matches.
+func (p *PendingTxs) expire(now time.Time) []*trigger.TxMatch {
+	p.Lock()
+	defer p.Unlock()
+
+	var dropped []*trigger.TxMatch
+	for hash, lastSeen := range p.seen {
+		if now.Sub(lastSeen) < p.dropAfter {
+			continue
+		}
+		for _, pm := range p.matches[hash] {
+			m := *pm
+			m.MatchUUID = ""
+			m.Status = trigger.TxStatusDropped
+			m.PendingMatchUUID = pm.MatchUUID
+			dropped = append(dropped, &m)
+		}
+		delete(p.matches, hash)
+		delete(p.seen, hash)
+	}
+	for hash, lastSeen := range p.mined {
+		if now.Sub(lastSeen) >= p.dropAfter {
+			delete(p.mined, hash)
+		}
+	}
+	return dropped
+}
+
+// MempoolMatcher polls the node's mempool and matches every new pending tx against WaT triggers.
+// Mined txs are reconciled by TxMatcher, sharing the same PendingTxs.
+func MempoolMatcher(
+	client tokenapi.IEthRpc,
+	matchesChan chan trigger.IMatch,
+	idb db.IDB,
+	api tokenapi.ITokenAPI,
+	pending *PendingTxs,
+	interval time.Duration,
+) {
+
+	ticker := time.NewTicker(interval)
+	for range ticker.C {
+		txs, err := client.EthGetPendingTransactions()
+		if err != nil {
+			log.Warnf("cannot read the mempool: %s", err)
+			continue
+		}
+		for _, m := range matchPendingTxs(txs, idb, api, pending, time.Now()) {
+			matchesChan <- m
+		}
+	}
+}
+
+func matchPendingTxs(txs []tokenapi.Transaction, idb db.IDB, api tokenapi.ITokenAPI, pending *PendingTxs, now time.Time) []*trigger.TxMatch {
+	start := time.Now()
+
+	newTxs := pending.observe(txs, now)
+	index, err := db.LoadTxIndex(idb)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var matches []*trigger.TxMatch
+	for i := range newTxs {
+		for _, tg := range index.Candidates(&newTxs[i]) {
+			m := trigger.MatchPendingTransaction(tg, &newTxs[i], api)
+			if m == nil {
+				continue
+			}
+			if err = idb.LogMatch(m); err != nil {
+				log.Fatal(err)
+			}
+			pending.add(m)
+			matches = append(matches, m)
+		}
+	}
+
+	for _, m := range pending.expire(now) {
+		if err = idb.LogMatch(m); err != nil {
+			log.Fatal(err)
+		}
+		matches = append(matches, m)
+	}
+
+	log.Infof("MEMPOOL: Processed %d new txs out of %d with %d triggers in %s", len(newTxs), len(txs), index.Len(), time.Since(start))
+	return matches
+}
@@ -0,0 +1,128 @@
+package matcher
+
+import (
+	"fmt"
+	"github.com/HAL-xyz/zoroaster/db"
+	"github.com/HAL-xyz/zoroaster/tokenapi"
+	"github.com/HAL-xyz/zoroaster/trigger"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+// IDB mock, with WaT triggers only
+type mockMempoolDB struct {
+	db.IDB
+	tgs    []*trigger.Trigger
+	logged *[]trigger.IMatch
+}
+
+func (m mockMempoolDB) LoadTriggersFromDB(tgType trigger.TgType) ([]*trigger.Trigger, error) {
+	return m.tgs, nil
+}
+
+func (m mockMempoolDB) LogMatch(match trigger.IMatch) error {
+	*m.logged = append(*m.logged, match)
+	match.SetMatchUUID(fmt.Sprintf("%s-%d", match.GetTriggerUUID(), len(*m.logged)))
+	return nil
+}
+
+func TestMatchPendingTxs(t *testing.T) {
+
+	block, err := trigger.GetBlockFromFile("../resources/blocks/block1.json")
+	assert.NoError(t, err)
+	tg, err := trigger.GetTriggerFromFile("../resources/triggers/t2.json")
+	assert.NoError(t, err)
+	tg.TriggerUUID = "tg"
+
+	// t2 matches txs #6 and #8
+	var mempool []tokenapi.Transaction
+	for _, i := range []int{0, 6, 8} {
+		tx := block.Transactions[i]
+		tx.BlockHash, tx.BlockNumber, tx.TransactionIndex = "", nil, nil
+		mempool = append(mempool, tx)
+	}
+
+	var logged []trigger.IMatch
+	idb := mockMempoolDB{tgs: []*trigger.Trigger{tg}, logged: &logged}
+	api := tokenapi.MustNew(mockETHCli{})
+	pending := NewPendingTxs(10 * time.Minute)
+	now := time.Now()
+
+	matches := matchPendingTxs(mempool, idb, api, pending, now)
+	assert.Len(t, matches, 2)
+	assert.Len(t, logged, 2)
+	for _, m := range matches {
+		assert.Equal(t, trigger.TxStatusPending, m.Status)
+	}
+
+	// txs we've already seen don't match again
+	matches = matchPendingTxs(mempool, idb, api, pending, now.Add(time.Minute))
+	assert.Len(t, matches, 0)
+
+	// tx #6 gets mined and is reconciled with its pending match
+	minedBlock := &tokenapi.Block{Transactions: []tokenapi.Transaction{block.Transactions[6]}}
+	mined := trigger.MatchTransaction(tg, minedBlock, api)
+	assert.Len(t, pending.Reconcile(minedBlock, mined), 0)
+	assert.Len(t, mined, 1)
+	assert.Equal(t, trigger.TxStatusMined, mined[0].Status)
+	assert.Equal(t, "tg-1", mined[0].PendingMatchUUID)
+
+	// the node keeps the mined tx in the mempool for a couple of polls: it's not pending again
+	matches = matchPendingTxs(mempool, idb, api, pending, now.Add(2*time.Minute))
+	assert.Len(t, matches, 0)
+
+	// tx #8 leaves the mempool without being mined
+	matches = matchPendingTxs(mempool[:1], idb, api, pending, now.Add(5*time.Minute))
+	assert.Len(t, matches, 0)
+	matches = matchPendingTxs(mempool[:1], idb, api, pending, now.Add(12*time.Minute))
+	assert.Len(t, matches, 1)
+	assert.Equal(t, trigger.TxStatusDropped, matches[0].Status)
+	assert.Equal(t, "tg-2", matches[0].PendingMatchUUID)
+	assert.Equal(t, block.Transactions[8].Hash, matches[0].Tx.Hash)
+
+	// ... and it's dropped only once
+	matches = matchPendingTxs(mempool[:1], idb, api, pending, now.Add(30*time.Minute))
+	assert.Len(t, matches, 0)
+
+	// mined txs are forgotten some time after they left the mempool
+	assert.Len(t, pending.mined, 0)
+
+	// a nil tracker doesn't reconcile anything
+	var noPending *PendingTxs
+	mined = trigger.MatchTransaction(tg, minedBlock, api)
+	assert.Nil(t, noPending.Reconcile(minedBlock, mined))
+	assert.Equal(t, "", mined[0].Status)
+}
+
+func TestReconcileFailedTxs(t *testing.T) {
+
+	block, err := trigger.GetBlockFromFile("../resources/blocks/block1.json")
+	assert.NoError(t, err)
+	tg, err := trigger.GetTriggerFromFile("../resources/triggers/t2.json")
+	assert.NoError(t, err)
+	tg.TriggerUUID = "tg"
+
+	var logged []trigger.IMatch
+	idb := mockMempoolDB{tgs: []*trigger.Trigger{tg}, logged: &logged}
+	api := tokenapi.MustNew(mockETHCli{})
+	pending := NewPendingTxs(10 * time.Minute)
+
+	matches := matchPendingTxs([]tokenapi.Transaction{block.Transactions[6], block.Transactions[8]}, idb, api, pending, time.Now())
+	assert.Len(t, matches, 2)
+
+	// both txs are mined, but only #6 still matches
+	minedBlock := &tokenapi.Block{Timestamp: 1554828248, Transactions: []tokenapi.Transaction{block.Transactions[6], block.Transactions[8]}}
+	mined := trigger.MatchTransaction(tg, &tokenapi.Block{Transactions: minedBlock.Transactions[:1]}, api)
+	failed := pending.Reconcile(minedBlock, mined)
+	assert.Equal(t, trigger.TxStatusMined, mined[0].Status)
+	assert.Len(t, failed, 1)
+	assert.Equal(t, trigger.TxStatusFailed, failed[0].Status)
+	assert.Equal(t, "tg-2", failed[0].PendingMatchUUID)
+	assert.Equal(t, "", failed[0].MatchUUID)
+	assert.Equal(t, block.Transactions[8].Hash, failed[0].Tx.Hash)
+	assert.Equal(t, 1554828248, failed[0].BlockTimestamp)
+
+	// mined txs are forgotten, so they can't be dropped later on
+	assert.Len(t, pending.expire(time.Now().Add(time.Hour)), 0)
+}
@@ -0,0 +1,93 @@
+package matcher
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/HAL-xyz/zoroaster/action"
+	"github.com/HAL-xyz/zoroaster/config"
+	"github.com/HAL-xyz/zoroaster/db"
+	"github.com/HAL-xyz/zoroaster/tokenapi"
+	"github.com/HAL-xyz/zoroaster/trigger"
+	"github.com/aws/aws-sdk-go/service/ses/sesiface"
+	log "github.com/sirupsen/logrus"
+	"time"
+)
+
+// runWithQuota runs an action only if the user has some quota left for it,
+// and gives the quota back if the action fails, so that only successful actions count.
+// The follow-ups of a pending tx (mined, failed or dropped) are free: the pending match already counted.
+func runWithQuota(
+	act string,
+	match trigger.IMatch,
+	idb db.IDB,
+	iEmail sesiface.SESAPI,
+	httpCli IHttpClient,
+	tokenApi tokenapi.ITokenAPI,
+	conf *config.ZConfiguration) *trigger.Outcome {
+	if isFollowUp(match) {
+		return action.ProcessActions([]string{act}, match, iEmail, httpCli, tokenApi, conf)[0]
+	}
+	userUUID, actionType, now := match.GetUserUUID(), action.GetActionType(act), time.Now()
+
+	reservation, err := idb.ReserveQuota(userUUID, actionType, now)
+	if err != nil {
+		log.Errorf("tg %s - %s", match.GetTriggerUUID(), err)
+		return quotaOutcome(fmt.Sprintf("cannot check quota: %s", err))
+	}
+	if !reservation.Allowed {
+		log.Debugf("tg %s - quota exceeded: %s", match.GetTriggerUUID(), reservation.Exceeded)
+		return quotaOutcome(fmt.Sprintf("quota exceeded: %s", reservation.Exceeded))
+	}
+
+	out := action.ProcessActions([]string{act}, match, iEmail, httpCli, tokenApi, conf)[0]
+	if !out.Success {
+		if err := idb.ReleaseQuota(userUUID, actionType, now); err != nil {
+			log.Errorf("tg %s - %s", match.GetTriggerUUID(), err)
+		}
+	}
+	// each soft limit is reached only once per window, so these can't be put off
+	for _, w := range reservation.Warnings {
+		warnUser(userUUID, w, idb, iEmail)
+	}
+	return out
+}
+
+func isFollowUp(match trigger.IMatch) bool {
+	m, ok := match.(*trigger.TxMatch)
+	return ok && m.PendingMatchUUID != ""
+}
+
+func quotaOutcome(msg string) *trigger.Outcome {
+	outcome, _ := json.Marshal(action.ErrorMsg{Error: msg})
+	return &trigger.Outcome{
+		Payload: "",
+		Outcome: string(outcome),
+		Success: false,
+	}
+}
+
+// warnUser lets a user know they're close to one of their limits
+func warnUser(userUUID string, usage db.QuotaUsage, idb db.IDB, iEmail sesiface.SESAPI) {
+	email, err := idb.GetUserEmail(userUUID)
+	if err != nil {
+		log.Errorf("cannot warn user %s about their quota: %s", userUUID, err)
+		return
+	}
+	actions := "actions"
+	if usage.ActionType != db.AnyAction {
+		actions = usage.ActionType + " actions"
+	}
+	when := "this month"
+	if usage.Window == db.DailyQuota {
+		when = "today"
+	}
+	subject := fmt.Sprintf("You've used %d of the %d %s you can run %s", usage.Used, usage.Hard, actions, when)
+	body := fmt.Sprintf("Hi,\n\nyou've used %d of the %d %s you can run %s.\n"+
+		"Once you reach the limit, no more %s will be run %s.\n\nHAL",
+		usage.Used, usage.Hard, actions, when, actions, when)
+	if err = action.SendNotice(iEmail, email, subject, body); err != nil {
+		log.Errorf("cannot warn user %s about their quota: %s", userUUID, err)
+		return
+	}
+	log.Infof("user %s warned: %s", userUUID, usage)
+}
@@ -1,15 +1,16 @@
 package matcher
 
 import (
-	"github.com/HAL-xyz/ethrpc"
 	"github.com/HAL-xyz/zoroaster/db"
+	"github.com/HAL-xyz/zoroaster/engine"
 	"github.com/HAL-xyz/zoroaster/tokenapi"
 	"github.com/HAL-xyz/zoroaster/trigger"
 	log "github.com/sirupsen/logrus"
 	"time"
 )
 
-func TxMatcher(blocksChan chan *ethrpc.Block, matchesChan chan trigger.IMatch, idb db.IDB, api tokenapi.ITokenAPI) {
+// pending can be nil if we're not watching the mempool
+func TxMatcher(blocksChan chan *tokenapi.Block, matchesChan chan trigger.IMatch, idb db.IDB, api tokenapi.ITokenAPI, pending *PendingTxs) {
 
 	for {
 		block := <-blocksChan
@@ -17,22 +18,21 @@ func TxMatcher(blocksChan chan *ethrpc.Block, matchesChan chan trigger.IMatch, i
 		api.LogFiatStatsAndReset(block.Number - 1)
 		start := time.Now()
 
-		triggers, err := idb.LoadTriggersFromDB(trigger.WaT)
+		index, err := db.LoadTxIndex(idb)
 		if err != nil {
 			log.Fatal(err)
 		}
-		for _, tg := range triggers {
-			matchingTxs := trigger.MatchTransaction(tg, block, api)
-			for _, m := range matchingTxs {
-				if err = idb.LogMatch(m); err != nil {
-					log.Fatal(err)
-				}
-				matchesChan <- m
+		matches := engine.MatchTransactions(index, block, api)
+		matches = append(matches, pending.Reconcile(block, matches)...)
+		for _, m := range matches {
+			if err = idb.LogMatch(m); err != nil {
+				log.Fatal(err)
 			}
+			matchesChan <- m
 		}
 		if err = idb.SetLastBlockProcessed(block.Number, trigger.WaT); err != nil {
 			log.Fatal(err)
 		}
-		log.Infof("TX: Processed %d triggers in %s from block %d", len(triggers), time.Since(start), block.Number)
+		log.Infof("TX: Processed %d triggers in %s from block %d", index.Len(), time.Since(start), block.Number)
 	}
 }
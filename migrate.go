@@ -0,0 +1,66 @@
+package main
+
+import (
+	"fmt"
+	"github.com/HAL-xyz/zoroaster/config"
+	"github.com/HAL-xyz/zoroaster/db"
+	log "github.com/sirupsen/logrus"
+	"strconv"
+)
+
+const migrateUsage = `usage: zoroaster migrate <up|down|version|force> [N]
+  up [N]      apply all (or the next N) pending migrations
+  down [N]    revert all (or the last N) migrations
+  version     print the current and the required schema version
+  force N     set the schema version to N and clear the dirty flag, without running anything`
+
+// runMigrate handles the `migrate` subcommand
+func runMigrate(conf *config.ZConfiguration, args []string) error {
+	if len(args) == 0 || len(args) > 2 {
+		return fmt.Errorf(migrateUsage)
+	}
+	n := 0
+	if len(args) == 2 {
+		var err error
+		n, err = strconv.Atoi(args[1])
+		if err != nil || n < 0 {
+			return fmt.Errorf("invalid number of migrations: %s\n%s", args[1], migrateUsage)
+		}
+	}
+
+	// migrations don't load any trigger, so there's no need for a TokenAPI
+	psqlClient := db.NewPostgresClient(conf, nil)
+	defer psqlClient.Close()
+
+	switch args[0] {
+	case "up":
+		return psqlClient.MigrateUp(n)
+	case "down":
+		return psqlClient.MigrateDown(n)
+	case "version":
+		version, dirty, err := psqlClient.SchemaVersion()
+		if err != nil {
+			return err
+		}
+		log.Infof("schema version = %d, dirty = %t, required = %d", version, dirty, db.LatestSchemaVersion())
+		return nil
+	case "force":
+		if len(args) != 2 {
+			return fmt.Errorf(migrateUsage)
+		}
+		return psqlClient.ForceSchemaVersion(n)
+	}
+	return fmt.Errorf(migrateUsage)
+}
+
+// setupSchema migrates the db on startup if we're asked to, then makes sure the schema is the expected one
+func setupSchema(conf *config.ZConfiguration, psqlClient *db.PostgresClient) {
+	if conf.Database.Migrate {
+		if err := psqlClient.MigrateUp(0); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if err := psqlClient.CheckSchemaVersion(); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -1,8 +1,6 @@
 package poller
 
 import (
-	"github.com/HAL-xyz/ethrpc"
-	"github.com/HAL-xyz/zoroaster/config"
 	"github.com/HAL-xyz/zoroaster/db"
 	"github.com/HAL-xyz/zoroaster/tokenapi"
 	"github.com/HAL-xyz/zoroaster/trigger"
@@ -11,22 +9,26 @@ import (
 )
 
 func BlocksPoller(
-	txChan chan *ethrpc.Block,
-	cnChan chan *ethrpc.Block,
-	evChan chan *ethrpc.Block,
+	txChan chan *tokenapi.Block,
+	cnChan chan *tokenapi.Block,
+	evChan chan *tokenapi.Block,
+	blChan chan *tokenapi.Block,
 	client tokenapi.IEthRpc,
+	templatingApi tokenapi.ITokenAPI,
 	idb db.IDB,
-	blocksDelay int) {
+	blocksDelay int,
+	pollingInterval int) {
 
 	txLastBlockProcessed, err1 := idb.ReadLastBlockProcessed(trigger.WaT)
 	cnLastBlockProcessed, err2 := idb.ReadLastBlockProcessed(trigger.WaC)
 	evLastBlockProcessed, err3 := idb.ReadLastBlockProcessed(trigger.WaE)
+	blLastBlockProcessed, err4 := idb.ReadLastBlockProcessed(trigger.WaB)
 
-	if err1 != nil || err2 != nil || err3 != nil {
-		log.Fatal(err1, err2, err3)
+	if err1 != nil || err2 != nil || err3 != nil || err4 != nil {
+		log.Fatal(err1, err2, err3, err4)
 	}
 
-	ticker := time.NewTicker(time.Duration(config.Zconf.PollingInterval) * time.Second)
+	ticker := time.NewTicker(time.Duration(pollingInterval) * time.Second)
 	for range ticker.C {
 		lastBlockSeen, err := client.EthBlockNumber()
 		if err != nil {
@@ -34,21 +36,25 @@ func BlocksPoller(
 		}
 
 		// Watch a Transaction
-		fetchLastBlock(lastBlockSeen, &txLastBlockProcessed, txChan, client, true, blocksDelay)
+		fetchLastBlock(lastBlockSeen, &txLastBlockProcessed, txChan, client, templatingApi, true, blocksDelay)
 
 		// Watch a Contract
-		fetchLastBlock(lastBlockSeen, &cnLastBlockProcessed, cnChan, client, false, blocksDelay)
+		fetchLastBlock(lastBlockSeen, &cnLastBlockProcessed, cnChan, client, templatingApi, false, blocksDelay)
 
 		// Watch an Event
-		fetchLastBlock(lastBlockSeen, &evLastBlockProcessed, evChan, client, true, blocksDelay)
+		fetchLastBlock(lastBlockSeen, &evLastBlockProcessed, evChan, client, templatingApi, true, blocksDelay)
+
+		// Watch Blocks; usually the same block WaT has just fetched, so it comes from the client's cache
+		fetchLastBlock(lastBlockSeen, &blLastBlockProcessed, blChan, client, templatingApi, true, blocksDelay)
 	}
 }
 
 func fetchLastBlock(
 	lastBlockSeen int,
 	lastBlockProcessed *int,
-	ch chan *ethrpc.Block,
+	ch chan *tokenapi.Block,
 	client tokenapi.IEthRpc,
+	templatingApi tokenapi.ITokenAPI,
 	withTxs bool,
 	blocksDelay int) {
 
@@ -62,9 +68,9 @@ func fetchLastBlock(
 			// Since templating client is shared between WaT/C/E, we reset the stats after every new
 			// block discovered by WaT. This way stats will be overall consistent, although they might
 			// be slightly off on a per-block basis.
-			client.ResetCounterAndLogStats(*lastBlockProcessed)              // BlocksPoller eth client
-			tokenapi.GetTokenAPI().ResetETHRPCstats(*lastBlockProcessed)     // Templating eth client
-			tokenapi.GetTokenAPI().LogFiatStatsAndReset(*lastBlockProcessed) // Templating eth client
+			client.ResetCounterAndLogStats(*lastBlockProcessed)                    // BlocksPoller eth client
+			templatingApi.GetRPCCli().ResetCounterAndLogStats(*lastBlockProcessed) // Templating eth client
+			templatingApi.LogFiatStatsAndReset(*lastBlockProcessed)                // Templating eth client
 		}
 
 		block, err := client.EthGetBlockByNumber(*lastBlockProcessed+1, withTxs)
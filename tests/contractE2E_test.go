@@ -3,7 +3,6 @@ package tests
 import (
 	"fmt"
 	"github.com/HAL-xyz/zoroaster/action"
-	"github.com/HAL-xyz/zoroaster/config"
 	"github.com/HAL-xyz/zoroaster/tokenapi"
 	"github.com/HAL-xyz/zoroaster/trigger"
 	"github.com/stretchr/testify/assert"
@@ -38,10 +37,10 @@ func TestDepositAAVE_v1_single_call(t *testing.T) {
   "FunctionName": "getReserveData"
 }
 `
-	tg, err := trigger.NewTriggerFromJson(js)
+	tg, err := trigger.NewTriggerFromJson(js, nil)
 	assert.NoError(t, err)
 
-	tkkapi := tokenapi.New(tokenapi.NewZRPC(config.Zconf.EthNode, "test"))
+	tkkapi := tokenapi.MustNew(tokenapi.NewZRPC(testConf.EthNode, "test"), tokenapi.WithConfig(testConf))
 
 	blockNo, err := tkkapi.GetRPCCli().EthBlockNumber()
 	match, err := trigger.MatchContract(tkkapi, tg, blockNo)
@@ -51,7 +50,7 @@ func TestDepositAAVE_v1_single_call(t *testing.T) {
 
 	template := `The deposit rate for USDC is {{ formatNumber (fromWei (index .Contract.ReturnedValues 4) 25) 2 }}%`
 
-	rendered, err := action.RenderTemplateWithData(template, match.ToTemplateMatch())
+	rendered, err := action.RenderTemplateWithData(template, match.ToTemplateMatch(), tkkapi)
 	fmt.Println(rendered)
 
 }
@@ -88,20 +87,21 @@ func TestDepositAAVE_v2_multi_call(t *testing.T) {
   "FunctionName": "getReserveData"
 }
 `
-	tg, err := trigger.NewTriggerFromJson(js)
+	tg, err := trigger.NewTriggerFromJson(js, nil)
 	assert.NoError(t, err)
 
-	tkkapi := tokenapi.New(tokenapi.NewZRPC(config.Zconf.EthNode, "test"))
+	tkkapi := tokenapi.MustNew(tokenapi.NewZRPC(testConf.EthNode, "test"), tokenapi.WithConfig(testConf))
 
 	blockNo, err := tkkapi.GetRPCCli().EthBlockNumber()
 
-	matches, uuidErrors, err := trigger.MatchTriggersMulti([]*trigger.Trigger{tg}, tkkapi, blockNo)
+	mcAddress, _ := trigger.MulticallAddress("1_eth_mainnet")
+	matches, uuidErrors := trigger.MatchTriggersMulti([]*trigger.Trigger{tg}, tkkapi, blockNo, trigger.NewMulticaller(mcAddress))
 
 	template := `The current deposit rate for USDC is {{ round (fromWei (index .Contract.MatchedValues 0) 25) 2 }}%`
 
 	assert.Equal(t, 1, len(matches))
 	assert.Equal(t, 0, len(uuidErrors))
-	rendered, err := action.RenderTemplateWithData(template, matches[0].ToTemplateMatch())
+	rendered, err := action.RenderTemplateWithData(template, matches[0].ToTemplateMatch(), tkkapi)
 	fmt.Println(rendered)
 
 }
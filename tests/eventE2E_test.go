@@ -3,7 +3,6 @@ package tests
 import (
 	"fmt"
 	"github.com/HAL-xyz/zoroaster/action"
-	"github.com/HAL-xyz/zoroaster/config"
 	"github.com/HAL-xyz/zoroaster/tokenapi"
 	"github.com/HAL-xyz/zoroaster/trigger"
 	"github.com/stretchr/testify/assert"
@@ -43,9 +42,9 @@ func TestWaEWithCustomTemplate(t *testing.T) {
 	// ...
 
 	// new token api reading from default node config
-	var tapi = tokenapi.New(tokenapi.NewZRPC(config.Zconf.EthNode, "test"))
+	var tapi = tokenapi.MustNew(tokenapi.NewZRPC(testConf.EthNode, "test"), tokenapi.WithConfig(testConf))
 
-	tg, err := trigger.NewTriggerFromJson(triggerJson)
+	tg, err := trigger.NewTriggerFromJson(triggerJson, nil)
 	assert.NoError(t, err)
 
 	logs, _ := tapi.GetRPCCli().EthGetLogsByNumber(blockNumber, tg.ContractAdd)
@@ -53,6 +52,6 @@ func TestWaEWithCustomTemplate(t *testing.T) {
 
 	assert.Equal(t, 1, len(matches))
 
-	rendered, err := action.RenderTemplateWithData(template, matches[0].ToTemplateMatch())
+	rendered, err := action.RenderTemplateWithData(template, matches[0].ToTemplateMatch(), tapi)
 	fmt.Println(rendered)
 }
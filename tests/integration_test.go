@@ -11,7 +11,8 @@ import (
 	"testing"
 )
 
-var CliMain = tokenapi.NewZRPC(config.Zconf.EthNode, "mainnet test client")
+var testConf = config.NewConfig()
+var CliMain = tokenapi.NewZRPC(testConf.EthNode, "mainnet test client")
 
 type AllRules struct {
 	Rules []Rule
@@ -3,7 +3,6 @@ package tests
 import (
 	"fmt"
 	"github.com/HAL-xyz/zoroaster/action"
-	"github.com/HAL-xyz/zoroaster/config"
 	"github.com/HAL-xyz/zoroaster/tokenapi"
 	"github.com/HAL-xyz/zoroaster/trigger"
 	"github.com/stretchr/testify/assert"
@@ -54,9 +53,9 @@ amountOutMin is: {{ formatNumber (fromWei .Contract.MethodParameters.amountOutMi
 	// ...
 
 	// new token api reading from default node config
-	var tapi = tokenapi.New(tokenapi.NewZRPC(config.Zconf.EthNode, "test"))
+	var tapi = tokenapi.MustNew(tokenapi.NewZRPC(testConf.EthNode, "test"), tokenapi.WithConfig(testConf))
 
-	tg, err := trigger.NewTriggerFromJson(triggerJson)
+	tg, err := trigger.NewTriggerFromJson(triggerJson, nil)
 	assert.NoError(t, err)
 
 	block, err := tapi.GetRPCCli().EthGetBlockByNumber(12612785, true)
@@ -66,7 +65,7 @@ amountOutMin is: {{ formatNumber (fromWei .Contract.MethodParameters.amountOutMi
 
 	assert.Equal(t, 1, len(matches))
 
-	rendered, err := action.RenderTemplateWithData(template, matches[0].ToTemplateMatch())
+	rendered, err := action.RenderTemplateWithData(template, matches[0].ToTemplateMatch(), tapi)
 	fmt.Println(rendered)
 	fmt.Println(err)
 }
@@ -0,0 +1,130 @@
+package tokenapi
+
+import (
+	"fmt"
+	"github.com/HAL-xyz/zoroaster/utils"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	log "github.com/sirupsen/logrus"
+	"strings"
+	"time"
+)
+
+// same address on mainnet and on the test networks
+const ensRegistry = "0x00000000000c2e074ec69a0dfb2997ba6c7d2e1e"
+
+const ensRegistryABI = `[ { "constant": true, "inputs": [ { "name": "node", "type": "bytes32" } ], "name": "resolver", "outputs": [ { "name": "", "type": "address" } ], "stateMutability": "view", "type": "function" } ]`
+
+const ensResolverABI = `[ { "constant": true, "inputs": [ { "name": "node", "type": "bytes32" } ], "name": "addr", "outputs": [ { "name": "", "type": "address" } ], "stateMutability": "view", "type": "function" }, { "constant": true, "inputs": [ { "name": "node", "type": "bytes32" } ], "name": "name", "outputs": [ { "name": "", "type": "string" } ], "stateMutability": "view", "type": "function" } ]`
+
+// ENSRefreshInterval is how long names and addresses are cached before being looked up again
+const ENSRefreshInterval = 15 * time.Minute
+
+type ensEntry struct {
+	value   string
+	fetched time.Time
+}
+
+// IsENSName tells if s looks like an ENS name (e.g. vitalik.eth) rather than an address
+func IsENSName(s string) bool {
+	s = strings.TrimSpace(s)
+	return !strings.HasPrefix(s, "0x") && strings.Contains(s, ".") && !strings.ContainsAny(s, " []")
+}
+
+// Namehash computes the ENS node of a name, as per EIP-137
+func Namehash(name string) common.Hash {
+	node := common.Hash{}
+	if name == "" {
+		return node
+	}
+	labels := strings.Split(name, ".")
+	for i := len(labels) - 1; i >= 0; i-- {
+		node = crypto.Keccak256Hash(node.Bytes(), crypto.Keccak256([]byte(labels[i])))
+	}
+	return node
+}
+
+// ResolveENS returns the address an ENS name points to.
+// If a name can't be refreshed we keep using the last address we've seen.
+func (t *TokenAPI) ResolveENS(name string) (string, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	return t.ensLookup("name"+name, func(blockNo int) (string, error) {
+		node := Namehash(name).Hex()
+		resolver, err := t.ensResolver(node, blockNo)
+		if err != nil {
+			return "", err
+		}
+		res, err := t.EthCall(resolver, "addr", ensResolverABI, blockNo, node)
+		if err != nil {
+			return "", err
+		}
+		address, ok := res[0].(common.Address)
+		if !ok || address == (common.Address{}) {
+			return "", fmt.Errorf("ENS name %s doesn't resolve to any address", name)
+		}
+		return utils.NormalizeAddress(address.String()), nil
+	})
+}
+
+// LookupENS returns the primary ENS name of an address, or an empty string if it has none.
+// The reverse record is only trusted if the name resolves back to the address.
+func (t *TokenAPI) LookupENS(address string) string {
+	address = utils.NormalizeAddress(address)
+	name, err := t.ensLookup("address"+address, func(blockNo int) (string, error) {
+		node := Namehash(strings.TrimPrefix(address, "0x") + ".addr.reverse").Hex()
+		resolver, err := t.ensResolver(node, blockNo)
+		if err != nil {
+			return "", nil
+		}
+		res, err := t.EthCall(resolver, "name", ensResolverABI, blockNo, node)
+		if err != nil {
+			return "", nil
+		}
+		name, _ := res[0].(string)
+		if name == "" {
+			return "", nil
+		}
+		if forward, err := t.ResolveENS(name); err != nil || forward != address {
+			return "", nil
+		}
+		return name, nil
+	})
+	if err != nil {
+		return ""
+	}
+	return name
+}
+
+func (t *TokenAPI) ensResolver(node string, blockNo int) (string, error) {
+	res, err := t.EthCall(ensRegistry, "resolver", ensRegistryABI, blockNo, node)
+	if err != nil {
+		return "", err
+	}
+	resolver, ok := res[0].(common.Address)
+	if !ok || resolver == (common.Address{}) {
+		return "", fmt.Errorf("no ENS resolver for node %s", node)
+	}
+	return resolver.String(), nil
+}
+
+// ensLookup serves records from the cache, refreshing them every ENSRefreshInterval
+func (t *TokenAPI) ensLookup(key string, fetch func(blockNo int) (string, error)) (string, error) {
+	val, found := t.ensCache.Get(key)
+	if found && time.Since(val.(ensEntry).fetched) < ENSRefreshInterval {
+		return val.(ensEntry).value, nil
+	}
+	blockNo, err := t.rpcCli.EthBlockNumber()
+	var value string
+	if err == nil {
+		value, err = fetch(blockNo)
+	}
+	if err != nil {
+		if found {
+			log.Warnf("cannot refresh ENS record %s: %s", key, err)
+			return val.(ensEntry).value, nil
+		}
+		return "", err
+	}
+	t.ensCache.SetDefault(key, ensEntry{value, time.Now()})
+	return value, nil
+}
@@ -0,0 +1,88 @@
+package tokenapi
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+	"time"
+)
+
+const vitalik = "0xd8da6bf26964af9d7eed9e03e53415d37aa96045"
+
+// an ENS registry where vitalik.eth is set up both ways
+type mockENSCli struct {
+	IEthRpc
+	down bool
+}
+
+func (cli mockENSCli) EthBlockNumber() (int, error) {
+	if cli.down {
+		return 0, fmt.Errorf("connection refused")
+	}
+	return 13000000, nil
+}
+
+func (cli mockENSCli) MakeEthRpcCall(cntAddress, data string, blockNumber int) (string, error) {
+	word := func(s string) string {
+		return "0x" + strings.Repeat("0", 64-len(s)) + s
+	}
+	node := "0x" + data[10:]
+	switch data[2:10] {
+	case "0178b8bf": // resolver
+		if node == Namehash("vitalik.eth").Hex() || node == Namehash(vitalik[2:]+".addr.reverse").Hex() {
+			return word("4976fb03c32e5b8cfe2b6ccb31c09ba78ebaba41"), nil
+		}
+		return word(""), nil
+	case "3b3b57de": // addr
+		if node == Namehash("vitalik.eth").Hex() {
+			return word(vitalik[2:]), nil
+		}
+		return word(""), nil
+	case "691f3431": // name
+		return packString("vitalik.eth"), nil
+	}
+	return "", fmt.Errorf("unknown method %s", data[:10])
+}
+
+func TestNamehash(t *testing.T) {
+	assert.Equal(t, "0x0000000000000000000000000000000000000000000000000000000000000000", Namehash("").Hex())
+	assert.Equal(t, "0x93cdeb708b7545dc668eb9280176169d1c33cfd8ed6f04690a0bcc88a93fc4ae", Namehash("eth").Hex())
+	assert.Equal(t, "0xde9b09fd7c5f901e23a3f19fecc54828e9c848539801e86591bd9801b019f84f", Namehash("foo.eth").Hex())
+}
+
+func TestIsENSName(t *testing.T) {
+	assert.True(t, IsENSName("vitalik.eth"))
+	assert.True(t, IsENSName("pay.hal.xyz"))
+	assert.False(t, IsENSName(vitalik))
+	assert.False(t, IsENSName("[vitalik.eth]"))
+	assert.False(t, IsENSName("vitalik"))
+}
+
+func TestTokenAPI_ResolveENS(t *testing.T) {
+	tapi := MustNew(mockENSCli{})
+
+	add, err := tapi.ResolveENS("Vitalik.eth")
+	assert.NoError(t, err)
+	assert.Equal(t, vitalik, add)
+
+	_, err = tapi.ResolveENS("nobody.eth")
+	assert.Error(t, err)
+
+	assert.Equal(t, "vitalik.eth", tapi.LookupENS("0xD8dA6BF26964aF9D7eEd9e03E53415D37aA96045"))
+	// no reverse record
+	assert.Equal(t, "", tapi.LookupENS("0x4976fb03c32e5b8cfe2b6ccb31c09ba78ebaba41"))
+}
+
+func TestTokenAPI_ResolveENSStale(t *testing.T) {
+	tapi := MustNew(mockENSCli{down: true})
+
+	// records that can't be refreshed are still used
+	tapi.ensCache.SetDefault("namevitalik.eth", ensEntry{vitalik, time.Now().Add(-time.Hour)})
+	add, err := tapi.ResolveENS("vitalik.eth")
+	assert.NoError(t, err)
+	assert.Equal(t, vitalik, add)
+
+	_, err = tapi.ResolveENS("nick.eth")
+	assert.Error(t, err)
+}
@@ -0,0 +1,134 @@
+package tokenapi
+
+import (
+	"fmt"
+	"github.com/HAL-xyz/ethrpc"
+	"github.com/HAL-xyz/zoroaster/utils"
+	"github.com/ethereum/go-ethereum/common"
+	"strings"
+)
+
+// ERC-165 interface ids of the two NFT standards
+const (
+	ERC721InterfaceID  = "80ac58cd"
+	ERC1155InterfaceID = "d9b67a26"
+)
+
+const ERC721ABI = `[ { "anonymous": false, "inputs": [ { "indexed": true, "name": "from", "type": "address" }, { "indexed": true, "name": "to", "type": "address" }, { "indexed": true, "name": "tokenId", "type": "uint256" } ], "name": "Transfer", "type": "event" }, { "anonymous": false, "inputs": [ { "indexed": true, "name": "owner", "type": "address" }, { "indexed": true, "name": "approved", "type": "address" }, { "indexed": true, "name": "tokenId", "type": "uint256" } ], "name": "Approval", "type": "event" }, { "anonymous": false, "inputs": [ { "indexed": true, "name": "owner", "type": "address" }, { "indexed": true, "name": "operator", "type": "address" }, { "indexed": false, "name": "approved", "type": "bool" } ], "name": "ApprovalForAll", "type": "event" }, { "constant": true, "inputs": [], "name": "name", "outputs": [ { "name": "", "type": "string" } ], "stateMutability": "view", "type": "function" }, { "constant": true, "inputs": [], "name": "symbol", "outputs": [ { "name": "", "type": "string" } ], "stateMutability": "view", "type": "function" }, { "constant": true, "inputs": [ { "name": "owner", "type": "address" } ], "name": "balanceOf", "outputs": [ { "name": "", "type": "uint256" } ], "stateMutability": "view", "type": "function" }, { "constant": true, "inputs": [ { "name": "tokenId", "type": "uint256" } ], "name": "ownerOf", "outputs": [ { "name": "", "type": "address" } ], "stateMutability": "view", "type": "function" }, { "constant": true, "inputs": [ { "name": "tokenId", "type": "uint256" } ], "name": "tokenURI", "outputs": [ { "name": "", "type": "string" } ], "stateMutability": "view", "type": "function" } ]`
+
+const ERC1155ABI = `[ { "anonymous": false, "inputs": [ { "indexed": true, "name": "operator", "type": "address" }, { "indexed": true, "name": "from", "type": "address" }, { "indexed": true, "name": "to", "type": "address" }, { "indexed": false, "name": "id", "type": "uint256" }, { "indexed": false, "name": "value", "type": "uint256" } ], "name": "TransferSingle", "type": "event" }, { "anonymous": false, "inputs": [ { "indexed": true, "name": "operator", "type": "address" }, { "indexed": true, "name": "from", "type": "address" }, { "indexed": true, "name": "to", "type": "address" }, { "indexed": false, "name": "ids", "type": "uint256[]" }, { "indexed": false, "name": "values", "type": "uint256[]" } ], "name": "TransferBatch", "type": "event" }, { "anonymous": false, "inputs": [ { "indexed": true, "name": "account", "type": "address" }, { "indexed": true, "name": "operator", "type": "address" }, { "indexed": false, "name": "approved", "type": "bool" } ], "name": "ApprovalForAll", "type": "event" }, { "anonymous": false, "inputs": [ { "indexed": false, "name": "value", "type": "string" }, { "indexed": true, "name": "id", "type": "uint256" } ], "name": "URI", "type": "event" }, { "constant": true, "inputs": [ { "name": "account", "type": "address" }, { "name": "id", "type": "uint256" } ], "name": "balanceOf", "outputs": [ { "name": "", "type": "uint256" } ], "stateMutability": "view", "type": "function" }, { "constant": true, "inputs": [ { "name": "id", "type": "uint256" } ], "name": "uri", "outputs": [ { "name": "", "type": "string" } ], "stateMutability": "view", "type": "function" } ]`
+
+// There is no list of all the NFT collections like the one we have for ERC20 tokens,
+// so we ask each contract which standard it implements (once, then we cache the answer;
+// if the node can't be reached there is no answer, and we ask again next time).
+func (t *TokenAPI) IsERC721(address string) bool {
+	return t.implements(address, ERC721InterfaceID)
+}
+
+func (t *TokenAPI) IsERC1155(address string) bool {
+	return t.implements(address, ERC1155InterfaceID)
+}
+
+func (t *TokenAPI) implements(address, interfaceID string) bool {
+	key := utils.NormalizeAddress(address) + interfaceID
+	val, found := t.nftCache.Get(key)
+	if found {
+		return val.(bool)
+	}
+	lastBlock, err := t.rpcCli.EthBlockNumber()
+	if err != nil {
+		return false
+	}
+	ok, err := supportsInterface(t.rpcCli, address, interfaceID, lastBlock)
+	if err != nil {
+		return false
+	}
+	t.nftCache.SetDefault(key, ok)
+	return ok
+}
+
+// SupportsInterface follows the ERC-165 detection procedure
+func SupportsInterface(cli IEthRpc, address, interfaceID string, blockNo int) bool {
+	ok, _ := supportsInterface(cli, address, interfaceID, blockNo)
+	return ok
+}
+
+// supportsInterface fails when the contract can't be asked, rather than when it says no
+func supportsInterface(cli IEthRpc, address, interfaceID string, blockNo int) (bool, error) {
+	const erc165ID, invalidID = "01ffc9a7", "ffffffff"
+	ok, err := callSupportsInterface(cli, address, erc165ID, blockNo)
+	if err != nil || !ok {
+		return false, err
+	}
+	ok, err = callSupportsInterface(cli, address, invalidID, blockNo)
+	if err != nil || ok {
+		return false, err
+	}
+	return callSupportsInterface(cli, address, interfaceID, blockNo)
+}
+
+// a call that reverts is an answer too: the contract doesn't implement supportsInterface
+func callSupportsInterface(cli IEthRpc, address, interfaceID string, blockNo int) (bool, error) {
+	data := "0x01ffc9a7" + interfaceID + strings.Repeat("0", 56)
+	res, err := cli.MakeEthRpcCall(address, data, blockNo)
+	if err != nil && !isRevert(err) {
+		return false, err
+	}
+	word := common.FromHex(res)
+	return err == nil && len(word) == 32 && word[31] == 1, nil
+}
+
+func isRevert(err error) bool {
+	ethErr, ok := err.(ethrpc.EthError)
+	return ok && (ethErr.Code == 3 || strings.Contains(ethErr.Message, "revert"))
+}
+
+// OwnerOf returns the owner of an ERC-721 token, or an empty string if it doesn't exist
+func (t *TokenAPI) OwnerOf(collection string, tokenId string) string {
+	res, err := t.callNFT(collection, "ownerOf", ERC721ABI, tokenId)
+	if err != nil || len(res) != 1 {
+		return ""
+	}
+	owner, ok := res[0].(common.Address)
+	if !ok {
+		return ""
+	}
+	return utils.NormalizeAddress(owner.String())
+}
+
+// TokenURI returns the metadata URI of a token, using tokenURI() for ERC-721
+// and uri() for ERC-1155 collections, where the {id} placeholder is expanded.
+func (t *TokenAPI) TokenURI(collection string, tokenId string) string {
+	res, err := t.callNFT(collection, "tokenURI", ERC721ABI, tokenId)
+	if err == nil && len(res) == 1 {
+		return fmt.Sprintf("%v", res[0])
+	}
+	res, err = t.callNFT(collection, "uri", ERC1155ABI, tokenId)
+	if err != nil || len(res) != 1 {
+		return ""
+	}
+	// the id is substituted as lowercase hex, zero-padded to 64 chars
+	id := fmt.Sprintf("%064x", utils.MakeBigInt(tokenId))
+	return strings.ReplaceAll(fmt.Sprintf("%v", res[0]), "{id}", id)
+}
+
+// CollectionName returns the name of a collection; it's optional in both standards
+func (t *TokenAPI) CollectionName(collection string) string {
+	methodHash, err := encodeMethod("name", ERC721ABI, []Input{})
+	if err != nil {
+		return ""
+	}
+	res, err := t.callView(collection, methodHash, "name", ERC721ABI)
+	if err != nil || len(res) != 1 {
+		return ""
+	}
+	return fmt.Sprintf("%v", res[0])
+}
+
+func (t *TokenAPI) callNFT(collection, methodName, cntABI, tokenId string) ([]interface{}, error) {
+	methodHash, err := encodeMethod(methodName, cntABI, []Input{{ParameterType: "uint256", ParameterValue: tokenId}})
+	if err != nil {
+		return nil, err
+	}
+	return t.callView(collection, methodHash, methodName, cntABI)
+}
@@ -0,0 +1,101 @@
+package tokenapi
+
+import (
+	"encoding/hex"
+	"fmt"
+	"github.com/HAL-xyz/ethrpc"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+// a contract implementing ERC-721 or ERC-1155, depending on interfaceID
+type mockNFTCli struct {
+	IEthRpc
+	interfaceID string
+	calls       *int
+	err         error // returned by supportsInterface, if set
+}
+
+func (cli mockNFTCli) EthBlockNumber() (int, error) {
+	return 13000000, nil
+}
+
+func (cli mockNFTCli) MakeEthRpcCall(cntAddress, data string, blockNumber int) (string, error) {
+	*cli.calls++
+	switch data[2:10] {
+	case "01ffc9a7": // supportsInterface
+		if cli.err != nil {
+			return "", cli.err
+		}
+		id := data[10:18]
+		if id == "01ffc9a7" || id == cli.interfaceID {
+			return "0x" + strings.Repeat("0", 63) + "1", nil
+		}
+		return "0x" + strings.Repeat("0", 64), nil
+	case "6352211e": // ownerOf
+		return "0x000000000000000000000000" + "7c40c393dc0f283f318791d746d894ddd3693572", nil
+	case "c87b56dd": // tokenURI
+		if cli.interfaceID != ERC721InterfaceID {
+			return "", fmt.Errorf("execution reverted")
+		}
+		return packString("ipfs://QmHash/42"), nil
+	case "0e89341c": // uri
+		return packString("https://token-cdn-domain/{id}.json"), nil
+	case "06fdde03": // name
+		return packString("Bored Ape Yacht Club"), nil
+	}
+	return "", fmt.Errorf("unknown method %s", data[:10])
+}
+
+func packString(s string) string {
+	stringType, _ := abi.NewType("string", "", nil)
+	data, _ := abi.Arguments{{Type: stringType}}.Pack(s)
+	return "0x" + hex.EncodeToString(data)
+}
+
+func TestTokenAPI_IsERC721(t *testing.T) {
+	calls := 0
+	tapi := MustNew(mockNFTCli{interfaceID: ERC721InterfaceID, calls: &calls})
+
+	assert.True(t, tapi.IsERC721("0xBC4CA0EdA7647A8aB7C2061c2E118A18a936f13D"))
+	assert.False(t, tapi.IsERC1155("0xbc4ca0eda7647a8ab7c2061c2e118a18a936f13d"))
+
+	// answers are cached
+	calls = 0
+	assert.True(t, tapi.IsERC721("0xbc4ca0eda7647a8ab7c2061c2e118a18a936f13d"))
+	assert.Equal(t, 0, calls)
+
+	// but only definitive ones: a node that's down isn't a no
+	cli := &mockNFTCli{interfaceID: ERC721InterfaceID, calls: &calls, err: fmt.Errorf("connection refused")}
+	tapi = MustNew(cli)
+	assert.False(t, tapi.IsERC721("0xbc4ca0eda7647a8ab7c2061c2e118a18a936f13d"))
+	cli.err = nil
+	assert.True(t, tapi.IsERC721("0xbc4ca0eda7647a8ab7c2061c2e118a18a936f13d"))
+
+	// while a contract without supportsInterface is
+	cli.err = ethrpc.EthError{Code: 3, Message: "execution reverted"}
+	assert.False(t, tapi.IsERC721("0x6b175474e89094c44da98b954eedeac495271d0f"))
+	cli.err = nil
+	assert.False(t, tapi.IsERC721("0x6b175474e89094c44da98b954eedeac495271d0f"))
+}
+
+func TestTokenAPI_OwnerOf(t *testing.T) {
+	calls := 0
+	tapi := MustNew(mockNFTCli{interfaceID: ERC721InterfaceID, calls: &calls})
+
+	assert.Equal(t, "0x7c40c393dc0f283f318791d746d894ddd3693572", tapi.OwnerOf("0xbc4ca0eda7647a8ab7c2061c2e118a18a936f13d", "42"))
+	assert.Equal(t, "ipfs://QmHash/42", tapi.TokenURI("0xbc4ca0eda7647a8ab7c2061c2e118a18a936f13d", "42"))
+	assert.Equal(t, "Bored Ape Yacht Club", tapi.CollectionName("0xbc4ca0eda7647a8ab7c2061c2e118a18a936f13d"))
+}
+
+func TestTokenAPI_TokenURI1155(t *testing.T) {
+	calls := 0
+	tapi := MustNew(mockNFTCli{interfaceID: ERC1155InterfaceID, calls: &calls})
+
+	// the {id} placeholder is replaced by the hex id, padded to 64 chars
+	assert.Equal(t,
+		"https://token-cdn-domain/000000000000000000000000000000000000000000000000000000000004cce0.json",
+		tapi.TokenURI("0x495f947276749ce646f68ac8c248420045cb7b5e", "314592"))
+}
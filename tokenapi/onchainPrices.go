@@ -0,0 +1,255 @@
+package tokenapi
+
+import (
+	"fmt"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"math"
+	"math/big"
+	"strings"
+	"time"
+)
+
+// On-chain sources only quote in usd, as they rely on feeds and pools priced in dollars (or stablecoins).
+
+const aggregatorABI = `[ { "inputs": [], "name": "decimals", "outputs": [ { "name": "", "type": "uint8" } ], "stateMutability": "view", "type": "function" }, { "inputs": [], "name": "latestRoundData", "outputs": [ { "name": "roundId", "type": "uint80" }, { "name": "answer", "type": "int256" }, { "name": "startedAt", "type": "uint256" }, { "name": "updatedAt", "type": "uint256" }, { "name": "answeredInRound", "type": "uint80" } ], "stateMutability": "view", "type": "function" } ]`
+
+// Chainlink USD aggregators, by token address
+var chainlinkFeeds = map[string]map[string]string{
+	"1_eth_mainnet": {
+		"0xeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeee": "0x5f4ec3df9cbd43714fe2740f5e3616155c5b8419", // ETH / USD
+		"0x0000000000000000000000000000000000000000": "0x5f4ec3df9cbd43714fe2740f5e3616155c5b8419",
+		"0xc02aaa39b223fe8d0a0e5c4f27ead9083c756cc2": "0x5f4ec3df9cbd43714fe2740f5e3616155c5b8419", // WETH
+	},
+	"5_polygon_mainnet": {
+		"0x0000000000000000000000000000000000000000": "0xab594600376ec9fd91f8e885dadf0ce036862de0", // MATIC / USD
+		"0x0d500b1d8e8ef31e21c99d1db9a6444d3adf1270": "0xab594600376ec9fd91f8e885dadf0ce036862de0", // WMATIC
+	},
+}
+
+// answers older than this are not trusted
+const chainlinkMaxAge = 24 * time.Hour
+
+// A ChainlinkSource reads the latest answer of Chainlink aggregators
+type ChainlinkSource struct {
+	Feeds map[string]string // token address -> aggregator address
+	api   ITokenAPI
+}
+
+func NewChainlinkSource(feeds map[string]string, api ITokenAPI) *ChainlinkSource {
+	return &ChainlinkSource{Feeds: feeds, api: api}
+}
+
+func (s *ChainlinkSource) Name() string {
+	return "chainlink"
+}
+
+func (s *ChainlinkSource) Price(tokenAddress, fiatCurrency string) (float32, error) {
+	blockNo, err := s.api.GetRPCCli().EthBlockNumber()
+	if err != nil {
+		return 0, ApiNetworkErr{fmt.Sprintf("network error for currency %s fiat %s, %s", tokenAddress, fiatCurrency, err)}
+	}
+	return s.priceAt(tokenAddress, fiatCurrency, blockNo, time.Now())
+}
+
+// PriceAtBlock returns the answer of the aggregator at blockNo, which must be recent enough for the block's timestamp
+func (s *ChainlinkSource) PriceAtBlock(tokenAddress, fiatCurrency string, blockNo, timestamp int) (float32, error) {
+	return s.priceAt(tokenAddress, fiatCurrency, blockNo, time.Unix(int64(timestamp), 0))
+}
+
+func (s *ChainlinkSource) priceAt(tokenAddress, fiatCurrency string, blockNo int, now time.Time) (float32, error) {
+	feed, ok := s.Feeds[tokenAddress]
+	if !ok || fiatCurrency != "usd" {
+		return 0, ApiNotFoundErr{fmt.Sprintf("not found error for currency %s fiat %s: no chainlink feed", tokenAddress, fiatCurrency)}
+	}
+	round, err := s.api.EthCall(feed, "latestRoundData", aggregatorABI, blockNo)
+	if err != nil {
+		return 0, ApiNetworkErr{fmt.Sprintf("network error for currency %s fiat %s, %s", tokenAddress, fiatCurrency, err)}
+	}
+	decimals, err := s.api.EthCall(feed, "decimals", aggregatorABI, blockNo)
+	if err != nil {
+		return 0, ApiNetworkErr{fmt.Sprintf("network error for currency %s fiat %s, %s", tokenAddress, fiatCurrency, err)}
+	}
+
+	answer, ok1 := round[1].(*big.Int)
+	updatedAt, ok2 := round[3].(*big.Int)
+	dec, ok3 := decimals[0].(uint8)
+	if !ok1 || !ok2 || !ok3 || answer.Sign() <= 0 {
+		return 0, ApiNotFoundErr{fmt.Sprintf("not found error for currency %s fiat %s: invalid chainlink answer", tokenAddress, fiatCurrency)}
+	}
+	if now.Sub(time.Unix(updatedAt.Int64(), 0)) > chainlinkMaxAge {
+		return 0, ApiNotFoundErr{fmt.Sprintf("not found error for currency %s fiat %s: stale chainlink answer", tokenAddress, fiatCurrency)}
+	}
+	return scaleDown(answer, int(dec)), nil
+}
+
+const uniswapV2PairABI = `[ { "inputs": [], "name": "getReserves", "outputs": [ { "name": "reserve0", "type": "uint112" }, { "name": "reserve1", "type": "uint112" }, { "name": "blockTimestampLast", "type": "uint32" } ], "stateMutability": "view", "type": "function" }, { "inputs": [], "name": "price0CumulativeLast", "outputs": [ { "name": "", "type": "uint256" } ], "stateMutability": "view", "type": "function" } ]`
+
+const uniswapV3PoolABI = `[ { "inputs": [ { "name": "secondsAgos", "type": "uint32[]" } ], "name": "observe", "outputs": [ { "name": "tickCumulatives", "type": "int56[]" }, { "name": "secondsPerLiquidityCumulativeX128s", "type": "uint160[]" } ], "stateMutability": "view", "type": "function" } ]`
+
+// A Uniswap pool between a token and a USD stablecoin
+type UniswapPool struct {
+	Address       string
+	Version       int  // 2 or 3
+	TokenIsToken0 bool // otherwise the stablecoin is token0
+	TokenDecimals int
+	QuoteDecimals int
+}
+
+var uniswapPools = map[string]map[string]UniswapPool{
+	"1_eth_mainnet": {
+		// the USDC/WETH 0.05% pool
+		"0xeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeee": {"0x88e6a0c2ddd26feeb64f039a2c41296fcb3f5640", 3, false, 18, 6},
+		"0x0000000000000000000000000000000000000000": {"0x88e6a0c2ddd26feeb64f039a2c41296fcb3f5640", 3, false, 18, 6},
+		"0xc02aaa39b223fe8d0a0e5c4f27ead9083c756cc2": {"0x88e6a0c2ddd26feeb64f039a2c41296fcb3f5640", 3, false, 18, 6},
+	},
+}
+
+// A UniswapSource prices tokens with the time-weighted average price of their pools
+type UniswapSource struct {
+	Pools  map[string]UniswapPool // token address -> pool
+	Window uint32                 // seconds; defaults to 30 minutes
+	cli    IEthRpc
+}
+
+func NewUniswapSource(pools map[string]UniswapPool, window uint32, cli IEthRpc) *UniswapSource {
+	return &UniswapSource{Pools: pools, Window: window, cli: cli}
+}
+
+func (s *UniswapSource) Name() string {
+	return "uniswap"
+}
+
+func (s *UniswapSource) Price(tokenAddress, fiatCurrency string) (float32, error) {
+	blockNo, err := s.cli.EthBlockNumber()
+	if err != nil {
+		return 0, ApiNetworkErr{fmt.Sprintf("network error for currency %s fiat %s, %s", tokenAddress, fiatCurrency, err)}
+	}
+	return s.PriceAtBlock(tokenAddress, fiatCurrency, blockNo, 0)
+}
+
+// PriceAtBlock returns the average price over the window ending at blockNo
+func (s *UniswapSource) PriceAtBlock(tokenAddress, fiatCurrency string, blockNo, timestamp int) (float32, error) {
+	pool, ok := s.Pools[tokenAddress]
+	if !ok || fiatCurrency != "usd" {
+		return 0, ApiNotFoundErr{fmt.Sprintf("not found error for currency %s fiat %s: no uniswap pool", tokenAddress, fiatCurrency)}
+	}
+	window := s.Window
+	if window == 0 {
+		window = 30 * 60
+	}
+
+	// the price of token0 in token1, without decimals
+	var rawPrice float64
+	var err error
+	switch pool.Version {
+	case 2:
+		rawPrice, err = s.twapV2(pool.Address, window, blockNo)
+	case 3:
+		rawPrice, err = s.twapV3(pool.Address, window, blockNo)
+	default:
+		return 0, ApiNotFoundErr{fmt.Sprintf("not found error for currency %s fiat %s: unknown uniswap version %d", tokenAddress, fiatCurrency, pool.Version)}
+	}
+	if err != nil {
+		return 0, ApiNetworkErr{fmt.Sprintf("network error for currency %s fiat %s, %s", tokenAddress, fiatCurrency, err)}
+	}
+	if rawPrice <= 0 {
+		return 0, ApiNotFoundErr{fmt.Sprintf("not found error for currency %s fiat %s: empty uniswap pool", tokenAddress, fiatCurrency)}
+	}
+
+	if pool.TokenIsToken0 {
+		return float32(rawPrice * math.Pow10(pool.TokenDecimals-pool.QuoteDecimals)), nil
+	}
+	return float32(1 / rawPrice * math.Pow10(pool.TokenDecimals-pool.QuoteDecimals)), nil
+}
+
+// twapV3 averages the pool's tick over the window ending at blockNo, as per Uniswap's OracleLibrary
+func (s *UniswapSource) twapV3(pool string, window uint32, blockNo int) (float64, error) {
+	res, err := callPool(s.cli, pool, uniswapV3PoolABI, "observe", blockNo, []uint32{window, 0})
+	if err != nil {
+		return 0, err
+	}
+	tickCumulatives, ok := res[0].([]*big.Int)
+	if !ok || len(tickCumulatives) != 2 {
+		return 0, fmt.Errorf("unexpected observe() output")
+	}
+	delta := new(big.Int).Sub(tickCumulatives[1], tickCumulatives[0])
+	// Euclidean division rounds negative ticks down, like the OracleLibrary does
+	avgTick := new(big.Int).Div(delta, big.NewInt(int64(window)))
+	return math.Pow(1.0001, float64(avgTick.Int64())), nil
+}
+
+// twapV2 compares the pool's cumulative price at blockNo and a window before;
+// blocks are ~12 seconds apart.
+func (s *UniswapSource) twapV2(pool string, window uint32, blockNo int) (float64, error) {
+	cumNow, tsNow, err := s.cumulativeV2(pool, blockNo)
+	if err != nil {
+		return 0, err
+	}
+	cumPast, tsPast, err := s.cumulativeV2(pool, blockNo-int(window/12))
+	if err != nil {
+		return 0, err
+	}
+	if tsNow <= tsPast {
+		return 0, fmt.Errorf("invalid twap window")
+	}
+	// cumulative prices are UQ112x112 numbers
+	avg := new(big.Float).SetInt(new(big.Int).Sub(cumNow, cumPast))
+	avg.Quo(avg, new(big.Float).SetInt(new(big.Int).Lsh(big.NewInt(1), 112)))
+	avg.Quo(avg, big.NewFloat(float64(tsNow-tsPast)))
+	res, _ := avg.Float64()
+	return res, nil
+}
+
+// cumulativeV2 returns price0CumulativeLast as of the block's timestamp, even if the pair hasn't been touched since
+func (s *UniswapSource) cumulativeV2(pool string, blockNo int) (*big.Int, int, error) {
+	block, err := s.cli.EthGetBlockByNumber(blockNo, false)
+	if err != nil {
+		return nil, 0, err
+	}
+	if block == nil {
+		return nil, 0, fmt.Errorf("block %d not found", blockNo)
+	}
+	cumulative, err := callPool(s.cli, pool, uniswapV2PairABI, "price0CumulativeLast", blockNo)
+	if err != nil {
+		return nil, 0, err
+	}
+	reserves, err := callPool(s.cli, pool, uniswapV2PairABI, "getReserves", blockNo)
+	if err != nil {
+		return nil, 0, err
+	}
+	cum, ok1 := cumulative[0].(*big.Int)
+	reserve0, ok2 := reserves[0].(*big.Int)
+	reserve1, ok3 := reserves[1].(*big.Int)
+	tsLast, ok4 := reserves[2].(uint32)
+	if !ok1 || !ok2 || !ok3 || !ok4 || reserve0.Sign() == 0 {
+		return nil, 0, fmt.Errorf("unexpected uniswap pair outputs")
+	}
+	elapsed := int64(block.Timestamp) - int64(tsLast)
+	spot := new(big.Int).Div(new(big.Int).Lsh(reserve1, 112), reserve0)
+	cum = new(big.Int).Add(cum, spot.Mul(spot, big.NewInt(elapsed)))
+	return cum, block.Timestamp, nil
+}
+
+func callPool(cli IEthRpc, pool, poolABI, method string, blockNo int, args ...interface{}) ([]interface{}, error) {
+	abiObj, err := abi.JSON(strings.NewReader(poolABI))
+	if err != nil {
+		return nil, err
+	}
+	data, err := abiObj.Pack(method, args...)
+	if err != nil {
+		return nil, err
+	}
+	rawData, err := cli.MakeEthRpcCall(pool, "0x"+common.Bytes2Hex(data), blockNo)
+	if err != nil {
+		return nil, err
+	}
+	return abiObj.Methods[method].Outputs.UnpackValues(common.FromHex(rawData))
+}
+
+func scaleDown(v *big.Int, decimals int) float32 {
+	f := new(big.Float).SetInt(v)
+	f.Quo(f, new(big.Float).SetFloat64(math.Pow10(decimals)))
+	res, _ := f.Float32()
+	return res
+}
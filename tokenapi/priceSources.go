@@ -0,0 +1,298 @@
+package tokenapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/patrickmn/go-cache"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// A PriceSource knows the price of (some) tokens in (some) fiat currencies.
+// Sources return an ApiNotFoundErr for prices they don't know about,
+// and an ApiNetworkErr when they can't be reached.
+type PriceSource interface {
+	Name() string
+	Price(tokenAddress, fiatCurrency string) (float32, error)
+}
+
+// A HistoricalPriceSource also knows past prices
+type HistoricalPriceSource interface {
+	PriceSource
+	PriceAtDate(tokenAddress, fiatCurrency, date string) (float32, error)
+}
+
+// A BlockPriceSource knows the price of tokens as of a given block: on-chain sources read their
+// contracts at the block, REST sources look at the price history around the block's timestamp.
+type BlockPriceSource interface {
+	PriceSource
+	PriceAtBlock(tokenAddress, fiatCurrency string, blockNo, timestamp int) (float32, error)
+}
+
+// A Price and where it comes from
+type Price struct {
+	Value  float32
+	Source string
+}
+
+// The price sources of each network, in order of priority; sources are referred to by name
+// and can be reordered (or left out) with the PRICE_SOURCES env variable.
+var defaultPriceSources = map[string][]string{
+	"1_eth_mainnet":     {"coingecko", "custom", "chainlink", "uniswap"},
+	"3_xdai_mainnet":    {"coingecko", "custom"},
+	"4_binance_mainnet": {"coingecko", "custom"},
+	"5_polygon_mainnet": {"coingecko", "custom", "chainlink"},
+}
+
+// NewPriceSources returns the price sources of a network, in the given order;
+// an empty order means the network's default.
+func NewPriceSources(network string, order []string, t *TokenAPI) ([]PriceSource, error) {
+	if len(order) == 0 {
+		order = defaultPriceSources[network]
+	}
+	if len(order) == 0 {
+		order = []string{"coingecko", "custom"}
+	}
+	sources := make([]PriceSource, 0, len(order))
+	for _, name := range order {
+		switch name {
+		case "coingecko":
+			sources = append(sources, NewCoinGeckoSource(t.httpCli, coingeckoPlatforms[network]))
+		case "custom":
+			sources = append(sources, &RESTSource{
+				Label:       "custom",
+				URLTemplate: "https://xyxoolw445.execute-api.us-east-1.amazonaws.com/dev/{address}",
+				HttpCli:     t.httpCli,
+			})
+		case "chainlink":
+			sources = append(sources, NewChainlinkSource(chainlinkFeeds[network], t))
+		case "uniswap":
+			sources = append(sources, NewUniswapSource(uniswapPools[network], 0, t.rpcCli))
+		default:
+			return nil, fmt.Errorf("unknown price source: %s", name)
+		}
+	}
+	return sources, nil
+}
+
+// the platform ids CoinGecko uses for token addresses
+var coingeckoPlatforms = map[string]string{
+	"1_eth_mainnet":     "ethereum",
+	"3_xdai_mainnet":    "xdai",
+	"4_binance_mainnet": "binance-smart-chain",
+	"5_polygon_mainnet": "polygon-pos",
+}
+
+// the CoinGecko ids of the native coins, by platform
+var coingeckoNativeCoins = map[string]string{
+	"ethereum":            "ethereum",
+	"xdai":                "xdai",
+	"binance-smart-chain": "binancecoin",
+	"polygon-pos":         "matic-network",
+}
+
+type CoinGeckoSource struct {
+	httpCli    *http.Client
+	platform   string
+	nativeCoin string            // the id of the platform's coin, e.g. binancecoin
+	idsMap     map[string]string // token address -> coingecko id, for historical prices
+	charts     *cache.Cache      // hourly price charts, for prices at a block
+	sync.Mutex
+}
+
+func NewCoinGeckoSource(httpCli *http.Client, platform string) *CoinGeckoSource {
+	return &CoinGeckoSource{
+		httpCli:    httpCli,
+		platform:   platform,
+		nativeCoin: coingeckoNativeCoins[platform],
+		idsMap:     map[string]string{},
+		charts:     cache.New(24*time.Hour, time.Hour),
+	}
+}
+
+func (s *CoinGeckoSource) Name() string {
+	return "coingecko"
+}
+
+func (s *CoinGeckoSource) Price(tokenAddress, fiatCurrency string) (float32, error) {
+	if isEthereumAddress(tokenAddress) {
+		if s.nativeCoin == "" {
+			return 0, ApiNotFoundErr{fmt.Sprintf("not found error for currency %s: no native coin on platform %q", tokenAddress, s.platform)}
+		}
+		url := fmt.Sprintf("https://api.coingecko.com/api/v3/simple/price?ids=%s&vs_currencies=%s", s.nativeCoin, fiatCurrency)
+		return fetchPrice(s.httpCli, url, s.nativeCoin, fiatCurrency)
+	}
+	url := fmt.Sprintf("https://api.coingecko.com/api/v3/simple/token_price/%s?contract_addresses=%s&vs_currencies=%s", s.platform, tokenAddress, fiatCurrency)
+	return fetchPrice(s.httpCli, url, tokenAddress, fiatCurrency)
+}
+
+// PriceAtDate returns the price of a token on a date formatted as dd-mm-yyyy
+func (s *CoinGeckoSource) PriceAtDate(tokenAddress, fiatCurrency, date string) (float32, error) {
+	id, err := s.coinId(tokenAddress)
+	if err != nil {
+		return 0, err
+	}
+
+	url := fmt.Sprintf("https://api.coingecko.com/api/v3/coins/%s/history?date=%s&localization=false", id, date)
+	body, err := httpGet(s.httpCli, url)
+	if err != nil {
+		return 0, ApiNetworkErr{fmt.Sprintf("network error for currency %s fiat %s, %s", tokenAddress, fiatCurrency, err)}
+	}
+
+	m := map[string]json.RawMessage{}
+	if err = json.Unmarshal(body, &m); err != nil {
+		return 0, ApiNotFoundErr{fmt.Sprintf("not found error for currency %s fiat %s, unexpected json input", tokenAddress, fiatCurrency)}
+	}
+	mm := map[string]map[string]float32{}
+	if err = json.Unmarshal(m["market_data"], &mm); err != nil {
+		return 0, ApiNotFoundErr{fmt.Sprintf("not found error for currency %s fiat %s, unexpected json input", tokenAddress, fiatCurrency)}
+	}
+	price, ok := mm["current_price"][fiatCurrency]
+	if !ok {
+		return 0, ApiNotFoundErr{fmt.Sprintf("not found error for currency %s fiat %s", tokenAddress, fiatCurrency)}
+	}
+	return price, nil
+}
+
+// PriceAtBlock returns the last price CoinGecko has at the block's timestamp.
+// Charts are bucketed by the hour, so that all the blocks of an hour are priced from the same data.
+func (s *CoinGeckoSource) PriceAtBlock(tokenAddress, fiatCurrency string, blockNo, timestamp int) (float32, error) {
+	id, err := s.coinId(tokenAddress)
+	if err != nil {
+		return 0, err
+	}
+	bucket := timestamp - timestamp%3600
+	prices, err := s.chart(id, fiatCurrency, bucket)
+	if err != nil {
+		return 0, ApiNetworkErr{fmt.Sprintf("network error for currency %s fiat %s, %s", tokenAddress, fiatCurrency, err)}
+	}
+	// prices are sorted by time, in milliseconds
+	var price float64
+	found := false
+	for _, p := range prices {
+		if int(p[0]/1000) > timestamp {
+			break
+		}
+		price, found = p[1], true
+	}
+	if !found {
+		return 0, ApiNotFoundErr{fmt.Sprintf("not found error for currency %s fiat %s at block %d", tokenAddress, fiatCurrency, blockNo)}
+	}
+	return float32(price), nil
+}
+
+// chart returns the prices of the hour starting at bucket, along with the previous hour
+func (s *CoinGeckoSource) chart(id, fiatCurrency string, bucket int) ([][2]float64, error) {
+	key := fmt.Sprintf("%s%s%d", id, fiatCurrency, bucket)
+	if prices, found := s.charts.Get(key); found {
+		return prices.([][2]float64), nil
+	}
+	url := fmt.Sprintf("https://api.coingecko.com/api/v3/coins/%s/market_chart/range?vs_currency=%s&from=%d&to=%d", id, fiatCurrency, bucket-3600, bucket+3600)
+	body, err := httpGet(s.httpCli, url)
+	if err != nil {
+		return nil, err
+	}
+	var chart struct {
+		Prices [][2]float64 `json:"prices"`
+	}
+	if err = json.Unmarshal(body, &chart); err != nil {
+		return nil, fmt.Errorf("unexpected json input: %s", err)
+	}
+	// the current hour is still being filled in
+	if int64(bucket+3600) < time.Now().Unix() {
+		s.charts.SetDefault(key, chart.Prices)
+	}
+	return chart.Prices, nil
+}
+
+func (s *CoinGeckoSource) coinId(tokenAddress string) (string, error) {
+	// first time we run this we download the full list of token-ids for Coingecko
+	if err := s.loadIds(); err != nil {
+		return "", err
+	}
+	s.Lock()
+	id, ok := s.idsMap[tokenAddress]
+	s.Unlock()
+	if !ok {
+		return "", ApiNotFoundErr{fmt.Sprintf("not found error for currency %s: unknown coingecko id", tokenAddress)}
+	}
+	return id, nil
+}
+
+func (s *CoinGeckoSource) loadIds() error {
+	s.Lock()
+	defer s.Unlock()
+	if len(s.idsMap) > 0 {
+		return nil
+	}
+	body, err := httpGet(s.httpCli, "https://api.coingecko.com/api/v3/coins/list?include_platform=true")
+	if err != nil {
+		return ApiNetworkErr{fmt.Sprintf("cannot download coingecko ids: %s", err)}
+	}
+	ids := GeckoIDSJson{}
+	if err = json.Unmarshal(body, &ids); err != nil {
+		return ApiNetworkErr{fmt.Sprintf("cannot read coingecko ids: %s", err)}
+	}
+
+	// create a map tokenAdd -> coinGecko-id, for the tokens on our platform
+	for _, e := range ids {
+		if address := e.Platforms[s.platform]; address != "" {
+			s.idsMap[strings.ToLower(address)] = e.ID
+		}
+	}
+	// add the native coin
+	if s.nativeCoin != "" {
+		s.idsMap["0xeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeee"] = s.nativeCoin
+		s.idsMap["0x0000000000000000000000000000000000000000"] = s.nativeCoin
+	}
+	return nil
+}
+
+// A RESTSource is any endpoint replying in CoinGecko's format, i.e.
+// {"0xb1cd6e4153b2a390cf00a6556b0fc1458c4a5533": {"usd": 1.58}}
+// where {address} and {fiat} in the URL template are replaced with the token address and the currency.
+type RESTSource struct {
+	Label       string
+	URLTemplate string
+	HttpCli     *http.Client
+}
+
+func (s *RESTSource) Name() string {
+	return s.Label
+}
+
+func (s *RESTSource) Price(tokenAddress, fiatCurrency string) (float32, error) {
+	url := strings.NewReplacer("{address}", tokenAddress, "{fiat}", fiatCurrency).Replace(s.URLTemplate)
+	return fetchPrice(s.HttpCli, url, tokenAddress, fiatCurrency)
+}
+
+func fetchPrice(httpCli *http.Client, url, key, fiatCurrency string) (float32, error) {
+	body, err := httpGet(httpCli, url)
+	if err != nil {
+		return 0, ApiNetworkErr{fmt.Sprintf("network error for currency %s fiat %s, %s", key, fiatCurrency, err.Error())}
+	}
+
+	var currencyMap map[string]map[string]float32
+	err = json.Unmarshal(body, &currencyMap)
+	if err != nil {
+		return 0, ApiNotFoundErr{fmt.Sprintf("not found error for currency %s fiat %s, unexpected json input", key, fiatCurrency)}
+	}
+
+	val, ok := currencyMap[key][fiatCurrency]
+	if !ok {
+		return 0, ApiNotFoundErr{fmt.Sprintf("not found error for currency %s fiat %s", key, fiatCurrency)}
+	}
+	return val, nil
+}
+
+func httpGet(httpCli *http.Client, url string) ([]byte, error) {
+	resp, err := httpCli.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	return ioutil.ReadAll(resp.Body)
+}
@@ -0,0 +1,328 @@
+package tokenapi
+
+import (
+	"fmt"
+	"github.com/HAL-xyz/zoroaster/config"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+type stubSource struct {
+	name   string
+	prices map[string]float32
+	err    error
+	calls  int
+}
+
+func (s *stubSource) Name() string {
+	return s.name
+}
+
+func (s *stubSource) Price(tokenAddress, fiatCurrency string) (float32, error) {
+	s.calls++
+	if s.err != nil {
+		return 0, s.err
+	}
+	price, ok := s.prices[tokenAddress+fiatCurrency]
+	if !ok {
+		return 0, ApiNotFoundErr{"not found"}
+	}
+	return price, nil
+}
+
+// a stub that also knows prices at blocks, e.g. dai+"usd"+"100"
+type stubBlockSource struct {
+	stubSource
+}
+
+func (s *stubBlockSource) PriceAtBlock(tokenAddress, fiatCurrency string, blockNo, timestamp int) (float32, error) {
+	return s.Price(tokenAddress, fmt.Sprintf("%s%d", fiatCurrency, blockNo))
+}
+
+const dai = "0x6b175474e89094c44da98b954eedeac495271d0f"
+const usdc = "0xa0b86991c6218b36c1d19d4a2e9eb0ce3606eb48"
+
+func TestTokenAPI_GetPrice(t *testing.T) {
+	down := &stubSource{name: "down", err: ApiNetworkErr{"connection refused"}}
+	first := &stubSource{name: "first", prices: map[string]float32{dai + "usd": 1.001}}
+	second := &stubSource{name: "second", prices: map[string]float32{dai + "usd": 1.002, usdc + "usd": 0.999}}
+
+	tapi := MustNew(mockNFTCli{})
+	tapi.SetPriceSources(down, first, second)
+
+	// sources are tried in order, and the source of each price is recorded
+	price, err := tapi.GetPrice("0x"+strings.ToUpper(dai[2:]), "USD")
+	assert.NoError(t, err)
+	assert.Equal(t, Price{1.001, "first"}, price)
+
+	price, err = tapi.GetPrice(usdc, "usd")
+	assert.NoError(t, err)
+	assert.Equal(t, Price{0.999, "second"}, price)
+
+	// cached
+	rate, err := tapi.GetExchangeRate(usdc, "usd")
+	assert.NoError(t, err)
+	assert.Equal(t, float32(0.999), rate)
+	assert.Equal(t, 1, second.calls)
+	assert.Equal(t, 1, tapi.fiatStats["first"])
+	assert.Equal(t, 1, tapi.fiatStats["second"])
+
+	// nobody knows, but one of the sources might have
+	_, err = tapi.GetPrice("0x1f9840a85d5af5bf1d1762f925bdaddc4201f984", "usd")
+	_, ok := err.(ApiNetworkErr)
+	assert.True(t, ok)
+
+	tapi.SetPriceSources(first)
+	_, err = tapi.GetPrice("0x1f9840a85d5af5bf1d1762f925bdaddc4201f984", "usd")
+	_, ok = err.(ApiNotFoundErr)
+	assert.True(t, ok)
+
+	// no historical sources
+	_, err = tapi.GetExchangeRateAtDate(dai, "usd", "yesterday")
+	assert.Error(t, err)
+}
+
+func TestTokenAPI_GetExchangeRateAtBlock(t *testing.T) {
+	current := &stubSource{name: "current", prices: map[string]float32{dai + "usd": 1.001, dai + "usd100": 1.001}}
+	atBlock := &stubBlockSource{stubSource{name: "atBlock", prices: map[string]float32{dai + "usd100": 0.998, dai + "usd101": 0.999}}}
+
+	tapi := MustNew(mockPricesCli{})
+	tapi.SetPriceSources(current, atBlock)
+
+	// only block sources are asked
+	rate, err := tapi.GetExchangeRateAtBlock(dai, "USD", 100, 2000)
+	assert.NoError(t, err)
+	assert.Equal(t, float32(0.998), rate)
+	assert.Equal(t, 0, current.calls)
+
+	// the timestamp is read from the block if needed
+	rate, err = tapi.GetExchangeRateAtBlock(dai, "usd", 101, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, float32(0.999), rate)
+
+	// cached by block
+	_, _ = tapi.GetExchangeRateAtBlock(dai, "usd", 100, 2000)
+	assert.Equal(t, 2, atBlock.calls)
+
+	// tokens the block sources don't know are priced at the current price, which is kept for the block
+	rate, err = tapi.GetExchangeRateAtBlock(usdc, "usd", 102, 2000)
+	_, ok := err.(ApiNotFoundErr)
+	assert.True(t, ok)
+	current.prices[usdc+"usd"] = 1.002
+	rate, err = tapi.GetExchangeRateAtBlock(usdc, "usd", 102, 2000)
+	assert.NoError(t, err)
+	assert.Equal(t, float32(1.002), rate)
+	current.prices[usdc+"usd"] = 1.003
+	tapi.fiatCache.Flush()
+	rate, err = tapi.GetExchangeRateAtBlock(usdc, "usd", 102, 2000)
+	assert.NoError(t, err)
+	assert.Equal(t, float32(1.002), rate)
+
+	// the AtBlock view converts at the block's price
+	rate, err = AtBlock(tapi, 101, 2000).GetExchangeRate(dai, "usd")
+	assert.NoError(t, err)
+	assert.Equal(t, float32(0.999), rate)
+	rate, err = tapi.GetExchangeRate(dai, "usd")
+	assert.NoError(t, err)
+	assert.Equal(t, float32(1.001), rate)
+}
+
+// serves CoinGecko's price charts
+type mockChartTransport struct {
+	urls []string
+}
+
+func (m *mockChartTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	m.urls = append(m.urls, req.URL.String())
+	body := `{"prices": [[1600000000000, 380.5], [1600001800000, 381.5], [1600003600000, 382.5], [1600005400000, 383.5]]}`
+	return &http.Response{StatusCode: 200, Body: ioutil.NopCloser(strings.NewReader(body)), Header: http.Header{}}, nil
+}
+
+// serves CoinGecko's list of coins, and the price of any coin
+type mockCoinListTransport struct {
+	urls []string
+}
+
+func (m *mockCoinListTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	m.urls = append(m.urls, req.URL.String())
+	body := `{"binancecoin": {"usd": 300}}`
+	if req.URL.Path == "/api/v3/coins/list" {
+		list, err := ioutil.ReadFile("resources/coin_list.json")
+		if err != nil {
+			return nil, err
+		}
+		body = string(list)
+	}
+	return &http.Response{StatusCode: 200, Body: ioutil.NopCloser(strings.NewReader(body)), Header: http.Header{}}, nil
+}
+
+func TestCoinGeckoSource_Platforms(t *testing.T) {
+	transport := &mockCoinListTransport{}
+	source := NewCoinGeckoSource(&http.Client{Transport: transport}, "binance-smart-chain")
+
+	// only the tokens of the platform, and its own coin
+	id, err := source.coinId("0x8ac76a51cc950d9822d68b83fe1ad97b32cd580d")
+	assert.NoError(t, err)
+	assert.Equal(t, "usd-coin", id)
+	_, err = source.coinId(usdc)
+	assert.Error(t, err)
+	id, err = source.coinId("0x0000000000000000000000000000000000000000")
+	assert.NoError(t, err)
+	assert.Equal(t, "binancecoin", id)
+
+	price, err := source.Price("0xeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeee", "usd")
+	assert.NoError(t, err)
+	assert.Equal(t, float32(300), price)
+	assert.Equal(t, "https://api.coingecko.com/api/v3/simple/price?ids=binancecoin&vs_currencies=usd", transport.urls[len(transport.urls)-1])
+
+	// unknown platforms have no native coin
+	_, err = NewCoinGeckoSource(&http.Client{Transport: transport}, "").Price("0xeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeee", "usd")
+	_, ok := err.(ApiNotFoundErr)
+	assert.True(t, ok)
+}
+
+func TestCoinGeckoSource_PriceAtBlock(t *testing.T) {
+	transport := &mockChartTransport{}
+	source := NewCoinGeckoSource(&http.Client{Transport: transport}, "ethereum")
+	source.idsMap["0xeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeee"] = "ethereum"
+
+	price, err := source.PriceAtBlock("0xeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeee", "usd", 10000000, 1600001900)
+	assert.NoError(t, err)
+	assert.Equal(t, float32(381.5), price)
+	assert.Equal(t, "https://api.coingecko.com/api/v3/coins/ethereum/market_chart/range?vs_currency=usd&from=1599994800&to=1600002000", transport.urls[0])
+
+	// same hour, same chart
+	price, err = source.PriceAtBlock("0xeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeee", "usd", 10000001, 1600001850)
+	assert.NoError(t, err)
+	assert.Equal(t, float32(381.5), price)
+	assert.Len(t, transport.urls, 1)
+
+	_, err = source.PriceAtBlock("0xeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeee", "usd", 9999000, 1599990000)
+	_, ok := err.(ApiNotFoundErr)
+	assert.True(t, ok)
+
+	_, err = source.PriceAtBlock(dai, "usd", 10000000, 1600001900)
+	_, ok = err.(ApiNotFoundErr)
+	assert.True(t, ok)
+}
+
+func TestNewPriceSources(t *testing.T) {
+	tapi := MustNew(mockNFTCli{})
+
+	sources, err := NewPriceSources("1_eth_mainnet", nil, tapi)
+	assert.NoError(t, err)
+	names := make([]string, len(sources))
+	for i, s := range sources {
+		names[i] = s.Name()
+	}
+	assert.Equal(t, []string{"coingecko", "custom", "chainlink", "uniswap"}, names)
+
+	sources, err = NewPriceSources("1_eth_mainnet", []string{"chainlink", "coingecko"}, tapi)
+	assert.NoError(t, err)
+	assert.Equal(t, "chainlink", sources[0].Name())
+	assert.Len(t, sources, 2)
+
+	_, err = NewPriceSources("1_eth_mainnet", []string{"oracle"}, tapi)
+	assert.Error(t, err)
+
+	// the TokenAPI doesn't start with an unknown source
+	_, err = New(mockNFTCli{}, WithConfig(&config.ZConfiguration{Network: "1_eth_mainnet", PriceSources: []string{"oracle"}}))
+	assert.EqualError(t, err, "cannot init TokenAPI: unknown price source: oracle")
+}
+
+// a Chainlink aggregator and two Uniswap pools where ETH is worth $2000
+type mockPricesCli struct {
+	IEthRpc
+}
+
+func (cli mockPricesCli) EthBlockNumber() (int, error) {
+	return 100000, nil
+}
+
+func (cli mockPricesCli) EthGetBlockByNumber(number int, withTransactions bool) (*Block, error) {
+	if number == 100000 {
+		return &Block{Number: number, Timestamp: 3800}, nil
+	}
+	return &Block{Number: number, Timestamp: 2000}, nil
+}
+
+func (cli mockPricesCli) MakeEthRpcCall(cntAddress, data string, blockNumber int) (string, error) {
+	aggregator, _ := abi.JSON(strings.NewReader(aggregatorABI))
+	pairV2, _ := abi.JSON(strings.NewReader(uniswapV2PairABI))
+	poolV3, _ := abi.JSON(strings.NewReader(uniswapV3PoolABI))
+
+	selector := func(a abi.ABI, method string) string {
+		return "0x" + common.Bytes2Hex(a.Methods[method].ID)
+	}
+
+	var out []byte
+	var err error
+	switch data[:10] {
+	case selector(aggregator, "latestRoundData"):
+		out, err = aggregator.Methods["latestRoundData"].Outputs.Pack(big.NewInt(1), big.NewInt(200000000000), big.NewInt(0), big.NewInt(time.Now().Unix()), big.NewInt(1))
+	case selector(aggregator, "decimals"):
+		out, err = aggregator.Methods["decimals"].Outputs.Pack(uint8(8))
+	case selector(pairV2, "getReserves"):
+		// 2M USDC and 1000 WETH, last touched at 1000
+		out, err = pairV2.Methods["getReserves"].Outputs.Pack(big.NewInt(2000000000000), new(big.Int).Exp(big.NewInt(10), big.NewInt(21), nil), uint32(1000))
+	case selector(pairV2, "price0CumulativeLast"):
+		out, err = pairV2.Methods["price0CumulativeLast"].Outputs.Pack(big.NewInt(12345))
+	case selector(poolV3, "observe"):
+		out, err = poolV3.Methods["observe"].Outputs.Pack([]*big.Int{big.NewInt(1000), big.NewInt(1000 + 200311*1800)}, []*big.Int{big.NewInt(0), big.NewInt(0)})
+	default:
+		return "", fmt.Errorf("unknown method %s", data[:10])
+	}
+	return "0x" + common.Bytes2Hex(out), err
+}
+
+func TestChainlinkSource(t *testing.T) {
+	source := NewChainlinkSource(chainlinkFeeds["1_eth_mainnet"], MustNew(mockPricesCli{}))
+
+	price, err := source.Price("0xeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeee", "usd")
+	assert.NoError(t, err)
+	assert.Equal(t, float32(2000), price)
+
+	_, err = source.Price("0xeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeee", "eur")
+	_, ok := err.(ApiNotFoundErr)
+	assert.True(t, ok)
+
+	_, err = source.Price(dai, "usd")
+	_, ok = err.(ApiNotFoundErr)
+	assert.True(t, ok)
+
+	// the answer must be recent for the block
+	price, err = source.PriceAtBlock("0xeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeee", "usd", 99000, int(time.Now().Unix()))
+	assert.NoError(t, err)
+	assert.Equal(t, float32(2000), price)
+
+	_, err = source.PriceAtBlock("0xeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeee", "usd", 99000, int(time.Now().Add(48*time.Hour).Unix()))
+	_, ok = err.(ApiNotFoundErr)
+	assert.True(t, ok)
+}
+
+func TestUniswapSource(t *testing.T) {
+	const weth = "0xc02aaa39b223fe8d0a0e5c4f27ead9083c756cc2"
+
+	v3 := NewUniswapSource(uniswapPools["1_eth_mainnet"], 1800, mockPricesCli{})
+	price, err := v3.Price(weth, "usd")
+	assert.NoError(t, err)
+	assert.InDelta(t, 2000.04, price, 0.01)
+
+	v2 := NewUniswapSource(map[string]UniswapPool{
+		weth: {"0xb4e16d0168e52d35cacd2c6185b44281ec28c9dc", 2, false, 18, 6},
+	}, 1800, mockPricesCli{})
+	price, err = v2.Price(weth, "usd")
+	assert.NoError(t, err)
+	assert.InDelta(t, 2000, price, 0.01)
+
+	_, err = v2.Price(usdc, "usd")
+	_, ok := err.(ApiNotFoundErr)
+	assert.True(t, ok)
+}
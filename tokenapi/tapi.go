@@ -1,13 +1,11 @@
 package tokenapi
 
 import (
-	"encoding/json"
 	"fmt"
 	"github.com/HAL-xyz/zoroaster/config"
 	"github.com/ethereum/go-ethereum/accounts/abi"
 	"github.com/patrickmn/go-cache"
 	log "github.com/sirupsen/logrus"
-	"io/ioutil"
 	"math"
 	"math/big"
 	"net/http"
@@ -21,10 +19,19 @@ type ITokenAPI interface {
 	GetAllERC20TokensMap() map[string]ERC20Token
 	Symbol(address string) string
 	Decimals(address string) string
+	TokenName(address string) string
 	BalanceOf(token string, user string) string
+	IsERC721(address string) bool
+	IsERC1155(address string) bool
+	OwnerOf(collection string, tokenId string) string
+	TokenURI(collection string, tokenId string) string
+	CollectionName(collection string) string
+	ResolveENS(name string) (string, error)
+	LookupENS(address string) string
 	FromWei(wei interface{}, units interface{}) string
 	GetExchangeRate(tokenAddress, fiatCurrency string) (float32, error)
 	GetExchangeRateAtDate(tokenAddress, fiatCurrency, when string) (float32, error)
+	GetExchangeRateAtBlock(tokenAddress, fiatCurrency string, blockNo, timestamp int) (float32, error)
 	LogFiatStatsAndReset(blockNo int)
 	EthCall(address, method, abiJsn string, blockNo int, args ...string) ([]interface{}, error)
 	GetRPCCli() IEthRpc
@@ -33,68 +40,119 @@ type ITokenAPI interface {
 type TokenAPI struct {
 	fiatCache        *cache.Cache
 	fiatCacheHistory *cache.Cache
+	nftCache         *cache.Cache
+	ensCache         *cache.Cache
+	tokenMisses      *cache.Cache
 	fiatStats        map[string]int
 	httpCli          *http.Client
 	rpcCli           IEthRpc
+	network          string
+	tokenList        string // URL or file path
 	tokenMap         map[string]ERC20Token
-	TokenEndpoint    string
-	coingeckoIdsMap  map[string]string
+	tokenStore       *tokenStore
+	priceSources     []PriceSource
+	priceSourceOrder []string
+	etherscanKey     string
 	sync.Mutex
 }
 
-// package-level singleton accessed through GetTokenAPI()
-// some day it would be nice to pass it explicitly as a dependency of the templating system
-var tokenApi = New(NewZRPC(config.Zconf.EthNode, "templating client"))
-
-func GetTokenAPI() *TokenAPI {
-	return tokenApi
-}
-
-// returns a new TokenAPI
-func New(cli IEthRpc) *TokenAPI {
+// returns a new TokenAPI; with no options it's set up for mainnet, with the default
+// token list and price sources and no token cache.
+// It fails if one of the price sources asked for is unknown.
+func New(cli IEthRpc, options ...func(t *TokenAPI)) (*TokenAPI, error) {
 
 	tapi := TokenAPI{
 		fiatCache:        cache.New(15*time.Minute, 15*time.Minute),
 		fiatCacheHistory: cache.New(24*time.Hour, 24*time.Hour),
+		nftCache:         cache.New(24*time.Hour, 24*time.Hour),
+		ensCache:         cache.New(24*time.Hour, time.Hour),
+		tokenMisses:      cache.New(time.Hour, time.Hour),
 		fiatStats:        map[string]int{},
 		httpCli:          &http.Client{},
 		rpcCli:           cli,
-		TokenEndpoint:    "https://23m8idpr31.execute-api.eu-central-1.amazonaws.com/PROD/v1",
-		coingeckoIdsMap:  map[string]string{},
+		network:          "1_eth_mainnet",
+		tokenList:        config.DefaultTokenList,
+		tokenStore:       newTokenStore(""),
+	}
+	for _, opt := range options {
+		opt(&tapi)
+	}
+	if tapi.priceSources == nil {
+		sources, err := NewPriceSources(tapi.network, tapi.priceSourceOrder, &tapi)
+		if err != nil {
+			return nil, fmt.Errorf("cannot init TokenAPI: %s", err)
+		}
+		tapi.priceSources = sources
+	}
+	return &tapi, nil
+}
+
+// MustNew is like New, but panics if the TokenAPI can't be set up;
+// it's meant for tests and package level variables.
+func MustNew(cli IEthRpc, options ...func(t *TokenAPI)) *TokenAPI {
+	tapi, err := New(cli, options...)
+	if err != nil {
+		panic(err)
+	}
+	return tapi
+}
+
+// WithConfig sets the network, token list, token cache, price sources and Etherscan key
+func WithConfig(conf *config.ZConfiguration) func(t *TokenAPI) {
+	return func(t *TokenAPI) {
+		t.network = conf.Network
+		t.tokenList = conf.TokenList
+		t.tokenStore = newTokenStore(conf.TokenCache)
+		t.priceSourceOrder = conf.PriceSources
+		t.etherscanKey = conf.EtherscanKey
+	}
+}
+
+// WithTokenMap sets the tokens instead of loading them from the token list,
+// so that a list loaded once can be shared by several TokenAPIs
+func WithTokenMap(tokens map[string]ERC20Token) func(t *TokenAPI) {
+	return func(t *TokenAPI) {
+		t.tokenMap = tokens
+	}
+}
+
+// WithPriceSources replaces the network's price sources, in order of priority
+func WithPriceSources(sources ...PriceSource) func(t *TokenAPI) {
+	return func(t *TokenAPI) {
+		t.priceSources = sources
 	}
-	return &tapi
 }
 
-// Initialize the ERC20 map of all tokens.
+// Initialize the ERC20 map of all tokens from the token list.
 // Only the methods that actually need the map will call this, so we don't
-// load it every time we create an instance of token api for whatever reason
+// load it every time we create an instance of token api for whatever reason.
+// If the list can't be loaded we carry on without it, and look up tokens on-chain.
 func (t *TokenAPI) init() {
 	t.Lock()
-	tokenMapLength := len(t.tokenMap)
-	t.Unlock()
-	if tokenMapLength == 0 {
-		resp, err := http.Get(fmt.Sprintf("%s/all_tokens", t.TokenEndpoint))
-		defer resp.Body.Close()
-		if err != nil {
-			log.Fatalf("cannot init TokenAPI: %s", err)
-		}
-		body, err := ioutil.ReadAll(resp.Body)
-		if err != nil {
-			log.Fatalf("cannot init TokenAPI: %s", err)
-		}
-		t.Lock()
-		err = json.Unmarshal(body, &t.tokenMap)
-		t.Unlock()
-		if err != nil {
-			log.Fatalf("cannot init TokenAPI: %s", err)
-		}
+	defer t.Unlock()
+	if t.tokenMap != nil {
+		return
+	}
+	t.tokenMap = map[string]ERC20Token{}
+	if t.tokenList == "" {
+		return
+	}
+	tokens, err := LoadTokenList(t.tokenList, chainIds[t.network], t.httpCli)
+	if err != nil {
+		log.Errorf("cannot init TokenAPI token list: %s", err)
+		return
 	}
+	t.tokenMap = tokens
 }
 
 func (t *TokenAPI) LogFiatStatsAndReset(blockNo int) {
-	log.Infof("FiatStats: %s on block %d made %d calls to Coingecko, %d calls to Custom, had %d not-found errors, %d network errors. Cache size is %d",
-		t.rpcCli.GetLabel(), blockNo, t.fiatStats["coingecko"], t.fiatStats["custom"], t.fiatStats["not_found"], t.fiatStats["network_error"], t.fiatCache.ItemCount())
 	t.Lock()
+	calls := make([]string, len(t.priceSources))
+	for i, source := range t.priceSources {
+		calls[i] = fmt.Sprintf("%d calls to %s", t.fiatStats[source.Name()], source.Name())
+	}
+	log.Infof("FiatStats: %s on block %d made %s, had %d not-found errors, %d network errors. Cache size is %d",
+		t.rpcCli.GetLabel(), blockNo, strings.Join(calls, ", "), t.fiatStats["not_found"], t.fiatStats["network_error"], t.fiatCache.ItemCount())
 	for k := range t.fiatStats {
 		delete(t.fiatStats, k)
 	}
@@ -117,31 +175,30 @@ func (t *TokenAPI) GetAllERC20TokensMap() map[string]ERC20Token {
 }
 
 func (t *TokenAPI) Symbol(address string) string {
-	t.init()
-	_, ok := t.tokenMap[address]
-	if ok {
-		return t.tokenMap[address].Symbol
-	}
-	token, err := t.callERC20api(address)
+	token, err := t.TokenMetadata(address)
 	if err != nil {
 		return ""
 	}
 	return token.Symbol
 }
 
+// Decimals returns an empty string when the decimals of a token are unknown
 func (t *TokenAPI) Decimals(address string) string {
-	t.init()
-	_, ok := t.tokenMap[address]
-	if ok {
-		return fmt.Sprintf("%d", t.tokenMap[address].Decimals)
-	}
-	token, err := t.callERC20api(address)
+	token, err := t.TokenMetadata(address)
 	if err != nil {
-		return "18"
+		return ""
 	}
 	return fmt.Sprintf("%d", token.Decimals)
 }
 
+func (t *TokenAPI) TokenName(address string) string {
+	token, err := t.TokenMetadata(address)
+	if err != nil {
+		return ""
+	}
+	return token.Name
+}
+
 func (t *TokenAPI) BalanceOf(token string, user string) string {
 	if isEthereumAddress(token) {
 		return "0"
@@ -186,199 +243,175 @@ func (t *TokenAPI) FromWei(wei interface{}, units interface{}) string {
 }
 
 func (t *TokenAPI) GetExchangeRate(tokenAddress, fiatCurrency string) (float32, error) {
+	price, err := t.GetPrice(tokenAddress, fiatCurrency)
+	return price.Value, err
+}
+
+// SetPriceSources replaces the price sources, which are tried in order
+func (t *TokenAPI) SetPriceSources(sources ...PriceSource) {
+	t.priceSources = sources
+}
+
+// GetPrice asks each price source in turn, and returns the first price found along with its source
+func (t *TokenAPI) GetPrice(tokenAddress, fiatCurrency string) (Price, error) {
 	tokenAddress = strings.ToLower(tokenAddress)
 	fiatCurrency = strings.ToLower(fiatCurrency)
 
 	if len(tokenAddress) != 42 {
-		return 0, fmt.Errorf("invalid token address: %s", tokenAddress)
+		return Price{}, fmt.Errorf("invalid token address: %s", tokenAddress)
 	}
 
-	var coinGeckoUrl string
+	key := tokenAddress + fiatCurrency
 	if isEthereumAddress(tokenAddress) {
-		coinGeckoUrl = fmt.Sprintf("https://api.coingecko.com/api/v3/simple/price?ids=ethereum&vs_currencies=%s", fiatCurrency)
-		tokenAddress = "ethereum"
-	} else {
-		coinGeckoUrl = fmt.Sprintf("https://api.coingecko.com/api/v3/simple/token_price/%s?contract_addresses=%s&vs_currencies=%s", getCoingeckoNetwork(), tokenAddress, fiatCurrency)
+		key = "ethereum" + fiatCurrency
 	}
 
-	key := tokenAddress + fiatCurrency
-
 	// try cache first
 	price, found := t.fiatCache.Get(key)
 	if found {
-		return price.(float32), nil
-	}
-
-	// call CoinGecko
-	price, err := t.callPriceAPIs(coinGeckoUrl, tokenAddress, fiatCurrency)
-	if err == nil {
-		t.fiatCache.Set(key, price, cache.DefaultExpiration)
-		t.increaseFiatStats("coingecko")
-		return price.(float32), nil
-	}
-
-	if _, ok := err.(ApiNetworkErr); ok {
-		t.increaseFiatStats("network_error")
-		log.Error(err)
-		return 0, err
-	}
-
-	// not found on Coingecko, fallback to our own endpoint
-	customEndpoint := fmt.Sprintf("https://xyxoolw445.execute-api.us-east-1.amazonaws.com/dev/%s", tokenAddress)
-	price, err = t.callPriceAPIs(customEndpoint, tokenAddress, fiatCurrency)
-	if err == nil {
-		t.fiatCache.Set(key, price, cache.DefaultExpiration)
-		t.increaseFiatStats("custom")
-		return price.(float32), nil
+		return price.(Price), nil
+	}
+
+	var networkErr error
+	for _, source := range t.priceSources {
+		value, err := source.Price(tokenAddress, fiatCurrency)
+		if err == nil {
+			price := Price{Value: value, Source: source.Name()}
+			t.fiatCache.Set(key, price, cache.DefaultExpiration)
+			t.increaseFiatStats(source.Name())
+			log.Debugf("price of %s in %s is %f from %s", tokenAddress, fiatCurrency, value, source.Name())
+			return price, nil
+		}
+		if _, ok := err.(ApiNetworkErr); ok {
+			t.increaseFiatStats("network_error")
+			log.Error(err)
+			networkErr = err
+		} else {
+			log.Debugf("%s: %s", source.Name(), err)
+		}
 	}
 
 	// sorry :(
-	switch err.(type) {
-	case ApiNetworkErr:
-		t.increaseFiatStats("network_error")
-		log.Error(err)
-	case ApiNotFoundErr:
-		log.Error(err)
-		t.increaseFiatStats("not_found")
-	default:
-		t.increaseFiatStats("unknown_error")
-		log.Errorf("unknown error for currency %s fiat %s", tokenAddress, fiatCurrency)
+	if networkErr != nil {
+		return Price{}, networkErr
 	}
-	return 0, err
-}
-
-func getCoingeckoNetwork() string {
-	if config.Zconf.IsNetworkETHMainnet() {
-		return "ethereum"
-	}
-	if config.Zconf.IsNetworkPolygon() {
-		return "polygon-pos"
-	}
-	if config.Zconf.IsNetworkXDAI() {
-		return "xdai"
-	}
-	if config.Zconf.IsNetworkBinance() {
-		return "binance-smart-chain"
-	}
-	return ""
+	t.increaseFiatStats("not_found")
+	return Price{}, ApiNotFoundErr{fmt.Sprintf("not found error for currency %s fiat %s", tokenAddress, fiatCurrency)}
 }
 
-func (t *TokenAPI) callPriceAPIs(url, tokenAddress, fiatCurrency string) (float32, error) {
-	// all APIs return data in this format
-	// {
-	// 	 "0xb1cd6e4153b2a390cf00a6556b0fc1458c4a5533": {
-	//	   "usd": 1.58
-	//	 }
-	// }
-
-	resp, err := t.httpCli.Get(url)
-	if err != nil {
-		return 0, ApiNetworkErr{fmt.Sprintf("network error for currency %s fiat %s, %s", tokenAddress, fiatCurrency, err.Error())}
-	}
-	defer resp.Body.Close()
+func (t *TokenAPI) GetExchangeRateAtDate(tokenAddress, fiatCurrency, when string) (float32, error) {
+	tokenAddress = strings.ToLower(tokenAddress)
+	fiatCurrency = strings.ToLower(fiatCurrency)
 
-	body, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		return 0, ApiNetworkErr{fmt.Sprintf("network error for currency %s fiat %s, %s", tokenAddress, fiatCurrency, err.Error())}
+	date := parseCurrencyDate(when)
+	if date == "" {
+		return 0, fmt.Errorf("invalid date: %s", when)
 	}
 
-	var currencyMap map[string]map[string]float32
-	err = json.Unmarshal(body, &currencyMap)
-	if err != nil {
-		return 0, ApiNotFoundErr{fmt.Sprintf("not found error for currency %s fiat %s, unexpected json input", tokenAddress, fiatCurrency)}
-	}
+	// try cache first
+	key := tokenAddress + fiatCurrency + when
 
-	val, ok := currencyMap[tokenAddress][fiatCurrency]
-	if ok {
-		return val, nil
-	} else {
-		return 0, ApiNotFoundErr{fmt.Sprintf("not found error for currency %s fiat %s", tokenAddress, fiatCurrency)}
+	price, found := t.fiatCacheHistory.Get(key)
+	if found {
+		return price.(Price).Value, nil
 	}
-}
 
-func (t *TokenAPI) callERC20api(address string) (ERC20Token, error) {
-	resp, err := http.Get(fmt.Sprintf("%s/token?address=%s", t.TokenEndpoint, address))
-	if err != nil {
-		return ERC20Token{}, err
-	}
-	defer resp.Body.Close()
-	body, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		return ERC20Token{}, err
-	}
-	var tokenMap map[string]ERC20Token
-	err = json.Unmarshal(body, &tokenMap)
-	if err != nil {
-		return ERC20Token{}, err
+	err := fmt.Errorf("no historical price source")
+	for _, source := range t.priceSources {
+		hs, ok := source.(HistoricalPriceSource)
+		if !ok {
+			continue
+		}
+		var value float32
+		value, err = hs.PriceAtDate(tokenAddress, fiatCurrency, date)
+		if err == nil {
+			t.fiatCacheHistory.Set(key, Price{Value: value, Source: hs.Name()}, cache.DefaultExpiration)
+			t.increaseFiatStats(hs.Name())
+			return value, nil
+		}
+		log.Debugf("%s: %s", hs.Name(), err)
 	}
-	return tokenMap[address], nil
+	return 0, err
 }
 
-func (t *TokenAPI) GetExchangeRateAtDate(tokenAddress, fiatCurrency, when string) (float32, error) {
+// GetExchangeRateAtBlock returns the price of a token as of a block, so that conversions
+// made for a block are the same whenever the block is processed.
+// Tokens no block source knows about are priced at the current price, which is then kept for the block;
+// if the timestamp is 0 it's read from the block.
+func (t *TokenAPI) GetExchangeRateAtBlock(tokenAddress, fiatCurrency string, blockNo, timestamp int) (float32, error) {
 	tokenAddress = strings.ToLower(tokenAddress)
 	fiatCurrency = strings.ToLower(fiatCurrency)
 
-	// first time we run this we download the full list of token-ids for Coingecko
-	if len(t.coingeckoIdsMap) == 0 {
-		resp, err := http.Get("https://api.coingecko.com/api/v3/coins/list?include_platform=true")
-		if err != nil {
-			log.Fatal(err)
-		}
-		body, err := ioutil.ReadAll(resp.Body)
-		if err != nil {
-			log.Fatal(err)
-		}
-		ids := GeckoIDSJson{}
-		err = json.Unmarshal(body, &ids)
-		if err != nil {
-			log.Fatal(err)
-		}
+	if len(tokenAddress) != 42 {
+		return 0, fmt.Errorf("invalid token address: %s", tokenAddress)
+	}
 
-		// create a map tokenAdd -> coinGecko-id
-		for _, e := range ids {
-			if e.Platforms.Ethereum != "" {
-				t.coingeckoIdsMap[e.Platforms.Ethereum] = e.ID
-			}
-		}
-		// add ETH entries
-		t.coingeckoIdsMap["0xeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeee"] = "ethereum"
-		t.coingeckoIdsMap["0x0000000000000000000000000000000000000000"] = "ethereum"
+	key := fmt.Sprintf("%s%s#%d", tokenAddress, fiatCurrency, blockNo)
+	if isEthereumAddress(tokenAddress) {
+		key = fmt.Sprintf("ethereum%s#%d", fiatCurrency, blockNo)
 	}
 
 	// try cache first
-	key := tokenAddress + fiatCurrency + when
-
 	price, found := t.fiatCacheHistory.Get(key)
 	if found {
-		return price.(float32), nil
+		return price.(Price).Value, nil
 	}
 
-	// make request using date
-	url := fmt.Sprintf("https://api.coingecko.com/api/v3/coins/%s/history?date=%s&localization=false", t.coingeckoIdsMap[tokenAddress], parseCurrencyDate(when))
-	resp, err := http.Get(url)
-	if err != nil {
-		return 0, err
-	}
-	body, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		return 0, err
+	if timestamp == 0 {
+		block, err := t.rpcCli.EthGetBlockByNumber(blockNo, false)
+		if err != nil || block == nil {
+			return 0, ApiNetworkErr{fmt.Sprintf("network error for currency %s fiat %s, cannot get block %d", tokenAddress, fiatCurrency, blockNo)}
+		}
+		timestamp = block.Timestamp
 	}
 
-	m := map[string]json.RawMessage{}
-	if err = json.Unmarshal(body, &m); err != nil {
-		return 0, err
+	var networkErr error
+	for _, source := range t.priceSources {
+		bs, ok := source.(BlockPriceSource)
+		if !ok {
+			continue
+		}
+		value, err := bs.PriceAtBlock(tokenAddress, fiatCurrency, blockNo, timestamp)
+		if err == nil {
+			t.fiatCacheHistory.Set(key, Price{Value: value, Source: bs.Name()}, cache.DefaultExpiration)
+			t.increaseFiatStats(bs.Name())
+			return value, nil
+		}
+		if _, ok := err.(ApiNetworkErr); ok {
+			t.increaseFiatStats("network_error")
+			log.Error(err)
+			networkErr = err
+		} else {
+			log.Debugf("%s: %s", bs.Name(), err)
+		}
 	}
 
-	mm := map[string]map[string]float32{}
-	if err = json.Unmarshal(m["market_data"], &mm); err != nil {
+	if networkErr != nil {
+		return 0, networkErr
+	}
+	// GetPrice keeps its own stats
+	current, err := t.GetPrice(tokenAddress, fiatCurrency)
+	if err != nil {
 		return 0, err
 	}
+	t.fiatCacheHistory.Set(key, current, cache.DefaultExpiration)
+	return current.Value, nil
+}
 
-	historicalPrice := mm["current_price"][fiatCurrency]
-	t.fiatCacheHistory.Set(key, historicalPrice, cache.DefaultExpiration)
-	t.increaseFiatStats("coingecko")
+// AtBlock returns a view of api where exchange rates are the ones at blockNo;
+// used when matching a block, so that currency conditions don't depend on when the block is matched.
+func AtBlock(api ITokenAPI, blockNo, timestamp int) ITokenAPI {
+	return &blockTokenAPI{ITokenAPI: api, blockNo: blockNo, timestamp: timestamp}
+}
+
+type blockTokenAPI struct {
+	ITokenAPI
+	blockNo   int
+	timestamp int
+}
 
-	return historicalPrice, nil
+func (b *blockTokenAPI) GetExchangeRate(tokenAddress, fiatCurrency string) (float32, error) {
+	return b.GetExchangeRateAtBlock(tokenAddress, fiatCurrency, b.blockNo, b.timestamp)
 }
 
 // EthCall is a high level helper that executes a view call against a contract
@@ -387,7 +420,7 @@ func (t *TokenAPI) EthCall(address, method, abiJsn string, blockNo int, args ...
 
 	var err error
 	if abiJsn == "" {
-		abiJsn, err = fetchAbi(address)
+		abiJsn, err = t.fetchAbi(address)
 		if err != nil {
 			return []interface{}{}, fmt.Errorf("cannot fetch abi for contract: %s - %s", address, err)
 		}
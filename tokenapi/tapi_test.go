@@ -10,7 +10,8 @@ import (
 	"testing"
 )
 
-var tapi = New(NewZRPC(config.Zconf.EthNode, "test"))
+var testConf = config.NewConfig()
+var tapi = MustNew(NewZRPC(testConf.EthNode, "test"), WithConfig(testConf))
 
 func setupGock(filename, url, path, method string) error {
 	testJSON, err := os.Open(filename)
@@ -59,13 +60,11 @@ func TestTokenAPI_GetFiatCacheCount(t *testing.T) {
 	assert.Equal(t, 2, tapi.fiatCache.ItemCount())
 
 	// token on Binance
-	config.Zconf.Network = "4_binance_mainnet"
 	_, err = tapi.GetExchangeRate("0xe9e7cea3dedca5984780bafc599bd69add087d56", "usd")
 	assert.NoError(t, err)
 	assert.Equal(t, 3, tapi.fiatCache.ItemCount())
 
 	// token on Polygon
-	config.Zconf.Network = "5_polygon_mainnet"
 	_, err = tapi.GetExchangeRate("0xb33eaad8d922b1083446dc23f610c2567fb5180f", "usd")
 	assert.NoError(t, err)
 	assert.Equal(t, 4, tapi.fiatCache.ItemCount())
@@ -94,10 +93,11 @@ func TestTokenAPI_GetExchangeRateAtDate(t *testing.T) {
 	res, err := tapi.GetExchangeRateAtDate("0xa0b86991c6218b36c1d19d4a2e9eb0ce3606eb48", "usd", "yesterday")
 	assert.NoError(t, err)
 
-	assert.Equal(t, 4, len(tapi.coingeckoIdsMap))
-	assert.Equal(t, "ethereum", tapi.coingeckoIdsMap["0x0000000000000000000000000000000000000000"])
-	assert.Equal(t, "ethereum", tapi.coingeckoIdsMap["0xeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeee"])
-	assert.Equal(t, "usd-coin", tapi.coingeckoIdsMap["0xa0b86991c6218b36c1d19d4a2e9eb0ce3606eb48"])
+	coingecko := tapi.priceSources[0].(*CoinGeckoSource)
+	assert.Equal(t, 4, len(coingecko.idsMap))
+	assert.Equal(t, "ethereum", coingecko.idsMap["0x0000000000000000000000000000000000000000"])
+	assert.Equal(t, "ethereum", coingecko.idsMap["0xeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeee"])
+	assert.Equal(t, "usd-coin", coingecko.idsMap["0xa0b86991c6218b36c1d19d4a2e9eb0ce3606eb48"])
 	assert.Equal(t, float32(1.0013702), res)
 	assert.Equal(t, 1, tapi.fiatCacheHistory.ItemCount())
 
@@ -115,12 +115,3 @@ func TestTokenAPI_GetExchangeRateAtDate(t *testing.T) {
 	assert.Equal(t, float32(1.0013702), res)
 	assert.Equal(t, 2, tapi.fiatCacheHistory.ItemCount())
 }
-func TestCallERC20API(t *testing.T) {
-
-	_ = setupGock("resources/tokenLookup.json", tapi.TokenEndpoint, "token", "GET")
-
-	token, err := tapi.callERC20api("0x6b175474e89094c44da98b954eedeac495271d0f")
-	assert.NoError(t, err)
-	assert.Equal(t, "DAI", token.Symbol)
-	assert.Equal(t, 18, token.Decimals)
-}
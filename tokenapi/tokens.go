@@ -0,0 +1,243 @@
+package tokenapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/HAL-xyz/zoroaster/utils"
+	"github.com/ethereum/go-ethereum/common"
+	log "github.com/sirupsen/logrus"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// ERC20 view methods
+const (
+	nameSelector     = "0x06fdde03"
+	symbolSelector   = "0x95d89b41"
+	decimalsSelector = "0x313ce567"
+)
+
+// the chain id of each network, used to filter token lists
+var chainIds = map[string]int{
+	"1_eth_mainnet":     1,
+	"3_xdai_mainnet":    100,
+	"4_binance_mainnet": 56,
+	"5_polygon_mainnet": 137,
+}
+
+// the native currency of each network, which has no contract to ask
+var nativeTokens = map[string]ERC20Token{
+	"1_eth_mainnet":     {ChainId: 1, Name: "Ether", Symbol: "ETH", Decimals: 18},
+	"3_xdai_mainnet":    {ChainId: 100, Name: "xDai", Symbol: "XDAI", Decimals: 18},
+	"4_binance_mainnet": {ChainId: 56, Name: "BNB", Symbol: "BNB", Decimals: 18},
+	"5_polygon_mainnet": {ChainId: 137, Name: "Matic", Symbol: "MATIC", Decimals: 18},
+}
+
+// LoadTokenList reads a token list from a URL or a local file.
+// Both the Uniswap format ({"tokens": [...]}) and a plain map of address -> token are supported;
+// tokens from other chains than chainId are left out, unless chainId is 0.
+func LoadTokenList(source string, chainId int, httpCli *http.Client) (map[string]ERC20Token, error) {
+	var body []byte
+	var err error
+	if strings.HasPrefix(source, "http://") || strings.HasPrefix(source, "https://") {
+		body, err = httpGet(httpCli, source)
+	} else {
+		body, err = ioutil.ReadFile(source)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("cannot read token list %s: %s", source, err)
+	}
+
+	var tokens []ERC20Token
+	var list struct {
+		Tokens []ERC20Token `json:"tokens"`
+	}
+	if err = json.Unmarshal(body, &list); err == nil && list.Tokens != nil {
+		tokens = list.Tokens
+	} else {
+		m := map[string]ERC20Token{}
+		if err = json.Unmarshal(body, &m); err != nil {
+			return nil, fmt.Errorf("cannot decode token list %s: %s", source, err)
+		}
+		for _, token := range m {
+			tokens = append(tokens, token)
+		}
+	}
+
+	tokenMap := make(map[string]ERC20Token, len(tokens))
+	for _, token := range tokens {
+		if chainId != 0 && token.ChainId != 0 && token.ChainId != chainId {
+			continue
+		}
+		token.Address = utils.NormalizeAddress(token.Address)
+		tokenMap[token.Address] = token
+	}
+	return tokenMap, nil
+}
+
+// TokenMetadata returns the name, symbol and decimals of a token, looking at the token list first,
+// then at the tokens we've already seen, and finally at the token contract itself.
+func (t *TokenAPI) TokenMetadata(address string) (ERC20Token, error) {
+	address = utils.NormalizeAddress(address)
+	if isEthereumAddress(address) {
+		if native, ok := nativeTokens[t.network]; ok {
+			native.Address = address
+			return native, nil
+		}
+	}
+	if token, ok := t.GetAllERC20TokensMap()[address]; ok {
+		return token, nil
+	}
+	if token, ok := t.tokenStore.get(address); ok {
+		return token, nil
+	}
+	if _, found := t.tokenMisses.Get(address); found {
+		return ERC20Token{}, fmt.Errorf("%s is not an ERC20 token", address)
+	}
+
+	token, err := t.fetchTokenMetadata(address)
+	if err != nil {
+		return ERC20Token{}, err
+	}
+	t.tokenStore.put(token)
+	return token, nil
+}
+
+// fetchTokenMetadata reads a token's metadata on-chain; decimals are mandatory, name and symbol aren't.
+func (t *TokenAPI) fetchTokenMetadata(address string) (ERC20Token, error) {
+	blockNo, err := t.rpcCli.EthBlockNumber()
+	if err != nil {
+		return ERC20Token{}, err
+	}
+	raw, err := t.rpcCli.MakeEthRpcCall(address, decimalsSelector, blockNo)
+	if err != nil {
+		return ERC20Token{}, err
+	}
+	decimals := new(big.Int).SetBytes(common.FromHex(raw))
+	if len(common.FromHex(raw)) != 32 || decimals.Cmp(big.NewInt(255)) > 0 {
+		// not a network error: don't ask again for a while
+		t.tokenMisses.SetDefault(address, true)
+		return ERC20Token{}, fmt.Errorf("%s is not an ERC20 token", address)
+	}
+
+	token := ERC20Token{
+		ChainId:  chainIds[t.network],
+		Address:  address,
+		Decimals: int(decimals.Int64()),
+	}
+	token.Name, err = t.callTokenString(address, nameSelector, blockNo)
+	if err != nil {
+		log.Debugf("cannot read the name of token %s: %s", address, err)
+	}
+	token.Symbol, err = t.callTokenString(address, symbolSelector, blockNo)
+	if err != nil {
+		log.Debugf("cannot read the symbol of token %s: %s", address, err)
+	}
+	return token, nil
+}
+
+// callTokenString reads a string view method; legacy tokens like MKR return a bytes32 instead
+func (t *TokenAPI) callTokenString(address, selector string, blockNo int) (string, error) {
+	raw, err := t.rpcCli.MakeEthRpcCall(address, selector, blockNo)
+	if err != nil {
+		return "", err
+	}
+	data := common.FromHex(raw)
+	if len(data) == 32 {
+		s := strings.TrimRight(string(data), "\x00")
+		if !utf8.ValidString(s) {
+			return "", fmt.Errorf("invalid bytes32 string")
+		}
+		return s, nil
+	}
+	if len(data) < 64 {
+		return "", fmt.Errorf("unexpected output: %s", raw)
+	}
+	offset := new(big.Int).SetBytes(data[:32])
+	if !offset.IsInt64() || offset.Int64()+32 > int64(len(data)) {
+		return "", fmt.Errorf("unexpected output: %s", raw)
+	}
+	start := int(offset.Int64()) + 32
+	length := new(big.Int).SetBytes(data[start-32 : start])
+	if !length.IsInt64() || int64(start)+length.Int64() > int64(len(data)) {
+		return "", fmt.Errorf("unexpected output: %s", raw)
+	}
+	s := string(data[start : start+int(length.Int64())])
+	if !utf8.ValidString(s) {
+		return "", fmt.Errorf("invalid string")
+	}
+	return s, nil
+}
+
+// A tokenStore remembers the metadata of the tokens we've looked up on-chain,
+// in a JSON file if a path is given, so that they survive restarts.
+type tokenStore struct {
+	path   string
+	tokens map[string]ERC20Token
+	sync.Mutex
+}
+
+func newTokenStore(path string) *tokenStore {
+	s := &tokenStore{path: path, tokens: map[string]ERC20Token{}}
+	if path == "" {
+		return s
+	}
+	body, err := ioutil.ReadFile(path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Warnf("cannot read token cache %s: %s", path, err)
+		}
+		return s
+	}
+	if err = json.Unmarshal(body, &s.tokens); err != nil {
+		log.Warnf("cannot decode token cache %s: %s", path, err)
+	}
+	return s
+}
+
+func (s *tokenStore) get(address string) (ERC20Token, bool) {
+	s.Lock()
+	defer s.Unlock()
+	token, ok := s.tokens[address]
+	return token, ok
+}
+
+func (s *tokenStore) put(token ERC20Token) {
+	s.Lock()
+	defer s.Unlock()
+	s.tokens[token.Address] = token
+	if s.path == "" {
+		return
+	}
+	if err := s.save(); err != nil {
+		log.Warnf("cannot write token cache %s: %s", s.path, err)
+	}
+}
+
+// save writes the whole cache to a temp file, then moves it in place
+func (s *tokenStore) save() error {
+	body, err := json.Marshal(s.tokens)
+	if err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(s.path), filepath.Base(s.path))
+	if err != nil {
+		return err
+	}
+	if _, err = tmp.Write(body); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), s.path)
+}
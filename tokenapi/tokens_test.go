@@ -0,0 +1,122 @@
+package tokenapi
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const mkr = "0x9f8f72aa9304c8b593d555f12ef6589cc3a579a2"
+
+// DAI returns strings, MKR returns bytes32; everything else isn't a token
+type mockTokenCli struct {
+	IEthRpc
+	calls *int
+}
+
+func (cli mockTokenCli) EthBlockNumber() (int, error) {
+	return 13000000, nil
+}
+
+func (cli mockTokenCli) MakeEthRpcCall(cntAddress, data string, blockNumber int) (string, error) {
+	*cli.calls++
+	word := func(s string) string {
+		return s + strings.Repeat("0", 64-len(s))
+	}
+	switch cntAddress + data {
+	case dai + decimalsSelector, mkr + decimalsSelector:
+		return "0x" + fmt.Sprintf("%064x", 18), nil
+	case dai + nameSelector:
+		return packString("Dai Stablecoin"), nil
+	case dai + symbolSelector:
+		return packString("DAI"), nil
+	case mkr + nameSelector:
+		return "0x" + word("4d616b6572"), nil
+	case mkr + symbolSelector:
+		return "0x" + word("4d4b52"), nil
+	}
+	return "0x", nil
+}
+
+func TestTokenAPI_TokenMetadata(t *testing.T) {
+	calls := 0
+	tapi := MustNew(mockTokenCli{calls: &calls})
+	tapi.network = "1_eth_mainnet"
+	tapi.tokenMap = map[string]ERC20Token{}
+
+	assert.Equal(t, "DAI", tapi.Symbol(dai))
+	assert.Equal(t, "18", tapi.Decimals(dai))
+	assert.Equal(t, "Dai Stablecoin", tapi.TokenName("0x6B175474E89094C44Da98b954EedeAC495271d0F"))
+	assert.Equal(t, 3, calls)
+
+	// legacy tokens
+	assert.Equal(t, "MKR", tapi.Symbol(mkr))
+	assert.Equal(t, "Maker", tapi.TokenName(mkr))
+
+	// not a token, and we don't ask again
+	assert.Equal(t, "", tapi.Decimals(vitalik))
+	assert.Equal(t, "", tapi.Symbol(vitalik))
+	assert.Equal(t, 7, calls)
+
+	assert.Equal(t, "ETH", tapi.Symbol("0xeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeee"))
+	assert.Equal(t, "18", tapi.Decimals("0x0000000000000000000000000000000000000000"))
+
+	// the token list comes first
+	tapi.tokenMap[usdc] = ERC20Token{ChainId: 1, Address: usdc, Symbol: "USDC", Decimals: 6}
+	assert.Equal(t, "6", tapi.Decimals(usdc))
+	assert.Equal(t, 7, calls)
+}
+
+func TestTokenStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tokens")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "tokens.json")
+
+	store := newTokenStore(path)
+	store.put(ERC20Token{ChainId: 1, Address: dai, Symbol: "DAI", Decimals: 18})
+
+	// survives a restart
+	store = newTokenStore(path)
+	token, ok := store.get(dai)
+	assert.True(t, ok)
+	assert.Equal(t, "DAI", token.Symbol)
+
+	_, ok = newTokenStore("").get(dai)
+	assert.False(t, ok)
+}
+
+func TestLoadTokenList(t *testing.T) {
+	// a map of address -> token
+	tokens, err := LoadTokenList("resources/tokenLookup.json", 1, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "DAI", tokens[dai].Symbol)
+
+	// a Uniswap token list
+	dir, err := ioutil.TempDir("", "tokens")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "list.json")
+	list := `{"name": "test", "tokens": [
+		{"chainId": 1, "address": "0xA0b86991c6218b36c1d19D4a2e9Eb0cE3606eB48", "name": "USD Coin", "symbol": "USDC", "decimals": 6},
+		{"chainId": 137, "address": "0x2791Bca1f2de4661ED88A30C99A7a9449Aa84174", "name": "USD Coin", "symbol": "USDC", "decimals": 6}
+	]}`
+	assert.NoError(t, ioutil.WriteFile(path, []byte(list), 0644))
+
+	tokens, err = LoadTokenList(path, 1, nil)
+	assert.NoError(t, err)
+	assert.Len(t, tokens, 1)
+	assert.Equal(t, 6, tokens[usdc].Decimals)
+
+	_, err = LoadTokenList(filepath.Join(dir, "nope.json"), 1, nil)
+	assert.Error(t, err)
+
+	// the service starts anyway
+	tapi := MustNew(mockTokenCli{})
+	tapi.tokenList = filepath.Join(dir, "nope.json")
+	assert.Empty(t, tapi.GetAllERC20TokensMap())
+}
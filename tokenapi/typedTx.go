@@ -0,0 +1,191 @@
+package tokenapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/HAL-xyz/ethrpc"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"math/big"
+)
+
+// The EIP-2718/EIP-1559 fields of a tx, which ethrpc.Transaction doesn't know about
+type TypedTxData struct {
+	Type                 int      // 0 for legacy txs, 1 for access list txs, 2 for dynamic fee txs
+	MaxFeePerGas         *big.Int // type 2 only
+	MaxPriorityFeePerGas *big.Int // type 2 only
+	AccessList           []AccessTuple
+}
+
+type AccessTuple struct {
+	Address     string   `json:"address"`
+	StorageKeys []string `json:"storageKeys"`
+}
+
+// Transaction is an ethrpc.Transaction with its typed fields;
+// legacy txs, and txs we know nothing more about, have a zero TypedTxData.
+type Transaction struct {
+	ethrpc.Transaction
+	TypedTxData
+}
+
+// Block is an ethrpc.Block with typed txs and the block's base fee, as decoded by DecodeBlock;
+// blocks that come from somewhere else can be wrapped with NewBlock.
+type Block struct {
+	Number           int
+	Hash             string
+	ParentHash       string
+	Nonce            string
+	Sha3Uncles       string
+	LogsBloom        string
+	TransactionsRoot string
+	StateRoot        string
+	Miner            string
+	Difficulty       big.Int
+	TotalDifficulty  big.Int
+	ExtraData        string
+	Size             int
+	GasLimit         int
+	GasUsed          int
+	Timestamp        int
+	Uncles           []string
+	Transactions     []Transaction
+	BaseFeePerGas    *big.Int // nil before London
+}
+
+// NewBlock wraps an ethrpc.Block; its txs are treated as legacy txs, and it has no base fee
+func NewBlock(b *ethrpc.Block) *Block {
+	block := Block{
+		Number:           b.Number,
+		Hash:             b.Hash,
+		ParentHash:       b.ParentHash,
+		Nonce:            b.Nonce,
+		Sha3Uncles:       b.Sha3Uncles,
+		LogsBloom:        b.LogsBloom,
+		TransactionsRoot: b.TransactionsRoot,
+		StateRoot:        b.StateRoot,
+		Miner:            b.Miner,
+		Difficulty:       b.Difficulty,
+		TotalDifficulty:  b.TotalDifficulty,
+		ExtraData:        b.ExtraData,
+		Size:             b.Size,
+		GasLimit:         b.GasLimit,
+		GasUsed:          b.GasUsed,
+		Timestamp:        b.Timestamp,
+		Uncles:           b.Uncles,
+		Transactions:     make([]Transaction, len(b.Transactions)),
+	}
+	for i, tx := range b.Transactions {
+		block.Transactions[i] = Transaction{Transaction: tx}
+	}
+	return &block
+}
+
+type rpcBlock struct {
+	Number           string            `json:"number"`
+	Hash             string            `json:"hash"`
+	ParentHash       string            `json:"parentHash"`
+	Nonce            string            `json:"nonce"`
+	Sha3Uncles       string            `json:"sha3Uncles"`
+	LogsBloom        string            `json:"logsBloom"`
+	TransactionsRoot string            `json:"transactionsRoot"`
+	StateRoot        string            `json:"stateRoot"`
+	Miner            string            `json:"miner"`
+	Difficulty       string            `json:"difficulty"`
+	TotalDifficulty  string            `json:"totalDifficulty"`
+	ExtraData        string            `json:"extraData"`
+	Size             string            `json:"size"`
+	GasLimit         string            `json:"gasLimit"`
+	GasUsed          string            `json:"gasUsed"`
+	Timestamp        string            `json:"timestamp"`
+	Uncles           []string          `json:"uncles"`
+	Transactions     []json.RawMessage `json:"transactions"`
+	BaseFeePerGas    *hexutil.Big      `json:"baseFeePerGas"`
+}
+
+type rpcTypedTx struct {
+	Type                 *hexutil.Uint64 `json:"type"`
+	MaxFeePerGas         *hexutil.Big    `json:"maxFeePerGas"`
+	MaxPriorityFeePerGas *hexutil.Big    `json:"maxPriorityFeePerGas"`
+	AccessList           []AccessTuple   `json:"accessList"`
+}
+
+// DecodeBlock decodes the response of eth_getBlockByNumber, typed tx fields and base fee included
+func DecodeBlock(raw []byte) (*Block, error) {
+	var rb rpcBlock
+	if err := json.Unmarshal(raw, &rb); err != nil {
+		return nil, err
+	}
+
+	ints := make([]int, 5)
+	for i, s := range []string{rb.Number, rb.Size, rb.GasLimit, rb.GasUsed, rb.Timestamp} {
+		n, err := ethrpc.ParseInt(s)
+		if err != nil && s != "" {
+			return nil, fmt.Errorf("invalid block: %s", err)
+		}
+		ints[i] = n
+	}
+	difficulty, _ := ethrpc.ParseBigInt(rb.Difficulty)
+	totalDifficulty, _ := ethrpc.ParseBigInt(rb.TotalDifficulty)
+
+	block := Block{
+		Number:           ints[0],
+		Hash:             rb.Hash,
+		ParentHash:       rb.ParentHash,
+		Nonce:            rb.Nonce,
+		Sha3Uncles:       rb.Sha3Uncles,
+		LogsBloom:        rb.LogsBloom,
+		TransactionsRoot: rb.TransactionsRoot,
+		StateRoot:        rb.StateRoot,
+		Miner:            rb.Miner,
+		Difficulty:       difficulty,
+		TotalDifficulty:  totalDifficulty,
+		ExtraData:        rb.ExtraData,
+		Size:             ints[1],
+		GasLimit:         ints[2],
+		GasUsed:          ints[3],
+		Timestamp:        ints[4],
+		Uncles:           rb.Uncles,
+		Transactions:     make([]Transaction, len(rb.Transactions)),
+	}
+	if rb.BaseFeePerGas != nil {
+		block.BaseFeePerGas = rb.BaseFeePerGas.ToInt()
+	}
+
+	for i, rawTx := range rb.Transactions {
+		// without full txs we only get their hashes
+		var hash string
+		if json.Unmarshal(rawTx, &hash) == nil {
+			block.Transactions[i] = Transaction{Transaction: ethrpc.Transaction{Hash: hash}}
+			continue
+		}
+		tx, err := DecodeTransaction(rawTx)
+		if err != nil {
+			return nil, fmt.Errorf("invalid tx #%d: %s", i, err)
+		}
+		block.Transactions[i] = tx
+	}
+	return &block, nil
+}
+
+// DecodeTransaction decodes a full tx object, typed fields included
+func DecodeTransaction(raw []byte) (Transaction, error) {
+	var tx Transaction
+	// ethrpc.Transaction has its own UnmarshalJSON, which would take over the whole of tx
+	if err := json.Unmarshal(raw, &tx.Transaction); err != nil {
+		return tx, err
+	}
+	var typed rpcTypedTx
+	if err := json.Unmarshal(raw, &typed); err != nil {
+		return tx, err
+	}
+	if typed.Type != nil && *typed.Type > 0 {
+		tx.TypedTxData = TypedTxData{Type: int(*typed.Type), AccessList: typed.AccessList}
+		if typed.MaxFeePerGas != nil {
+			tx.MaxFeePerGas = typed.MaxFeePerGas.ToInt()
+		}
+		if typed.MaxPriorityFeePerGas != nil {
+			tx.MaxPriorityFeePerGas = typed.MaxPriorityFeePerGas.ToInt()
+		}
+	}
+	return tx, nil
+}
@@ -1,6 +1,12 @@
 package tokenapi
 
-import "fmt"
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"math/big"
+	"strings"
+)
 
 type ApiNetworkErr struct {
 	msg string
@@ -19,10 +25,8 @@ func (e ApiNotFoundErr) Error() string {
 }
 
 type GeckoIDSJson []struct {
-	ID        string `json:"id"`
-	Platforms struct {
-		Ethereum string `json:"ethereum"`
-	} `json:"platforms"`
+	ID        string            `json:"id"`
+	Platforms map[string]string `json:"platforms"` // platform -> token address
 }
 
 type ERC20Token struct {
@@ -33,3 +37,59 @@ type ERC20Token struct {
 	Decimals int    `json:"decimals"`
 	LogoURI  string `json:"logoURI,omitempty"`
 }
+
+// A call made during the execution of a tx, as returned by geth's callTracer;
+// the root frame is the tx itself, and internal calls are nested under Calls.
+type CallFrame struct {
+	Type    string      `json:"type"`
+	From    string      `json:"from"`
+	To      string      `json:"to"`
+	Value   string      `json:"value,omitempty"`
+	Gas     string      `json:"gas"`
+	GasUsed string      `json:"gasUsed"`
+	Input   string      `json:"input"`
+	Output  string      `json:"output,omitempty"`
+	Error   string      `json:"error,omitempty"`
+	Calls   []CallFrame `json:"calls,omitempty"`
+}
+
+// The receipt of a mined tx, as far as Zoroaster is concerned
+type TxReceipt struct {
+	TransactionHash   string
+	Status            int // 1 if the tx succeeded, 0 if it reverted
+	GasUsed           int
+	EffectiveGasPrice *big.Int // only available after London
+	ContractAddress   string   // contract creations only
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface.
+func (r *TxReceipt) UnmarshalJSON(data []byte) error {
+	// otherwise it'd look like a successful tx
+	if string(data) == "null" {
+		return fmt.Errorf("missing receipt")
+	}
+	var proxy struct {
+		TransactionHash   string          `json:"transactionHash"`
+		Status            *hexutil.Uint64 `json:"status"`
+		GasUsed           hexutil.Uint64  `json:"gasUsed"`
+		EffectiveGasPrice *hexutil.Big    `json:"effectiveGasPrice"`
+		ContractAddress   *string         `json:"contractAddress"`
+	}
+	if err := json.Unmarshal(data, &proxy); err != nil {
+		return err
+	}
+	r.TransactionHash = proxy.TransactionHash
+	r.GasUsed = int(proxy.GasUsed)
+	// receipts before Byzantium have no status, but only successful txs made it into a block
+	r.Status = 1
+	if proxy.Status != nil {
+		r.Status = int(*proxy.Status)
+	}
+	if proxy.EffectiveGasPrice != nil {
+		r.EffectiveGasPrice = proxy.EffectiveGasPrice.ToInt()
+	}
+	if proxy.ContractAddress != nil {
+		r.ContractAddress = strings.ToLower(*proxy.ContractAddress)
+	}
+	return nil
+}
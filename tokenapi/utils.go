@@ -4,7 +4,6 @@ import (
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
-	"github.com/HAL-xyz/zoroaster/config"
 	"github.com/ethereum/go-ethereum/accounts/abi"
 	"io/ioutil"
 	"math"
@@ -17,16 +16,7 @@ import (
 const erc20abi = `[ { "constant": true, "inputs": [], "name": "name", "outputs": [ { "name": "", "type": "string" } ], "payable": false, "stateMutability": "view", "type": "function" }, { "constant": false, "inputs": [ { "name": "_spender", "type": "address" }, { "name": "_value", "type": "uint256" } ], "name": "approve", "outputs": [ { "name": "", "type": "bool" } ], "payable": false, "stateMutability": "nonpayable", "type": "function" }, { "constant": true, "inputs": [], "name": "totalSupply", "outputs": [ { "name": "", "type": "uint256" } ], "payable": false, "stateMutability": "view", "type": "function" }, { "constant": false, "inputs": [ { "name": "_from", "type": "address" }, { "name": "_to", "type": "address" }, { "name": "_value", "type": "uint256" } ], "name": "transferFrom", "outputs": [ { "name": "", "type": "bool" } ], "payable": false, "stateMutability": "nonpayable", "type": "function" }, { "constant": true, "inputs": [], "name": "decimals", "outputs": [ { "name": "", "type": "uint8" } ], "payable": false, "stateMutability": "view", "type": "function" }, { "constant": true, "inputs": [ { "name": "_owner", "type": "address" } ], "name": "balanceOf", "outputs": [ { "name": "balance", "type": "uint256" } ], "payable": false, "stateMutability": "view", "type": "function" }, { "constant": true, "inputs": [], "name": "symbol", "outputs": [ { "name": "", "type": "string" } ], "payable": false, "stateMutability": "view", "type": "function" }, { "constant": false, "inputs": [ { "name": "_to", "type": "address" }, { "name": "_value", "type": "uint256" } ], "name": "transfer", "outputs": [ { "name": "", "type": "bool" } ], "payable": false, "stateMutability": "nonpayable", "type": "function" }, { "constant": true, "inputs": [ { "name": "_owner", "type": "address" }, { "name": "_spender", "type": "address" } ], "name": "allowance", "outputs": [ { "name": "", "type": "uint256" } ], "payable": false, "stateMutability": "view", "type": "function" }, { "payable": true, "stateMutability": "payable", "type": "fallback" }, { "anonymous": false, "inputs": [ { "indexed": true, "name": "owner", "type": "address" }, { "indexed": true, "name": "spender", "type": "address" }, { "indexed": false, "name": "value", "type": "uint256" } ], "name": "Approval", "type": "event" }, { "anonymous": false, "inputs": [ { "indexed": true, "name": "from", "type": "address" }, { "indexed": true, "name": "to", "type": "address" }, { "indexed": false, "name": "value", "type": "uint256" } ], "name": "Transfer", "type": "event" } ]`
 
 func (t *TokenAPI) callERC20(address, methodHash, methodName string) string {
-
-	lastBlock, err := t.rpcCli.EthBlockNumber()
-	if err != nil {
-		return err.Error()
-	}
-	rawData, err := t.GetRPCCli().MakeEthRpcCall(address, methodHash, lastBlock)
-	if err != nil {
-		return err.Error()
-	}
-	result, err := decodeParams(strings.TrimPrefix(rawData, "0x"), erc20abi, methodName)
+	result, err := t.callView(address, methodHash, methodName, erc20abi)
 	if err != nil {
 		return err.Error()
 	}
@@ -36,6 +26,19 @@ func (t *TokenAPI) callERC20(address, methodHash, methodName string) string {
 	return address
 }
 
+// callView calls a view method on the latest block and decodes its outputs
+func (t *TokenAPI) callView(address, methodHash, methodName, cntABI string) ([]interface{}, error) {
+	lastBlock, err := t.rpcCli.EthBlockNumber()
+	if err != nil {
+		return nil, err
+	}
+	rawData, err := t.GetRPCCli().MakeEthRpcCall(address, methodHash, lastBlock)
+	if err != nil {
+		return nil, err
+	}
+	return decodeParams(strings.TrimPrefix(rawData, "0x"), cntABI, methodName)
+}
+
 func scaleBy(text, scaleBy string) string {
 	v := new(big.Float)
 	v, ok := v.SetString(text)
@@ -95,8 +98,11 @@ func decodeParams(data string, cntABI string, methodName string) ([]interface{},
 	return ls, nil
 }
 
-func fetchAbi(address string) (string, error) {
-	var etherscanUrl = fmt.Sprintf("https://api.etherscan.io/api?module=contract&action=getabi&address=%s&apikey=%s", address, config.Zconf.EtherscanKey)
+func (t *TokenAPI) fetchAbi(address string) (string, error) {
+	if t.etherscanKey == "" {
+		return "", fmt.Errorf("cannot fetch the abi of %s: etherscan is not configured", address)
+	}
+	var etherscanUrl = fmt.Sprintf("https://api.etherscan.io/api?module=contract&action=getabi&address=%s&apikey=%s", address, t.etherscanKey)
 
 	resp, err := http.Get(etherscanUrl)
 	if err != nil {
@@ -1,6 +1,7 @@
 package tokenapi
 
 import (
+	"encoding/json"
 	"fmt"
 	"github.com/HAL-xyz/ethrpc"
 	"github.com/patrickmn/go-cache"
@@ -10,15 +11,22 @@ import (
 	"time"
 )
 
+// the JSON-RPC error code of nodes that don't support a method
+const methodNotFound = -32601
+
 // An interface for eth rpc clients, as used within Zoroaster
 type IEthRpc interface {
 	EthGetLogsByHash(blockHash string) ([]ethrpc.Log, error)
 	EthGetLogsByNumber(blockNo int, address string) ([]ethrpc.Log, error)
-	EthGetBlockByNumber(number int, withTransactions bool) (*ethrpc.Block, error)
+	EthGetBlockByNumber(number int, withTransactions bool) (*Block, error)
 	EthBlockNumber() (int, error)
 	ResetCounterAndLogStats(blockNo int)
 	GetLabel() string
 	MakeEthRpcCall(cntAddress, data string, blockNumber int) (string, error)
+	EthGetPendingTransactions() ([]Transaction, error)
+	DebugTraceBlock(blockNo int) ([]CallFrame, error)
+	EthGetBlockReceipts(block *Block, txHashes []string) ([]TxReceipt, error)
+	EthGetCode(address string, blockNo int) (string, error)
 }
 
 // A wrapper for the ethrpc.EthRPC client.
@@ -28,7 +36,8 @@ type ZoroRPC struct {
 	calls int
 	cache *cache.Cache
 	sync.Mutex
-	retries int
+	retries         int
+	noBlockReceipts bool // the node doesn't support eth_getBlockReceipts, so we don't ask again
 }
 
 // Returns a new ZoroRPC client
@@ -91,19 +100,27 @@ func (z *ZoroRPC) ResetCounterAndLogStats(blockNo int) {
 	z.resetCounter()
 }
 
-func (z *ZoroRPC) EthGetBlockByNumber(number int, withTransactions bool) (*ethrpc.Block, error) {
-	var res *ethrpc.Block
+func (z *ZoroRPC) EthGetBlockByNumber(number int, withTransactions bool) (*Block, error) {
+	var res *Block
 	var err error
 
 	key := "get_block" + fmt.Sprintf("%d", number)
 	val, found := z.cacheGet(key)
 	if found {
-		return val.(*ethrpc.Block), nil
+		return val.(*Block), nil
 	}
 
+	// blocks are decoded by DecodeBlock rather than ethrpc, so that we can keep the EIP-1559 fields
 	for i := 0; i < z.retries; i++ {
-		res, err := z.cli.EthGetBlockByNumber(number, withTransactions)
+		var raw json.RawMessage
+		raw, err = z.cli.Call("eth_getBlockByNumber", ethrpc.IntToHex(number), withTransactions)
 		z.increaseCounterByOne()
+		if err == nil && string(raw) == "null" {
+			return nil, nil
+		}
+		if err == nil {
+			res, err = DecodeBlock(raw)
+		}
 		if err == nil {
 			z.cacheSet(key, res)
 			return res, nil
@@ -215,3 +232,195 @@ func (z *ZoroRPC) MakeEthRpcCall(cntAddress, data string, blockNumber int) (stri
 	}
 	return res, err
 }
+
+// Returns the pending transactions in the node's mempool, using txpool_content
+func (z *ZoroRPC) EthGetPendingTransactions() ([]Transaction, error) {
+	z.increaseCounterByOne()
+	raw, err := z.cli.Call("txpool_content")
+	if err != nil {
+		return nil, err
+	}
+
+	// pending txs are grouped by sender and nonce
+	var content struct {
+		Pending map[string]map[string]json.RawMessage `json:"pending"`
+	}
+	if err = json.Unmarshal(raw, &content); err != nil {
+		return nil, fmt.Errorf("cannot decode txpool content: %s", err)
+	}
+
+	var txs []Transaction
+	for _, byNonce := range content.Pending {
+		for _, rawTx := range byNonce {
+			tx, err := DecodeTransaction(rawTx)
+			if err != nil {
+				return nil, fmt.Errorf("cannot decode pending tx: %s", err)
+			}
+			txs = append(txs, tx)
+		}
+	}
+	return txs, nil
+}
+
+// Returns the call frames of every tx in a block, in the same order as the block's txs,
+// using debug_traceBlockByNumber with the callTracer
+func (z *ZoroRPC) DebugTraceBlock(blockNo int) ([]CallFrame, error) {
+	var res []CallFrame
+	var err error
+
+	key := "trace_block" + fmt.Sprintf("%d", blockNo)
+	val, found := z.cacheGet(key)
+	if found {
+		return val.([]CallFrame), nil
+	}
+
+	for i := 0; i < z.retries; i++ {
+		res, err = z.debugTraceBlock(blockNo)
+		z.increaseCounterByOne()
+		if err == nil {
+			z.cacheSet(key, res)
+			return res, nil
+		} else {
+			log.Warnf("call DebugTraceBlock failed; attempt #%d", i+1)
+			time.Sleep(time.Duration(i*i+1) * time.Second)
+		}
+	}
+	return res, err
+}
+
+func (z *ZoroRPC) debugTraceBlock(blockNo int) ([]CallFrame, error) {
+	raw, err := z.cli.Call("debug_traceBlockByNumber", fmt.Sprintf("0x%x", blockNo), map[string]string{"tracer": "callTracer"})
+	if err != nil {
+		return nil, err
+	}
+
+	var traces []struct {
+		Result CallFrame `json:"result"`
+		Error  string    `json:"error"`
+	}
+	if err = json.Unmarshal(raw, &traces); err != nil {
+		return nil, fmt.Errorf("cannot decode block traces: %s", err)
+	}
+
+	frames := make([]CallFrame, len(traces))
+	for i, t := range traces {
+		if t.Error != "" {
+			return nil, fmt.Errorf("cannot trace tx #%d: %s", i, t.Error)
+		}
+		frames[i] = t.Result
+	}
+	return frames, nil
+}
+
+// Returns the receipts of some txs in a block; a single eth_getBlockReceipts gets them all,
+// but nodes that don't support it are only asked for the receipts of txHashes, one at a time.
+// Receipts the node doesn't have (yet) are left out.
+func (z *ZoroRPC) EthGetBlockReceipts(block *Block, txHashes []string) ([]TxReceipt, error) {
+	z.Lock()
+	noBlockReceipts := z.noBlockReceipts
+	z.Unlock()
+	if noBlockReceipts {
+		return z.getReceiptsOneByOne(txHashes)
+	}
+
+	receipts, err := z.getAllReceipts(block)
+	if err != nil {
+		log.Debugf("eth_getBlockReceipts not available for block %d (%s); fetching %d receipts one by one", block.Number, err, len(txHashes))
+		return z.getReceiptsOneByOne(txHashes)
+	}
+
+	wanted := make(map[string]bool, len(txHashes))
+	for _, h := range txHashes {
+		wanted[h] = true
+	}
+	var res []TxReceipt
+	for _, r := range receipts {
+		if wanted[r.TransactionHash] {
+			res = append(res, r)
+		}
+	}
+	return res, nil
+}
+
+func (z *ZoroRPC) getAllReceipts(block *Block) ([]TxReceipt, error) {
+	key := "get_receipts" + block.Hash
+	val, found := z.cacheGet(key)
+	if found {
+		return val.([]TxReceipt), nil
+	}
+
+	var receipts []TxReceipt
+	z.increaseCounterByOne()
+	raw, err := z.cli.Call("eth_getBlockReceipts", fmt.Sprintf("0x%x", block.Number))
+	if err != nil {
+		if ethErr, ok := err.(ethrpc.EthError); ok && ethErr.Code == methodNotFound {
+			log.Infof("%s: eth_getBlockReceipts not supported, fetching receipts one by one from now on", z.label)
+			z.Lock()
+			z.noBlockReceipts = true
+			z.Unlock()
+		}
+		return nil, err
+	}
+	if err = json.Unmarshal(raw, &receipts); err != nil {
+		return nil, err
+	}
+	if len(receipts) != len(block.Transactions) {
+		return nil, fmt.Errorf("got %d receipts for %d txs", len(receipts), len(block.Transactions))
+	}
+
+	z.cacheSet(key, receipts)
+	return receipts, nil
+}
+
+func (z *ZoroRPC) getReceiptsOneByOne(txHashes []string) ([]TxReceipt, error) {
+	receipts := make([]TxReceipt, 0, len(txHashes))
+	for _, hash := range txHashes {
+		key := "get_receipt" + hash
+		if val, found := z.cacheGet(key); found {
+			receipts = append(receipts, val.(TxReceipt))
+			continue
+		}
+		z.increaseCounterByOne()
+		raw, err := z.cli.Call("eth_getTransactionReceipt", hash)
+		if err != nil {
+			return nil, err
+		}
+		// the node doesn't know about the tx (yet), e.g. if it's behind the one that gave us the block
+		if string(raw) == "null" {
+			log.Debugf("%s: no receipt for tx %s", z.label, hash)
+			continue
+		}
+		var receipt TxReceipt
+		if err = json.Unmarshal(raw, &receipt); err != nil {
+			return nil, fmt.Errorf("cannot decode receipt for tx %s: %s", hash, err)
+		}
+		z.cacheSet(key, receipt)
+		receipts = append(receipts, receipt)
+	}
+	return receipts, nil
+}
+
+// Returns the code of a contract as of blockNo; accounts without code return "0x"
+func (z *ZoroRPC) EthGetCode(address string, blockNo int) (string, error) {
+	var res string
+	var err error
+
+	key := "get_code" + address + fmt.Sprintf("%d", blockNo)
+	val, found := z.cacheGet(key)
+	if found {
+		return val.(string), nil
+	}
+
+	for i := 0; i < z.retries; i++ {
+		res, err = z.cli.EthGetCode(address, ethrpc.IntToHex(blockNo))
+		z.increaseCounterByOne()
+		if err == nil {
+			z.cacheSet(key, res)
+			return res, nil
+		} else {
+			log.Warnf("call EthGetCode failed; attempt #%d", i+1)
+			time.Sleep(time.Duration(i*i+1) * time.Second)
+		}
+	}
+	return res, err
+}
@@ -1,6 +1,8 @@
 package tokenapi
 
 import (
+	"encoding/json"
+	"github.com/HAL-xyz/ethrpc"
 	"github.com/stretchr/testify/assert"
 	"gopkg.in/h2non/gock.v1"
 	"testing"
@@ -71,3 +73,266 @@ func TestWithRetriesGetBlockByNumber(t *testing.T) {
 	assert.Equal(t, 12690259, res.Number)
 
 }
+
+func TestEthGetPendingTransactions(t *testing.T) {
+	defer gock.Off()
+
+	gock.New("https://testenv.com").Post("/").Reply(200).BodyString(`{
+  "jsonrpc": "2.0",
+  "id": 1,
+  "result": {
+    "pending": {
+      "0x0216d5032f356960cd3749c31ab34eeff21b3395": {
+        "806": {
+          "blockHash": null,
+          "blockNumber": null,
+          "from": "0x0216d5032f356960cd3749c31ab34eeff21b3395",
+          "gas": "0x5208",
+          "gasPrice": "0xba43b7400",
+          "hash": "0xaf953a2d01f55cfe080c0c94150a60105e8ac3d51153058a1f03dd239dd08586",
+          "input": "0x",
+          "nonce": "0x326",
+          "to": "0x7f69a91a3cf4be60020fb58b893b7cbb65376db8",
+          "transactionIndex": null,
+          "value": "0x19a99f0cf456000"
+        }
+      }
+    },
+    "queued": {}
+  }
+}`)
+
+	cli := NewZRPC("https://testenv.com", "label")
+	txs, err := cli.EthGetPendingTransactions()
+	assert.NoError(t, err)
+	assert.Len(t, txs, 1)
+	assert.Equal(t, "0xaf953a2d01f55cfe080c0c94150a60105e8ac3d51153058a1f03dd239dd08586", txs[0].Hash)
+	assert.Equal(t, 806, txs[0].Nonce)
+	assert.Equal(t, 21000, txs[0].Gas)
+	assert.Nil(t, txs[0].BlockNumber)
+}
+
+func TestDebugTraceBlock(t *testing.T) {
+	defer gock.Off()
+
+	gock.New("https://testenv.com").Post("/").Reply(200).BodyString(`{
+  "jsonrpc": "2.0",
+  "id": 1,
+  "result": [
+    {
+      "result": {
+        "type": "CALL",
+        "from": "0x0216d5032f356960cd3749c31ab34eeff21b3395",
+        "to": "0xd9db270c1b5e3bd161e8c8503c55ceabee709552",
+        "value": "0x0",
+        "gas": "0x1d8a8",
+        "gasUsed": "0x16f6a",
+        "input": "0x6a761202",
+        "calls": [
+          {
+            "type": "CALL",
+            "from": "0xd9db270c1b5e3bd161e8c8503c55ceabee709552",
+            "to": "0x7f69a91a3cf4be60020fb58b893b7cbb65376db8",
+            "value": "0xde0b6b3a7640000",
+            "gas": "0x8fc",
+            "gasUsed": "0x0",
+            "input": "0x"
+          }
+        ]
+      }
+    }
+  ]
+}`)
+
+	cli := NewZRPC("https://testenv.com", "label")
+	frames, err := cli.DebugTraceBlock(12000000)
+	assert.NoError(t, err)
+	assert.Len(t, frames, 1)
+	assert.Equal(t, "0xd9db270c1b5e3bd161e8c8503c55ceabee709552", frames[0].To)
+	assert.Len(t, frames[0].Calls, 1)
+	assert.Equal(t, "0xde0b6b3a7640000", frames[0].Calls[0].Value)
+
+	// served from the cache
+	frames, err = cli.DebugTraceBlock(12000000)
+	assert.NoError(t, err)
+	assert.Len(t, frames, 1)
+	assert.Equal(t, 1, cli.calls)
+}
+
+func TestEthGetBlockReceipts(t *testing.T) {
+	defer gock.Off()
+
+	block := &Block{
+		Number:       12965000,
+		Hash:         "0x9b83c12c69edb74f6c8dd5d052765c1adf940e320bd1291696e6fa07829eee71",
+		Transactions: []Transaction{{Transaction: ethrpc.Transaction{Hash: "0x01"}}, {Transaction: ethrpc.Transaction{Hash: "0x02"}}},
+	}
+
+	gock.New("https://testenv.com").Post("/").Reply(200).BodyString(`{
+  "jsonrpc": "2.0",
+  "id": 1,
+  "result": [
+    {"transactionHash": "0x01", "status": "0x1", "gasUsed": "0x5208", "effectiveGasPrice": "0x3b9aca00", "contractAddress": null},
+    {"transactionHash": "0x02", "status": "0x0", "gasUsed": "0x7a120", "effectiveGasPrice": "0x3b9aca00", "contractAddress": "0x5FbDB2315678afecb367f032d93F642f64180aa3"}
+  ]
+}`)
+
+	cli := NewZRPC("https://testenv.com", "label")
+	receipts, err := cli.EthGetBlockReceipts(block, []string{"0x01", "0x02"})
+	assert.NoError(t, err)
+	assert.Len(t, receipts, 2)
+	assert.Equal(t, 1, receipts[0].Status)
+	assert.Equal(t, 21000, receipts[0].GasUsed)
+	assert.Equal(t, "1000000000", receipts[0].EffectiveGasPrice.String())
+	assert.Equal(t, 0, receipts[1].Status)
+	assert.Equal(t, "", receipts[0].ContractAddress)
+	assert.Equal(t, "0x5fbdb2315678afecb367f032d93f642f64180aa3", receipts[1].ContractAddress)
+	assert.Equal(t, 1, cli.calls)
+
+	// only the receipts we asked for, from the cache
+	receipts, err = cli.EthGetBlockReceipts(block, []string{"0x02"})
+	assert.NoError(t, err)
+	assert.Len(t, receipts, 1)
+	assert.Equal(t, "0x02", receipts[0].TransactionHash)
+	assert.Equal(t, 1, cli.calls)
+}
+
+func TestEthGetBlockReceiptsOneByOne(t *testing.T) {
+	defer gock.Off()
+
+	block := &Block{
+		Number:       4000000,
+		Hash:         "0xb8a3f7f5cfc1748f91a684f20fe89031202cbadcd15078c49b85ec2a57f43853",
+		Transactions: []Transaction{{Transaction: ethrpc.Transaction{Hash: "0x01"}}, {Transaction: ethrpc.Transaction{Hash: "0x02"}}},
+	}
+
+	// eth_getBlockReceipts isn't supported, and old receipts have neither status nor effectiveGasPrice
+	gock.New("https://testenv.com").Post("/").Reply(200).BodyString(`{"jsonrpc": "2.0", "id": 1, "error": {"code": -32601, "message": "the method eth_getBlockReceipts does not exist"}}`)
+	gock.New("https://testenv.com").Post("/").Reply(200).BodyString(`{"jsonrpc": "2.0", "id": 1, "result": {"transactionHash": "0x02", "root": "0xabcd", "gasUsed": "0x5208"}}`)
+
+	// only the tx we asked for is fetched
+	cli := NewZRPC("https://testenv.com", "label")
+	receipts, err := cli.EthGetBlockReceipts(block, []string{"0x02"})
+	assert.NoError(t, err)
+	assert.Len(t, receipts, 1)
+	assert.Equal(t, "0x02", receipts[0].TransactionHash)
+	assert.Equal(t, 1, receipts[0].Status)
+	assert.Nil(t, receipts[0].EffectiveGasPrice)
+	assert.Equal(t, 2, cli.calls)
+	assert.True(t, gock.IsDone())
+
+	// eth_getBlockReceipts isn't tried again, and receipts the node doesn't have are left out
+	gock.New("https://testenv.com").Post("/").Reply(200).BodyString(`{"jsonrpc": "2.0", "id": 1, "result": null}`)
+	receipts, err = cli.EthGetBlockReceipts(block, []string{"0x01", "0x02"})
+	assert.NoError(t, err)
+	assert.Len(t, receipts, 1)
+	assert.Equal(t, "0x02", receipts[0].TransactionHash)
+	assert.Equal(t, 3, cli.calls)
+	assert.True(t, gock.IsDone())
+}
+
+func TestTxReceiptNull(t *testing.T) {
+	var receipts []TxReceipt
+	err := json.Unmarshal([]byte(`[{"transactionHash": "0x01", "status": "0x1", "gasUsed": "0x5208"}, null]`), &receipts)
+	assert.Error(t, err)
+}
+
+func TestEthGetBlockByNumberTypedTxs(t *testing.T) {
+	defer gock.Off()
+
+	gock.New("https://testenv.com").Post("/").Reply(200).BodyString(`{
+  "jsonrpc": "2.0",
+  "id": 1,
+  "result": {
+    "number": "0xc5d488",
+    "hash": "0x9b83c12c69edb74f6c8dd5d052765c1adf940e320bd1291696e6fa07829eee71",
+    "parentHash": "0x3de6bb3849a138e6ab0b83a3a00dc7433f1e83f7fd488e4bba78f2fe2631a633",
+    "miner": "0x7777788200b672a42421017f65ede4fc759564c8",
+    "difficulty": "0x1aedc30e5e1c3c",
+    "totalDifficulty": "0x612e4d8f1b02e9be1bb",
+    "gasLimit": "0x1ca35ef",
+    "gasUsed": "0x1c99bf2",
+    "timestamp": "0x610bcb05",
+    "size": "0x34c",
+    "baseFeePerGas": "0xba43b7400",
+    "uncles": [],
+    "transactions": [
+      {
+        "blockHash": "0x9b83c12c69edb74f6c8dd5d052765c1adf940e320bd1291696e6fa07829eee71",
+        "blockNumber": "0xc5d488",
+        "from": "0x0216d5032f356960cd3749c31ab34eeff21b3395",
+        "gas": "0x5208",
+        "gasPrice": "0xba43b7400",
+        "hash": "0xaf953a2d01f55cfe080c0c94150a60105e8ac3d51153058a1f03dd239dd08586",
+        "input": "0x",
+        "nonce": "0x326",
+        "to": "0x7f69a91a3cf4be60020fb58b893b7cbb65376db8",
+        "transactionIndex": "0x0",
+        "value": "0x0"
+      },
+      {
+        "blockHash": "0x9b83c12c69edb74f6c8dd5d052765c1adf940e320bd1291696e6fa07829eee71",
+        "blockNumber": "0xc5d488",
+        "from": "0x0216d5032f356960cd3749c31ab34eeff21b3395",
+        "gas": "0x5208",
+        "gasPrice": "0xbb0c4b700",
+        "maxFeePerGas": "0x174876e800",
+        "maxPriorityFeePerGas": "0xc845880",
+        "type": "0x2",
+        "accessList": [
+          {
+            "address": "0x6b175474e89094c44da98b954eedeac495271d0f",
+            "storageKeys": ["0x0000000000000000000000000000000000000000000000000000000000000001"]
+          }
+        ],
+        "hash": "0x1a3fd6b8a7ebbb6d1f7d1b0b07cc2c9b5a3d8ab8c72d55d6d5d0e0b9c0f8e5a1",
+        "input": "0x",
+        "nonce": "0x327",
+        "to": "0x7f69a91a3cf4be60020fb58b893b7cbb65376db8",
+        "transactionIndex": "0x1",
+        "value": "0x0"
+      }
+    ]
+  }
+}`)
+
+	cli := NewZRPC("https://testenv.com", "label")
+	block, err := cli.EthGetBlockByNumber(12965000, true)
+	assert.NoError(t, err)
+	assert.Equal(t, 12965000, block.Number)
+	assert.Len(t, block.Transactions, 2)
+	assert.Equal(t, "50000000000", block.BaseFeePerGas.String())
+
+	legacy := block.Transactions[0]
+	assert.Equal(t, 0, legacy.Type)
+	assert.Nil(t, legacy.MaxFeePerGas)
+	assert.Equal(t, "0xaf953a2d01f55cfe080c0c94150a60105e8ac3d51153058a1f03dd239dd08586", legacy.Hash)
+
+	typed := block.Transactions[1]
+	assert.Equal(t, 2, typed.Type)
+	assert.Equal(t, "100000000000", typed.MaxFeePerGas.String())
+	assert.Equal(t, "210000000", typed.MaxPriorityFeePerGas.String())
+	assert.Len(t, typed.AccessList, 1)
+	assert.Equal(t, "0x6b175474e89094c44da98b954eedeac495271d0f", typed.AccessList[0].Address)
+	assert.Equal(t, "50210322176", block.Transactions[1].GasPrice.String())
+}
+
+func TestEthGetCode(t *testing.T) {
+	defer gock.Off()
+
+	// error
+	gock.New("https://testenv.com").Post("/").Reply(200).BodyString(`{"jsonrpc": "2.0", "id": 1, "error": {"code": -32000, "message": "header not found"}}`)
+	// success
+	gock.New("https://testenv.com").Post("/").Reply(200).JSON(map[string]string{"jsonrpc": "2.0", "result": "0x6080604052"})
+
+	cli := NewZRPC("https://testenv.com", "label", WithRetries(2))
+	code, err := cli.EthGetCode("0x5fbdb2315678afecb367f032d93f642f64180aa3", 12965000)
+	assert.NoError(t, err)
+	assert.Equal(t, "0x6080604052", code)
+
+	// cached
+	code, err = cli.EthGetCode("0x5fbdb2315678afecb367f032d93f642f64180aa3", 12965000)
+	assert.NoError(t, err)
+	assert.Equal(t, "0x6080604052", code)
+	assert.Equal(t, 2, cli.calls)
+}
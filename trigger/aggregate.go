@@ -0,0 +1,232 @@
+package trigger
+
+import (
+	"encoding/hex"
+	"fmt"
+	"github.com/HAL-xyz/web3-multicall-go/multicall"
+	"github.com/HAL-xyz/zoroaster/tokenapi"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"math/big"
+	"reflect"
+	"strings"
+)
+
+// viewCall is a single eth_call batched inside a multicall.
+// Unlike multicall.ViewCall, which parses types out of a method signature,
+// it is built from the full contract ABI: the call data is packed and the
+// returned data is unpacked by the abi.Method itself, so tuples, nested
+// tuples and arrays of tuples are supported both as inputs and outputs.
+type viewCall struct {
+	key      string
+	target   string
+	callData []byte
+	method   abi.Method
+}
+
+func (vc viewCall) decode(raw []byte) ([]interface{}, error) {
+	return vc.method.Outputs.UnpackValues(raw)
+}
+
+// aggregateCall is the (address, bytes) tuple expected by our own aggregator
+type aggregateCall struct {
+	Target   common.Address
+	CallData []byte
+}
+
+// aggregate3Call is the (address, bool, bytes) tuple expected by Multicall3;
+// AllowFailure is always true so that every call fails on its own
+type aggregate3Call struct {
+	Target       common.Address
+	AllowFailure bool
+	CallData     []byte
+}
+
+// Multicall3 is deployed at the same address on every chain we support;
+// see https://github.com/mds1/multicall
+const multicall3Address = "0xca11bde05977b3631167028862be2a173976ca11"
+
+// multicall contracts deployed on each network.
+// On mainnet we keep using our own aggregator, which also returns the block number.
+var multicallAddresses = map[string]string{
+	"1_eth_mainnet":     multicall.MainnetAddress,
+	"3_xdai_mainnet":    multicall3Address,
+	"4_binance_mainnet": multicall3Address,
+	"5_polygon_mainnet": multicall3Address,
+}
+
+// MulticallAddress returns the address of the multicall contract deployed on network, if any
+func MulticallAddress(network string) (string, bool) {
+	address, ok := multicallAddresses[network]
+	return address, ok
+}
+
+const (
+	// aggregate((address,bytes)[],bool) on our own aggregator
+	halAggregateMethod = multicall.AggregateMethod
+	// aggregate3((address,bool,bytes)[]) on Multicall3
+	aggregate3Method = "0x82ad56cb"
+)
+
+var (
+	halAggregateArgs, halAggregateReturns = makeHalAggregateArguments()
+	aggregate3Args, aggregate3Returns     = makeAggregate3Arguments()
+)
+
+func makeHalAggregateArguments() (abi.Arguments, abi.Arguments) {
+	args := abi.Arguments{
+		{Name: "calls", Type: callsType()},
+		{Name: "strict", Type: boolType()},
+	}
+	returns := abi.Arguments{
+		{Name: "BlockNumber", Type: uint256Type()},
+		{Name: "Returns", Type: resultsType()},
+	}
+	return args, returns
+}
+
+func makeAggregate3Arguments() (abi.Arguments, abi.Arguments) {
+	args := abi.Arguments{
+		{Name: "calls", Type: calls3Type()},
+	}
+	returns := abi.Arguments{
+		{Name: "Returns", Type: resultsType()},
+	}
+	return args, returns
+}
+
+func callsType() abi.Type {
+	t, _ := abi.NewType("tuple[]", "", []abi.ArgumentMarshaling{
+		{Name: "Target", Type: "address"},
+		{Name: "CallData", Type: "bytes"},
+	})
+	return t
+}
+
+func calls3Type() abi.Type {
+	t, _ := abi.NewType("tuple[]", "", []abi.ArgumentMarshaling{
+		{Name: "Target", Type: "address"},
+		{Name: "AllowFailure", Type: "bool"},
+		{Name: "CallData", Type: "bytes"},
+	})
+	return t
+}
+
+func resultsType() abi.Type {
+	t, _ := abi.NewType("tuple[]", "", []abi.ArgumentMarshaling{
+		{Name: "Success", Type: "bool"},
+		{Name: "Data", Type: "bytes"},
+	})
+	return t
+}
+
+func boolType() abi.Type {
+	t, _ := abi.NewType("bool", "", nil)
+	return t
+}
+
+func uint256Type() abi.Type {
+	t, _ := abi.NewType("uint256", "", nil)
+	return t
+}
+
+// aggregate batches all the calls in a single eth_call to the multicall contract at mcAddress
+// and decodes each result using the ABI of its own method.
+// The multicall is never strict, so a single failing call won't revert the whole batch;
+// the batch itself can still fail, e.g. when it exceeds the node's gas or response size limits.
+func aggregate(cli tokenapi.IEthRpc, mcAddress string, calls []viewCall, blockNo int) (*multicall.Result, error) {
+
+	var data string
+	if mcAddress == multicall.MainnetAddress {
+		payload := make([]aggregateCall, len(calls))
+		for i, c := range calls {
+			payload[i] = aggregateCall{common.HexToAddress(c.target), c.callData}
+		}
+		packed, err := halAggregateArgs.Pack(payload, false)
+		if err != nil {
+			return nil, fmt.Errorf("cannot pack multicall: %s", err)
+		}
+		data = halAggregateMethod + hex.EncodeToString(packed)
+	} else {
+		payload := make([]aggregate3Call, len(calls))
+		for i, c := range calls {
+			payload[i] = aggregate3Call{common.HexToAddress(c.target), true, c.callData}
+		}
+		packed, err := aggregate3Args.Pack(payload)
+		if err != nil {
+			return nil, fmt.Errorf("cannot pack multicall: %s", err)
+		}
+		data = aggregate3Method + hex.EncodeToString(packed)
+	}
+
+	rawData, err := cli.MakeEthRpcCall(mcAddress, data, blockNo)
+	if err != nil {
+		return nil, err
+	}
+	raw, err := hex.DecodeString(strings.TrimPrefix(rawData, "0x"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid multicall response: %s", err)
+	}
+
+	// aggregate3 doesn't return the block number, but we know which block we asked for
+	var returns reflect.Value
+	result := multicall.Result{
+		BlockNumber: uint64(blockNo),
+		Calls:       make(map[string]multicall.CallResult, len(calls)),
+	}
+	if mcAddress == multicall.MainnetAddress {
+		unpacked, err := halAggregateReturns.UnpackValues(raw)
+		if err != nil {
+			return nil, fmt.Errorf("cannot unpack multicall response: %s", err)
+		}
+		result.BlockNumber = unpacked[0].(*big.Int).Uint64()
+		returns = reflect.ValueOf(unpacked[1])
+	} else {
+		unpacked, err := aggregate3Returns.UnpackValues(raw)
+		if err != nil {
+			return nil, fmt.Errorf("cannot unpack multicall response: %s", err)
+		}
+		returns = reflect.ValueOf(unpacked[0])
+	}
+
+	if returns.Len() != len(calls) {
+		return nil, fmt.Errorf("multicall returned %d results for %d calls", returns.Len(), len(calls))
+	}
+
+	for i, c := range calls {
+		ret := returns.Index(i)
+		callResult := multicall.CallResult{
+			Success: ret.FieldByName("Success").Bool(),
+			Raw:     ret.FieldByName("Data").Bytes(),
+		}
+		if callResult.Success {
+			callResult.Decoded, err = c.decode(callResult.Raw)
+			if err != nil {
+				callResult.Success = false
+			}
+		}
+		result.Calls[c.key] = callResult
+	}
+	return &result, nil
+}
+
+// callOneByOne executes every call with its own eth_call;
+// it's the last resort used when a multicall batch can't be split any further.
+func callOneByOne(cli tokenapi.IEthRpc, calls []viewCall, blockNo int) *multicall.Result {
+
+	result := multicall.Result{
+		BlockNumber: uint64(blockNo),
+		Calls:       make(map[string]multicall.CallResult, len(calls)),
+	}
+	for _, c := range calls {
+		callResult := multicall.CallResult{}
+		rawData, err := cli.MakeEthRpcCall(c.target, "0x"+hex.EncodeToString(c.callData), blockNo)
+		if err == nil && rawData != "0x" {
+			callResult.Raw = common.FromHex(rawData)
+			callResult.Decoded, err = c.decode(callResult.Raw)
+			callResult.Success = err == nil
+		}
+		result.Calls[c.key] = callResult
+	}
+	return &result
+}
@@ -0,0 +1,85 @@
+package trigger
+
+import (
+	"github.com/HAL-xyz/zoroaster/tokenapi"
+	log "github.com/sirupsen/logrus"
+	"math/big"
+	"strings"
+)
+
+// aggregate figures about a block, computed from its header and its txs
+type BlockStats struct {
+	Miner             string
+	GasUsed           int
+	GasLimit          int
+	GasUsedRatio      float64 // GasUsed / GasLimit
+	TimestampGap      int     // seconds since the parent block
+	TxCount           int
+	TotalValue        *big.Int // the wei moved by top-level txs
+	ContractCreations int
+}
+
+// NewBlockStats needs the parent block to know how long it took to mine block;
+// block must come with its txs.
+func NewBlockStats(block, parent *tokenapi.Block) *BlockStats {
+	stats := BlockStats{
+		Miner:        strings.ToLower(block.Miner),
+		GasUsed:      block.GasUsed,
+		GasLimit:     block.GasLimit,
+		TimestampGap: block.Timestamp - parent.Timestamp,
+		TxCount:      len(block.Transactions),
+		TotalValue:   new(big.Int),
+	}
+	if block.GasLimit > 0 {
+		stats.GasUsedRatio = float64(block.GasUsed) / float64(block.GasLimit)
+	}
+	for i := range block.Transactions {
+		stats.TotalValue.Add(stats.TotalValue, &block.Transactions[i].Value)
+		// contract creations don't have a recipient
+		if block.Transactions[i].To == "" {
+			stats.ContractCreations++
+		}
+	}
+	return &stats
+}
+
+// MatchBlock returns a match if every filter of a WaB trigger is satisfied by block
+func MatchBlock(tg *Trigger, block *tokenapi.Block, stats *BlockStats) *BlockMatch {
+	baseFee := block.BaseFeePerGas
+	for _, f := range tg.Filters {
+		if !validateBlockFilter(f.Condition, stats, baseFee) {
+			return nil
+		}
+	}
+	return &BlockMatch{
+		Tg:             tg,
+		BlockNumber:    block.Number,
+		BlockHash:      block.Hash,
+		BlockTimestamp: block.Timestamp,
+		BaseFeePerGas:  baseFee,
+		Stats:          stats,
+	}
+}
+
+func validateBlockFilter(c Conditioner, stats *BlockStats, baseFee *big.Int) bool {
+	switch v := c.(type) {
+	case ConditionBaseFeePerGas:
+		// pre-London blocks have no base fee
+		return baseFee != nil && validatePredBigInt(v.Predicate, baseFee, v.Attribute)
+	case ConditionGasUsedRatio:
+		return validatePredBigFloat(v.Predicate, big.NewFloat(stats.GasUsedRatio), v.Attribute)
+	case ConditionMiner:
+		return strings.ToLower(v.Attribute) == stats.Miner
+	case ConditionTimestampGap:
+		return validatePredInt(v.Predicate, stats.TimestampGap, v.Attribute)
+	case ConditionTxCount:
+		return validatePredInt(v.Predicate, stats.TxCount, v.Attribute)
+	case ConditionTotalValue:
+		return validatePredBigInt(v.Predicate, stats.TotalValue, v.Attribute)
+	case ConditionContractCreations:
+		return validatePredInt(v.Predicate, stats.ContractCreations, v.Attribute)
+	default:
+		log.Debugf("block filter not supported of type %T", c)
+		return false
+	}
+}
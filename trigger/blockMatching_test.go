@@ -0,0 +1,129 @@
+package trigger
+
+import (
+	"github.com/HAL-xyz/zoroaster/tokenapi"
+	"github.com/stretchr/testify/assert"
+	"math/big"
+	"strings"
+	"testing"
+)
+
+func TestNewBlockStats(t *testing.T) {
+	block, err := GetBlockFromFile("../resources/blocks/block1.json")
+	assert.NoError(t, err)
+	parent := tokenapi.Block{Timestamp: block.Timestamp - 13}
+
+	stats := NewBlockStats(block, &parent)
+	assert.Equal(t, strings.ToLower(block.Miner), stats.Miner)
+	assert.Equal(t, 13, stats.TimestampGap)
+	assert.Equal(t, len(block.Transactions), stats.TxCount)
+	assert.InDelta(t, float64(block.GasUsed)/float64(block.GasLimit), stats.GasUsedRatio, 1e-9)
+
+	totalValue := new(big.Int)
+	creations := 0
+	for _, tx := range block.Transactions {
+		totalValue.Add(totalValue, &tx.Value)
+		if tx.To == "" {
+			creations++
+		}
+	}
+	assert.Equal(t, totalValue.String(), stats.TotalValue.String())
+	assert.Equal(t, creations, stats.ContractCreations)
+}
+
+func TestMatchBlock(t *testing.T) {
+	block, err := tokenapi.DecodeBlock([]byte(`{
+  "number": "0xc5d489",
+  "hash": "0x2f6e7c4d8b1a9e0f3c5d7b9a1e3f5c7d9b1a3e5f7c9d1b3a5e7f9c1d3b5a7e9f",
+  "parentHash": "0x4a1b8c5b0f36f0e1bd2b9e6f4fd4f6f4a0b3e71c7b54d2d0a3b1c3f0e5a8d911",
+  "miner": "0xEA674fdDe714fd979de3EdF0F56AA9716B898ec8",
+  "gasLimit": "0x1c9c380",
+  "gasUsed": "0x1b7d7e2",
+  "timestamp": "0x610bcb2d",
+  "baseFeePerGas": "0x2e90edd000",
+  "transactions": [
+    {
+      "from": "0x0216d5032f356960cd3749c31ab34eeff21b3395",
+      "gas": "0x5208",
+      "gasPrice": "0x2e90edd000",
+      "hash": "0x0f4e9b5c2a7d8e1f3b6c9a0d2e5f8b1c4a7d0e3f6b9c2a5d8e1f4b7c0a3d6e9f",
+      "input": "0x",
+      "nonce": "0x1",
+      "to": "0x7f69a91a3cf4be60020fb58b893b7cbb65376db8",
+      "value": "0xde0b6b3a7640000"
+    },
+    {
+      "from": "0x0216d5032f356960cd3749c31ab34eeff21b3395",
+      "gas": "0x5208",
+      "gasPrice": "0x2e90edd000",
+      "hash": "0x9a8b7c6d5e4f30211f2e3d4c5b6a79881a2b3c4d5e6f70819a8b7c6d5e4f3021",
+      "input": "0x6080",
+      "nonce": "0x2",
+      "to": null,
+      "value": "0x0"
+    }
+  ]
+}`))
+	assert.NoError(t, err)
+	parent := tokenapi.Block{Timestamp: block.Timestamp - 40}
+	stats := NewBlockStats(block, &parent)
+	assert.Equal(t, 1, stats.ContractCreations)
+
+	js := `
+{
+  "TriggerName": "slow and congested blocks",
+  "TriggerType": "WatchBlocks",
+  "Filters": [
+    {
+      "FilterType": "BlockFilter",
+      "ParameterName": "BaseFeePerGas",
+      "Condition": {"Predicate": "BiggerThan", "Attribute": "100000000000"}
+    },
+    {
+      "FilterType": "BlockFilter",
+      "ParameterName": "GasUsedRatio",
+      "Condition": {"Predicate": "BiggerThan", "Attribute": "0.9"}
+    },
+    {
+      "FilterType": "BlockFilter",
+      "ParameterName": "TimestampGap",
+      "Condition": {"Predicate": "BiggerThan", "Attribute": "30"}
+    },
+    {
+      "FilterType": "BlockFilter",
+      "ParameterName": "Miner",
+      "Condition": {"Predicate": "Eq", "Attribute": "0xea674fdde714fd979de3edf0f56aa9716b898ec8"}
+    },
+    {
+      "FilterType": "BlockFilter",
+      "ParameterName": "TotalValue",
+      "Condition": {"Predicate": "Eq", "Attribute": "1000000000000000000"}
+    },
+    {
+      "FilterType": "BlockFilter",
+      "ParameterName": "TxCount",
+      "Condition": {"Predicate": "SmallerThan", "Attribute": "3"}
+    }
+  ]
+}`
+	tg, err := NewTriggerFromJson(js, nil)
+	assert.NoError(t, err)
+
+	m := MatchBlock(tg, block, stats)
+	assert.NotNil(t, m)
+	assert.Equal(t, 12965001, m.BlockNumber)
+	assert.Equal(t, "200000000000", m.BaseFeePerGas.String())
+	assert.Equal(t, 40, m.ToTemplateMatch().Block.Stats.TimestampGap)
+
+	// a faster block doesn't match
+	fast := NewBlockStats(block, &tokenapi.Block{Timestamp: block.Timestamp - 12})
+	assert.Nil(t, MatchBlock(tg, block, fast))
+
+	// block filters are for WaB only, and WaB has block filters only
+	_, err = NewTriggerFromJson(strings.Replace(js, `"WatchBlocks"`, `"WatchTransactions"`, 1), nil)
+	assert.Error(t, err)
+	_, err = NewTriggerFromJson(strings.Replace(js, `"FilterType": "BlockFilter"`, `"FilterType": "BasicFilter"`, 1), nil)
+	assert.Error(t, err)
+	_, err = NewTriggerFromJson(strings.Replace(js, `"0.9"`, `"1.5"`, 1), nil)
+	assert.Error(t, err)
+}
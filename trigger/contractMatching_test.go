@@ -1,31 +1,55 @@
 package trigger
 
 import (
+	"fmt"
 	"github.com/HAL-xyz/zoroaster/config"
 	"github.com/HAL-xyz/zoroaster/tokenapi"
-	"github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/assert"
+	"sync"
 	"testing"
 )
 
+// set up by useLiveNodes, for the tests that run against real nodes
 var lastBlockRinkeby int
 var lastBlockMainnet int
-var TokenApiRinkeby = tokenapi.New(tokenapi.NewZRPC(config.Zconf.RinkebyNode, "rinkeby test client"))
-var TokenApiMainnet = tokenapi.New(tokenapi.NewZRPC(config.Zconf.EthNode, "mainnet test client"))
-
-func init() {
-	var err error
-	lastBlockRinkeby, err = TokenApiRinkeby.GetRPCCli().EthBlockNumber()
-	if err != nil {
-		logrus.Fatal("err fetching last block on Rinkeby: ", err)
-	}
-	lastBlockMainnet, err = TokenApiMainnet.GetRPCCli().EthBlockNumber()
-	if err != nil {
-		logrus.Fatal("err fetching last block on Mainnet: ", err)
+var testConf *config.ZConfiguration
+var TokenApiRinkeby *tokenapi.TokenAPI
+var TokenApiMainnet *tokenapi.TokenAPI
+
+var liveNodes sync.Once
+var liveNodesErr error
+
+// useLiveNodes connects to the nodes in the configuration the first time it's called;
+// the test is skipped if they aren't configured or can't be reached
+func useLiveNodes(t *testing.T) {
+	liveNodes.Do(func() {
+		testConf, liveNodesErr = config.Load(config.FilePath())
+		if liveNodesErr != nil {
+			return
+		}
+		TokenApiRinkeby = tokenapi.MustNew(tokenapi.NewZRPC(testConf.RinkebyNode, "rinkeby test client"), tokenapi.WithConfig(testConf))
+		TokenApiMainnet = tokenapi.MustNew(tokenapi.NewZRPC(testConf.EthNode, "mainnet test client"), tokenapi.WithConfig(testConf))
+		lastBlockRinkeby, liveNodesErr = TokenApiRinkeby.GetRPCCli().EthBlockNumber()
+		if liveNodesErr != nil {
+			liveNodesErr = fmt.Errorf("err fetching last block on Rinkeby: %s", liveNodesErr)
+			return
+		}
+		lastBlockMainnet, liveNodesErr = TokenApiMainnet.GetRPCCli().EthBlockNumber()
+		if liveNodesErr != nil {
+			liveNodesErr = fmt.Errorf("err fetching last block on Mainnet: %s", liveNodesErr)
+			return
+		}
+		logs550, _ = TokenApiRinkeby.GetRPCCli().EthGetLogsByNumber(5690550, "0x494b4a86212fee251aa9019fe3cdb92a54d9efa1")
+		logs551, _ = TokenApiRinkeby.GetRPCCli().EthGetLogsByNumber(5690551, "0x494b4a86212fee251aa9019fe3cdb92a54d9efa1")
+		logs552, _ = TokenApiRinkeby.GetRPCCli().EthGetLogsByNumber(5690552, "0x494b4a86212fee251aa9019fe3cdb92a54d9efa1")
+	})
+	if liveNodesErr != nil {
+		t.Skipf("needs live nodes: %s", liveNodesErr)
 	}
 }
 
 func TestMatchContract1(t *testing.T) {
+	useLiveNodes(t)
 
 	// () -> address
 	tg, err := GetTriggerFromFile("../resources/triggers/wac1.json")
@@ -39,6 +63,7 @@ func TestMatchContract1(t *testing.T) {
 }
 
 func TestMatchContract2(t *testing.T) {
+	useLiveNodes(t)
 
 	// address -> uint256
 	tg, err := GetTriggerFromFile("../resources/triggers/wac2.json")
@@ -52,6 +77,7 @@ func TestMatchContract2(t *testing.T) {
 }
 
 func TestMatchContract3(t *testing.T) {
+	useLiveNodes(t)
 
 	// () -> bool
 	tg, err := GetTriggerFromFile("../resources/triggers/wac3.json")
@@ -65,6 +91,7 @@ func TestMatchContract3(t *testing.T) {
 }
 
 func TestMatchContract4(t *testing.T) {
+	useLiveNodes(t)
 
 	// uint256 -> address
 	tg, err := GetTriggerFromFile("../resources/triggers/wac4.json")
@@ -78,6 +105,7 @@ func TestMatchContract4(t *testing.T) {
 }
 
 func TestMatchContract5(t *testing.T) {
+	useLiveNodes(t)
 
 	// uint16 -> address
 	tg, err := GetTriggerFromFile("../resources/triggers/wac5.json")
@@ -91,6 +119,7 @@ func TestMatchContract5(t *testing.T) {
 }
 
 func TestMatchContract6(t *testing.T) {
+	useLiveNodes(t)
 
 	// () -> uint256[3]
 	tg, err := GetTriggerFromFile("../resources/triggers/wac6.json")
@@ -104,6 +133,7 @@ func TestMatchContract6(t *testing.T) {
 }
 
 func TestMatchContract7(t *testing.T) {
+	useLiveNodes(t)
 
 	// () -> (int128, int128, int128)
 	tg, err := GetTriggerFromFile("../resources/triggers/wac7.json")
@@ -117,6 +147,7 @@ func TestMatchContract7(t *testing.T) {
 }
 
 func TestMatchContract8(t *testing.T) {
+	useLiveNodes(t)
 
 	// () -> (int128, string, string)
 	tg, err := GetTriggerFromFile("../resources/triggers/wac8.json")
@@ -130,6 +161,7 @@ func TestMatchContract8(t *testing.T) {
 }
 
 func TestMatchContract9(t *testing.T) {
+	useLiveNodes(t)
 
 	// () -> string[3]
 	tg, err := GetTriggerFromFile("../resources/triggers/wac9.json")
@@ -143,6 +175,7 @@ func TestMatchContract9(t *testing.T) {
 }
 
 func TestMatchContract10(t *testing.T) {
+	useLiveNodes(t)
 
 	// () -> string[3]
 	tg, err := GetTriggerFromFile("../resources/triggers/wac10.json")
@@ -165,6 +198,7 @@ func TestMatchContract10(t *testing.T) {
 }
 
 func TestMatchContract11(t *testing.T) {
+	useLiveNodes(t)
 
 	// () -> string[3]
 	tg, err := GetTriggerFromFile("../resources/triggers/wac11.json")
@@ -176,6 +210,7 @@ func TestMatchContract11(t *testing.T) {
 }
 
 func TestMatchContract12(t *testing.T) {
+	useLiveNodes(t)
 
 	// int8 -> string
 	tg, err := GetTriggerFromFile("../resources/triggers/wac12.json")
@@ -189,6 +224,7 @@ func TestMatchContract12(t *testing.T) {
 }
 
 func TestMatchContract13(t *testing.T) {
+	useLiveNodes(t)
 
 	// int8[3] -> string
 	tg, err := GetTriggerFromFile("../resources/triggers/wac13.json")
@@ -202,6 +238,7 @@ func TestMatchContract13(t *testing.T) {
 }
 
 func TestMatchContract14(t *testing.T) {
+	useLiveNodes(t)
 
 	// int8[] -> string
 	tg, err := GetTriggerFromFile("../resources/triggers/wac14.json")
@@ -215,6 +252,7 @@ func TestMatchContract14(t *testing.T) {
 }
 
 func TestMatchContract15(t *testing.T) {
+	useLiveNodes(t)
 
 	// int8, int16[3], int32[] -> int256[3], bytes, int64
 	tg, err := GetTriggerFromFile("../resources/triggers/wac15.json")
@@ -238,6 +276,7 @@ func TestMatchContract15(t *testing.T) {
 }
 
 func TestMatchContract16(t *testing.T) {
+	useLiveNodes(t)
 
 	// address, address[3], address[] -> address, address[3], address[]
 	tg, err := GetTriggerFromFile("../resources/triggers/wac16.json")
@@ -259,6 +298,7 @@ func TestMatchContract16(t *testing.T) {
 }
 
 func TestMatchContract17(t *testing.T) {
+	useLiveNodes(t)
 
 	// bytes -> bytes
 	tg, err := GetTriggerFromFile("../resources/triggers/wac17.json")
@@ -272,6 +312,7 @@ func TestMatchContract17(t *testing.T) {
 }
 
 func TestMatchContract18(t *testing.T) {
+	useLiveNodes(t)
 
 	// bytes32 -> bytes32
 	tg, err := GetTriggerFromFile("../resources/triggers/wac18.json")
@@ -285,6 +326,7 @@ func TestMatchContract18(t *testing.T) {
 }
 
 func TestMatchContract19(t *testing.T) {
+	useLiveNodes(t)
 
 	// byte16 -> byte16
 	tg, err := GetTriggerFromFile("../resources/triggers/wac19.json")
@@ -323,7 +365,7 @@ func TestMatchContractTuple(t *testing.T) {
    "FunctionName":"getSpotPrice"
 }
 `
-	tg, err := NewTriggerFromJson(js)
+	tg, err := NewTriggerFromJson(js, nil)
 	assert.NoError(t, err)
 
 	match, err := MatchContract(mockTokenApi, tg, 1999999)
@@ -0,0 +1,95 @@
+package trigger
+
+import (
+	"encoding/hex"
+	"github.com/HAL-xyz/zoroaster/tokenapi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"strings"
+)
+
+// a contract created by a tx, either directly or by one of its internal calls
+type Deployment struct {
+	Address      string
+	Creator      string
+	BytecodeHash string // keccak256 of the runtime code
+	code         []byte
+}
+
+// deployment filters are checked against the deployed code, once we know the new contract's address
+func isDeploymentCondition(c Conditioner) bool {
+	switch c.(type) {
+	case ConditionCreator, ConditionBytecodeHash, ConditionContainsSelector, ConditionSupportsInterface:
+		return true
+	default:
+		return false
+	}
+}
+
+// WatchesDeployments tells if a WaT trigger only cares about contract creations
+func (tg Trigger) WatchesDeployments() bool {
+	for _, f := range tg.Filters {
+		if isDeploymentCondition(f.Condition) {
+			return true
+		}
+	}
+	return false
+}
+
+// SetCode stores the runtime code of the new contract, as returned by eth_getCode
+func (d *Deployment) SetCode(code string) {
+	d.code = common.FromHex(code)
+	d.BytecodeHash = crypto.Keccak256Hash(d.code).Hex()
+}
+
+// ValidateDeployment checks the deployment filters of a trigger against the code of the new contract;
+// contracts without code (i.e. reverted or self-destructed in the same tx) never match.
+func (tg Trigger) ValidateDeployment(d *Deployment, blockNo int, cli tokenapi.IEthRpc) bool {
+	if d == nil || len(d.code) == 0 {
+		return false
+	}
+	var selectors map[string]bool
+	for _, f := range tg.Filters {
+		switch v := f.Condition.(type) {
+		case ConditionBytecodeHash:
+			if strings.ToLower(v.Attribute) != d.BytecodeHash {
+				return false
+			}
+		case ConditionContainsSelector:
+			if selectors == nil {
+				selectors = codeSelectors(d.code)
+			}
+			if !selectors[normalizeSelector(v.Attribute)] {
+				return false
+			}
+		case ConditionSupportsInterface:
+			if !tokenapi.SupportsInterface(cli, d.Address, normalizeSelector(v.Attribute), blockNo) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// codeSelectors returns every 4-byte value pushed by the code,
+// which is how the function dispatcher compares the selector of the call.
+func codeSelectors(code []byte) map[string]bool {
+	const push1, push4, push32 = 0x60, 0x63, 0x7f
+	selectors := make(map[string]bool)
+	for i := 0; i < len(code); i++ {
+		op := code[i]
+		if op < push1 || op > push32 {
+			continue
+		}
+		size := int(op-push1) + 1
+		if op == push4 && i+size < len(code) {
+			selectors[hex.EncodeToString(code[i+1:i+1+size])] = true
+		}
+		i += size // skip the pushed data, which isn't code
+	}
+	return selectors
+}
+
+func normalizeSelector(s string) string {
+	return strings.TrimPrefix(strings.ToLower(s), "0x")
+}
@@ -0,0 +1,141 @@
+package trigger
+
+import (
+	"github.com/HAL-xyz/zoroaster/tokenapi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+// answers supportsInterface() for the given interface ids
+type mockERC165Cli struct {
+	tokenapi.IEthRpc
+	supported map[string]bool
+}
+
+func (cli mockERC165Cli) MakeEthRpcCall(cntAddress, data string, blockNumber int) (string, error) {
+	if cli.supported[data[10:18]] {
+		return "0x" + strings.Repeat("0", 63) + "1", nil
+	}
+	return "0x" + strings.Repeat("0", 64), nil
+}
+
+func TestCodeSelectors(t *testing.T) {
+	// PUSH4 a9059cbb EQ, then a PUSH32 whose data happens to contain PUSH4 deadbeef
+	code := "63a9059cbb14" + "7f" + "63deadbeef" + strings.Repeat("00", 27) + "6370a08231"
+	selectors := codeSelectors(common.FromHex(code))
+	assert.True(t, selectors["a9059cbb"])
+	assert.True(t, selectors["70a08231"])
+	assert.False(t, selectors["deadbeef"])
+
+	// truncated push
+	assert.Len(t, codeSelectors(common.FromHex("63a905")), 0)
+}
+
+func TestValidateDeployment(t *testing.T) {
+	js := `
+{
+  "TriggerName": "new ERC-721 by a watched deployer",
+  "TriggerType": "WatchTransactions",
+  "Filters": [
+    {
+      "FilterType": "DeploymentFilter",
+      "ParameterName": "Creator",
+      "Condition": {"Predicate": "Eq", "Attribute": "0x0216d5032f356960cd3749c31ab34eeff21b3395"}
+    },
+    {
+      "FilterType": "DeploymentFilter",
+      "ParameterName": "ContainsSelector",
+      "Condition": {"Predicate": "Eq", "Attribute": "0x23b872dd"}
+    },
+    {
+      "FilterType": "DeploymentFilter",
+      "ParameterName": "SupportsInterface",
+      "Condition": {"Predicate": "Eq", "Attribute": "0x80ac58cd"}
+    }
+  ]
+}`
+	tg, err := NewTriggerFromJson(js, nil)
+	assert.NoError(t, err)
+	assert.True(t, tg.WatchesDeployments())
+	assert.True(t, tg.NeedsReceipt())
+
+	erc721 := mockERC165Cli{supported: map[string]bool{"01ffc9a7": true, "80ac58cd": true}}
+	erc20 := mockERC165Cli{supported: map[string]bool{}}
+
+	d := &Deployment{Address: "0x5fbdb2315678afecb367f032d93f642f64180aa3", Creator: "0x0216d5032f356960cd3749c31ab34eeff21b3395"}
+	d.SetCode("0x6080604052" + "6323b872dd14")
+	assert.Equal(t, "0x", d.BytecodeHash[:2])
+	assert.Len(t, d.BytecodeHash, 66)
+	assert.True(t, tg.ValidateDeployment(d, 12965000, erc721))
+	assert.False(t, tg.ValidateDeployment(d, 12965000, erc20))
+
+	// contracts that claim to support everything don't follow ERC-165
+	liar := mockERC165Cli{supported: map[string]bool{"01ffc9a7": true, "80ac58cd": true, "ffffffff": true}}
+	assert.False(t, tg.ValidateDeployment(d, 12965000, liar))
+
+	// selector not in the code
+	d.SetCode("0x6080604052")
+	assert.False(t, tg.ValidateDeployment(d, 12965000, erc721))
+
+	// no code, no contract
+	d.SetCode("0x")
+	assert.False(t, tg.ValidateDeployment(d, 12965000, erc721))
+
+	// bytecode hash
+	d.SetCode("0x6080604052")
+	hashTg, err := NewTriggerFromJson(strings.Replace(js, `"ContainsSelector",
+      "Condition": {"Predicate": "Eq", "Attribute": "0x23b872dd"}`, `"BytecodeHash",
+      "Condition": {"Predicate": "Eq", "Attribute": "`+d.BytecodeHash+`"}`, 1), nil)
+	assert.NoError(t, err)
+	assert.True(t, hashTg.ValidateDeployment(d, 12965000, erc721))
+
+	// deployment filters are for WaT only
+	_, err = NewTriggerFromJson(strings.Replace(js, `"WatchTransactions"`, `"WatchEvents"`, 1), nil)
+	assert.Error(t, err)
+	_, err = NewTriggerFromJson(strings.Replace(js, `"0x80ac58cd"`, `"0x80ac58"`, 1), nil)
+	assert.Error(t, err)
+}
+
+func TestMatchDeployments(t *testing.T) {
+	block, _ := GetBlockFromFile("../resources/blocks/block1.json")
+	factory := "0x5c69bee701ef814a2b6a3edd4b1652cb9cc5aa6f"
+
+	tg, err := NewTriggerFromJson(`
+{
+  "TriggerName": "pairs created by the factory",
+  "TriggerType": "WatchTransactions",
+  "IncludeInternalTxs": true,
+  "Filters": [
+    {
+      "FilterType": "DeploymentFilter",
+      "ParameterName": "Creator",
+      "Condition": {"Predicate": "Eq", "Attribute": "`+factory+`"}
+    }
+  ]
+}`, nil)
+	assert.NoError(t, err)
+
+	traces := make([]tokenapi.CallFrame, len(block.Transactions))
+	traces[3] = tokenapi.CallFrame{
+		Type: "CALL",
+		From: block.Transactions[3].From,
+		To:   factory,
+		Calls: []tokenapi.CallFrame{
+			{Type: "CREATE2", From: factory, To: "0xB4e16d0168e52d35CaCD2c6185b44281Ec28C9Dc", Value: "0x0", Gas: "0x1e8480", Input: "0x6080"},
+			{Type: "CALL", From: factory, To: "0xb4e16d0168e52d35cacd2c6185b44281ec28c9dc", Value: "0x0", Gas: "0x8fc", Input: "0x485cc955"},
+		},
+	}
+
+	// the factory is no top-level creator
+	assert.Len(t, MatchTransaction(tg, block, mockTokenApi), 0)
+
+	matches := MatchInternalTransactions(tg, block, traces, mockTokenApi)
+	assert.Len(t, matches, 1)
+	assert.Equal(t, "", matches[0].Tx.To)
+	assert.Equal(t, "0xb4e16d0168e52d35cacd2c6185b44281ec28c9dc", matches[0].Deployment.Address)
+	assert.Equal(t, factory, matches[0].Deployment.Creator)
+	assert.Equal(t, matches[0].Deployment, matches[0].ToTemplateMatch().Tx.Deployment)
+	assert.Equal(t, matches[0].Deployment, matches[0].ToPersistent().(*PersistentTxMatch).PTx.Deployment)
+}
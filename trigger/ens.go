@@ -0,0 +1,82 @@
+package trigger
+
+import (
+	"fmt"
+	"github.com/HAL-xyz/zoroaster/tokenapi"
+	"github.com/HAL-xyz/zoroaster/utils"
+)
+
+// An ENSError means a trigger uses an ENS name that couldn't be resolved; unlike the other
+// errors it might go away by itself, e.g. once the node can be reached again.
+type ENSError struct {
+	Name string
+	Err  error
+}
+
+func (e *ENSError) Error() string {
+	return fmt.Sprintf("cannot resolve ENS name %s: %s", e.Name, e.Err)
+}
+
+// IsENSError tells if a trigger couldn't be created because of an ENSError
+func IsENSError(err error) bool {
+	if e, ok := err.(*triggerCreationError); ok {
+		err = e.err
+	}
+	_, ok := err.(*ENSError)
+	return ok
+}
+
+// resolveENSNames replaces the ENS names used in place of addresses (e.g. vitalik.eth) with
+// the addresses they resolve to, and returns the names. Cached triggers using ENS names are
+// reloaded as often as names are resolved again (see db.TriggerCache), so they follow the
+// names' records as they change.
+func (tjs *TriggerJson) resolveENSNames(resolve func(name string) (string, error)) ([]string, error) {
+	var names []string
+	var err error
+	resolveName := func(s *string) {
+		if err != nil || !tokenapi.IsENSName(*s) {
+			return
+		}
+		address, resolveErr := resolve(*s)
+		if resolveErr != nil {
+			err = &ENSError{Name: *s, Err: resolveErr}
+			return
+		}
+		if !utils.IsIn(*s, names) {
+			names = append(names, *s)
+		}
+		*s = address
+	}
+
+	for i, fjs := range tjs.Filters {
+		if isAddressFilter(fjs) {
+			resolveName(&tjs.Filters[i].Condition.Attribute)
+		}
+	}
+	for i, inputJs := range tjs.Inputs {
+		if inputJs.ParameterType == "address" {
+			resolveName(&tjs.Inputs[i].ParameterValue)
+		}
+	}
+	for i, outputJs := range tjs.Outputs {
+		if outputJs.ReturnType == "address" || outputJs.Component.Type == "address" {
+			resolveName(&tjs.Outputs[i].Condition.Attribute)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return names, nil
+}
+
+func isAddressFilter(fjs FilterJson) bool {
+	switch fjs.FilterType {
+	case "BasicFilter":
+		return fjs.ParameterName == "From" || fjs.ParameterName == "To"
+	case "DeploymentFilter":
+		return fjs.ParameterName == "Creator"
+	case "CheckFunctionParameter", "CheckEventParameter":
+		return fjs.ParameterType == "address"
+	}
+	return false
+}
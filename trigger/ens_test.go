@@ -0,0 +1,66 @@
+package trigger
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func mockResolveENS(name string) (string, error) {
+	if name == "vitalik.eth" {
+		return "0xd8da6bf26964af9d7eed9e03e53415d37aa96045", nil
+	}
+	return "", fmt.Errorf("ENS name %s doesn't resolve to any address", name)
+}
+
+func TestResolveENSNames(t *testing.T) {
+	js := `
+{
+  "TriggerName": "transfers to vitalik.eth",
+  "TriggerType": "WatchTransactions",
+  "ContractAdd": "0x6b175474e89094c44da98b954eedeac495271d0f",
+  "Filters": [
+    {
+      "FilterType": "BasicFilter",
+      "ParameterName": "From",
+      "Condition": {"Predicate": "Eq", "Attribute": "vitalik.eth"}
+    },
+    {
+      "FilterType": "CheckFunctionParameter",
+      "FunctionName": "transfer",
+      "ParameterName": "dst",
+      "ParameterType": "address",
+      "Condition": {"Predicate": "Eq", "Attribute": "vitalik.eth"}
+    },
+    {
+      "FilterType": "CheckFunctionParameter",
+      "FunctionName": "transfer",
+      "ParameterName": "memo",
+      "ParameterType": "string",
+      "Condition": {"Predicate": "Eq", "Attribute": "vitalik.eth"}
+    }
+  ],
+  "Inputs": [
+    {"ParameterType": "address", "ParameterValue": "vitalik.eth"}
+  ]
+}`
+	tjs, err := NewTriggerJson(js)
+	assert.NoError(t, err)
+
+	names, err := tjs.resolveENSNames(mockResolveENS)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"vitalik.eth"}, names)
+	assert.Equal(t, "0xd8da6bf26964af9d7eed9e03e53415d37aa96045", tjs.Filters[0].Condition.Attribute)
+	assert.Equal(t, "0xd8da6bf26964af9d7eed9e03e53415d37aa96045", tjs.Filters[1].Condition.Attribute)
+	assert.Equal(t, "vitalik.eth", tjs.Filters[2].Condition.Attribute)
+	assert.Equal(t, "0xd8da6bf26964af9d7eed9e03e53415d37aa96045", tjs.Inputs[0].ParameterValue)
+
+	// names that don't resolve are an error, rather than a trigger that never matches
+	tjs.Filters[0].Condition.Attribute = "nobody.eth"
+	_, err = tjs.resolveENSNames(mockResolveENS)
+	assert.Error(t, err)
+
+	// and so are names that can't be resolved at all
+	_, err = NewTriggerFromJson(js, nil)
+	assert.True(t, IsENSError(err))
+}
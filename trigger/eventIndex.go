@@ -0,0 +1,93 @@
+package trigger
+
+import (
+	"github.com/HAL-xyz/ethrpc"
+	"github.com/HAL-xyz/zoroaster/tokenapi"
+	"github.com/HAL-xyz/zoroaster/utils"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/sirupsen/logrus"
+)
+
+// An EventIndex finds the WaE triggers a log is relevant for, without going through all of them:
+// triggers are grouped by contract address and event signature (topic0).
+// Triggers watching all_erc20_tokens, all_erc721_collections or all_erc1155_collections
+// go in a wildcard bucket, and are checked against any contract of the right kind.
+type EventIndex struct {
+	byAddress map[eventKey][]*indexedTrigger
+	wildcards map[string]map[string][]*indexedTrigger // keyword -> topic0 -> triggers
+	size      int
+}
+
+type eventKey struct {
+	address string
+	topic0  string
+}
+
+// the ABI and the event signature are computed only once, when the index is built
+type indexedTrigger struct {
+	tg     *Trigger
+	abiObj abi.ABI
+}
+
+var wildcardAddresses = []string{"all_erc20_tokens", "all_erc721_collections", "all_erc1155_collections"}
+
+// NewEventIndex indexes the triggers; the ones without a valid ABI or event are left out
+func NewEventIndex(tgs []*Trigger) *EventIndex {
+	idx := &EventIndex{
+		byAddress: map[eventKey][]*indexedTrigger{},
+		wildcards: map[string]map[string][]*indexedTrigger{},
+	}
+	for _, tg := range tgs {
+		abiObj, eventSignature, err := tg.eventTopic()
+		if err != nil {
+			logrus.Debugf("trigger %s: %s", tg.TriggerUUID, err)
+			continue
+		}
+		it := &indexedTrigger{tg: tg, abiObj: abiObj}
+		if utils.IsIn(tg.ContractAdd, wildcardAddresses) {
+			if idx.wildcards[tg.ContractAdd] == nil {
+				idx.wildcards[tg.ContractAdd] = map[string][]*indexedTrigger{}
+			}
+			idx.wildcards[tg.ContractAdd][eventSignature] = append(idx.wildcards[tg.ContractAdd][eventSignature], it)
+		} else {
+			key := eventKey{address: utils.NormalizeAddress(tg.ContractAdd), topic0: eventSignature}
+			idx.byAddress[key] = append(idx.byAddress[key], it)
+		}
+		idx.size++
+	}
+	return idx
+}
+
+// Len is the number of triggers in the index
+func (idx *EventIndex) Len() int {
+	return idx.size
+}
+
+// MatchEvents matches every log against the relevant triggers only;
+// it returns the same matches MatchEvent would, ordered by log.
+func (idx *EventIndex) MatchEvents(logs []ethrpc.Log, txs []tokenapi.Transaction, tokenApi tokenapi.ITokenAPI) []*EventMatch {
+	var eventMatches []*EventMatch
+	for i := range logs {
+		if len(logs[i].Topics) == 0 {
+			continue
+		}
+		for _, it := range idx.relevantTriggers(&logs[i], tokenApi) {
+			eventMatches = append(eventMatches, matchEventLog(it.tg, &it.abiObj, &logs[i], txs, tokenApi)...)
+		}
+	}
+	return eventMatches
+}
+
+func (idx *EventIndex) relevantTriggers(evLog *ethrpc.Log, tokenApi tokenapi.ITokenAPI) []*indexedTrigger {
+	topic0 := evLog.Topics[0]
+	relevant := idx.byAddress[eventKey{address: utils.NormalizeAddress(evLog.Address), topic0: topic0}]
+
+	// the contract is only looked up if someone is watching this event on every contract
+	for _, keyword := range wildcardAddresses {
+		its := idx.wildcards[keyword][topic0]
+		if len(its) > 0 && isRelevantLog(evLog, keyword, tokenApi) {
+			relevant = append(relevant[:len(relevant):len(relevant)], its...)
+		}
+	}
+	return relevant
+}
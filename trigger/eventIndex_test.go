@@ -0,0 +1,72 @@
+package trigger
+
+import (
+	"github.com/HAL-xyz/zoroaster/tokenapi"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+// USDT is the only ERC20 token we know
+type mockTApiERC20 struct {
+	tokenapi.ITokenAPI
+}
+
+func (t mockTApiERC20) GetAllERC20TokensMap() map[string]tokenapi.ERC20Token {
+	return map[string]tokenapi.ERC20Token{
+		"0xdac17f958d2ee523a2206206994597c13d831ec7": {Symbol: "USDT", Decimals: 6},
+	}
+}
+
+func TestEventIndex(t *testing.T) {
+
+	logs, err := GetLogsFromFile("../resources/events/logs1.json")
+	assert.NoError(t, err)
+
+	tg1, err := GetTriggerFromFile("../resources/triggers/ev1.json")
+	assert.NoError(t, err)
+	tg2, err := GetTriggerFromFile("../resources/triggers/ev2.json")
+	assert.NoError(t, err)
+
+	// same as tg2, but on every token
+	allTokens, err := GetTriggerFromFile("../resources/triggers/ev2.json")
+	assert.NoError(t, err)
+	allTokens.ContractAdd = "all_erc20_tokens"
+
+	// a different contract, and no event at all
+	elsewhere, err := GetTriggerFromFile("../resources/triggers/ev2.json")
+	assert.NoError(t, err)
+	elsewhere.ContractAdd = "0x6b175474e89094c44da98b954eedeac495271d0f"
+	noEvent, err := GetTriggerFromFile("../resources/triggers/ev2.json")
+	assert.NoError(t, err)
+	noEvent.Filters = nil
+
+	// the trigger address isn't case sensitive
+	tg1.ContractAdd = "0x" + strings.ToUpper(tg1.ContractAdd[2:])
+
+	tgs := []*Trigger{tg1, tg2, allTokens, elsewhere, noEvent}
+	idx := NewEventIndex(tgs)
+	assert.Equal(t, 4, idx.Len())
+
+	// the index finds the same matches as going through every trigger
+	api := mockTApiERC20{mockTokenApi}
+	var expected []*EventMatch
+	for _, tg := range tgs {
+		expected = append(expected, MatchEvent(tg, logs, []tokenapi.Transaction{}, api)...)
+	}
+	matches := idx.MatchEvents(logs, []tokenapi.Transaction{}, api)
+	assert.Len(t, expected, 7)
+	assert.ElementsMatch(t, expected, matches)
+
+	// matches are ordered by log
+	for i := 1; i < len(matches); i++ {
+		assert.True(t, matches[i-1].Log.LogIndex <= matches[i].Log.LogIndex)
+	}
+
+	// without USDT in the token list, the wildcard trigger doesn't match
+	matches = idx.MatchEvents(logs, []tokenapi.Transaction{}, mockTApiNFT{mockTokenApi})
+	assert.Len(t, matches, 4)
+	for _, m := range matches {
+		assert.NotEqual(t, "all_erc20_tokens", m.Tg.ContractAdd)
+	}
+}
@@ -13,47 +13,72 @@ import (
 	"strings"
 )
 
-func MatchEvent(tg *Trigger, logs []ethrpc.Log, txs []ethrpc.Transaction, tokenApi tokenapi.ITokenAPI) []*EventMatch {
-	if tg.eventName() == "" {
-		logrus.Debug("no valid Event Name found in trigger ", tg.TriggerUUID)
+func MatchEvent(tg *Trigger, logs []ethrpc.Log, txs []tokenapi.Transaction, tokenApi tokenapi.ITokenAPI) []*EventMatch {
+	abiObj, eventSignature, err := tg.eventTopic()
+	if err != nil {
+		logrus.Debugf("trigger %s: %s", tg.TriggerUUID, err)
 		return []*EventMatch{}
 	}
 
+	var eventMatches []*EventMatch
+	for i := range logs {
+		// only logs of the event we're looking for can match
+		if len(logs[i].Topics) == 0 || logs[i].Topics[0] != eventSignature {
+			continue
+		}
+		if !isRelevantLog(&logs[i], tg.ContractAdd, tokenApi) {
+			continue
+		}
+		eventMatches = append(eventMatches, matchEventLog(tg, &abiObj, &logs[i], txs, tokenApi)...)
+	}
+	return eventMatches
+}
+
+// eventTopic returns the trigger's ABI and the signature of the event it's watching, i.e. the logs' topic0
+func (tg *Trigger) eventTopic() (abi.ABI, string, error) {
+	if tg.eventName() == "" {
+		return abi.ABI{}, "", fmt.Errorf("no valid Event Name found")
+	}
 	// loading ABIs is expensive, so we want to do it as little as possible
 	abiObj, err := tg.getABIObj()
 	if err != nil {
-		logrus.Debug(err)
-		return []*EventMatch{}
+		return abi.ABI{}, "", err
+	}
+	eventSignature, err := getEventSignature(abiObj, tg.eventName())
+	if err != nil {
+		return abi.ABI{}, "", err
 	}
+	return abiObj, eventSignature, nil
+}
 
+// matchEventLog matches a log that's already known to be relevant for the trigger
+func matchEventLog(tg *Trigger, abiObj *abi.ABI, evLog *ethrpc.Log, txs []tokenapi.Transaction, tokenApi tokenapi.ITokenAPI) []*EventMatch {
 	var eventMatches []*EventMatch
-	for i, log := range logs {
-		if !isRelevantLog(log.Address, tg.ContractAdd, tokenApi) {
+	for _, log := range expandLog(evLog, tg, abiObj) {
+		if !validateTriggerLog(log, tg, tokenApi, *abiObj) && !validateEmittedEvent(log, tg, *abiObj) {
+			continue
+		}
+		tx := getTxByHash(log.TransactionHash, txs)
+		if !validateBasicFiltersForEvent(tg, &tx) {
 			continue
 		}
-		if validateTriggerLog(&log, tg, tokenApi, abiObj) || validateEmittedEvent(&log, tg, abiObj) {
-			tx := getTxByHash(log.TransactionHash, txs)
-			if !validateBasicFiltersForEvent(tg, &tx) {
-				continue
-			}
 
-			// make a new EventMatch
-			decodedData, _ := decodeDataField(log.Data, tg.eventName(), &abiObj)
-			topicsMap := getTopicsMap(&abiObj, tg.eventName(), &log)
-			ev := EventMatch{
-				Tg:          tg,
-				Log:         &logs[i],
-				EventParams: makeEventParams(decodedData, topicsMap),
-				TxTo:        tx.To,
-				TxFrom:      tx.From,
-			}
-			eventMatches = append(eventMatches, &ev)
+		// make a new EventMatch
+		decodedData, _ := decodeDataField(log.Data, tg.eventName(), abiObj)
+		topicsMap := getTopicsMap(abiObj, tg.eventName(), log)
+		ev := EventMatch{
+			Tg:          tg,
+			Log:         log,
+			EventParams: makeEventParams(decodedData, topicsMap),
+			TxTo:        tx.To,
+			TxFrom:      tx.From,
 		}
+		eventMatches = append(eventMatches, &ev)
 	}
 	return eventMatches
 }
 
-func validateBasicFiltersForEvent(tg *Trigger, tx *ethrpc.Transaction) bool {
+func validateBasicFiltersForEvent(tg *Trigger, tx *tokenapi.Transaction) bool {
 	if !tg.hasBasicFilters() {
 		return true
 	}
@@ -174,7 +199,7 @@ func getTopicsMap(abiObj *abi.ABI, eventName string, evLog *ethrpc.Log) map[stri
 
 	var i = 1 // topic_name_0 is the event signature so we start from 1
 	for _, input := range myEvent.Inputs {
-		if input.Indexed {
+		if input.Indexed && i < len(evLog.Topics) {
 			if intRgx.MatchString(input.Type.String()) {
 				finalMap[input.Name] = utils.MakeBigIntFromHex(evLog.Topics[i]).String()
 			} else {
@@ -206,6 +231,7 @@ func decodeDataField(rawData, eventName string, abiObj *abi.ABI) (map[string]int
 	if err != nil {
 		return nil, err
 	}
+	addSingleTransferParams(getMap, abiObj.Events[eventName])
 	return getMap, nil
 }
 
@@ -221,8 +247,8 @@ func makeEventParams(data map[string]interface{}, topics map[string]string) map[
 	return paramsMap
 }
 
-func getTxByHash(hash string, txs []ethrpc.Transaction) ethrpc.Transaction {
-	tx := ethrpc.Transaction{}
+func getTxByHash(hash string, txs []tokenapi.Transaction) tokenapi.Transaction {
+	tx := tokenapi.Transaction{}
 	for _, t := range txs {
 		if t.Hash == hash {
 			tx = t
@@ -232,15 +258,23 @@ func getTxByHash(hash string, txs []ethrpc.Transaction) ethrpc.Transaction {
 	return tx
 }
 
-// isRelevantLog decides if the inspected logAdd is to be considered a match, given the tgAdd.
+// isRelevantLog decides if the inspected log is to be considered a match, given the tgAdd.
 // there are two cases:
 // 1 - there is an exact match; in this case, it is obviously relevant
-// 2 - the tgAdd is set to the keyword `all_erc20_tokens`; in this case, the inspected logAdd will be considered
-// relevant as long as it is the address of any erc20 token we know.
-func isRelevantLog(logAdd, tgAdd string, api tokenapi.ITokenAPI) bool {
-	if tgAdd == "all_erc20_tokens" {
+// 2 - the tgAdd is set to one of the keywords `all_erc20_tokens`, `all_erc721_collections` or
+// `all_erc1155_collections`; in this case, the inspected logAdd will be considered relevant as long as
+// it is the address of any erc20 token we know, or of a contract implementing the given NFT standard.
+func isRelevantLog(evLog *ethrpc.Log, tgAdd string, api tokenapi.ITokenAPI) bool {
+	logAdd := evLog.Address
+	switch tgAdd {
+	case "all_erc20_tokens":
 		_, ok := api.GetAllERC20TokensMap()[utils.NormalizeAddress(logAdd)]
 		return ok
+	case "all_erc721_collections":
+		// the topics are enough to rule out ERC-20 tokens
+		return !isERC20Log(evLog) && api.IsERC721(logAdd)
+	case "all_erc1155_collections":
+		return api.IsERC1155(logAdd)
 	}
 	return utils.NormalizeAddress(logAdd) == utils.NormalizeAddress(tgAdd)
 }
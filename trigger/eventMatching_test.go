@@ -2,7 +2,6 @@ package trigger
 
 import (
 	"github.com/HAL-xyz/ethrpc"
-	"github.com/HAL-xyz/zoroaster/config"
 	"github.com/HAL-xyz/zoroaster/tokenapi"
 	"github.com/HAL-xyz/zoroaster/utils"
 	"github.com/stretchr/testify/assert"
@@ -19,7 +18,7 @@ func (cli mockETHCli) MakeEthRpcCall(cntAddress, data string, blockNumber int) (
 
 var mockCli mockETHCli
 
-var mockTokenApi = tokenapi.New(mockCli)
+var mockTokenApi = tokenapi.MustNew(mockCli)
 
 type mockTApiCurrency struct {
 	tokenapi.ITokenAPI
@@ -43,9 +42,8 @@ func (t mockTApiCurrency) GetRPCCli() tokenapi.IEthRpc {
 
 var mockTApiCurr mockTApiCurrency
 
-var logs550, _ = TokenApiRinkeby.GetRPCCli().EthGetLogsByNumber(5690550, "0x494b4a86212fee251aa9019fe3cdb92a54d9efa1")
-var logs551, _ = TokenApiRinkeby.GetRPCCli().EthGetLogsByNumber(5690551, "0x494b4a86212fee251aa9019fe3cdb92a54d9efa1")
-var logs552, _ = TokenApiRinkeby.GetRPCCli().EthGetLogsByNumber(5690552, "0x494b4a86212fee251aa9019fe3cdb92a54d9efa1")
+// fetched from Rinkeby by useLiveNodes
+var logs550, logs551, logs552 []ethrpc.Log
 
 func TestValidateFilterLog(t *testing.T) {
 
@@ -74,6 +72,7 @@ func TestValidateFilterLog(t *testing.T) {
 
 // https://rinkeby.etherscan.io/tx/0x1e5aebb232ae66459d6c6144e6bfe8269362db01ae47a7a8f89b4df6feff8271
 func TestAddressFixedArrayEqAtPosition0(t *testing.T) {
+	useLiveNodes(t)
 	js := `{
         "Filters": [
             {
@@ -94,10 +93,10 @@ func TestAddressFixedArrayEqAtPosition0(t *testing.T) {
         "TriggerType": "WatchEvents"
     }`
 
-	tg, err := NewTriggerFromJson(js)
+	tg, err := NewTriggerFromJson(js, nil)
 	assert.NoError(t, err)
 
-	matches := MatchEvent(tg, logs550, []ethrpc.Transaction{}, mockTokenApi)
+	matches := MatchEvent(tg, logs550, []tokenapi.Transaction{}, mockTokenApi)
 
 	assert.Equal(t, 1, len(matches))
 	assert.Equal(t, 5690550, matches[0].Log.BlockNumber)
@@ -105,6 +104,7 @@ func TestAddressFixedArrayEqAtPosition0(t *testing.T) {
 
 // https://rinkeby.etherscan.io/tx/0x1e5aebb232ae66459d6c6144e6bfe8269362db01ae47a7a8f89b4df6feff8271
 func TestAddressFixedArrayIsIn(t *testing.T) {
+	useLiveNodes(t)
 	js := `{
         "Filters": [
             {
@@ -124,10 +124,10 @@ func TestAddressFixedArrayIsIn(t *testing.T) {
         "TriggerType": "WatchEvents"
     }`
 
-	tg, err := NewTriggerFromJson(js)
+	tg, err := NewTriggerFromJson(js, nil)
 	assert.NoError(t, err)
 
-	matches := MatchEvent(tg, logs550, []ethrpc.Transaction{}, mockTokenApi)
+	matches := MatchEvent(tg, logs550, []tokenapi.Transaction{}, mockTokenApi)
 
 	assert.Equal(t, 1, len(matches))
 	assert.Equal(t, 5690550, matches[0].Log.BlockNumber)
@@ -135,6 +135,7 @@ func TestAddressFixedArrayIsIn(t *testing.T) {
 
 // https://rinkeby.etherscan.io/tx/0x1e5aebb232ae66459d6c6144e6bfe8269362db01ae47a7a8f89b4df6feff8271
 func TestAddressFixedArrayLengthInBetween(t *testing.T) {
+	useLiveNodes(t)
 	js := `{
         "Filters": [
             {
@@ -158,10 +159,10 @@ func TestAddressFixedArrayLengthInBetween(t *testing.T) {
         "TriggerType": "WatchEvents"
     }`
 
-	tg, err := NewTriggerFromJson(js)
+	tg, err := NewTriggerFromJson(js, nil)
 	assert.NoError(t, err)
 
-	matches := MatchEvent(tg, logs550, []ethrpc.Transaction{}, mockTokenApi)
+	matches := MatchEvent(tg, logs550, []tokenapi.Transaction{}, mockTokenApi)
 
 	assert.Equal(t, 1, len(matches))
 	assert.Equal(t, 5690550, matches[0].Log.BlockNumber)
@@ -169,6 +170,7 @@ func TestAddressFixedArrayLengthInBetween(t *testing.T) {
 
 // https://rinkeby.etherscan.io/tx/0x1e5aebb232ae66459d6c6144e6bfe8269362db01ae47a7a8f89b4df6feff8271
 func TestAddressFixedArrayLengthSmallerThan(t *testing.T) {
+	useLiveNodes(t)
 	js := `{
         "Filters": [
             {
@@ -188,10 +190,10 @@ func TestAddressFixedArrayLengthSmallerThan(t *testing.T) {
         "TriggerType": "WatchEvents"
     }`
 
-	tg, err := NewTriggerFromJson(js)
+	tg, err := NewTriggerFromJson(js, nil)
 	assert.NoError(t, err)
 
-	matches := MatchEvent(tg, logs550, []ethrpc.Transaction{}, mockTokenApi)
+	matches := MatchEvent(tg, logs550, []tokenapi.Transaction{}, mockTokenApi)
 
 	assert.Equal(t, 1, len(matches))
 	assert.Equal(t, 5690550, matches[0].Log.BlockNumber)
@@ -199,6 +201,7 @@ func TestAddressFixedArrayLengthSmallerThan(t *testing.T) {
 
 // https://rinkeby.etherscan.io/tx/0x1e5aebb232ae66459d6c6144e6bfe8269362db01ae47a7a8f89b4df6feff8271
 func TestAddressFixedArrayLengthBiggerThan(t *testing.T) {
+	useLiveNodes(t)
 	js := `{
         "Filters": [
             {
@@ -218,10 +221,10 @@ func TestAddressFixedArrayLengthBiggerThan(t *testing.T) {
         "TriggerType": "WatchEvents"
     }`
 
-	tg, err := NewTriggerFromJson(js)
+	tg, err := NewTriggerFromJson(js, nil)
 	assert.NoError(t, err)
 
-	matches := MatchEvent(tg, logs550, []ethrpc.Transaction{}, mockTokenApi)
+	matches := MatchEvent(tg, logs550, []tokenapi.Transaction{}, mockTokenApi)
 
 	assert.Equal(t, 1, len(matches))
 	assert.Equal(t, 5690550, matches[0].Log.BlockNumber)
@@ -229,6 +232,7 @@ func TestAddressFixedArrayLengthBiggerThan(t *testing.T) {
 
 // https://rinkeby.etherscan.io/tx/0x1e5aebb232ae66459d6c6144e6bfe8269362db01ae47a7a8f89b4df6feff8271
 func TestAddressFixedArrayLengthEq(t *testing.T) {
+	useLiveNodes(t)
 	js := `{
         "Filters": [
             {
@@ -248,10 +252,10 @@ func TestAddressFixedArrayLengthEq(t *testing.T) {
         "TriggerType": "WatchEvents"
     }`
 
-	tg, err := NewTriggerFromJson(js)
+	tg, err := NewTriggerFromJson(js, nil)
 	assert.NoError(t, err)
 
-	matches := MatchEvent(tg, logs550, []ethrpc.Transaction{}, mockTokenApi)
+	matches := MatchEvent(tg, logs550, []tokenapi.Transaction{}, mockTokenApi)
 
 	assert.Equal(t, 1, len(matches))
 	assert.Equal(t, 5690550, matches[0].Log.BlockNumber)
@@ -259,6 +263,7 @@ func TestAddressFixedArrayLengthEq(t *testing.T) {
 
 // https://rinkeby.etherscan.io/tx/0x1e5aebb232ae66459d6c6144e6bfe8269362db01ae47a7a8f89b4df6feff8271
 func TestAddressDynamicArrayEqAtPosition0(t *testing.T) {
+	useLiveNodes(t)
 	js := `{
         "Filters": [
             {
@@ -279,10 +284,10 @@ func TestAddressDynamicArrayEqAtPosition0(t *testing.T) {
         "TriggerType": "WatchEvents"
     }`
 
-	tg, err := NewTriggerFromJson(js)
+	tg, err := NewTriggerFromJson(js, nil)
 	assert.NoError(t, err)
 
-	matches := MatchEvent(tg, logs550, []ethrpc.Transaction{}, mockTokenApi)
+	matches := MatchEvent(tg, logs550, []tokenapi.Transaction{}, mockTokenApi)
 
 	assert.Equal(t, 1, len(matches))
 	assert.Equal(t, 5690550, matches[0].Log.BlockNumber)
@@ -290,6 +295,7 @@ func TestAddressDynamicArrayEqAtPosition0(t *testing.T) {
 
 // https://rinkeby.etherscan.io/tx/0x1e5aebb232ae66459d6c6144e6bfe8269362db01ae47a7a8f89b4df6feff8271
 func TestAddressDynamicArrayIsIn(t *testing.T) {
+	useLiveNodes(t)
 	js := `{
         "Filters": [
             {
@@ -309,10 +315,10 @@ func TestAddressDynamicArrayIsIn(t *testing.T) {
         "TriggerType": "WatchEvents"
     }`
 
-	tg, err := NewTriggerFromJson(js)
+	tg, err := NewTriggerFromJson(js, nil)
 	assert.NoError(t, err)
 
-	matches := MatchEvent(tg, logs550, []ethrpc.Transaction{}, mockTokenApi)
+	matches := MatchEvent(tg, logs550, []tokenapi.Transaction{}, mockTokenApi)
 
 	assert.Equal(t, 1, len(matches))
 	assert.Equal(t, 5690550, matches[0].Log.BlockNumber)
@@ -320,6 +326,7 @@ func TestAddressDynamicArrayIsIn(t *testing.T) {
 
 // https://rinkeby.etherscan.io/tx/0x1e5aebb232ae66459d6c6144e6bfe8269362db01ae47a7a8f89b4df6feff8271
 func TestAddressDynamicArrayLengthInBetween(t *testing.T) {
+	useLiveNodes(t)
 	js := `{
         "Filters": [
             {
@@ -343,10 +350,10 @@ func TestAddressDynamicArrayLengthInBetween(t *testing.T) {
         "TriggerType": "WatchEvents"
     }`
 
-	tg, err := NewTriggerFromJson(js)
+	tg, err := NewTriggerFromJson(js, nil)
 	assert.NoError(t, err)
 
-	matches := MatchEvent(tg, logs550, []ethrpc.Transaction{}, mockTokenApi)
+	matches := MatchEvent(tg, logs550, []tokenapi.Transaction{}, mockTokenApi)
 
 	assert.Equal(t, 1, len(matches))
 	assert.Equal(t, 5690550, matches[0].Log.BlockNumber)
@@ -354,6 +361,7 @@ func TestAddressDynamicArrayLengthInBetween(t *testing.T) {
 
 // https://rinkeby.etherscan.io/tx/0x1e5aebb232ae66459d6c6144e6bfe8269362db01ae47a7a8f89b4df6feff8271
 func TestAddressDynamicArrayLengthSmallerThan(t *testing.T) {
+	useLiveNodes(t)
 	js := `{
         "Filters": [
             {
@@ -373,10 +381,10 @@ func TestAddressDynamicArrayLengthSmallerThan(t *testing.T) {
         "TriggerType": "WatchEvents"
     }`
 
-	tg, err := NewTriggerFromJson(js)
+	tg, err := NewTriggerFromJson(js, nil)
 	assert.NoError(t, err)
 
-	matches := MatchEvent(tg, logs550, []ethrpc.Transaction{}, mockTokenApi)
+	matches := MatchEvent(tg, logs550, []tokenapi.Transaction{}, mockTokenApi)
 
 	assert.Equal(t, 1, len(matches))
 	assert.Equal(t, 5690550, matches[0].Log.BlockNumber)
@@ -384,6 +392,7 @@ func TestAddressDynamicArrayLengthSmallerThan(t *testing.T) {
 
 // https://rinkeby.etherscan.io/tx/0x1e5aebb232ae66459d6c6144e6bfe8269362db01ae47a7a8f89b4df6feff8271
 func TestAddressDynamicArrayLengthBiggerThan(t *testing.T) {
+	useLiveNodes(t)
 	js := `{
         "Filters": [
             {
@@ -403,10 +412,10 @@ func TestAddressDynamicArrayLengthBiggerThan(t *testing.T) {
         "TriggerType": "WatchEvents"
     }`
 
-	tg, err := NewTriggerFromJson(js)
+	tg, err := NewTriggerFromJson(js, nil)
 	assert.NoError(t, err)
 
-	matches := MatchEvent(tg, logs550, []ethrpc.Transaction{}, mockTokenApi)
+	matches := MatchEvent(tg, logs550, []tokenapi.Transaction{}, mockTokenApi)
 
 	assert.Equal(t, 1, len(matches))
 	assert.Equal(t, 5690550, matches[0].Log.BlockNumber)
@@ -414,6 +423,7 @@ func TestAddressDynamicArrayLengthBiggerThan(t *testing.T) {
 
 // https://rinkeby.etherscan.io/tx/0x1e5aebb232ae66459d6c6144e6bfe8269362db01ae47a7a8f89b4df6feff8271
 func TestAddressDynamicArrayLengthEq(t *testing.T) {
+	useLiveNodes(t)
 	js := `{
         "Filters": [
             {
@@ -433,10 +443,10 @@ func TestAddressDynamicArrayLengthEq(t *testing.T) {
         "TriggerType": "WatchEvents"
     }`
 
-	tg, err := NewTriggerFromJson(js)
+	tg, err := NewTriggerFromJson(js, nil)
 	assert.NoError(t, err)
 
-	matches := MatchEvent(tg, logs550, []ethrpc.Transaction{}, mockTokenApi)
+	matches := MatchEvent(tg, logs550, []tokenapi.Transaction{}, mockTokenApi)
 
 	assert.Equal(t, 1, len(matches))
 	assert.Equal(t, 5690550, matches[0].Log.BlockNumber)
@@ -444,6 +454,7 @@ func TestAddressDynamicArrayLengthEq(t *testing.T) {
 
 // https://rinkeby.etherscan.io/tx/0x56bce35c702186f21f4a102a116bc9c822f879a81f6d29d75502547945721d5e
 func TestBoolFixedArrayEqAtPosition1(t *testing.T) {
+	useLiveNodes(t)
 	js := `{
         "Filters": [
             {
@@ -464,10 +475,10 @@ func TestBoolFixedArrayEqAtPosition1(t *testing.T) {
         "TriggerType": "WatchEvents"
     }`
 
-	tg, err := NewTriggerFromJson(js)
+	tg, err := NewTriggerFromJson(js, nil)
 	assert.NoError(t, err)
 
-	matches := MatchEvent(tg, logs550, []ethrpc.Transaction{}, mockTokenApi)
+	matches := MatchEvent(tg, logs550, []tokenapi.Transaction{}, mockTokenApi)
 
 	assert.Equal(t, 1, len(matches))
 	assert.Equal(t, 5690550, matches[0].Log.BlockNumber)
@@ -475,6 +486,7 @@ func TestBoolFixedArrayEqAtPosition1(t *testing.T) {
 
 // https://rinkeby.etherscan.io/tx/0x56bce35c702186f21f4a102a116bc9c822f879a81f6d29d75502547945721d5e
 func TestBoolFixedArrayIsIn(t *testing.T) {
+	useLiveNodes(t)
 	js := `{
         "Filters": [
             {
@@ -494,10 +506,10 @@ func TestBoolFixedArrayIsIn(t *testing.T) {
         "TriggerType": "WatchEvents"
     }`
 
-	tg, err := NewTriggerFromJson(js)
+	tg, err := NewTriggerFromJson(js, nil)
 	assert.NoError(t, err)
 
-	matches := MatchEvent(tg, logs550, []ethrpc.Transaction{}, mockTokenApi)
+	matches := MatchEvent(tg, logs550, []tokenapi.Transaction{}, mockTokenApi)
 
 	assert.Equal(t, 1, len(matches))
 	assert.Equal(t, 5690550, matches[0].Log.BlockNumber)
@@ -505,6 +517,7 @@ func TestBoolFixedArrayIsIn(t *testing.T) {
 
 // https://rinkeby.etherscan.io/tx/0x56bce35c702186f21f4a102a116bc9c822f879a81f6d29d75502547945721d5e
 func TestBoolFixedArrayLengthInBetween(t *testing.T) {
+	useLiveNodes(t)
 	js := `{
         "Filters": [
             {
@@ -528,10 +541,10 @@ func TestBoolFixedArrayLengthInBetween(t *testing.T) {
         "TriggerType": "WatchEvents"
     }`
 
-	tg, err := NewTriggerFromJson(js)
+	tg, err := NewTriggerFromJson(js, nil)
 	assert.NoError(t, err)
 
-	matches := MatchEvent(tg, logs550, []ethrpc.Transaction{}, mockTokenApi)
+	matches := MatchEvent(tg, logs550, []tokenapi.Transaction{}, mockTokenApi)
 
 	assert.Equal(t, 1, len(matches))
 	assert.Equal(t, 5690550, matches[0].Log.BlockNumber)
@@ -539,6 +552,7 @@ func TestBoolFixedArrayLengthInBetween(t *testing.T) {
 
 // // https://rinkeby.etherscan.io/tx/0x56bce35c702186f21f4a102a116bc9c822f879a81f6d29d75502547945721d5e
 func TestBoolFixedArrayLengthSmallerThan(t *testing.T) {
+	useLiveNodes(t)
 	js := `{
         "Filters": [
             {
@@ -558,10 +572,10 @@ func TestBoolFixedArrayLengthSmallerThan(t *testing.T) {
         "TriggerType": "WatchEvents"
     }`
 
-	tg, err := NewTriggerFromJson(js)
+	tg, err := NewTriggerFromJson(js, nil)
 	assert.NoError(t, err)
 
-	matches := MatchEvent(tg, logs550, []ethrpc.Transaction{}, mockTokenApi)
+	matches := MatchEvent(tg, logs550, []tokenapi.Transaction{}, mockTokenApi)
 
 	assert.Equal(t, 1, len(matches))
 	assert.Equal(t, 5690550, matches[0].Log.BlockNumber)
@@ -569,6 +583,7 @@ func TestBoolFixedArrayLengthSmallerThan(t *testing.T) {
 
 // https://rinkeby.etherscan.io/tx/0x56bce35c702186f21f4a102a116bc9c822f879a81f6d29d75502547945721d5e
 func TestBoolFixedArrayLengthBiggerThan(t *testing.T) {
+	useLiveNodes(t)
 	js := `{
         "Filters": [
             {
@@ -588,10 +603,10 @@ func TestBoolFixedArrayLengthBiggerThan(t *testing.T) {
         "TriggerType": "WatchEvents"
     }`
 
-	tg, err := NewTriggerFromJson(js)
+	tg, err := NewTriggerFromJson(js, nil)
 	assert.NoError(t, err)
 
-	matches := MatchEvent(tg, logs550, []ethrpc.Transaction{}, mockTokenApi)
+	matches := MatchEvent(tg, logs550, []tokenapi.Transaction{}, mockTokenApi)
 
 	assert.Equal(t, 1, len(matches))
 	assert.Equal(t, 5690550, matches[0].Log.BlockNumber)
@@ -599,6 +614,7 @@ func TestBoolFixedArrayLengthBiggerThan(t *testing.T) {
 
 // https://rinkeby.etherscan.io/tx/0x56bce35c702186f21f4a102a116bc9c822f879a81f6d29d75502547945721d5e
 func TestBoolFixedArrayLengthEq(t *testing.T) {
+	useLiveNodes(t)
 	js := `{
         "Filters": [
             {
@@ -618,10 +634,10 @@ func TestBoolFixedArrayLengthEq(t *testing.T) {
         "TriggerType": "WatchEvents"
     }`
 
-	tg, err := NewTriggerFromJson(js)
+	tg, err := NewTriggerFromJson(js, nil)
 	assert.NoError(t, err)
 
-	matches := MatchEvent(tg, logs550, []ethrpc.Transaction{}, mockTokenApi)
+	matches := MatchEvent(tg, logs550, []tokenapi.Transaction{}, mockTokenApi)
 
 	assert.Equal(t, 1, len(matches))
 	assert.Equal(t, 5690550, matches[0].Log.BlockNumber)
@@ -629,6 +645,7 @@ func TestBoolFixedArrayLengthEq(t *testing.T) {
 
 // https://rinkeby.etherscan.io/tx/0x56bce35c702186f21f4a102a116bc9c822f879a81f6d29d75502547945721d5e
 func TestBoolDynamicArrayEqAtPosition1(t *testing.T) {
+	useLiveNodes(t)
 	js := `{
         "Filters": [
             {
@@ -649,10 +666,10 @@ func TestBoolDynamicArrayEqAtPosition1(t *testing.T) {
         "TriggerType": "WatchEvents"
     }`
 
-	tg, err := NewTriggerFromJson(js)
+	tg, err := NewTriggerFromJson(js, nil)
 	assert.NoError(t, err)
 
-	matches := MatchEvent(tg, logs550, []ethrpc.Transaction{}, mockTokenApi)
+	matches := MatchEvent(tg, logs550, []tokenapi.Transaction{}, mockTokenApi)
 
 	assert.Equal(t, 1, len(matches))
 	assert.Equal(t, 5690550, matches[0].Log.BlockNumber)
@@ -660,6 +677,7 @@ func TestBoolDynamicArrayEqAtPosition1(t *testing.T) {
 
 // https://rinkeby.etherscan.io/tx/0x56bce35c702186f21f4a102a116bc9c822f879a81f6d29d75502547945721d5e
 func TestBoolDynamicArrayIsIn(t *testing.T) {
+	useLiveNodes(t)
 	js := `{
         "Filters": [
             {
@@ -679,10 +697,10 @@ func TestBoolDynamicArrayIsIn(t *testing.T) {
         "TriggerType": "WatchEvents"
     }`
 
-	tg, err := NewTriggerFromJson(js)
+	tg, err := NewTriggerFromJson(js, nil)
 	assert.NoError(t, err)
 
-	matches := MatchEvent(tg, logs550, []ethrpc.Transaction{}, mockTokenApi)
+	matches := MatchEvent(tg, logs550, []tokenapi.Transaction{}, mockTokenApi)
 
 	assert.Equal(t, 1, len(matches))
 	assert.Equal(t, 5690550, matches[0].Log.BlockNumber)
@@ -690,6 +708,7 @@ func TestBoolDynamicArrayIsIn(t *testing.T) {
 
 // https://rinkeby.etherscan.io/tx/0x56bce35c702186f21f4a102a116bc9c822f879a81f6d29d75502547945721d5e
 func TestBoolDynamicArrayLengthInBetween(t *testing.T) {
+	useLiveNodes(t)
 	js := `{
         "Filters": [
             {
@@ -713,10 +732,10 @@ func TestBoolDynamicArrayLengthInBetween(t *testing.T) {
         "TriggerType": "WatchEvents"
     }`
 
-	tg, err := NewTriggerFromJson(js)
+	tg, err := NewTriggerFromJson(js, nil)
 	assert.NoError(t, err)
 
-	matches := MatchEvent(tg, logs550, []ethrpc.Transaction{}, mockTokenApi)
+	matches := MatchEvent(tg, logs550, []tokenapi.Transaction{}, mockTokenApi)
 
 	assert.Equal(t, 1, len(matches))
 	assert.Equal(t, 5690550, matches[0].Log.BlockNumber)
@@ -724,6 +743,7 @@ func TestBoolDynamicArrayLengthInBetween(t *testing.T) {
 
 // https://rinkeby.etherscan.io/tx/0x56bce35c702186f21f4a102a116bc9c822f879a81f6d29d75502547945721d5e
 func TestBoolDynamicArrayLengthSmallerThan(t *testing.T) {
+	useLiveNodes(t)
 	js := `{
         "Filters": [
             {
@@ -743,10 +763,10 @@ func TestBoolDynamicArrayLengthSmallerThan(t *testing.T) {
         "TriggerType": "WatchEvents"
     }`
 
-	tg, err := NewTriggerFromJson(js)
+	tg, err := NewTriggerFromJson(js, nil)
 	assert.NoError(t, err)
 
-	matches := MatchEvent(tg, logs550, []ethrpc.Transaction{}, mockTokenApi)
+	matches := MatchEvent(tg, logs550, []tokenapi.Transaction{}, mockTokenApi)
 
 	assert.Equal(t, 1, len(matches))
 	assert.Equal(t, 5690550, matches[0].Log.BlockNumber)
@@ -754,6 +774,7 @@ func TestBoolDynamicArrayLengthSmallerThan(t *testing.T) {
 
 // https://rinkeby.etherscan.io/tx/0x56bce35c702186f21f4a102a116bc9c822f879a81f6d29d75502547945721d5e
 func TestBoolDynamicArrayLengthBiggerThan(t *testing.T) {
+	useLiveNodes(t)
 	js := `{
         "Filters": [
             {
@@ -773,10 +794,10 @@ func TestBoolDynamicArrayLengthBiggerThan(t *testing.T) {
         "TriggerType": "WatchEvents"
     }`
 
-	tg, err := NewTriggerFromJson(js)
+	tg, err := NewTriggerFromJson(js, nil)
 	assert.NoError(t, err)
 
-	matches := MatchEvent(tg, logs550, []ethrpc.Transaction{}, mockTokenApi)
+	matches := MatchEvent(tg, logs550, []tokenapi.Transaction{}, mockTokenApi)
 
 	assert.Equal(t, 1, len(matches))
 	assert.Equal(t, 5690550, matches[0].Log.BlockNumber)
@@ -784,6 +805,7 @@ func TestBoolDynamicArrayLengthBiggerThan(t *testing.T) {
 
 // https://rinkeby.etherscan.io/tx/0x56bce35c702186f21f4a102a116bc9c822f879a81f6d29d75502547945721d5e
 func TestBoolDynamicArrayLengthEq(t *testing.T) {
+	useLiveNodes(t)
 	js := `{
         "Filters": [
             {
@@ -803,16 +825,17 @@ func TestBoolDynamicArrayLengthEq(t *testing.T) {
         "TriggerType": "WatchEvents"
     }`
 
-	tg, err := NewTriggerFromJson(js)
+	tg, err := NewTriggerFromJson(js, nil)
 	assert.NoError(t, err)
 
-	matches := MatchEvent(tg, logs550, []ethrpc.Transaction{}, mockTokenApi)
+	matches := MatchEvent(tg, logs550, []tokenapi.Transaction{}, mockTokenApi)
 
 	assert.Equal(t, 1, len(matches))
 	assert.Equal(t, 5690550, matches[0].Log.BlockNumber)
 }
 
 func TestBytes16EqWithOX(t *testing.T) {
+	useLiveNodes(t)
 	js := `{
         "Filters": [
             {
@@ -833,16 +856,17 @@ func TestBytes16EqWithOX(t *testing.T) {
         "TriggerType": "WatchEvents"
     }`
 
-	tg, err := NewTriggerFromJson(js)
+	tg, err := NewTriggerFromJson(js, nil)
 	assert.NoError(t, err)
 
-	matches := MatchEvent(tg, logs551, []ethrpc.Transaction{}, mockTokenApi)
+	matches := MatchEvent(tg, logs551, []tokenapi.Transaction{}, mockTokenApi)
 
 	assert.Equal(t, 1, len(matches))
 	assert.Equal(t, 5690551, matches[0].Log.BlockNumber)
 }
 
 func TestBytes16Eq(t *testing.T) {
+	useLiveNodes(t)
 	js := `{
         "Filters": [
             {
@@ -863,16 +887,17 @@ func TestBytes16Eq(t *testing.T) {
         "TriggerType": "WatchEvents"
     }`
 
-	tg, err := NewTriggerFromJson(js)
+	tg, err := NewTriggerFromJson(js, nil)
 	assert.NoError(t, err)
 
-	matches := MatchEvent(tg, logs551, []ethrpc.Transaction{}, mockTokenApi)
+	matches := MatchEvent(tg, logs551, []tokenapi.Transaction{}, mockTokenApi)
 
 	assert.Equal(t, 1, len(matches))
 	assert.Equal(t, 5690551, matches[0].Log.BlockNumber)
 }
 
 func TestInt256FixedArrayEqAtPosition1(t *testing.T) {
+	useLiveNodes(t)
 	js := `{
         "Filters": [
             {
@@ -893,16 +918,17 @@ func TestInt256FixedArrayEqAtPosition1(t *testing.T) {
         "TriggerType": "WatchEvents"
     }`
 
-	tg, err := NewTriggerFromJson(js)
+	tg, err := NewTriggerFromJson(js, nil)
 	assert.NoError(t, err)
 
-	matches := MatchEvent(tg, logs551, []ethrpc.Transaction{}, mockTokenApi)
+	matches := MatchEvent(tg, logs551, []tokenapi.Transaction{}, mockTokenApi)
 
 	assert.Equal(t, 1, len(matches))
 	assert.Equal(t, 5690551, matches[0].Log.BlockNumber)
 }
 
 func TestInt256FixedArrayIsIn(t *testing.T) {
+	useLiveNodes(t)
 	js := `{
         "Filters": [
             {
@@ -922,16 +948,17 @@ func TestInt256FixedArrayIsIn(t *testing.T) {
         "TriggerType": "WatchEvents"
     }`
 
-	tg, err := NewTriggerFromJson(js)
+	tg, err := NewTriggerFromJson(js, nil)
 	assert.NoError(t, err)
 
-	matches := MatchEvent(tg, logs551, []ethrpc.Transaction{}, mockTokenApi)
+	matches := MatchEvent(tg, logs551, []tokenapi.Transaction{}, mockTokenApi)
 
 	assert.Equal(t, 1, len(matches))
 	assert.Equal(t, 5690551, matches[0].Log.BlockNumber)
 }
 
 func TestInt256FixedArrayLengthInBetween(t *testing.T) {
+	useLiveNodes(t)
 	js := `{
         "Filters": [
             {
@@ -955,16 +982,17 @@ func TestInt256FixedArrayLengthInBetween(t *testing.T) {
         "TriggerType": "WatchEvents"
     }`
 
-	tg, err := NewTriggerFromJson(js)
+	tg, err := NewTriggerFromJson(js, nil)
 	assert.NoError(t, err)
 
-	matches := MatchEvent(tg, logs551, []ethrpc.Transaction{}, mockTokenApi)
+	matches := MatchEvent(tg, logs551, []tokenapi.Transaction{}, mockTokenApi)
 
 	assert.Equal(t, 1, len(matches))
 	assert.Equal(t, 5690551, matches[0].Log.BlockNumber)
 }
 
 func TestInt256FixedArrayLengthSmallerThan(t *testing.T) {
+	useLiveNodes(t)
 	js := `{
         "Filters": [
             {
@@ -984,16 +1012,17 @@ func TestInt256FixedArrayLengthSmallerThan(t *testing.T) {
         "TriggerType": "WatchEvents"
     }`
 
-	tg, err := NewTriggerFromJson(js)
+	tg, err := NewTriggerFromJson(js, nil)
 	assert.NoError(t, err)
 
-	matches := MatchEvent(tg, logs551, []ethrpc.Transaction{}, mockTokenApi)
+	matches := MatchEvent(tg, logs551, []tokenapi.Transaction{}, mockTokenApi)
 
 	assert.Equal(t, 1, len(matches))
 	assert.Equal(t, 5690551, matches[0].Log.BlockNumber)
 }
 
 func TestInt256FixedArrayLengthBiggerThan(t *testing.T) {
+	useLiveNodes(t)
 	js := `{
         "Filters": [
             {
@@ -1013,16 +1042,17 @@ func TestInt256FixedArrayLengthBiggerThan(t *testing.T) {
         "TriggerType": "WatchEvents"
     }`
 
-	tg, err := NewTriggerFromJson(js)
+	tg, err := NewTriggerFromJson(js, nil)
 	assert.NoError(t, err)
 
-	matches := MatchEvent(tg, logs551, []ethrpc.Transaction{}, mockTokenApi)
+	matches := MatchEvent(tg, logs551, []tokenapi.Transaction{}, mockTokenApi)
 
 	assert.Equal(t, 1, len(matches))
 	assert.Equal(t, 5690551, matches[0].Log.BlockNumber)
 }
 
 func TestInt256FixedArrayLengthEq(t *testing.T) {
+	useLiveNodes(t)
 	js := `{
         "Filters": [
             {
@@ -1042,16 +1072,17 @@ func TestInt256FixedArrayLengthEq(t *testing.T) {
         "TriggerType": "WatchEvents"
     }`
 
-	tg, err := NewTriggerFromJson(js)
+	tg, err := NewTriggerFromJson(js, nil)
 	assert.NoError(t, err)
 
-	matches := MatchEvent(tg, logs551, []ethrpc.Transaction{}, mockTokenApi)
+	matches := MatchEvent(tg, logs551, []tokenapi.Transaction{}, mockTokenApi)
 
 	assert.Equal(t, 1, len(matches))
 	assert.Equal(t, 5690551, matches[0].Log.BlockNumber)
 }
 
 func TestInt256DinamicArrayEqAtPosition0(t *testing.T) {
+	useLiveNodes(t)
 	js := `{
         "Filters": [
             {
@@ -1072,16 +1103,17 @@ func TestInt256DinamicArrayEqAtPosition0(t *testing.T) {
         "TriggerType": "WatchEvents"
     }`
 
-	tg, err := NewTriggerFromJson(js)
+	tg, err := NewTriggerFromJson(js, nil)
 	assert.NoError(t, err)
 
-	matches := MatchEvent(tg, logs551, []ethrpc.Transaction{}, mockTokenApi)
+	matches := MatchEvent(tg, logs551, []tokenapi.Transaction{}, mockTokenApi)
 
 	assert.Equal(t, 1, len(matches))
 	assert.Equal(t, 5690551, matches[0].Log.BlockNumber)
 }
 
 func TestInt256DinamicArrayIsIn(t *testing.T) {
+	useLiveNodes(t)
 	js := `{
         "Filters": [
             {
@@ -1101,16 +1133,17 @@ func TestInt256DinamicArrayIsIn(t *testing.T) {
         "TriggerType": "WatchEvents"
     }`
 
-	tg, err := NewTriggerFromJson(js)
+	tg, err := NewTriggerFromJson(js, nil)
 	assert.NoError(t, err)
 
-	matches := MatchEvent(tg, logs551, []ethrpc.Transaction{}, mockTokenApi)
+	matches := MatchEvent(tg, logs551, []tokenapi.Transaction{}, mockTokenApi)
 
 	assert.Equal(t, 1, len(matches))
 	assert.Equal(t, 5690551, matches[0].Log.BlockNumber)
 }
 
 func TestInt256DinamicArrayLengthInBetween(t *testing.T) {
+	useLiveNodes(t)
 	js := `{
         "Filters": [
             {
@@ -1134,16 +1167,17 @@ func TestInt256DinamicArrayLengthInBetween(t *testing.T) {
         "TriggerType": "WatchEvents"
     }`
 
-	tg, err := NewTriggerFromJson(js)
+	tg, err := NewTriggerFromJson(js, nil)
 	assert.NoError(t, err)
 
-	matches := MatchEvent(tg, logs551, []ethrpc.Transaction{}, mockTokenApi)
+	matches := MatchEvent(tg, logs551, []tokenapi.Transaction{}, mockTokenApi)
 
 	assert.Equal(t, 1, len(matches))
 	assert.Equal(t, 5690551, matches[0].Log.BlockNumber)
 }
 
 func TestInt256DinamicArrayLengthSmallerThan(t *testing.T) {
+	useLiveNodes(t)
 	js := `{
         "Filters": [
             {
@@ -1163,16 +1197,17 @@ func TestInt256DinamicArrayLengthSmallerThan(t *testing.T) {
         "TriggerType": "WatchEvents"
     }`
 
-	tg, err := NewTriggerFromJson(js)
+	tg, err := NewTriggerFromJson(js, nil)
 	assert.NoError(t, err)
 
-	matches := MatchEvent(tg, logs551, []ethrpc.Transaction{}, mockTokenApi)
+	matches := MatchEvent(tg, logs551, []tokenapi.Transaction{}, mockTokenApi)
 
 	assert.Equal(t, 1, len(matches))
 	assert.Equal(t, 5690551, matches[0].Log.BlockNumber)
 }
 
 func TestInt256DinamicArrayLengthBiggerThan(t *testing.T) {
+	useLiveNodes(t)
 	js := `{
         "Filters": [
             {
@@ -1192,16 +1227,17 @@ func TestInt256DinamicArrayLengthBiggerThan(t *testing.T) {
         "TriggerType": "WatchEvents"
     }`
 
-	tg, err := NewTriggerFromJson(js)
+	tg, err := NewTriggerFromJson(js, nil)
 	assert.NoError(t, err)
 
-	matches := MatchEvent(tg, logs551, []ethrpc.Transaction{}, mockTokenApi)
+	matches := MatchEvent(tg, logs551, []tokenapi.Transaction{}, mockTokenApi)
 
 	assert.Equal(t, 1, len(matches))
 	assert.Equal(t, 5690551, matches[0].Log.BlockNumber)
 }
 
 func TestInt256DinamicArrayLengthEq(t *testing.T) {
+	useLiveNodes(t)
 	js := `{
         "Filters": [
             {
@@ -1221,16 +1257,17 @@ func TestInt256DinamicArrayLengthEq(t *testing.T) {
         "TriggerType": "WatchEvents"
     }`
 
-	tg, err := NewTriggerFromJson(js)
+	tg, err := NewTriggerFromJson(js, nil)
 	assert.NoError(t, err)
 
-	matches := MatchEvent(tg, logs551, []ethrpc.Transaction{}, mockTokenApi)
+	matches := MatchEvent(tg, logs551, []tokenapi.Transaction{}, mockTokenApi)
 
 	assert.Equal(t, 1, len(matches))
 	assert.Equal(t, 5690551, matches[0].Log.BlockNumber)
 }
 
 func TestStringFixedArrayIsIn(t *testing.T) {
+	useLiveNodes(t)
 	js := `{
         "Filters": [
             {
@@ -1250,16 +1287,17 @@ func TestStringFixedArrayIsIn(t *testing.T) {
         "TriggerType": "WatchEvents"
     }`
 
-	tg, err := NewTriggerFromJson(js)
+	tg, err := NewTriggerFromJson(js, nil)
 	assert.NoError(t, err)
 
-	matches := MatchEvent(tg, logs552, []ethrpc.Transaction{}, mockTokenApi)
+	matches := MatchEvent(tg, logs552, []tokenapi.Transaction{}, mockTokenApi)
 
 	assert.Equal(t, 1, len(matches))
 	assert.Equal(t, 5690552, matches[0].Log.BlockNumber)
 }
 
 func TestStringFixedArrayEqAtPosition0(t *testing.T) {
+	useLiveNodes(t)
 	js := `{
         "Filters": [
             {
@@ -1280,16 +1318,17 @@ func TestStringFixedArrayEqAtPosition0(t *testing.T) {
         "TriggerType": "WatchEvents"
     }`
 
-	tg, err := NewTriggerFromJson(js)
+	tg, err := NewTriggerFromJson(js, nil)
 	assert.NoError(t, err)
 
-	matches := MatchEvent(tg, logs552, []ethrpc.Transaction{}, mockTokenApi)
+	matches := MatchEvent(tg, logs552, []tokenapi.Transaction{}, mockTokenApi)
 
 	assert.Equal(t, 1, len(matches))
 	assert.Equal(t, 5690552, matches[0].Log.BlockNumber)
 }
 
 func TestStringFixedArrayLengthInBetween(t *testing.T) {
+	useLiveNodes(t)
 	js := `{
         "Filters": [
             {
@@ -1313,16 +1352,17 @@ func TestStringFixedArrayLengthInBetween(t *testing.T) {
         "TriggerType": "WatchEvents"
     }`
 
-	tg, err := NewTriggerFromJson(js)
+	tg, err := NewTriggerFromJson(js, nil)
 	assert.NoError(t, err)
 
-	matches := MatchEvent(tg, logs552, []ethrpc.Transaction{}, mockTokenApi)
+	matches := MatchEvent(tg, logs552, []tokenapi.Transaction{}, mockTokenApi)
 
 	assert.Equal(t, 1, len(matches))
 	assert.Equal(t, 5690552, matches[0].Log.BlockNumber)
 }
 
 func TestStringFixedArrayLengthBiggerThan(t *testing.T) {
+	useLiveNodes(t)
 	js := `{
         "Filters": [
             {
@@ -1342,16 +1382,17 @@ func TestStringFixedArrayLengthBiggerThan(t *testing.T) {
         "TriggerType": "WatchEvents"
     }`
 
-	tg, err := NewTriggerFromJson(js)
+	tg, err := NewTriggerFromJson(js, nil)
 	assert.NoError(t, err)
 
-	matches := MatchEvent(tg, logs552, []ethrpc.Transaction{}, mockTokenApi)
+	matches := MatchEvent(tg, logs552, []tokenapi.Transaction{}, mockTokenApi)
 
 	assert.Equal(t, 1, len(matches))
 	assert.Equal(t, 5690552, matches[0].Log.BlockNumber)
 }
 
 func TestStringFixedArrayLengthSmallerThan(t *testing.T) {
+	useLiveNodes(t)
 	js := `{
         "Filters": [
             {
@@ -1371,16 +1412,17 @@ func TestStringFixedArrayLengthSmallerThan(t *testing.T) {
         "TriggerType": "WatchEvents"
     }`
 
-	tg, err := NewTriggerFromJson(js)
+	tg, err := NewTriggerFromJson(js, nil)
 	assert.NoError(t, err)
 
-	matches := MatchEvent(tg, logs552, []ethrpc.Transaction{}, mockTokenApi)
+	matches := MatchEvent(tg, logs552, []tokenapi.Transaction{}, mockTokenApi)
 
 	assert.Equal(t, 1, len(matches))
 	assert.Equal(t, 5690552, matches[0].Log.BlockNumber)
 }
 
 func TestStringFixedArrayLengthEq(t *testing.T) {
+	useLiveNodes(t)
 	js := `{
         "Filters": [
             {
@@ -1400,16 +1442,17 @@ func TestStringFixedArrayLengthEq(t *testing.T) {
         "TriggerType": "WatchEvents"
     }`
 
-	tg, err := NewTriggerFromJson(js)
+	tg, err := NewTriggerFromJson(js, nil)
 	assert.NoError(t, err)
 
-	matches := MatchEvent(tg, logs552, []ethrpc.Transaction{}, mockTokenApi)
+	matches := MatchEvent(tg, logs552, []tokenapi.Transaction{}, mockTokenApi)
 
 	assert.Equal(t, 1, len(matches))
 	assert.Equal(t, 5690552, matches[0].Log.BlockNumber)
 }
 
 func TestStringDinamicArrayLengthInBetween(t *testing.T) {
+	useLiveNodes(t)
 	js := `{
         "Filters": [
             {
@@ -1433,16 +1476,17 @@ func TestStringDinamicArrayLengthInBetween(t *testing.T) {
         "TriggerType": "WatchEvents"
     }`
 
-	tg, err := NewTriggerFromJson(js)
+	tg, err := NewTriggerFromJson(js, nil)
 	assert.NoError(t, err)
 
-	matches := MatchEvent(tg, logs552, []ethrpc.Transaction{}, mockTokenApi)
+	matches := MatchEvent(tg, logs552, []tokenapi.Transaction{}, mockTokenApi)
 
 	assert.Equal(t, 1, len(matches))
 	assert.Equal(t, 5690552, matches[0].Log.BlockNumber)
 }
 
 func TestStringDinamicArrayLengthSmallerThan(t *testing.T) {
+	useLiveNodes(t)
 	js := `{
         "Filters": [
             {
@@ -1462,16 +1506,17 @@ func TestStringDinamicArrayLengthSmallerThan(t *testing.T) {
         "TriggerType": "WatchEvents"
     }`
 
-	tg, err := NewTriggerFromJson(js)
+	tg, err := NewTriggerFromJson(js, nil)
 	assert.NoError(t, err)
 
-	matches := MatchEvent(tg, logs552, []ethrpc.Transaction{}, mockTokenApi)
+	matches := MatchEvent(tg, logs552, []tokenapi.Transaction{}, mockTokenApi)
 
 	assert.Equal(t, 1, len(matches))
 	assert.Equal(t, 5690552, matches[0].Log.BlockNumber)
 }
 
 func TestStringDinamicArrayLengthBiggerThan(t *testing.T) {
+	useLiveNodes(t)
 	js := `{
         "Filters": [
             {
@@ -1491,16 +1536,17 @@ func TestStringDinamicArrayLengthBiggerThan(t *testing.T) {
         "TriggerType": "WatchEvents"
     }`
 
-	tg, err := NewTriggerFromJson(js)
+	tg, err := NewTriggerFromJson(js, nil)
 	assert.NoError(t, err)
 
-	matches := MatchEvent(tg, logs552, []ethrpc.Transaction{}, mockTokenApi)
+	matches := MatchEvent(tg, logs552, []tokenapi.Transaction{}, mockTokenApi)
 
 	assert.Equal(t, 1, len(matches))
 	assert.Equal(t, 5690552, matches[0].Log.BlockNumber)
 }
 
 func TestStringDinamicArrayLengthEq(t *testing.T) {
+	useLiveNodes(t)
 	js := `{
         "Filters": [
             {
@@ -1520,16 +1566,17 @@ func TestStringDinamicArrayLengthEq(t *testing.T) {
         "TriggerType": "WatchEvents"
     }`
 
-	tg, err := NewTriggerFromJson(js)
+	tg, err := NewTriggerFromJson(js, nil)
 	assert.NoError(t, err)
 
-	matches := MatchEvent(tg, logs552, []ethrpc.Transaction{}, mockTokenApi)
+	matches := MatchEvent(tg, logs552, []tokenapi.Transaction{}, mockTokenApi)
 
 	assert.Equal(t, 1, len(matches))
 	assert.Equal(t, 5690552, matches[0].Log.BlockNumber)
 }
 
 func TestStringDinamicArrayEqAtPosition0(t *testing.T) {
+	useLiveNodes(t)
 	js := `{
         "Filters": [
             {
@@ -1550,16 +1597,17 @@ func TestStringDinamicArrayEqAtPosition0(t *testing.T) {
         "TriggerType": "WatchEvents"
     }`
 
-	tg, err := NewTriggerFromJson(js)
+	tg, err := NewTriggerFromJson(js, nil)
 	assert.NoError(t, err)
 
-	matches := MatchEvent(tg, logs552, []ethrpc.Transaction{}, mockTokenApi)
+	matches := MatchEvent(tg, logs552, []tokenapi.Transaction{}, mockTokenApi)
 
 	assert.Equal(t, 1, len(matches))
 	assert.Equal(t, 5690552, matches[0].Log.BlockNumber)
 }
 
 func TestStringDinamicArrayIsIn(t *testing.T) {
+	useLiveNodes(t)
 	js := `{
         "Filters": [
             {
@@ -1579,16 +1627,17 @@ func TestStringDinamicArrayIsIn(t *testing.T) {
         "TriggerType": "WatchEvents"
     }`
 
-	tg, err := NewTriggerFromJson(js)
+	tg, err := NewTriggerFromJson(js, nil)
 	assert.NoError(t, err)
 
-	matches := MatchEvent(tg, logs552, []ethrpc.Transaction{}, mockTokenApi)
+	matches := MatchEvent(tg, logs552, []tokenapi.Transaction{}, mockTokenApi)
 
 	assert.Equal(t, 1, len(matches))
 	assert.Equal(t, 5690552, matches[0].Log.BlockNumber)
 }
 
 func TestStringEq(t *testing.T) {
+	useLiveNodes(t)
 	js := `{
         "Filters": [
             {
@@ -1608,16 +1657,17 @@ func TestStringEq(t *testing.T) {
         "TriggerType": "WatchEvents"
     }`
 
-	tg, err := NewTriggerFromJson(js)
+	tg, err := NewTriggerFromJson(js, nil)
 	assert.NoError(t, err)
 
-	matches := MatchEvent(tg, logs552, []ethrpc.Transaction{}, mockTokenApi)
+	matches := MatchEvent(tg, logs552, []tokenapi.Transaction{}, mockTokenApi)
 
 	assert.Equal(t, 1, len(matches))
 	assert.Equal(t, 5690552, matches[0].Log.BlockNumber)
 }
 
 func TestUint8Eq(t *testing.T) {
+	useLiveNodes(t)
 	js := `{
        "Filters": [
            {
@@ -1637,19 +1687,20 @@ func TestUint8Eq(t *testing.T) {
        "TriggerType": "WatchEvents"
    }`
 
-	tg, err := NewTriggerFromJson(js)
+	tg, err := NewTriggerFromJson(js, nil)
 	assert.NoError(t, err)
 
 	logs, err := TokenApiMainnet.GetRPCCli().EthGetLogsByNumber(9252401, "0x7be8076f4ea4a4ad08075c2508e481d6c946d12b")
 	assert.NoError(t, err)
 
-	matches := MatchEvent(tg, logs, []ethrpc.Transaction{}, mockTokenApi)
+	matches := MatchEvent(tg, logs, []tokenapi.Transaction{}, mockTokenApi)
 
 	assert.Equal(t, 2, len(matches))
 	assert.Equal(t, 9252401, matches[0].Log.BlockNumber)
 }
 
 func TestBytes32Eq(t *testing.T) {
+	useLiveNodes(t)
 	js := `{
         "Filters": [
             {
@@ -1669,11 +1720,11 @@ func TestBytes32Eq(t *testing.T) {
         "TriggerType": "WatchEvents"
     }`
 
-	tg, err := NewTriggerFromJson(js)
+	tg, err := NewTriggerFromJson(js, nil)
 	assert.NoError(t, err)
 
 	var logs, _ = TokenApiMainnet.GetRPCCli().EthGetLogsByNumber(9252045, "0x7a6425c9b3f5521bfa5d71df710a2fb80508319b")
-	matches := MatchEvent(tg, logs, []ethrpc.Transaction{}, mockTokenApi)
+	matches := MatchEvent(tg, logs, []tokenapi.Transaction{}, mockTokenApi)
 
 	assert.Equal(t, 1, len(matches))
 	assert.Equal(t, 9252045, matches[0].Log.BlockNumber)
@@ -1681,6 +1732,7 @@ func TestBytes32Eq(t *testing.T) {
 
 // https://etherscan.io/tx/0x55ae08e51da4e787b7589ba9342a81091ae76f29a86b723c2e96eb32be7303d0
 func TestBytesEqStartingWith0x(t *testing.T) {
+	useLiveNodes(t)
 	js := `{
         "Filters": [
             {
@@ -1700,11 +1752,11 @@ func TestBytesEqStartingWith0x(t *testing.T) {
         "TriggerType": "WatchEvents"
     }`
 
-	tg, err := NewTriggerFromJson(js)
+	tg, err := NewTriggerFromJson(js, nil)
 	assert.NoError(t, err)
 
 	var logs, _ = TokenApiMainnet.GetRPCCli().EthGetLogsByNumber(9243327, "0xc2058f5d9736e8df8ba03ca3582b7cd6ac613658")
-	matches := MatchEvent(tg, logs, []ethrpc.Transaction{}, mockTokenApi)
+	matches := MatchEvent(tg, logs, []tokenapi.Transaction{}, mockTokenApi)
 
 	assert.Equal(t, 1, len(matches))
 	assert.Equal(t, 9243327, matches[0].Log.BlockNumber)
@@ -1712,6 +1764,7 @@ func TestBytesEqStartingWith0x(t *testing.T) {
 
 // https://etherscan.io/tx/0x55ae08e51da4e787b7589ba9342a81091ae76f29a86b723c2e96eb32be7303d0
 func TestBytesEq(t *testing.T) {
+	useLiveNodes(t)
 	js := `{
         "Filters": [
             {
@@ -1731,17 +1784,18 @@ func TestBytesEq(t *testing.T) {
         "TriggerType": "WatchEvents"
     }`
 
-	tg, err := NewTriggerFromJson(js)
+	tg, err := NewTriggerFromJson(js, nil)
 	assert.NoError(t, err)
 
 	var logs, _ = TokenApiMainnet.GetRPCCli().EthGetLogsByNumber(9243327, "0xc2058f5d9736e8df8ba03ca3582b7cd6ac613658")
-	matches := MatchEvent(tg, logs, []ethrpc.Transaction{}, mockTokenApi)
+	matches := MatchEvent(tg, logs, []tokenapi.Transaction{}, mockTokenApi)
 
 	assert.Equal(t, 1, len(matches))
 	assert.Equal(t, 9243327, matches[0].Log.BlockNumber)
 }
 
 func TestBoolEq(t *testing.T) {
+	useLiveNodes(t)
 	js := `{
         "Filters": [
             {
@@ -1761,17 +1815,18 @@ func TestBoolEq(t *testing.T) {
         "TriggerType": "WatchEvents"
     }`
 
-	tg, err := NewTriggerFromJson(js)
+	tg, err := NewTriggerFromJson(js, nil)
 	assert.NoError(t, err)
 
 	var logs, _ = TokenApiMainnet.GetRPCCli().EthGetLogsByNumber(9133542, "0x73866e69c6f6f74fc48539dd541a6df8c8059e04")
-	matches := MatchEvent(tg, logs, []ethrpc.Transaction{}, mockTokenApi)
+	matches := MatchEvent(tg, logs, []tokenapi.Transaction{}, mockTokenApi)
 
 	assert.Equal(t, 1, len(matches))
 	assert.Equal(t, 9133542, matches[0].Log.BlockNumber)
 }
 
 func TestUint64Eq(t *testing.T) {
+	useLiveNodes(t)
 	js := `{
         "Filters": [
             {
@@ -1791,17 +1846,18 @@ func TestUint64Eq(t *testing.T) {
         "TriggerType": "WatchEvents"
     }`
 
-	tg, err := NewTriggerFromJson(js)
+	tg, err := NewTriggerFromJson(js, nil)
 	assert.NoError(t, err)
 
 	var logs, _ = TokenApiMainnet.GetRPCCli().EthGetLogsByNumber(9252369, "0x39755357759ce0d7f32dc8dc45414cca409ae24e")
-	matches := MatchEvent(tg, logs, []ethrpc.Transaction{}, mockTokenApi)
+	matches := MatchEvent(tg, logs, []tokenapi.Transaction{}, mockTokenApi)
 
 	assert.Equal(t, 1, len(matches))
 	assert.Equal(t, 9252369, matches[0].Log.BlockNumber)
 }
 
 func TestUint128Eq(t *testing.T) {
+	useLiveNodes(t)
 	js := `{
         "Filters": [
             {
@@ -1821,17 +1877,18 @@ func TestUint128Eq(t *testing.T) {
         "TriggerType": "WatchEvents"
     }`
 
-	tg, err := NewTriggerFromJson(js)
+	tg, err := NewTriggerFromJson(js, nil)
 	assert.NoError(t, err)
 
 	var logs, _ = TokenApiMainnet.GetRPCCli().EthGetLogsByNumber(9252369, "0x39755357759ce0d7f32dc8dc45414cca409ae24e")
-	matches := MatchEvent(tg, logs, []ethrpc.Transaction{}, mockTokenApi)
+	matches := MatchEvent(tg, logs, []tokenapi.Transaction{}, mockTokenApi)
 
 	assert.Equal(t, 1, len(matches))
 	assert.Equal(t, 9252369, matches[0].Log.BlockNumber)
 }
 
 func XXXTestUint128EqBis(t *testing.T) {
+	useLiveNodes(t)
 	js := `{
         "Filters": [
             {
@@ -1851,17 +1908,18 @@ func XXXTestUint128EqBis(t *testing.T) {
         "TriggerType": "WatchEvents"
     }`
 
-	tg, err := NewTriggerFromJson(js)
+	tg, err := NewTriggerFromJson(js, nil)
 	assert.NoError(t, err)
 
 	var logs, _ = TokenApiMainnet.GetRPCCli().EthGetLogsByNumber(9252460, "0x39755357759ce0d7f32dc8dc45414cca409ae24e")
-	matches := MatchEvent(tg, logs, []ethrpc.Transaction{}, mockTokenApi)
+	matches := MatchEvent(tg, logs, []tokenapi.Transaction{}, mockTokenApi)
 
 	assert.Equal(t, 2, len(matches))
 	assert.Equal(t, 9252460, matches[0].Log.BlockNumber)
 }
 
 func TestAddressEqNotDecoded(t *testing.T) {
+	useLiveNodes(t)
 	js := `{
     "Filters": [
         {
@@ -1881,17 +1939,18 @@ func TestAddressEqNotDecoded(t *testing.T) {
     "TriggerType": "WatchEvents"
 }`
 
-	tg, err := NewTriggerFromJson(js)
+	tg, err := NewTriggerFromJson(js, nil)
 	assert.NoError(t, err)
 
 	var logs, _ = TokenApiMainnet.GetRPCCli().EthGetLogsByNumber(9252175, "0x14094949152eddbfcd073717200da82fed8dc960")
-	matches := MatchEvent(tg, logs, []ethrpc.Transaction{}, mockTokenApi)
+	matches := MatchEvent(tg, logs, []tokenapi.Transaction{}, mockTokenApi)
 
 	assert.Equal(t, 1, len(matches))
 	assert.Equal(t, 9252175, matches[0].Log.BlockNumber)
 }
 
 func TestUint256Eq(t *testing.T) {
+	useLiveNodes(t)
 	js := `{
         "Filters": [
             {
@@ -1941,17 +2000,18 @@ func TestUint256Eq(t *testing.T) {
         "TriggerType": "WatchEvents"
     }`
 
-	tg, err := NewTriggerFromJson(js)
+	tg, err := NewTriggerFromJson(js, nil)
 	assert.NoError(t, err)
 
 	var logs, _ = TokenApiMainnet.GetRPCCli().EthGetLogsByNumber(9252357, "0xc7af99fe5513eb6710e6d5f44f9989da40f27f26")
-	matches := MatchEvent(tg, logs, []ethrpc.Transaction{}, mockTokenApi)
+	matches := MatchEvent(tg, logs, []tokenapi.Transaction{}, mockTokenApi)
 
 	assert.Equal(t, 1, len(matches))
 	assert.Equal(t, 9252357, matches[0].Log.BlockNumber)
 }
 
 func TestUint256InBetween(t *testing.T) {
+	useLiveNodes(t)
 	js := `{
         "Filters": [
             {
@@ -1981,17 +2041,18 @@ func TestUint256InBetween(t *testing.T) {
         "TriggerType": "WatchEvents"
     }`
 
-	tg, err := NewTriggerFromJson(js)
+	tg, err := NewTriggerFromJson(js, nil)
 	assert.NoError(t, err)
 
 	var logs, _ = TokenApiMainnet.GetRPCCli().EthGetLogsByNumber(9130794, "0xa52e014b3f5cc48287c2d483a3e026c32cc76e6d")
-	matches := MatchEvent(tg, logs, []ethrpc.Transaction{}, mockTokenApi)
+	matches := MatchEvent(tg, logs, []tokenapi.Transaction{}, mockTokenApi)
 
 	assert.Equal(t, 1, len(matches))
 	assert.Equal(t, 9130794, matches[0].Log.BlockNumber)
 }
 
 func TestUint256BiggerThan(t *testing.T) {
+	useLiveNodes(t)
 	js := `{
         "Filters": [
             {
@@ -2011,17 +2072,18 @@ func TestUint256BiggerThan(t *testing.T) {
         "TriggerType": "WatchEvents"
     }`
 
-	tg, err := NewTriggerFromJson(js)
+	tg, err := NewTriggerFromJson(js, nil)
 	assert.NoError(t, err)
 
 	var logs, _ = TokenApiMainnet.GetRPCCli().EthGetLogsByNumber(9130794, "0xa52e014b3f5cc48287c2d483a3e026c32cc76e6d")
-	matches := MatchEvent(tg, logs, []ethrpc.Transaction{}, mockTokenApi)
+	matches := MatchEvent(tg, logs, []tokenapi.Transaction{}, mockTokenApi)
 
 	assert.Equal(t, 1, len(matches))
 	assert.Equal(t, 9130794, matches[0].Log.BlockNumber)
 }
 
 func TestUint256EqBytes32EqAddressEq(t *testing.T) {
+	useLiveNodes(t)
 	js := `{
         "Filters": [
             {
@@ -2061,11 +2123,11 @@ func TestUint256EqBytes32EqAddressEq(t *testing.T) {
         "TriggerType": "WatchEvents"
     }`
 
-	tg, err := NewTriggerFromJson(js)
+	tg, err := NewTriggerFromJson(js, nil)
 	assert.NoError(t, err)
 
 	var logs, _ = TokenApiMainnet.GetRPCCli().EthGetLogsByNumber(9130794, "0xa52e014b3f5cc48287c2d483a3e026c32cc76e6d")
-	matches := MatchEvent(tg, logs, []ethrpc.Transaction{}, mockTokenApi)
+	matches := MatchEvent(tg, logs, []tokenapi.Transaction{}, mockTokenApi)
 
 	assert.Equal(t, 1, len(matches))
 	assert.Equal(t, 9130794, matches[0].Log.BlockNumber)
@@ -2091,11 +2153,11 @@ func TestMatchEvent9(t *testing.T) {
     "TriggerType": "WatchEvents"
 }`
 
-	tg, err := NewTriggerFromJson(js)
+	tg, err := NewTriggerFromJson(js, nil)
 	assert.NoError(t, err)
 
 	logs, _ := GetLogsFromFile("../resources/events/logs3.json")
-	matches := MatchEvent(tg, logs, []ethrpc.Transaction{}, mockTokenApi)
+	matches := MatchEvent(tg, logs, []tokenapi.Transaction{}, mockTokenApi)
 
 	assert.Equal(t, 2, len(matches))
 	assert.Equal(t, "0xdcbc1c05240f31ff3ad067ef1ee35ce4997762752e3a095284754544f4c709d7", matches[0].Log.Topics[0])
@@ -2103,6 +2165,7 @@ func TestMatchEvent9(t *testing.T) {
 }
 
 func TestMatchEvent8(t *testing.T) {
+	useLiveNodes(t)
 	js := `{
     "Filters": [
         {
@@ -2122,17 +2185,18 @@ func TestMatchEvent8(t *testing.T) {
     "TriggerType": "WatchEvents"
 }`
 
-	tg, err := NewTriggerFromJson(js)
+	tg, err := NewTriggerFromJson(js, nil)
 	assert.NoError(t, err)
 
 	var logs, _ = TokenApiMainnet.GetRPCCli().EthGetLogsByNumber(9222611, "0xc02aaa39b223fe8d0a0e5c4f27ead9083c756cc2")
-	matches := MatchEvent(tg, logs, []ethrpc.Transaction{}, mockTokenApi)
+	matches := MatchEvent(tg, logs, []tokenapi.Transaction{}, mockTokenApi)
 
 	assert.Equal(t, 1, len(matches))
 	assert.Equal(t, 9222611, matches[0].Log.BlockNumber)
 }
 
 func TestMatchEvent7(t *testing.T) {
+	useLiveNodes(t)
 	js := `{
     "Filters": [
         {
@@ -2151,17 +2215,18 @@ func TestMatchEvent7(t *testing.T) {
     "TriggerName": "test event",
     "TriggerType": "WatchEvents"
 }`
-	tg, err := NewTriggerFromJson(js)
+	tg, err := NewTriggerFromJson(js, nil)
 	assert.NoError(t, err)
 
 	var logs, _ = TokenApiRinkeby.GetRPCCli().EthGetLogsByNumber(5693736, "0x63cbf20c5e2a2a6599627fdce8b9f0cc3b782be1")
-	matches := MatchEvent(tg, logs, []ethrpc.Transaction{}, mockTokenApi)
+	matches := MatchEvent(tg, logs, []tokenapi.Transaction{}, mockTokenApi)
 
 	assert.Equal(t, 1, len(matches))
 	assert.Equal(t, 5693736, matches[0].Log.BlockNumber)
 }
 
 func TestMatchEvent6(t *testing.T) {
+	useLiveNodes(t)
 	js := `{
     "Filters": [
         {
@@ -2190,17 +2255,18 @@ func TestMatchEvent6(t *testing.T) {
     "TriggerName": "test event",
     "TriggerType": "WatchEvents"
 }`
-	tg, err := NewTriggerFromJson(js)
+	tg, err := NewTriggerFromJson(js, nil)
 	assert.NoError(t, err)
 
 	var logs, _ = TokenApiRinkeby.GetRPCCli().EthGetLogsByNumber(5693736, "0x63cbf20c5e2a2a6599627fdce8b9f0cc3b782be1")
-	matches := MatchEvent(tg, logs, []ethrpc.Transaction{}, mockTokenApi)
+	matches := MatchEvent(tg, logs, []tokenapi.Transaction{}, mockTokenApi)
 
 	assert.Equal(t, 1, len(matches))
 	assert.Equal(t, 5693736, matches[0].Log.BlockNumber)
 }
 
 func TestMatchEvent5(t *testing.T) {
+	useLiveNodes(t)
 	js := `{
     "Filters": [
         {
@@ -2219,17 +2285,18 @@ func TestMatchEvent5(t *testing.T) {
     "TriggerName": "test event",
     "TriggerType": "WatchEvents"
 }`
-	tg, err := NewTriggerFromJson(js)
+	tg, err := NewTriggerFromJson(js, nil)
 	assert.NoError(t, err)
 
 	var logs, _ = TokenApiRinkeby.GetRPCCli().EthGetLogsByNumber(5693738, "0x63cbf20c5e2a2a6599627fdce8b9f0cc3b782be1")
-	matches := MatchEvent(tg, logs, []ethrpc.Transaction{}, mockTokenApi)
+	matches := MatchEvent(tg, logs, []tokenapi.Transaction{}, mockTokenApi)
 
 	assert.Equal(t, 1, len(matches))
 	assert.Equal(t, 5693738, matches[0].Log.BlockNumber)
 }
 
 func TestMatchEvent4(t *testing.T) {
+	useLiveNodes(t)
 	js := `{
     "Filters": [
         {
@@ -2268,17 +2335,18 @@ func TestMatchEvent4(t *testing.T) {
     "TriggerName": "test event",
     "TriggerType": "WatchEvents"
 }`
-	tg, err := NewTriggerFromJson(js)
+	tg, err := NewTriggerFromJson(js, nil)
 	assert.NoError(t, err)
 
 	var logs, _ = TokenApiRinkeby.GetRPCCli().EthGetLogsByNumber(5693738, "0x63cbf20c5e2a2a6599627fdce8b9f0cc3b782be1")
-	matches := MatchEvent(tg, logs, []ethrpc.Transaction{}, mockTokenApi)
+	matches := MatchEvent(tg, logs, []tokenapi.Transaction{}, mockTokenApi)
 
 	assert.Equal(t, 1, len(matches))
 	assert.Equal(t, 5693738, matches[0].Log.BlockNumber)
 }
 
 func TestMatchEvent3(t *testing.T) {
+	useLiveNodes(t)
 	js := `{
     "Filters": [
         {
@@ -2325,7 +2393,7 @@ func TestMatchEvent3(t *testing.T) {
     "TriggerName": "WAE",
     "TriggerType": "WatchEvents"
 }`
-	tg, err := NewTriggerFromJson(js)
+	tg, err := NewTriggerFromJson(js, nil)
 	assert.NoError(t, err)
 
 	logs, _ := GetLogsFromFile("../resources/events/logs2.json")
@@ -2337,6 +2405,7 @@ func TestMatchEvent3(t *testing.T) {
 }
 
 func TestMatchEvent2(t *testing.T) {
+	useLiveNodes(t)
 	js := `{
     "Filters": [
         {
@@ -2365,11 +2434,11 @@ func TestMatchEvent2(t *testing.T) {
     "TriggerName": "WAE",
     "TriggerType": "WatchEvents"
 }`
-	tg, err := NewTriggerFromJson(js)
+	tg, err := NewTriggerFromJson(js, nil)
 	assert.NoError(t, err)
 
 	var logs, _ = TokenApiMainnet.GetRPCCli().EthGetLogsByNumber(9099675, "0x080bf510fcbf18b91105470639e9561022937712")
-	matches := MatchEvent(tg, logs, []ethrpc.Transaction{}, mockTokenApi)
+	matches := MatchEvent(tg, logs, []tokenapi.Transaction{}, mockTokenApi)
 
 	assert.Equal(t, 1, len(matches))
 	assert.Equal(t, 9099675, matches[0].Log.BlockNumber)
@@ -2381,14 +2450,14 @@ func TestMatchEvent1(t *testing.T) {
 
 	tg1, err := GetTriggerFromFile("../resources/triggers/ev1.json")
 	assert.NoError(t, err)
-	matches1 := MatchEvent(tg1, logs, []ethrpc.Transaction{}, mockTokenApi)
+	matches1 := MatchEvent(tg1, logs, []tokenapi.Transaction{}, mockTokenApi)
 
 	assert.Equal(t, 1, len(matches1))
 	assert.Equal(t, "677420000", matches1[0].EventParams["value"])
 
 	tg2, err := GetTriggerFromFile("../resources/triggers/ev2.json")
 	assert.NoError(t, err)
-	matches2 := MatchEvent(tg2, logs, []ethrpc.Transaction{}, mockTokenApi)
+	matches2 := MatchEvent(tg2, logs, []tokenapi.Transaction{}, mockTokenApi)
 
 	assert.Equal(t, 3, len(matches2))
 	assert.Equal(t, "677420000", matches2[0].EventParams["value"])
@@ -2446,16 +2515,17 @@ func TestMatchEventEmitted(t *testing.T) {
   ]
 }`
 
-	tg, err := NewTriggerFromJson(js)
+	tg, err := NewTriggerFromJson(js, nil)
 	assert.NoError(t, err)
 
 	logs, _ := GetLogsFromFile("../resources/events/logs1.json")
 
-	matches := MatchEvent(tg, logs, []ethrpc.Transaction{}, mockTokenApi)
+	matches := MatchEvent(tg, logs, []tokenapi.Transaction{}, mockTokenApi)
 	assert.Equal(t, 7, len(matches))
 }
 
 func TestHandleNullTerminatedStrings(t *testing.T) {
+	useLiveNodes(t)
 
 	js := `
 {
@@ -2477,16 +2547,17 @@ func TestHandleNullTerminatedStrings(t *testing.T) {
   "TriggerType": "WatchEvents"
 }
 `
-	tg, err := NewTriggerFromJson(js)
+	tg, err := NewTriggerFromJson(js, nil)
 	assert.NoError(t, err)
 
 	var logs, _ = TokenApiMainnet.GetRPCCli().EthGetLogsByNumber(10679595, "0x9ceb5486eD0F3F2DBCaE906E4192472e88657983")
-	matches := MatchEvent(tg, logs, []ethrpc.Transaction{}, mockTokenApi)
+	matches := MatchEvent(tg, logs, []tokenapi.Transaction{}, mockTokenApi)
 
 	assert.Len(t, matches, 1)
 }
 
 func TestIntConversion(t *testing.T) {
+	useLiveNodes(t)
 
 	js := `
 {
@@ -2508,11 +2579,11 @@ func TestIntConversion(t *testing.T) {
   "TriggerType": "WatchEvents"
 }
 `
-	tg, err := NewTriggerFromJson(js)
+	tg, err := NewTriggerFromJson(js, nil)
 	assert.NoError(t, err)
 
 	var logs, _ = TokenApiMainnet.GetRPCCli().EthGetLogsByNumber(10696118, "0x1d681d76ce96E4d70a88A00EBbcfc1E47808d0b8")
-	matches := MatchEvent(tg, logs, []ethrpc.Transaction{}, mockTokenApi)
+	matches := MatchEvent(tg, logs, []tokenapi.Transaction{}, mockTokenApi)
 
 	assert.Len(t, matches, 1)
 	assert.Equal(t, "68", matches[0].EventParams["taskReceiptId"])
@@ -2520,6 +2591,7 @@ func TestIntConversion(t *testing.T) {
 }
 
 func TestBasicFilters(t *testing.T) {
+	useLiveNodes(t)
 
 	js := `
 {
@@ -2551,7 +2623,7 @@ func TestBasicFilters(t *testing.T) {
   "TriggerType": "WatchEvents"
 }
 `
-	tg, err := NewTriggerFromJson(js)
+	tg, err := NewTriggerFromJson(js, nil)
 	assert.NoError(t, err)
 
 	var logs, _ = TokenApiMainnet.GetRPCCli().EthGetLogsByNumber(11020360, "0xa4fc358455febe425536fd1878be67ffdbdec59a")
@@ -2563,6 +2635,7 @@ func TestBasicFilters(t *testing.T) {
 }
 
 func TestBasicFilters2(t *testing.T) {
+	useLiveNodes(t)
 
 	js := `
 {
@@ -2594,7 +2667,7 @@ func TestBasicFilters2(t *testing.T) {
   "TriggerType": "WatchEvents"
 }
 `
-	tg, err := NewTriggerFromJson(js)
+	tg, err := NewTriggerFromJson(js, nil)
 	assert.NoError(t, err)
 
 	var logs, _ = TokenApiMainnet.GetRPCCli().EthGetLogsByNumber(11020360, "0xa4fc358455febe425536fd1878be67ffdbdec59a")
@@ -2605,6 +2678,7 @@ func TestBasicFilters2(t *testing.T) {
 }
 
 func TestBasicFilters3(t *testing.T) {
+	useLiveNodes(t)
 
 	js := `
 {
@@ -2636,7 +2710,7 @@ func TestBasicFilters3(t *testing.T) {
  "TriggerType": "WatchEvents"
 }
 `
-	tg, err := NewTriggerFromJson(js)
+	tg, err := NewTriggerFromJson(js, nil)
 	assert.NoError(t, err)
 
 	var logs, _ = TokenApiMainnet.GetRPCCli().EthGetLogsByNumber(11020360, "0xa4fc358455febe425536fd1878be67ffdbdec59a")
@@ -2648,6 +2722,7 @@ func TestBasicFilters3(t *testing.T) {
 }
 
 func TestTxFromAndToWithoutBasicFilters(t *testing.T) {
+	useLiveNodes(t)
 
 	js := `
 {
@@ -2663,7 +2738,7 @@ func TestTxFromAndToWithoutBasicFilters(t *testing.T) {
  "TriggerType": "WatchEvents"
 }
 `
-	tg, err := NewTriggerFromJson(js)
+	tg, err := NewTriggerFromJson(js, nil)
 	assert.NoError(t, err)
 
 	var logs, _ = TokenApiMainnet.GetRPCCli().EthGetLogsByNumber(11020360, "0xa4fc358455febe425536fd1878be67ffdbdec59a")
@@ -2676,6 +2751,7 @@ func TestTxFromAndToWithoutBasicFilters(t *testing.T) {
 }
 
 func TestCurrencyWithExplicitCurrenciesData(t *testing.T) {
+	useLiveNodes(t)
 	// ParameterCurrency is an explicit token address (AAVE)
 	// ParameterName is in the DATA field as an uint256
 	js := `
@@ -2700,16 +2776,17 @@ func TestCurrencyWithExplicitCurrenciesData(t *testing.T) {
   "TriggerType": "WatchEvents"
 }
 `
-	tg, err := NewTriggerFromJson(js)
+	tg, err := NewTriggerFromJson(js, nil)
 	assert.NoError(t, err)
 
 	var logs, _ = TokenApiMainnet.GetRPCCli().EthGetLogsByNumber(11646700, "0xf5fab5dbd2f3bf675de4cb76517d4767013cfb55")
 
-	matches := MatchEvent(tg, logs, []ethrpc.Transaction{}, mockTApiCurr)
+	matches := MatchEvent(tg, logs, []tokenapi.Transaction{}, mockTApiCurr)
 	assert.Len(t, matches, 1)
 }
 
 func TestCurrencyWithImplicitCurrencyInTopic(t *testing.T) {
+	useLiveNodes(t)
 	// ParameterCurrency is an event field (_reserveToken) in the TOPIC
 	// ParameterName is in the DATA field as an uint256
 	js := `
@@ -2734,12 +2811,12 @@ func TestCurrencyWithImplicitCurrencyInTopic(t *testing.T) {
   "TriggerType": "WatchEvents"
 }
 `
-	tg, err := NewTriggerFromJson(js)
+	tg, err := NewTriggerFromJson(js, nil)
 	assert.NoError(t, err)
 
 	var logs, _ = TokenApiMainnet.GetRPCCli().EthGetLogsByNumber(11646700, "0xf5fab5dbd2f3bf675de4cb76517d4767013cfb55")
 
-	matches := MatchEvent(tg, logs, []ethrpc.Transaction{}, mockTApiCurr)
+	matches := MatchEvent(tg, logs, []tokenapi.Transaction{}, mockTApiCurr)
 	assert.Len(t, matches, 1)
 
 	// make sure we DO NOT modify ParameterCurrency. Why?
@@ -2749,6 +2826,7 @@ func TestCurrencyWithImplicitCurrencyInTopic(t *testing.T) {
 }
 
 func TestCurrencyWithImplicitCurrencyInData(t *testing.T) {
+	useLiveNodes(t)
 	// ParameterCurrency is an event field (token) in the DATA
 	// ParameterName is in the DATA field as an uint96
 	js := `
@@ -2773,12 +2851,12 @@ func TestCurrencyWithImplicitCurrencyInData(t *testing.T) {
   "TriggerType": "WatchEvents"
 }
 `
-	tg, err := NewTriggerFromJson(js)
+	tg, err := NewTriggerFromJson(js, nil)
 	assert.NoError(t, err)
 
 	var logs, _ = TokenApiMainnet.GetRPCCli().EthGetLogsByNumber(11842974, "0x0BABA1Ad5bE3a5C0a66E7ac838a129Bf948f1eA4")
 
-	matches := MatchEvent(tg, logs, []ethrpc.Transaction{}, mockTApiCurr)
+	matches := MatchEvent(tg, logs, []tokenapi.Transaction{}, mockTApiCurr)
 	assert.Len(t, matches, 1)
 
 	// make sure we DO NOT modify ParameterCurrency
@@ -2786,6 +2864,7 @@ func TestCurrencyWithImplicitCurrencyInData(t *testing.T) {
 }
 
 func TestCurrencyWithExplicitCurrenciesDataArray(t *testing.T) {
+	useLiveNodes(t)
 	// ParameterCurrency is an explicit token address (AAVE)
 	// ParameterName is in the DATA field as an []int
 
@@ -2811,10 +2890,10 @@ func TestCurrencyWithExplicitCurrenciesDataArray(t *testing.T) {
         "TriggerType": "WatchEvents"
     }`
 
-	tg, err := NewTriggerFromJson(js)
+	tg, err := NewTriggerFromJson(js, nil)
 	assert.NoError(t, err)
 
-	matches := MatchEvent(tg, logs551, []ethrpc.Transaction{}, mockTApiCurr)
+	matches := MatchEvent(tg, logs551, []tokenapi.Transaction{}, mockTApiCurr)
 
 	assert.Equal(t, 1, len(matches))
 }
@@ -2842,16 +2921,17 @@ func TestCurrencyWithExplicitCurrenciesTopicInt(t *testing.T) {
     "TriggerName": "WAE",
     "TriggerType": "WatchEvents"
 }`
-	tg, err := NewTriggerFromJson(js)
+	tg, err := NewTriggerFromJson(js, nil)
 	assert.NoError(t, err)
 
 	logs, _ := GetLogsFromFile("../resources/events/logs2.json")
-	matches := MatchEvent(tg, logs, []ethrpc.Transaction{}, mockTApiCurr)
+	matches := MatchEvent(tg, logs, []tokenapi.Transaction{}, mockTApiCurr)
 
 	assert.Equal(t, 1, len(matches))
 }
 
 func TestAllERC20swaps(t *testing.T) {
+	useLiveNodes(t)
 	triggerJson := `
 {
   "Filters": [
@@ -2873,9 +2953,9 @@ func TestAllERC20swaps(t *testing.T) {
 }
 `
 	blockNumber := 12271057
-	var tapi = tokenapi.New(tokenapi.NewZRPC(config.Zconf.EthNode, "test"))
+	var tapi = tokenapi.MustNew(tokenapi.NewZRPC(testConf.EthNode, "test"), tokenapi.WithConfig(testConf))
 
-	tg, err := NewTriggerFromJson(triggerJson)
+	tg, err := NewTriggerFromJson(triggerJson, nil)
 	assert.NoError(t, err)
 
 	block, err := tapi.GetRPCCli().EthGetBlockByNumber(blockNumber, true)
@@ -2900,7 +2980,7 @@ func TestAllERC20swaps(t *testing.T) {
   "TriggerName": "erc20 uni ",
   "TriggerType": "WatchEvents"
 }`
-	tg, err = NewTriggerFromJson(triggerJson)
+	tg, err = NewTriggerFromJson(triggerJson, nil)
 	assert.NoError(t, err)
 
 	matches = MatchEvent(tg, logs, block.Transactions, tapi)
@@ -9,34 +9,28 @@ import (
 	"sync"
 )
 
-func MatchTriggersMulti(tgs []*Trigger, api tokenapi.ITokenAPI, blockNo int) ([]*CnMatch, []string, error) {
+// A Multicaller batches calls through the multicall contract deployed at Address.
+// It learns how big a batch the node can take, so keep one per network and node
+// instead of rediscovering the limits on every block.
+type Multicaller struct {
+	Address string
+	chunks  *chunkSizer
+}
 
-	// ATM the multicall library doesn't support tuples.
-	// We could extend it to support them, either natively or by passing the whole ABI to the multicall;
-	// for now we're in a hurry and we just use normal calls for those triggers.
-	tupleTgs, otherTgs := separateTupleTgs(tgs)
+func NewMulticaller(address string) *Multicaller {
+	return &Multicaller{Address: address, chunks: newChunkSizer(50, 1, 500)}
+}
 
-	var cnMatches []*CnMatch
-	var tgsWithErrorsUUIDs []string
+// MatchTriggersMulti matches all the WaC triggers on blockNo,
+// batching their calls through mc.
+func MatchTriggersMulti(tgs []*Trigger, api tokenapi.ITokenAPI, blockNo int, mc *Multicaller) ([]*CnMatch, []string) {
 
-	// single call, for triggers with tuples
-	for _, tg := range tupleTgs {
-		match, err := MatchContract(api, tg, blockNo)
-		if err != nil {
-			tgsWithErrorsUUIDs = append(tgsWithErrorsUUIDs, tg.TriggerUUID)
-		} else if match != nil {
-			cnMatches = append(cnMatches, match)
-		}
-	}
+	resMap := runMulticallForTriggers(tgs, blockNo, api, mc)
 
-	// multicall, for all other triggers
-	resMap, err := runMulticallForTriggers(otherTgs, blockNo, api)
-	if err != nil {
-		log.Warnf("MatchTriggersMulti failed: %s", err)
-		return []*CnMatch{}, []string{}, err
-	}
+	var cnMatches []*CnMatch
+	var tgsWithErrorsUUIDs []string
 
-	for _, tg := range otherTgs {
+	for _, tg := range tgs {
 		res, found := resMap.Calls[tg.getKey()]
 		if found && res.Success {
 			match := matchTriggerWithResult(tg, res.Decoded, api)
@@ -44,20 +38,56 @@ func MatchTriggersMulti(tgs []*Trigger, api tokenapi.ITokenAPI, blockNo int) ([]
 				cnMatches = append(cnMatches, match)
 			}
 		}
-		if found && !res.Success {
+		// the call failed, or we couldn't even build it
+		if !found || !res.Success {
 			tgsWithErrorsUUIDs = append(tgsWithErrorsUUIDs, tg.TriggerUUID)
 		}
 	}
 
-	return cnMatches, tgsWithErrorsUUIDs, nil
+	return cnMatches, tgsWithErrorsUUIDs
 }
 
-func runMulticallForTriggers(tgs []*Trigger, blockNo int, api tokenapi.ITokenAPI) (*multicall.Result, error) {
+// chunkSizer adapts the size of the multicall batches to the gas and response size limits of the node:
+// the size is halved every time a batch fails and grows back slowly after each successful batch.
+type chunkSizer struct {
+	sync.Mutex
+	size int
+	min  int
+	max  int
+}
 
-	mc, err := multicall.New(api.GetRPCCli())
-	if err != nil {
-		return nil, fmt.Errorf("create mu cli: %s", err)
+func newChunkSizer(size, min, max int) *chunkSizer {
+	return &chunkSizer{size: size, min: min, max: max}
+}
+
+func (cs *chunkSizer) get() int {
+	cs.Lock()
+	defer cs.Unlock()
+	return cs.size
+}
+
+func (cs *chunkSizer) grow() {
+	cs.Lock()
+	defer cs.Unlock()
+	cs.size += cs.size/10 + 1
+	if cs.size > cs.max {
+		cs.size = cs.max
 	}
+}
+
+// shrink makes sure the next batches are smaller than the failed one
+func (cs *chunkSizer) shrink(failedSize int) {
+	cs.Lock()
+	defer cs.Unlock()
+	if failedSize/2 < cs.size {
+		cs.size = failedSize / 2
+	}
+	if cs.size < cs.min {
+		cs.size = cs.min
+	}
+}
+
+func runMulticallForTriggers(tgs []*Trigger, blockNo int, api tokenapi.ITokenAPI, mc *Multicaller) *multicall.Result {
 
 	views := makeDistinctViews(tgs)
 	log.Info("MUL Distinct views: ", len(views))
@@ -66,95 +96,104 @@ func runMulticallForTriggers(tgs []*Trigger, blockNo int, api tokenapi.ITokenAPI
 	var finalRes multicall.Result
 	finalRes.Calls = make(map[string]multicall.CallResult, len(views))
 
-	chunks := chunkViews(views, 50)
+	chunks := chunkViews(views, mc.chunks.get())
 
 	chunkResults := make(chan *multicall.Result, len(chunks))
-	chunkErrors := make(chan error, len(chunks))
 	var wg sync.WaitGroup
 
 	for _, chunk := range chunks {
 		wg.Add(1)
-		go func(cn []multicall.ViewCall) {
+		go func(cn []viewCall) {
 			defer wg.Done()
-			chunkResult, err := mc.Call(cn, fmt.Sprintf("0x%x", blockNo))
-			if err != nil {
-				chunkErrors <- fmt.Errorf("mc call failed: %s", err)
-			}
-			chunkResults <- chunkResult
+			chunkResults <- aggregateAdaptive(api.GetRPCCli(), mc.Address, cn, blockNo, mc.chunks)
 		}(chunk)
 	}
 	wg.Wait()
-	close(chunkErrors)
 	close(chunkResults)
 
-	log.Infof("mul calls succeeded: %d; failed: %d ", len(chunkResults), len(chunkErrors))
-
-	if len(chunkErrors) != 0 {
-		return nil, <-chunkErrors
-	}
 	for r := range chunkResults {
+		finalRes.BlockNumber = r.BlockNumber
 		for k, v := range r.Calls {
 			finalRes.Calls[k] = v
 		}
 	}
+	log.Infof("mul calls: %d chunks; next chunk size: %d", len(chunks), mc.chunks.get())
 	log.Debug("Total no of calls: ", len(finalRes.Calls))
 
-	return &finalRes, nil
+	return &finalRes
 }
 
-func makeViewFromTrigger(tg *Trigger) (multicall.ViewCall, error) {
+// aggregateAdaptive runs calls in a single multicall;
+// if the batch fails it's split in half and each half is retried on its own,
+// down to a single call, which is then executed with a plain eth_call.
+func aggregateAdaptive(cli tokenapi.IEthRpc, mcAddress string, calls []viewCall, blockNo int, cs *chunkSizer) *multicall.Result {
 
-	viewMethod, err := makeViewMethod(tg)
-	if err != nil {
-		return multicall.ViewCall{}, err
+	if len(calls) == 0 {
+		return &multicall.Result{BlockNumber: uint64(blockNo), Calls: map[string]multicall.CallResult{}}
 	}
 
-	inputs := make([]tokenapi.Input, len(tg.Inputs))
-	for i, tgin := range tg.Inputs {
-		inputs[i].ParameterValue = tgin.ParameterValue
-		inputs[i].ParameterType = tgin.ParameterType
+	res, err := aggregate(cli, mcAddress, calls, blockNo)
+	if err == nil {
+		cs.grow()
+		return res
 	}
-	args, err := tokenapi.MakeObjectsFromInput(inputs)
+	log.Debugf("mc call with %d views failed: %s", len(calls), err)
+
+	if len(calls) == 1 {
+		return callOneByOne(cli, calls, blockNo)
+	}
+	cs.shrink(len(calls))
 
-	vc := multicall.NewViewCall(tg.getKey(), strings.ToLower(tg.ContractAdd), viewMethod, args)
-	return vc, vc.Validate()
+	mid := len(calls) / 2
+	res = aggregateAdaptive(cli, mcAddress, calls[:mid], blockNo, cs)
+	for k, v := range aggregateAdaptive(cli, mcAddress, calls[mid:], blockNo, cs).Calls {
+		res.Calls[k] = v
+	}
+	return res
 }
 
-func makeViewMethod(tg *Trigger) (string, error) {
+func makeViewFromTrigger(tg *Trigger) (viewCall, error) {
 
 	abiObj, err := tg.getABIObj()
 	if err != nil {
-		return "", fmt.Errorf("invalid abi for tg %s", tg.TriggerUUID)
+		return viewCall{}, fmt.Errorf("invalid abi for tg %s", tg.TriggerUUID)
 	}
 
 	method, ok := abiObj.Methods[tg.FunctionName]
 	if !ok {
-		return "", fmt.Errorf("function %s not found", tg.FunctionName)
+		return viewCall{}, fmt.Errorf("function %s not found", tg.FunctionName)
+	}
+	if len(method.Inputs) != len(tg.Inputs) {
+		return viewCall{}, fmt.Errorf("invalid number of arguments for method %s - expected %d, got %d", tg.FunctionName, len(method.Inputs), len(tg.Inputs))
 	}
 
-	var inputTypes, outputTypes string
-
-	for _, in := range method.Inputs {
-		if len(inputTypes) != 0 {
-			inputTypes += ","
-		}
-		inputTypes += in.Type.String()
+	// input types come from the ABI, so the packed arguments always match the method
+	inputs := make([]tokenapi.Input, len(tg.Inputs))
+	for i, tgin := range tg.Inputs {
+		inputs[i].ParameterValue = tgin.ParameterValue
+		inputs[i].ParameterType = method.Inputs[i].Type.String()
+	}
+	args, err := tokenapi.MakeObjectsFromInput(inputs)
+	if err != nil {
+		return viewCall{}, err
 	}
 
-	for _, out := range method.Outputs {
-		if len(outputTypes) != 0 {
-			outputTypes += ","
-		}
-		outputTypes += out.Type.String()
+	callData, err := abiObj.Pack(tg.FunctionName, args...)
+	if err != nil {
+		return viewCall{}, err
 	}
-	//outputTypes = strings.ReplaceAll(strings.ReplaceAll(outputTypes, "(", ""), ")", "")
 
-	return fmt.Sprintf("%s(%s)(%s)", tg.FunctionName, inputTypes, outputTypes), nil
+	return viewCall{
+		key:      tg.getKey(),
+		target:   strings.ToLower(tg.ContractAdd),
+		callData: callData,
+		method:   method,
+	}, nil
 }
 
-func chunkViews(slice []multicall.ViewCall, chunkSize int) [][]multicall.ViewCall {
+func chunkViews(slice []viewCall, chunkSize int) [][]viewCall {
 
-	var chunks = make([][]multicall.ViewCall, 0)
+	var chunks = make([][]viewCall, 0)
 
 	beg := 0
 	for beg+chunkSize < len(slice) {
@@ -167,9 +206,9 @@ func chunkViews(slice []multicall.ViewCall, chunkSize int) [][]multicall.ViewCal
 }
 
 // triggers with the same key have identical call arguments, so we treat them as such
-func makeDistinctViews(tgs []*Trigger) multicall.ViewCalls {
+func makeDistinctViews(tgs []*Trigger) []viewCall {
 	var distinctViews = make(map[string]struct{})
-	var views multicall.ViewCalls
+	var views []viewCall
 	for _, tg := range tgs {
 		_, found := distinctViews[tg.getKey()]
 		if !found {
@@ -182,19 +221,3 @@ func makeDistinctViews(tgs []*Trigger) multicall.ViewCalls {
 	}
 	return views
 }
-
-func separateTupleTgs(tgs []*Trigger) (tupleTgs []*Trigger, otherTgs []*Trigger) {
-
-	for _, tg := range tgs {
-		for _, o := range tg.Outputs {
-			if o.ReturnType == "tuple" {
-				tupleTgs = append(tupleTgs, tg)
-				continue
-			} else {
-				otherTgs = append(otherTgs, tg)
-				continue
-			}
-		}
-	}
-	return tupleTgs, otherTgs
-}
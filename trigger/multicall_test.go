@@ -1,11 +1,14 @@
 package trigger
 
 import (
+	"fmt"
 	"github.com/HAL-xyz/web3-multicall-go/multicall"
 	"github.com/HAL-xyz/zoroaster/tokenapi"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/stretchr/testify/assert"
 	"math/big"
+	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -38,23 +41,20 @@ func TestMakeViewFromTrigger(t *testing.T) {
   "FunctionName": "balanceOf"
 }
 `
-	tg1, err := NewTriggerFromJson(js1)
+	tg1, err := NewTriggerFromJson(js1, nil)
 	assert.NoError(t, err)
 
 	view, err := makeViewFromTrigger(tg1)
 	assert.NoError(t, err)
 
-	expectedView := multicall.NewViewCall(
-		"balanceOf+0x1f9840a85d5af5bf1d1762f925bdaddc4201f984+0x41ac4e73e8dE10E9A902785989Fbc28E7cdc5abC",
-		"0x1f9840a85d5af5bf1d1762f925bdaddc4201f984",
-		"balanceOf(address)(uint256)",
-		[]interface{}{common.HexToAddress("0x41ac4e73e8dE10E9A902785989Fbc28E7cdc5abC")},
-	)
-
-	assert.Equal(t, expectedView, view)
+	assert.Equal(t, "balanceOf+0x1f9840a85d5af5bf1d1762f925bdaddc4201f984+0x41ac4e73e8dE10E9A902785989Fbc28E7cdc5abC", view.key)
+	assert.Equal(t, "0x1f9840a85d5af5bf1d1762f925bdaddc4201f984", view.target)
+	assert.Equal(t, "70a0823100000000000000000000000041ac4e73e8de10e9a902785989fbc28e7cdc5abc", common.Bytes2Hex(view.callData))
+	assert.Equal(t, "balanceOf", view.method.Name)
 }
 
 func TestMatchTriggersMulti(t *testing.T) {
+	useLiveNodes(t)
 
 	js1 := `
 {
@@ -130,18 +130,18 @@ func TestMatchTriggersMulti(t *testing.T) {
 }
 `
 
-	tg1, err := NewTriggerFromJson(js1)
+	tg1, err := NewTriggerFromJson(js1, nil)
 	assert.NoError(t, err)
 
-	tg2, err := NewTriggerFromJson(js2)
+	tg2, err := NewTriggerFromJson(js2, nil)
 	assert.NoError(t, err)
 
 	// create a different trigger from the same json as tg2
-	tg3, err := NewTriggerFromJson(js2)
+	tg3, err := NewTriggerFromJson(js2, nil)
 	assert.NoError(t, err)
 
 	// create a trigger that makes a call that always fails
-	tgErr, err := NewTriggerFromJson(errorTgJsn)
+	tgErr, err := NewTriggerFromJson(errorTgJsn, nil)
 	assert.NoError(t, err)
 
 	tgs := []*Trigger{tg1, tg2, tg3, tgErr}
@@ -150,8 +150,7 @@ func TestMatchTriggersMulti(t *testing.T) {
 	assert.Equal(t, 3, len(makeDistinctViews(tgs)))
 
 	// test the multicall only
-	res, err := runMulticallForTriggers(tgs, lastBlockMainnet, tokenapi.GetTokenAPI())
-	assert.NoError(t, err)
+	res := runMulticallForTriggers(tgs, lastBlockMainnet, TokenApiMainnet, NewMulticaller(multicallAddresses["1_eth_mainnet"]))
 
 	// we only have 3 results, since tg3 == tg2
 	assert.Equal(t, 3, len(res.Calls))
@@ -166,14 +165,14 @@ func TestMatchTriggersMulti(t *testing.T) {
 	assert.Equal(t, true, res.Calls[tg3.getKey()].Success)
 
 	// Test Trigger -> multicall -> Matches
-	matches, tgsWithErrors, err := MatchTriggersMulti(tgs, tokenapi.GetTokenAPI(), lastBlockMainnet)
+	matches, tgsWithErrors := MatchTriggersMulti(tgs, TokenApiMainnet, lastBlockMainnet, NewMulticaller(multicallAddresses["1_eth_mainnet"]))
 	assert.Equal(t, 3, len(matches))
 	assert.Equal(t, 1, len(tgsWithErrors))
-	assert.NoError(t, err)
 
 }
 
 func TestMatchTriggerWithTuple(t *testing.T) {
+	useLiveNodes(t)
 
 	js1 := `
 {
@@ -260,20 +259,271 @@ func TestMatchTriggerWithTuple(t *testing.T) {
   "FunctionName": "getReserveData"
 }`
 
-	tg1, err := NewTriggerFromJson(js1)
+	tg1, err := NewTriggerFromJson(js1, nil)
 	assert.NoError(t, err)
 
-	tg2, err := NewTriggerFromJson(js2)
+	tg2, err := NewTriggerFromJson(js2, nil)
 	assert.NoError(t, err)
 
-	tg3, err := NewTriggerFromJson(js3)
+	tg3, err := NewTriggerFromJson(js3, nil)
 	assert.NoError(t, err)
 
 	tgs := []*Trigger{tg1, tg2, tg3}
 
-	matches, tgsWithErrors, err := MatchTriggersMulti(tgs, tokenapi.GetTokenAPI(), lastBlockMainnet)
+	matches, tgsWithErrors := MatchTriggersMulti(tgs, TokenApiMainnet, lastBlockMainnet, NewMulticaller(multicallAddresses["1_eth_mainnet"]))
 	assert.Equal(t, 2, len(matches))
 	assert.Equal(t, 1, len(tgsWithErrors))
+
+}
+
+// answers multicalls (and single calls, when the multicall reverts)
+// as if every call returned the same data
+type mockMulticallCli struct {
+	tokenapi.IEthRpc
+	data     []byte
+	revert   bool
+	maxBatch int    // batches bigger than this revert, as if they ran out of gas
+	failing  string // calls to this contract always fail
+	batches  *[]int // the size of every multicall we've been asked to run
+}
+
+func (cli mockMulticallCli) MakeEthRpcCall(cntAddress, data string, blockNumber int) (string, error) {
+	results := make([]struct {
+		Success bool
+		Data    []byte
+	}, 0)
+
+	var calls reflect.Value
+	switch cntAddress {
+	case multicall3Address:
+		unpacked, err := aggregate3Args.UnpackValues(common.FromHex(data)[4:])
+		if err != nil {
+			return "", err
+		}
+		calls = reflect.ValueOf(unpacked[0])
+	case multicallAddresses["1_eth_mainnet"]:
+		unpacked, err := halAggregateArgs.UnpackValues(common.FromHex(data)[4:])
+		if err != nil {
+			return "", err
+		}
+		calls = reflect.ValueOf(unpacked[0])
+	default:
+		if strings.EqualFold(cntAddress, cli.failing) {
+			return "0x", nil
+		}
+		return "0x" + common.Bytes2Hex(cli.data), nil
+	}
+
+	if cli.batches != nil {
+		*cli.batches = append(*cli.batches, calls.Len())
+	}
+	if cli.revert || (cli.maxBatch > 0 && calls.Len() > cli.maxBatch) {
+		return "", fmt.Errorf("execution reverted")
+	}
+	for i := 0; i < calls.Len(); i++ {
+		target := calls.Index(i).FieldByName("Target").Interface().(common.Address)
+		success := cli.failing == "" || target != common.HexToAddress(cli.failing)
+		results = append(results, struct {
+			Success bool
+			Data    []byte
+		}{success, cli.data})
+	}
+
+	var packed []byte
+	var err error
+	if cntAddress == multicall3Address {
+		packed, err = aggregate3Returns.Pack(results)
+	} else {
+		packed, err = halAggregateReturns.Pack(big.NewInt(int64(blockNumber)), results)
+	}
+	return "0x" + common.Bytes2Hex(packed), err
+}
+
+func (cli mockMulticallCli) GetLabel() string {
+	return "mock multicall"
+}
+
+func TestMatchTriggersMultiWithNestedTuple(t *testing.T) {
+
+	js := `
+{
+  "Inputs": [
+    {
+      "ParameterType": "address",
+      "ParameterValue": "0xA0b86991c6218b36c1d19D4a2e9Eb0cE3606eB48"
+    }
+  ],
+  "Outputs": [
+    {
+      "Condition": {
+        "Attribute": "1",
+        "Predicate": "BiggerThan"
+      },
+      "ReturnType": "tuple",
+      "ReturnIndex": 0,
+      "Component": {
+        "Name": "currentLiquidityRate",
+        "Type": "uint128"
+      }
+    }
+  ],
+  "ContractABI": "[{\"inputs\":[{\"internalType\":\"address\",\"name\":\"asset\",\"type\":\"address\"}],\"name\":\"getReserveData\",\"outputs\":[{\"components\":[{\"components\":[{\"internalType\":\"uint256\",\"name\":\"data\",\"type\":\"uint256\"}],\"internalType\":\"struct ReserveConfigurationMap\",\"name\":\"configuration\",\"type\":\"tuple\"},{\"internalType\":\"uint128\",\"name\":\"currentLiquidityRate\",\"type\":\"uint128\"},{\"internalType\":\"address\",\"name\":\"aTokenAddress\",\"type\":\"address\"}],\"internalType\":\"struct ReserveData\",\"name\":\"\",\"type\":\"tuple\"}],\"stateMutability\":\"view\",\"type\":\"function\"}]",
+  "ContractAdd": "0x7d2768dE32b0b80b7a3454c06BdAc94A69DDc7A9",
+  "TriggerName": "aave liquidity rate",
+  "TriggerType": "WatchContracts",
+  "FunctionName": "getReserveData"
+}`
+
+	tg, err := NewTriggerFromJson(js, nil)
 	assert.NoError(t, err)
 
+	view, err := makeViewFromTrigger(tg)
+	assert.NoError(t, err)
+
+	reserveData := struct {
+		Configuration struct {
+			Data *big.Int
+		}
+		CurrentLiquidityRate *big.Int
+		ATokenAddress        common.Address
+	}{
+		CurrentLiquidityRate: big.NewInt(42),
+		ATokenAddress:        common.HexToAddress("0xbcca60bb61934080951369a648fb03df4f96263c"),
+	}
+	reserveData.Configuration.Data = big.NewInt(7)
+	returnData, err := view.method.Outputs.Pack(reserveData)
+	assert.NoError(t, err)
+
+	api := tokenapi.MustNew(mockMulticallCli{data: returnData})
+
+	// every network where we have a multicall contract
+	for _, network := range []string{"1_eth_mainnet", "3_xdai_mainnet", "4_binance_mainnet", "5_polygon_mainnet"} {
+		mcAddress, ok := MulticallAddress(network)
+		assert.True(t, ok)
+
+		res := runMulticallForTriggers([]*Trigger{tg}, 12000000, api, NewMulticaller(mcAddress))
+		assert.Equal(t, uint64(12000000), res.BlockNumber)
+		assert.True(t, res.Calls[tg.getKey()].Success)
+
+		matches, tgsWithErrors := MatchTriggersMulti([]*Trigger{tg}, api, 12000000, NewMulticaller(mcAddress))
+		assert.Len(t, tgsWithErrors, 0)
+		assert.Len(t, matches, 1)
+		assert.Equal(t, []string{"42"}, matches[0].MatchedValues)
+	}
+
+	_, ok := MulticallAddress("2_rinkeby")
+	assert.False(t, ok)
+}
+
+func TestMatchTriggersMultiFallsBackToSingleCalls(t *testing.T) {
+
+	tg, err := GetTriggerFromFile("../resources/triggers/wac1.json")
+	assert.NoError(t, err)
+
+	// the multicall reverts, but every single call returns the same address
+	returnData := common.LeftPadBytes(common.HexToAddress("0x4a574510c7014e4ae985403536074abe582adfc8").Bytes(), 32)
+	api := tokenapi.MustNew(mockMulticallCli{data: returnData, revert: true})
+
+	res := runMulticallForTriggers([]*Trigger{tg}, 12000000, api, NewMulticaller(multicall3Address))
+	assert.True(t, res.Calls[tg.getKey()].Success)
+	assert.Equal(t, common.HexToAddress("0x4a574510c7014e4ae985403536074abe582adfc8"), res.Calls[tg.getKey()].Decoded[0])
+
+	matches, tgsWithErrors := MatchTriggersMulti([]*Trigger{tg}, api, 12000000, NewMulticaller(multicall3Address))
+	assert.Len(t, matches, 1)
+	assert.Len(t, tgsWithErrors, 0)
+
+	// a single call returning 0x is an error for that trigger only
+	api = tokenapi.MustNew(mockMulticallCli{data: []byte{}, revert: true})
+	matches, tgsWithErrors = MatchTriggersMulti([]*Trigger{tg}, api, 12000000, NewMulticaller(multicall3Address))
+	assert.Len(t, matches, 0)
+	assert.Equal(t, []string{tg.TriggerUUID}, tgsWithErrors)
+}
+
+// n distinct triggers, one per contract address
+func makeDistinctTriggers(t *testing.T, n int) []*Trigger {
+	var tgs []*Trigger
+	for i := 0; i < n; i++ {
+		tg, err := GetTriggerFromFile("../resources/triggers/wac1.json")
+		assert.NoError(t, err)
+		tg.TriggerUUID = fmt.Sprintf("uuid-%d", i)
+		tg.ContractAdd = common.BigToAddress(big.NewInt(int64(i + 1))).Hex()
+		tgs = append(tgs, tg)
+	}
+	return tgs
+}
+
+func TestMatchTriggersMultiIsolatesFailingCalls(t *testing.T) {
+
+	tgs := makeDistinctTriggers(t, 10)
+	returnData := common.LeftPadBytes(common.HexToAddress("0x4a574510c7014e4ae985403536074abe582adfc8").Bytes(), 32)
+
+	for _, mcAddress := range []string{multicall3Address, multicall.MainnetAddress} {
+		api := tokenapi.MustNew(mockMulticallCli{data: returnData, failing: tgs[3].ContractAdd})
+
+		matches, tgsWithErrors := MatchTriggersMulti(tgs, api, 12000000, NewMulticaller(mcAddress))
+		assert.Len(t, matches, 9)
+		assert.Equal(t, []string{"uuid-3"}, tgsWithErrors)
+	}
+}
+
+func TestMulticallersLearnOnTheirOwn(t *testing.T) {
+
+	tgs := makeDistinctTriggers(t, 10)
+	returnData := common.LeftPadBytes(common.HexToAddress("0x4a574510c7014e4ae985403536074abe582adfc8").Bytes(), 32)
+
+	// a node that can't take a batch of 10 only shrinks the batches of its own multicaller
+	limited, other := NewMulticaller(multicall3Address), NewMulticaller(multicall3Address)
+	api := tokenapi.MustNew(mockMulticallCli{data: returnData, maxBatch: 5})
+	_, tgsWithErrors := MatchTriggersMulti(tgs, api, 12000000, limited)
+	assert.Len(t, tgsWithErrors, 0)
+	assert.Less(t, limited.chunks.get(), 10)
+	assert.Equal(t, 50, other.chunks.get())
+}
+
+func TestAggregateAdaptive(t *testing.T) {
+
+	tgs := makeDistinctTriggers(t, 10)
+	views := makeDistinctViews(tgs)
+	returnData := common.LeftPadBytes(common.HexToAddress("0x4a574510c7014e4ae985403536074abe582adfc8").Bytes(), 32)
+
+	// the node can't handle more than 3 calls at once: 10 -> 5 + 5 -> 2 + 3 + 2 + 3
+	var batches []int
+	cs := newChunkSizer(10, 1, 100)
+	cli := mockMulticallCli{data: returnData, maxBatch: 3, batches: &batches}
+
+	res := aggregateAdaptive(cli, multicall3Address, views, 12000000, cs)
+	assert.Len(t, res.Calls, 10)
+	for _, tg := range tgs {
+		assert.True(t, res.Calls[tg.getKey()].Success)
+	}
+	assert.Equal(t, []int{10, 5, 2, 3, 5, 2, 3}, batches)
+
+	// shrunk to 2 after each failure, then grown back by the successful batches
+	assert.Equal(t, 4, cs.get())
+
+	// when even a batch of one fails we fall back to a plain eth_call
+	batches = nil
+	cli = mockMulticallCli{data: returnData, revert: true, batches: &batches}
+	res = aggregateAdaptive(cli, multicall3Address, views[:2], 12000000, cs)
+	assert.Equal(t, []int{2, 1, 1}, batches)
+	assert.True(t, res.Calls[tgs[0].getKey()].Success)
+	assert.True(t, res.Calls[tgs[1].getKey()].Success)
+}
+
+func TestChunkSizer(t *testing.T) {
+
+	cs := newChunkSizer(50, 1, 60)
+	cs.grow()
+	assert.Equal(t, 56, cs.get())
+	cs.grow()
+	assert.Equal(t, 60, cs.get())
+
+	// a bigger batch failing doesn't affect smaller chunk sizes
+	cs.shrink(200)
+	assert.Equal(t, 60, cs.get())
+
+	cs.shrink(60)
+	assert.Equal(t, 30, cs.get())
+	cs.shrink(1)
+	assert.Equal(t, 1, cs.get())
 }
@@ -0,0 +1,102 @@
+package trigger
+
+import (
+	"github.com/HAL-xyz/ethrpc"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"math/big"
+	"strings"
+)
+
+// the ERC-1155 TransferBatch event, whatever its parameters are called
+var transferBatchSignature = crypto.Keccak256Hash([]byte("TransferBatch(address,address,address,uint256[],uint256[])")).Hex()
+
+// ERC-20 and ERC-721 share the Transfer and Approval events, but ERC-721 indexes the token id too:
+// their logs have 3 topics for an ERC-20 token and 4 for an ERC-721 collection
+var (
+	transferSignature = crypto.Keccak256Hash([]byte("Transfer(address,address,uint256)")).Hex()
+	approvalSignature = crypto.Keccak256Hash([]byte("Approval(address,address,uint256)")).Hex()
+)
+
+// isERC20Log tells an ERC-20 log from an ERC-721 one, without asking the contract
+func isERC20Log(evLog *ethrpc.Log) bool {
+	return len(evLog.Topics) == 3 && (evLog.Topics[0] == transferSignature || evLog.Topics[0] == approvalSignature)
+}
+
+// expandLog returns the logs a trigger is matched against.
+// That's only the log itself, except for TransferBatch events filtered on a single id or value
+// (e.g. `id` or `_value`, as in TransferSingle): those are expanded into a batch of one for each id,
+// so that a batch transfer matches on an individual token id.
+func expandLog(evLog *ethrpc.Log, tg *Trigger, abiObj *abi.ABI) []*ethrpc.Log {
+	event := abiObj.Events[tg.eventName()]
+	if !isTransferBatch(event) || !filtersSingleTransfer(tg, event) {
+		return []*ethrpc.Log{evLog}
+	}
+	args := event.Inputs.NonIndexed()
+	values, err := args.Unpack(common.FromHex(evLog.Data))
+	if err != nil || len(values) != 2 {
+		return []*ethrpc.Log{evLog}
+	}
+	ids, ok1 := values[0].([]*big.Int)
+	amounts, ok2 := values[1].([]*big.Int)
+	if !ok1 || !ok2 || len(ids) != len(amounts) {
+		return []*ethrpc.Log{evLog}
+	}
+
+	expanded := make([]*ethrpc.Log, 0, len(ids))
+	for i := range ids {
+		data, err := args.Pack([]*big.Int{ids[i]}, []*big.Int{amounts[i]})
+		if err != nil {
+			return []*ethrpc.Log{evLog}
+		}
+		single := *evLog
+		single.Data = hexutil.Encode(data)
+		expanded = append(expanded, &single)
+	}
+	return expanded
+}
+
+func isTransferBatch(event abi.Event) bool {
+	return event.ID.Hex() == transferBatchSignature
+}
+
+// filtersSingleTransfer tells if any of the trigger's filters is on a single id or value
+func filtersSingleTransfer(tg *Trigger, event abi.Event) bool {
+	singleNames := map[string]bool{}
+	for _, input := range event.Inputs.NonIndexed() {
+		if name := singularName(input.Name); name != input.Name {
+			singleNames[name] = true
+		}
+	}
+	for _, f := range tg.Filters {
+		if f.FilterType == "CheckEventParameter" && singleNames[f.ParameterName] {
+			return true
+		}
+	}
+	return false
+}
+
+// addSingleTransferParams adds the parameters of a TransferSingle event (e.g. `id` and `value`)
+// to a decoded TransferBatch of one; this is what an expanded batch looks like.
+func addSingleTransferParams(decodedData map[string]interface{}, event abi.Event) {
+	if !isTransferBatch(event) {
+		return
+	}
+	for _, input := range event.Inputs.NonIndexed() {
+		name := singularName(input.Name)
+		vals, ok := decodedData[input.Name].([]*big.Int)
+		if name == input.Name || !ok || len(vals) != 1 {
+			continue
+		}
+		if _, taken := decodedData[name]; !taken {
+			decodedData[name] = vals[0]
+		}
+	}
+}
+
+// ids -> id, _values -> _value
+func singularName(name string) string {
+	return strings.TrimSuffix(name, "s")
+}
@@ -0,0 +1,167 @@
+package trigger
+
+import (
+	"github.com/HAL-xyz/ethrpc"
+	"github.com/HAL-xyz/zoroaster/tokenapi"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/stretchr/testify/assert"
+	"math/big"
+	"strings"
+	"testing"
+)
+
+// knows a single ERC-721 and a single ERC-1155 collection
+type mockTApiNFT struct {
+	tokenapi.ITokenAPI
+}
+
+func (t mockTApiNFT) IsERC721(address string) bool {
+	return address == "0xbc4ca0eda7647a8ab7c2061c2e118a18a936f13d"
+}
+
+func (t mockTApiNFT) IsERC1155(address string) bool {
+	return address == "0x495f947276749ce646f68ac8c248420045cb7b5e"
+}
+
+// counts how many contracts are asked if they're ERC-721 collections
+type probingTApiNFT struct {
+	mockTApiNFT
+	probes *int
+}
+
+func (t probingTApiNFT) IsERC721(address string) bool {
+	*t.probes++
+	return t.mockTApiNFT.IsERC721(address)
+}
+
+func makeTransferBatchLog(t *testing.T, ids, values []*big.Int) ethrpc.Log {
+	abiObj, err := abi.JSON(strings.NewReader(tokenapi.ERC1155ABI))
+	assert.NoError(t, err)
+	data, err := abiObj.Events["TransferBatch"].Inputs.NonIndexed().Pack(ids, values)
+	assert.NoError(t, err)
+	return ethrpc.Log{
+		Address:         "0x495f947276749ce646f68ac8c248420045cb7b5e",
+		TransactionHash: "0x61a3f7bbb9d3ff1cba2ab1a7a76e13ac4ebc6a9fa8c6e84c1f97dc8d1fe4e07a",
+		Data:            hexutil.Encode(data),
+		Topics: []string{
+			transferBatchSignature,
+			"0x000000000000000000000000207a3a7c3bfab3aa4dfb7ee4a6bfe9e4c7a4f1b7",
+			"0x0000000000000000000000000000000000000000000000000000000000000000",
+			"0x000000000000000000000000c2a8ef4d6e6a73f4f1e7b19d1d1c2dc2e1e8c9e0",
+		},
+	}
+}
+
+func TestMatchTransferBatchById(t *testing.T) {
+	js := `
+{
+  "TriggerName": "OpenSea shared storefront, token 2 minted",
+  "TriggerType": "WatchEvents",
+  "ContractAdd": "all_erc1155_collections",
+  "Filters": [
+    {
+      "FilterType": "CheckEventParameter",
+      "EventName": "TransferBatch",
+      "ParameterName": "id",
+      "ParameterType": "uint256",
+      "Condition": {"Predicate": "Eq", "Attribute": "2"}
+    }
+  ]
+}`
+	tg, err := NewTriggerFromJson(js, nil)
+	assert.NoError(t, err)
+
+	batch := makeTransferBatchLog(t, []*big.Int{big.NewInt(1), big.NewInt(2), big.NewInt(3)}, []*big.Int{big.NewInt(10), big.NewInt(20), big.NewInt(30)})
+
+	matches := MatchEvent(tg, []ethrpc.Log{batch}, []tokenapi.Transaction{}, mockTApiNFT{})
+	assert.Len(t, matches, 1)
+	assert.Equal(t, "2", matches[0].EventParams["id"])
+	assert.Equal(t, "20", matches[0].EventParams["value"])
+	assert.Equal(t, "0x207a3a7c3bfab3aa4dfb7ee4a6bfe9e4c7a4f1b7", matches[0].EventParams["operator"])
+
+	// a value filter on the same id
+	tg.Filters = append(tg.Filters, Filter{
+		FilterType:    "CheckEventParameter",
+		EventName:     "TransferBatch",
+		ParameterName: "value",
+		ParameterType: "uint256",
+		Condition:     ConditionEvent{Predicate: BiggerThan, Attribute: "25"},
+	})
+	matches = MatchEvent(tg, []ethrpc.Log{batch}, []tokenapi.Transaction{}, mockTApiNFT{})
+	assert.Len(t, matches, 0)
+
+	// not an ERC-1155 contract
+	batch.Address = "0xbc4ca0eda7647a8ab7c2061c2e118a18a936f13d"
+	matches = MatchEvent(tg, []ethrpc.Log{batch}, []tokenapi.Transaction{}, mockTApiNFT{})
+	assert.Len(t, matches, 0)
+}
+
+func TestMatchTransferBatchEmitted(t *testing.T) {
+	js := `
+{
+  "TriggerName": "any batch transfer",
+  "TriggerType": "WatchEvents",
+  "ContractAdd": "all_erc1155_collections",
+  "Filters": [
+    {
+      "FilterType": "CheckEventEmitted",
+      "EventName": "TransferBatch"
+    }
+  ]
+}`
+	tg, err := NewTriggerFromJson(js, nil)
+	assert.NoError(t, err)
+
+	// without filters on a single id, a batch is a single match
+	batch := makeTransferBatchLog(t, []*big.Int{big.NewInt(1), big.NewInt(2)}, []*big.Int{big.NewInt(10), big.NewInt(20)})
+	matches := MatchEvent(tg, []ethrpc.Log{batch}, []tokenapi.Transaction{}, mockTApiNFT{})
+	assert.Len(t, matches, 1)
+	assert.Equal(t, []string{"1", "2"}, matches[0].EventParams["ids"])
+	assert.Nil(t, matches[0].EventParams["id"])
+}
+
+func TestMatchAllERC721Collections(t *testing.T) {
+	js := `
+{
+  "TriggerName": "BAYC transfers",
+  "TriggerType": "WatchEvents",
+  "ContractAdd": "all_erc721_collections",
+  "Filters": [
+    {
+      "FilterType": "CheckEventParameter",
+      "EventName": "Transfer",
+      "ParameterName": "tokenId",
+      "ParameterType": "uint256",
+      "Condition": {"Predicate": "Eq", "Attribute": "42"}
+    }
+  ]
+}`
+	tg, err := NewTriggerFromJson(js, nil)
+	assert.NoError(t, err)
+
+	transfer := ethrpc.Log{
+		Address: "0xbc4ca0eda7647a8ab7c2061c2e118a18a936f13d",
+		Data:    "0x",
+		Topics: []string{
+			"0xddf252ad1be2c89b69c2b068fc378daa952ba7f163c4a11628f55a4df523b3ef",
+			"0x0000000000000000000000007c40c393dc0f283f318791d746d894ddd3693572",
+			"0x000000000000000000000000c2a8ef4d6e6a73f4f1e7b19d1d1c2dc2e1e8c9e0",
+			"0x000000000000000000000000000000000000000000000000000000000000002a",
+		},
+	}
+	// an ERC20 transfer has the same signature, but it's not emitted by an ERC-721 contract
+	erc20Transfer := ethrpc.Log{
+		Address: "0xa0b86991c6218b36c1d19d4a2e9eb0ce3606eb48",
+		Data:    "0x000000000000000000000000000000000000000000000000000000000000002a",
+		Topics:  transfer.Topics[:3],
+	}
+
+	// and the ERC20 contract isn't even asked
+	probes := 0
+	matches := MatchEvent(tg, []ethrpc.Log{erc20Transfer, transfer}, []tokenapi.Transaction{}, probingTApiNFT{probes: &probes})
+	assert.Len(t, matches, 1)
+	assert.Equal(t, "42", matches[0].EventParams["tokenId"])
+	assert.Equal(t, "0x7c40c393dc0f283f318791d746d894ddd3693572", matches[0].EventParams["from"])
+	assert.Equal(t, 1, probes)
+}
@@ -3,6 +3,7 @@ package trigger
 import (
 	"bytes"
 	"encoding/json"
+	"fmt"
 	"github.com/HAL-xyz/zoroaster/tokenapi"
 	"github.com/HAL-xyz/zoroaster/utils"
 	"github.com/ethereum/go-ethereum/common"
@@ -508,12 +509,16 @@ func compareBytesWithParameter(b []byte, p string) bool {
 	return common.Bytes2Hex(param) == common.Bytes2Hex(b)
 }
 
+// the matchers pass a tokenapi.AtBlock view, so the exchange rate is the one at the block being matched
 func convertToCurrency(tokenApi tokenapi.ITokenAPI, parameterCurrency, attributeCurrency string, param *big.Int) (*big.Float, error) {
 	exchangeRate, err := tokenApi.GetExchangeRate(parameterCurrency, attributeCurrency)
 	if err != nil {
 		return new(big.Float), err
 	}
 	decimals := tokenApi.Decimals(parameterCurrency)
+	if decimals == "" {
+		return new(big.Float), fmt.Errorf("unknown decimals for token %s", parameterCurrency)
+	}
 	scaledValue := utils.MakeBigFloat(tokenApi.FromWei(param, decimals))
 	convertedValue := scaledValue.Mul(scaledValue, utils.MakeBigFloat(exchangeRate))
 	return convertedValue, nil
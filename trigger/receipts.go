@@ -0,0 +1,80 @@
+package trigger
+
+import (
+	"github.com/HAL-xyz/zoroaster/tokenapi"
+	"math/big"
+)
+
+// the outcome of a mined tx, as read from its receipt
+type Receipt struct {
+	Status            int // 1 if the tx succeeded, 0 if it reverted
+	GasUsed           int
+	EffectiveGasPrice *big.Int
+	Fee               *big.Int // GasUsed * EffectiveGasPrice
+	ContractAddress   string   `json:"ContractAddress,omitempty"`
+}
+
+func NewReceipt(r tokenapi.TxReceipt, tx *tokenapi.Transaction) *Receipt {
+	// before London the gas price was the effective gas price
+	effectiveGasPrice := r.EffectiveGasPrice
+	if effectiveGasPrice == nil {
+		effectiveGasPrice = new(big.Int).Set(&tx.GasPrice)
+	}
+	return &Receipt{
+		Status:            r.Status,
+		GasUsed:           r.GasUsed,
+		EffectiveGasPrice: effectiveGasPrice,
+		Fee:               new(big.Int).Mul(big.NewInt(int64(r.GasUsed)), effectiveGasPrice),
+		ContractAddress:   r.ContractAddress,
+	}
+}
+
+// receipt filters can only be checked once the tx is mined and we have its receipt
+func isReceiptCondition(c Conditioner) bool {
+	switch c.(type) {
+	case ConditionStatus, ConditionGasUsed, ConditionEffectiveGasPrice, ConditionFee:
+		return true
+	default:
+		return false
+	}
+}
+
+// NeedsReceipt tells if a trigger has filters on the tx receipt;
+// deployments need it too, for the address of the new contract
+func (tg Trigger) NeedsReceipt() bool {
+	for _, f := range tg.Filters {
+		if isReceiptCondition(f.Condition) || isDeploymentCondition(f.Condition) {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidateReceipt checks the receipt filters of a trigger;
+// a trigger with receipt filters never matches a missing receipt.
+func (tg Trigger) ValidateReceipt(receipt *Receipt) bool {
+	for _, f := range tg.Filters {
+		if !isReceiptCondition(f.Condition) {
+			continue
+		}
+		if receipt == nil || !validateReceiptFilter(receipt, f.Condition) {
+			return false
+		}
+	}
+	return true
+}
+
+func validateReceiptFilter(receipt *Receipt, c Conditioner) bool {
+	switch v := c.(type) {
+	case ConditionStatus:
+		return validatePredInt(v.Predicate, receipt.Status, v.Attribute)
+	case ConditionGasUsed:
+		return validatePredInt(v.Predicate, receipt.GasUsed, v.Attribute)
+	case ConditionEffectiveGasPrice:
+		return validatePredBigInt(v.Predicate, receipt.EffectiveGasPrice, v.Attribute)
+	case ConditionFee:
+		return validatePredBigInt(v.Predicate, receipt.Fee, v.Attribute)
+	default:
+		return false
+	}
+}
@@ -4,22 +4,23 @@ import (
 	"encoding/json"
 	"fmt"
 	"github.com/HAL-xyz/ethrpc"
+	"github.com/HAL-xyz/zoroaster/tokenapi"
 	log "github.com/sirupsen/logrus"
 	"io/ioutil"
 	"strconv"
 )
 
-func JsonToTransaction(jsonSrc []byte) (*ethrpc.Transaction, error) {
-	var tx ethrpc.Transaction
-	err := json.Unmarshal(jsonSrc, &tx)
+func JsonToTransaction(jsonSrc []byte) (*tokenapi.Transaction, error) {
+	var tx tokenapi.Transaction
+	err := json.Unmarshal(jsonSrc, &tx.Transaction)
 	if err != nil {
 		return nil, err
 	}
-	fixHexTransaction(&tx)
+	fixHexTransaction(&tx.Transaction)
 	return &tx, nil
 }
 
-func GetTransactionFromFile(path string) (*ethrpc.Transaction, error) {
+func GetTransactionFromFile(path string) (*tokenapi.Transaction, error) {
 	txSrc, err := ioutil.ReadFile(path)
 	if err != nil {
 		return nil, err
@@ -31,7 +32,8 @@ func GetTransactionFromFile(path string) (*ethrpc.Transaction, error) {
 	return tx, nil
 }
 
-func jsonToBlock(jsonBlock []byte) (*ethrpc.Block, error) {
+// test blocks only have legacy txs
+func jsonToBlock(jsonBlock []byte) (*tokenapi.Block, error) {
 	var block ethrpc.Block
 	err := json.Unmarshal(jsonBlock, &block)
 	if err != nil {
@@ -40,10 +42,10 @@ func jsonToBlock(jsonBlock []byte) (*ethrpc.Block, error) {
 	for i := range block.Transactions {
 		fixHexTransaction(&block.Transactions[i])
 	}
-	return &block, nil
+	return tokenapi.NewBlock(&block), nil
 }
 
-func GetBlockFromFile(path string) (*ethrpc.Block, error) {
+func GetBlockFromFile(path string) (*tokenapi.Block, error) {
 	blockSrc, err := ioutil.ReadFile(path)
 	if err != nil {
 		return nil, err
@@ -76,7 +78,7 @@ func GetTriggerFromFile(path string) (*Trigger, error) {
 	if err != nil {
 		log.Error(err)
 	}
-	return NewTriggerFromJson(string(triggerSrc))
+	return NewTriggerFromJson(string(triggerSrc), nil)
 }
 
 // ethrpc.Transaction and ethrpc.Log expects some fields to be hex values,
@@ -0,0 +1,91 @@
+package trigger
+
+import (
+	"github.com/HAL-xyz/zoroaster/tokenapi"
+	"github.com/HAL-xyz/zoroaster/utils"
+	log "github.com/sirupsen/logrus"
+	"strings"
+)
+
+// an internal call, flattened into a tx so that it can go through validateTrigger
+type internalTx struct {
+	tx      tokenapi.Transaction
+	depth   int
+	path    []int  // the index of the call at every depth, starting from the top-level tx
+	created string // CREATE and CREATE2 only: the address of the new contract
+}
+
+// MatchInternalTransactions matches the internal calls of every tx in the block;
+// traces are the call frames of the block's txs, in the same order.
+// Top-level txs are left to MatchTransaction.
+func MatchInternalTransactions(trigger *Trigger, block *tokenapi.Block, traces []tokenapi.CallFrame, tokenApi tokenapi.ITokenAPI) []*TxMatch {
+	txMatches := make([]*TxMatch, 0)
+	if len(traces) != len(block.Transactions) {
+		log.Warnf("got %d traces for %d txs in block %d", len(traces), len(block.Transactions), block.Number)
+		return txMatches
+	}
+	for i := range block.Transactions {
+		internals := expandCallFrames(&block.Transactions[i], traces[i])
+		for j := range internals {
+			if validateTrigger(trigger, &internals[j].tx, block.BaseFeePerGas, tokenApi) {
+				match := makeTxMatch(trigger, &internals[j].tx, block.Timestamp, block.BaseFeePerGas)
+				match.CallDepth = internals[j].depth
+				match.TracePath = internals[j].path
+				if internals[j].created != "" {
+					match.Deployment = &Deployment{Address: internals[j].created, Creator: internals[j].tx.From}
+				}
+				txMatches = append(txMatches, match)
+			}
+		}
+	}
+	return txMatches
+}
+
+// expandCallFrames flattens the internal calls made by a tx.
+// Reverted calls are skipped along with everything they called, since none of it really happened;
+// static calls are skipped too, since they can't move funds or change any state.
+func expandCallFrames(parent *tokenapi.Transaction, root tokenapi.CallFrame) []internalTx {
+	var txs []internalTx
+
+	var walk func(frames []tokenapi.CallFrame, path []int)
+	walk = func(frames []tokenapi.CallFrame, path []int) {
+		for i, f := range frames {
+			if f.Error != "" {
+				continue
+			}
+			p := append(append([]int{}, path...), i)
+			if f.Type != "STATICCALL" {
+				itx := internalTx{tx: frameToTx(parent, f), depth: len(p), path: p}
+				if isCreateFrame(f) {
+					itx.created = strings.ToLower(f.To)
+				}
+				txs = append(txs, itx)
+			}
+			walk(f.Calls, p)
+		}
+	}
+
+	if root.Error == "" {
+		walk(root.Calls, []int{})
+	}
+	return txs
+}
+
+func isCreateFrame(f tokenapi.CallFrame) bool {
+	return f.Type == "CREATE" || f.Type == "CREATE2"
+}
+
+// the internal call keeps the hash, nonce, block and type of the tx that made it;
+// like top-level contract creations, internal ones have no recipient
+func frameToTx(parent *tokenapi.Transaction, f tokenapi.CallFrame) tokenapi.Transaction {
+	tx := *parent
+	tx.From = strings.ToLower(f.From)
+	tx.To = strings.ToLower(f.To)
+	if isCreateFrame(f) {
+		tx.To = ""
+	}
+	tx.Input = f.Input
+	tx.Value = *utils.MakeBigIntFromHex(f.Value)
+	tx.Gas = int(utils.MakeBigIntFromHex(f.Gas).Int64())
+	return tx
+}
@@ -0,0 +1,57 @@
+package trigger
+
+import (
+	"github.com/HAL-xyz/zoroaster/tokenapi"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestMatchInternalTransactions(t *testing.T) {
+	block, _ := GetBlockFromFile("../resources/blocks/block1.json")
+	trigger, _ := GetTriggerFromFile("../resources/triggers/t2.json")
+
+	// a transfer(), as made by tx #6 at the top level
+	transfer := block.Transactions[6].Input
+	token := "0x174bfa6600bf90c885c7c01c7031389ed1461ab9"
+	multisig := "0xd9db270c1b5e3bd161e8c8503c55ceabee709552"
+
+	traces := make([]tokenapi.CallFrame, len(block.Transactions))
+	traces[0] = tokenapi.CallFrame{
+		Type: "CALL",
+		From: block.Transactions[0].From,
+		To:   multisig,
+		Calls: []tokenapi.CallFrame{
+			{Type: "CALL", From: multisig, To: token, Value: "0x0", Gas: "0x8fc", Input: transfer},
+			{Type: "CALL", From: multisig, To: token, Value: "0x0", Gas: "0x8fc", Input: transfer, Error: "execution reverted"},
+			{Type: "DELEGATECALL", From: multisig, To: "0x34cfac646f301356faa8b21e94227e3583fe3f5f", Input: "0x", Calls: []tokenapi.CallFrame{
+				{Type: "CALL", From: multisig, To: token, Value: "0xde0b6b3a7640000", Gas: "0x8fc", Input: transfer},
+			}},
+			{Type: "STATICCALL", From: multisig, To: token, Input: transfer},
+		},
+	}
+
+	matches := MatchInternalTransactions(trigger, block, traces, mockTokenApi)
+	assert.Len(t, matches, 2)
+
+	assert.Equal(t, 1, matches[0].CallDepth)
+	assert.Equal(t, []int{0}, matches[0].TracePath)
+	assert.Equal(t, multisig, matches[0].Tx.From)
+	assert.Equal(t, block.Transactions[0].Hash, matches[0].Tx.Hash)
+	assert.Equal(t, "transfer", *matches[0].DecodedFnName)
+
+	assert.Equal(t, 2, matches[1].CallDepth)
+	assert.Equal(t, []int{2, 0}, matches[1].TracePath)
+	assert.Equal(t, "1000000000000000000", matches[1].Tx.Value.String())
+	assert.Equal(t, 2300, matches[1].Tx.Gas)
+
+	tm := matches[1].ToTemplateMatch()
+	assert.Equal(t, 2, tm.Tx.CallDepth)
+	assert.Equal(t, []int{2, 0}, tm.Tx.TracePath)
+
+	// the top-level tx itself reverted
+	traces[0].Error = "out of gas"
+	assert.Len(t, MatchInternalTransactions(trigger, block, traces, mockTokenApi), 0)
+
+	// traces don't match the block
+	assert.Len(t, MatchInternalTransactions(trigger, block, traces[:1], mockTokenApi), 0)
+}
@@ -2,6 +2,7 @@ package trigger
 
 import (
 	"fmt"
+	"github.com/HAL-xyz/zoroaster/tokenapi"
 	"github.com/ethereum/go-ethereum/accounts/abi"
 	"math/big"
 	"strings"
@@ -21,6 +22,19 @@ type Trigger struct {
 	UserUUID     string
 	CronJob      CronJob
 	LastFired    time.Time
+	// WaC only: how often (in blocks) the contract is called; 0 means the network default
+	BlocksInterval int
+	// WaT only: match the internal calls made by contracts as well
+	IncludeInternalTxs bool
+	// set by ParseABI, for triggers that are matched over and over again
+	parsedABI *abi.ABI
+	// the ENS names used in place of addresses, already resolved
+	ensNames []string
+}
+
+// ENSNames are the ENS names the trigger's addresses were resolved from
+func (tg *Trigger) ENSNames() []string {
+	return tg.ensNames
 }
 
 func (tg Trigger) hasBasicFilters() bool {
@@ -52,14 +66,60 @@ func (tg Trigger) CallArgs() []string {
 	return args
 }
 
+// ParseABI parses the contract ABI once and for all
+func (tg *Trigger) ParseABI() error {
+	tg.parsedABI = nil
+	abiObj, err := tg.getABIObj()
+	if err != nil {
+		return err
+	}
+	tg.parsedABI = &abiObj
+	return nil
+}
+
 func (tg Trigger) getABIObj() (abi.ABI, error) {
-	abiObj, err := abi.JSON(strings.NewReader(tg.ContractABI))
+	if tg.parsedABI != nil {
+		return *tg.parsedABI, nil
+	}
+	contractABI := tg.ContractABI
+	// NFT triggers can rely on the standard ABIs
+	if contractABI == "" && tg.ContractAdd == "all_erc721_collections" {
+		contractABI = tokenapi.ERC721ABI
+	}
+	if contractABI == "" && tg.ContractAdd == "all_erc1155_collections" {
+		contractABI = tokenapi.ERC1155ABI
+	}
+	abiObj, err := abi.JSON(strings.NewReader(contractABI))
 	if err != nil {
 		return abi.ABI{}, err
 	}
 	return abiObj, nil
 }
 
+// IsDue tells if a WaC trigger has to be checked on blockNo;
+// triggers without their own interval use defaultInterval.
+func (tg Trigger) IsDue(blockNo, defaultInterval int) bool {
+	interval := tg.BlocksInterval
+	if interval <= 0 {
+		interval = defaultInterval
+	}
+	if interval <= 1 {
+		return true
+	}
+	return blockNo%interval == 0
+}
+
+// DueTriggers returns the triggers that have to be checked on blockNo
+func DueTriggers(tgs []*Trigger, blockNo, defaultInterval int) []*Trigger {
+	var due []*Trigger
+	for _, tg := range tgs {
+		if tg.IsDue(blockNo, defaultInterval) {
+			due = append(due, tg)
+		}
+	}
+	return due
+}
+
 // this is only used by WaC at the moment;
 // the idea is that triggers with the same key effectively make the same eth_call,
 // so we can use it to group triggers together.
@@ -151,6 +211,114 @@ type ConditionValue struct {
 	Attribute *big.Int
 }
 
+type ConditionStatus struct {
+	Condition
+	Predicate Predicate
+	Attribute int
+}
+
+type ConditionGasUsed struct {
+	Condition
+	Predicate Predicate
+	Attribute int
+}
+
+type ConditionEffectiveGasPrice struct {
+	Condition
+	Predicate Predicate
+	Attribute *big.Int
+}
+
+type ConditionFee struct {
+	Condition
+	Predicate Predicate
+	Attribute *big.Int
+}
+
+type ConditionTxType struct {
+	Condition
+	Predicate Predicate
+	Attribute int
+}
+
+type ConditionMaxFeePerGas struct {
+	Condition
+	Predicate Predicate
+	Attribute *big.Int
+}
+
+type ConditionMaxPriorityFeePerGas struct {
+	Condition
+	Predicate Predicate
+	Attribute *big.Int
+}
+
+type ConditionBaseFeePerGas struct {
+	Condition
+	Predicate Predicate
+	Attribute *big.Int
+}
+
+type ConditionGasUsedRatio struct {
+	Condition
+	Predicate Predicate
+	Attribute *big.Float
+}
+
+type ConditionMiner struct {
+	Condition
+	Predicate Predicate
+	Attribute string
+}
+
+type ConditionTimestampGap struct {
+	Condition
+	Predicate Predicate
+	Attribute int
+}
+
+type ConditionTxCount struct {
+	Condition
+	Predicate Predicate
+	Attribute int
+}
+
+type ConditionTotalValue struct {
+	Condition
+	Predicate Predicate
+	Attribute *big.Int
+}
+
+type ConditionContractCreations struct {
+	Condition
+	Predicate Predicate
+	Attribute int
+}
+
+type ConditionCreator struct {
+	Condition
+	Predicate Predicate
+	Attribute string
+}
+
+type ConditionBytecodeHash struct {
+	Condition
+	Predicate Predicate
+	Attribute string
+}
+
+type ConditionContainsSelector struct {
+	Condition
+	Predicate Predicate
+	Attribute string
+}
+
+type ConditionSupportsInterface struct {
+	Condition
+	Predicate Predicate
+	Attribute string
+}
+
 type ConditionFunctionParam struct {
 	Condition
 	Predicate Predicate
@@ -190,12 +358,14 @@ func (p Predicate) String() string {
 	return [...]string{"Eq", "BiggerThan", "SmallerThan"}[p]
 }
 
-func NewTriggerFromJson(json string) (*Trigger, error) {
+// NewTriggerFromJson parses a trigger; tokenApi resolves ENS names and expands macros,
+// and can be nil if the trigger uses neither.
+func NewTriggerFromJson(json string, tokenApi tokenapi.ITokenAPI) (*Trigger, error) {
 	tjs, err := NewTriggerJson(json)
 	if err != nil {
 		return nil, &triggerCreationError{"cannot parse json trigger:", err}
 	}
-	tg, err := tjs.ToTrigger()
+	tg, err := tjs.ToTrigger(tokenApi)
 	if err != nil {
 		return nil, &triggerCreationError{"cannot convert TriggerJson to Trigger:", err}
 	}
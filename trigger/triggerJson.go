@@ -26,6 +26,10 @@ type TriggerJson struct {
 	Inputs       []InputJson  `json:"Inputs"`
 	Outputs      []OutputJson `json:"Outputs"`
 	CronJob      CronJobJson  `json:"CronJob"`
+	// WaC only: check the contract every BlocksInterval blocks
+	BlocksInterval int `json:"BlocksInterval,omitempty"`
+	// WaT only: match the internal calls made by contracts as well
+	IncludeInternalTxs bool `json:"IncludeInternalTxs,omitempty"`
 }
 
 type FilterJson struct {
@@ -81,12 +85,12 @@ func NewTriggerJson(input string) (*TriggerJson, error) {
 }
 
 // converts a TriggerJson to a Trigger
-func (tjs *TriggerJson) ToTrigger() (*Trigger, error) {
+func (tjs *TriggerJson) ToTrigger(tokenApi tokenapi.ITokenAPI) (*Trigger, error) {
 
 	if tjs.TriggerName == "" {
 		return nil, fmt.Errorf("cannot read trigger: missing TriggerName")
 	}
-	validTriggerTypes := []string{"WatchTransactions", "WatchContracts", "WatchEvents", "CronTrigger"}
+	validTriggerTypes := []string{"WatchTransactions", "WatchContracts", "WatchEvents", "CronTrigger", "WatchBlocks"}
 	if !utils.IsIn(tjs.TriggerType, validTriggerTypes) {
 		return nil, fmt.Errorf("invalid trigger type: %s", tjs.TriggerType)
 	}
@@ -95,6 +99,32 @@ func (tjs *TriggerJson) ToTrigger() (*Trigger, error) {
 		return nil, fmt.Errorf("cannot read WaC trigger: missing FunctionName")
 	}
 
+	// block filters only make sense for WaB, and WaB only has block filters
+	if tjs.TriggerType == "WatchBlocks" && len(tjs.Filters) == 0 {
+		return nil, fmt.Errorf("cannot read WaB trigger: missing Filters")
+	}
+	for _, fjs := range tjs.Filters {
+		if (fjs.FilterType == "BlockFilter") != (tjs.TriggerType == "WatchBlocks") {
+			return nil, fmt.Errorf("invalid filter type %s for %s", fjs.FilterType, tjs.TriggerType)
+		}
+		if fjs.FilterType == "DeploymentFilter" && tjs.TriggerType != "WatchTransactions" {
+			return nil, fmt.Errorf("invalid filter type %s for %s", fjs.FilterType, tjs.TriggerType)
+		}
+	}
+
+	resolve := func(string) (string, error) { return "", fmt.Errorf("no TokenAPI to resolve it with") }
+	if tokenApi != nil {
+		resolve = tokenApi.ResolveENS
+	}
+	ensNames, err := tjs.resolveENSNames(resolve)
+	if err != nil {
+		return nil, err
+	}
+
+	if tjs.BlocksInterval < 0 {
+		return nil, fmt.Errorf("invalid BlocksInterval: %d", tjs.BlocksInterval)
+	}
+
 	if tjs.TriggerType == "CronTrigger" {
 		_, err := cronexpr.Parse(tjs.CronJob.Rule)
 		if err != nil {
@@ -118,11 +148,18 @@ func (tjs *TriggerJson) ToTrigger() (*Trigger, error) {
 			Rule:     tjs.CronJob.Rule,
 			Timezone: tjs.CronJob.Timezone,
 		},
+		BlocksInterval:     tjs.BlocksInterval,
+		IncludeInternalTxs: tjs.IncludeInternalTxs,
+		ensNames:           ensNames,
 	}
 
 	// populate Input/Output for Watch a Contract & Cron Trigger
 	for _, inputJs := range tjs.Inputs {
-		trigger.Inputs = append(trigger.Inputs, *(inputJs.ToInput()))
+		input, err := inputJs.ToInput(tokenApi)
+		if err != nil {
+			return nil, err
+		}
+		trigger.Inputs = append(trigger.Inputs, *input)
 	}
 	for _, outputJs := range tjs.Outputs {
 		cond := ConditionOutput{Condition{}, unpackPredicate(outputJs.Condition.Predicate), outputJs.Condition.Attribute, outputJs.Condition.AttributeCurrency}
@@ -148,7 +185,7 @@ func (tjs *TriggerJson) ToTrigger() (*Trigger, error) {
 		trigger.Outputs = append(trigger.Outputs, out)
 	}
 
-	// populate Filters for WaT and WaE
+	// populate Filters for WaT, WaE and WaB
 	for _, fjs := range tjs.Filters {
 		f, err := fjs.ToFilter()
 		if err != nil {
@@ -160,8 +197,12 @@ func (tjs *TriggerJson) ToTrigger() (*Trigger, error) {
 }
 
 // converts an InputJson to an Input
-func (inputJs InputJson) ToInput() *Input {
-	return &Input{inputJs.ParameterType, expandMacro(inputJs.ParameterValue)}
+func (inputJs InputJson) ToInput(tokenApi tokenapi.ITokenAPI) (*Input, error) {
+	value, err := expandMacro(inputJs.ParameterValue, tokenApi)
+	if err != nil {
+		return nil, err
+	}
+	return &Input{inputJs.ParameterType, value}, nil
 }
 
 // converts a FilterJson to a Filter
@@ -232,10 +273,69 @@ func makeCondition(fjs FilterJson) (Conditioner, error) {
 				return nil, fmt.Errorf("invalid value %v", attribute)
 			}
 			return ConditionValue{Condition{}, predicate, value}, nil
+		case "Status":
+			status, err := strconv.Atoi(attribute)
+			if err != nil || (status != 0 && status != 1) {
+				return nil, fmt.Errorf("invalid status %v", attribute)
+			}
+			return ConditionStatus{Condition{}, predicate, status}, nil
+		case "GasUsed":
+			gasUsed, err := strconv.Atoi(attribute)
+			if err != nil {
+				return nil, err
+			}
+			return ConditionGasUsed{Condition{}, predicate, gasUsed}, nil
+		case "EffectiveGasPrice":
+			gasPrice := new(big.Int)
+			_, ok := gasPrice.SetString(attribute, 0)
+			if !ok {
+				return nil, fmt.Errorf("invalid effectiveGasPrice %v", attribute)
+			}
+			return ConditionEffectiveGasPrice{Condition{}, predicate, gasPrice}, nil
+		case "Fee":
+			fee := new(big.Int)
+			_, ok := fee.SetString(attribute, 0)
+			if !ok {
+				return nil, fmt.Errorf("invalid fee %v", attribute)
+			}
+			return ConditionFee{Condition{}, predicate, fee}, nil
+		case "Type":
+			txType, err := strconv.Atoi(attribute)
+			if err != nil || txType < 0 || txType > 2 {
+				return nil, fmt.Errorf("invalid tx type %v", attribute)
+			}
+			return ConditionTxType{Condition{}, predicate, txType}, nil
+		case "MaxFeePerGas":
+			maxFee := new(big.Int)
+			_, ok := maxFee.SetString(attribute, 0)
+			if !ok {
+				return nil, fmt.Errorf("invalid maxFeePerGas %v", attribute)
+			}
+			return ConditionMaxFeePerGas{Condition{}, predicate, maxFee}, nil
+		case "MaxPriorityFeePerGas":
+			priorityFee := new(big.Int)
+			_, ok := priorityFee.SetString(attribute, 0)
+			if !ok {
+				return nil, fmt.Errorf("invalid maxPriorityFeePerGas %v", attribute)
+			}
+			return ConditionMaxPriorityFeePerGas{Condition{}, predicate, priorityFee}, nil
+		case "BaseFeePerGas":
+			baseFee := new(big.Int)
+			_, ok := baseFee.SetString(attribute, 0)
+			if !ok {
+				return nil, fmt.Errorf("invalid baseFeePerGas %v", attribute)
+			}
+			return ConditionBaseFeePerGas{Condition{}, predicate, baseFee}, nil
 		default:
 			return nil, fmt.Errorf("parameter name not supported: %s", fjs.ParameterName)
 		}
 	}
+	if fjs.FilterType == "BlockFilter" {
+		return makeBlockCondition(fjs.ParameterName, predicate, attribute)
+	}
+	if fjs.FilterType == "DeploymentFilter" {
+		return makeDeploymentCondition(fjs.ParameterName, predicate, attribute)
+	}
 	if fjs.FilterType == "CheckFunctionParameter" {
 		c := ConditionFunctionParam{Condition{}, predicate, fjs.Condition.Attribute}
 		return c, nil
@@ -255,6 +355,86 @@ func makeCondition(fjs FilterJson) (Conditioner, error) {
 	return nil, fmt.Errorf("unsupported filter type %s", fjs.FilterType)
 }
 
+func makeBlockCondition(parameterName string, predicate Predicate, attribute string) (Conditioner, error) {
+	switch parameterName {
+	case "BaseFeePerGas":
+		baseFee := new(big.Int)
+		_, ok := baseFee.SetString(attribute, 0)
+		if !ok {
+			return nil, fmt.Errorf("invalid baseFeePerGas %v", attribute)
+		}
+		return ConditionBaseFeePerGas{Condition{}, predicate, baseFee}, nil
+	case "GasUsedRatio":
+		ratio, ok := new(big.Float).SetString(attribute)
+		if !ok || ratio.Sign() < 0 || ratio.Cmp(big.NewFloat(1)) > 0 {
+			return nil, fmt.Errorf("invalid gasUsedRatio %v", attribute)
+		}
+		return ConditionGasUsedRatio{Condition{}, predicate, ratio}, nil
+	case "Miner":
+		if predicate != Eq || !common.IsHexAddress(attribute) {
+			return nil, fmt.Errorf("invalid miner %v", attribute)
+		}
+		return ConditionMiner{Condition{}, predicate, attribute}, nil
+	case "TimestampGap":
+		gap, err := strconv.Atoi(attribute)
+		if err != nil {
+			return nil, err
+		}
+		return ConditionTimestampGap{Condition{}, predicate, gap}, nil
+	case "TxCount":
+		txCount, err := strconv.Atoi(attribute)
+		if err != nil {
+			return nil, err
+		}
+		return ConditionTxCount{Condition{}, predicate, txCount}, nil
+	case "TotalValue":
+		value := new(big.Int)
+		_, ok := value.SetString(attribute, 0)
+		if !ok {
+			return nil, fmt.Errorf("invalid totalValue %v", attribute)
+		}
+		return ConditionTotalValue{Condition{}, predicate, value}, nil
+	case "ContractCreations":
+		creations, err := strconv.Atoi(attribute)
+		if err != nil {
+			return nil, err
+		}
+		return ConditionContractCreations{Condition{}, predicate, creations}, nil
+	default:
+		return nil, fmt.Errorf("parameter name not supported: %s", parameterName)
+	}
+}
+
+func makeDeploymentCondition(parameterName string, predicate Predicate, attribute string) (Conditioner, error) {
+	if predicate != Eq {
+		return nil, fmt.Errorf("unsupported predicate for %s", parameterName)
+	}
+	switch parameterName {
+	case "Creator":
+		if !common.IsHexAddress(attribute) {
+			return nil, fmt.Errorf("invalid creator %v", attribute)
+		}
+		return ConditionCreator{Condition{}, predicate, attribute}, nil
+	case "BytecodeHash":
+		if len(common.FromHex(attribute)) != common.HashLength {
+			return nil, fmt.Errorf("invalid bytecodeHash %v", attribute)
+		}
+		return ConditionBytecodeHash{Condition{}, predicate, attribute}, nil
+	case "ContainsSelector":
+		if len(common.FromHex(attribute)) != 4 {
+			return nil, fmt.Errorf("invalid selector %v", attribute)
+		}
+		return ConditionContainsSelector{Condition{}, predicate, attribute}, nil
+	case "SupportsInterface":
+		if len(common.FromHex(attribute)) != 4 {
+			return nil, fmt.Errorf("invalid interface id %v", attribute)
+		}
+		return ConditionSupportsInterface{Condition{}, predicate, attribute}, nil
+	default:
+		return nil, fmt.Errorf("parameter name not supported: %s", parameterName)
+	}
+}
+
 func unpackPredicate(p string) Predicate {
 	switch p {
 	case "Eq":
@@ -272,21 +452,25 @@ func unpackPredicate(p string) Predicate {
 
 type Expander func(string) string
 
-func expandMacro(s string) string {
+// expandMacro replaces a macro with its value; tokenApi is only needed by $all_erc20_tokens
+func expandMacro(s string, tokenApi tokenapi.ITokenAPI) (string, error) {
 
 	var macros = map[string]Expander{
 		"$test": func(string) string {
 			return "hello, HAL ;)"
 		},
 		"$all_erc20_tokens": func(string) string {
-			return mapToStringListSorted(tokenapi.GetTokenAPI().GetAllERC20TokensMap())
+			return mapToStringListSorted(tokenApi.GetAllERC20TokensMap())
 		},
 	}
 	f, ok := macros[s]
-	if ok {
-		return f(s)
+	if !ok {
+		return s, nil
+	}
+	if s == "$all_erc20_tokens" && tokenApi == nil {
+		return "", fmt.Errorf("cannot expand %s: no TokenAPI", s)
 	}
-	return s
+	return f(s), nil
 }
 
 func mapToStringListSorted(m map[string]tokenapi.ERC20Token) string {
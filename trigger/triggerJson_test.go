@@ -21,7 +21,7 @@ func TestWaT(t *testing.T) {
 
 	tjs, err := NewTriggerJson(string(json))
 	assert.NoError(t, err)
-	trig, err := tjs.ToTrigger()
+	trig, err := tjs.ToTrigger(nil)
 	assert.NoError(t, err)
 
 	_, ok := trig.Filters[0].Condition.(ConditionTo)
@@ -34,7 +34,7 @@ func TestWaC(t *testing.T) {
 
 	tjs, err := NewTriggerJson(string(json))
 	assert.NoError(t, err)
-	trig, err := tjs.ToTrigger()
+	trig, err := tjs.ToTrigger(nil)
 	assert.NoError(t, err)
 
 	_, ok := trig.Outputs[0].Condition.(ConditionOutput)
@@ -67,7 +67,7 @@ func TestWaCWithComponents(t *testing.T) {
    "FunctionName":"getSpotPrice"
 }
 `
-	tg, err := NewTriggerFromJson(js)
+	tg, err := NewTriggerFromJson(js, nil)
 	assert.NoError(t, err)
 	assert.Equal(t, "d", tg.Outputs[0].Component.Name)
 	assert.Equal(t, "uint256", tg.Outputs[0].Component.Type)
@@ -93,7 +93,7 @@ func TestCronTrigger(t *testing.T) {
   }
 }
 `
-	tg, err := NewTriggerFromJson(js)
+	tg, err := NewTriggerFromJson(js, nil)
 	assert.NoError(t, err)
 
 	assert.Equal(t, "* * * * *", tg.CronJob.Rule)
@@ -118,7 +118,7 @@ func TestCronTrigger(t *testing.T) {
   }
 }
 `
-	tg, err = NewTriggerFromJson(js)
+	tg, err = NewTriggerFromJson(js, nil)
 	assert.Error(t, err)
 
 	js = `
@@ -140,7 +140,7 @@ func TestCronTrigger(t *testing.T) {
   }
 }
 `
-	tg, err = NewTriggerFromJson(js)
+	tg, err = NewTriggerFromJson(js, nil)
 	assert.Error(t, err)
 }
 
@@ -170,7 +170,7 @@ func TestMacro(t *testing.T) {
   "FunctionName": "balances"
 }
 `
-	tg, err := NewTriggerFromJson(js)
+	tg, err := NewTriggerFromJson(js, nil)
 	assert.NoError(t, err)
 	assert.Equal(t, "hello, HAL ;)", tg.Inputs[1].ParameterValue)
 
@@ -202,13 +202,27 @@ func TestMap2StringListSorted(t *testing.T) {
 	assert.Equal(t, "00000,0x123,0x345,0xxxx", mapToStringListSorted(m))
 }
 
+func TestExpandMacro(t *testing.T) {
+	// only the token list needs a TokenAPI
+	s, err := expandMacro("$test", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "hello, HAL ;)", s)
+
+	s, err = expandMacro("0x123", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "0x123", s)
+
+	_, err = expandMacro("$all_erc20_tokens", nil)
+	assert.Error(t, err)
+}
+
 func TestWaE(t *testing.T) {
 	json, err := ioutil.ReadFile("../resources/triggers/ev1.json")
 	assert.NoError(t, err)
 
 	tjs, err := NewTriggerJson(string(json))
 	assert.NoError(t, err)
-	trig, err := tjs.ToTrigger()
+	trig, err := tjs.ToTrigger(nil)
 	assert.NoError(t, err)
 
 	_, ok := trig.Filters[0].Condition.(ConditionEvent)
@@ -239,7 +253,7 @@ func TestWaEWithAttributeCurrency(t *testing.T) {
   "TriggerType": "WatchEvents"
 }
 `
-	tg, err := NewTriggerFromJson(js)
+	tg, err := NewTriggerFromJson(js, nil)
 	assert.NoError(t, err)
 	assert.Equal(t, "_reserveToken", tg.Filters[0].ParameterCurrency)
 
@@ -270,7 +284,7 @@ func TestWaEWithAttributeCurrencyMalformed(t *testing.T) {
   "TriggerType": "WatchEvents"
 }
 `
-	_, err := NewTriggerFromJson(js)
+	_, err := NewTriggerFromJson(js, nil)
 	assert.Error(t, err)
 }
 
@@ -302,7 +316,7 @@ func TestWaCWithAttributeCurrency(t *testing.T) {
    "FunctionName":"getSpotPrice"
 }
 `
-	tg, err := NewTriggerFromJson(js)
+	tg, err := NewTriggerFromJson(js, nil)
 	assert.NoError(t, err)
 
 	c, ok := tg.Outputs[0].Condition.(ConditionOutput)
@@ -340,13 +354,13 @@ func TestWaCWithAttributeCurrencyMalformed(t *testing.T) {
    "FunctionName":"getSpotPrice"
 }
 `
-	_, err := NewTriggerFromJson(js)
+	_, err := NewTriggerFromJson(js, nil)
 	assert.Error(t, err)
 }
 
 func TestMalformedJsonTrigger(t *testing.T) {
 	// handle broken TriggerJson creation
-	_, err := NewTriggerFromJson("def not json")
+	_, err := NewTriggerFromJson("def not json", nil)
 	assert.Error(t, err)
 
 	// handle broken Trigger creation
@@ -354,6 +368,6 @@ func TestMalformedJsonTrigger(t *testing.T) {
 	assert.Error(t, err2)
 
 	// handle some valid but random json
-	_, err3 := NewTriggerFromJson(`{ "hello": 1 }`)
+	_, err3 := NewTriggerFromJson(`{ "hello": 1 }`, nil)
 	assert.Error(t, err3)
 }
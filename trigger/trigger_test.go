@@ -2,6 +2,7 @@ package trigger
 
 import (
 	"github.com/stretchr/testify/assert"
+	"strings"
 	"testing"
 )
 
@@ -25,7 +26,64 @@ func TestGetKey(t *testing.T) {
   "FunctionName": "balanceOf"
 }
 `
-	tg, err := NewTriggerFromJson(js)
+	tg, err := NewTriggerFromJson(js, nil)
 	assert.NoError(t, err)
 	assert.Equal(t, "balanceOf+0x1f9840a85d5af5bf1d1762f925bdaddc4201f984+0x1f9840a85d5af5bf1d1762f925bdaddc4201f984", tg.getKey())
 }
+
+func TestDueTriggers(t *testing.T) {
+
+	js := `
+{
+  "Inputs": [],
+  "Outputs": [],
+  "ContractABI": "[{\"constant\":true,\"inputs\":[],\"name\":\"totalSupply\",\"outputs\":[{\"name\":\"\",\"type\":\"uint256\"}],\"stateMutability\":\"view\",\"type\":\"function\"}]",
+  "ContractAdd": "0x1f9840a85d5af5bf1d1762f925bdaddc4201f984",
+  "TriggerName": "total supply",
+  "TriggerType": "WatchContracts",
+  "FunctionName": "totalSupply",
+  "BlocksInterval": 100
+}
+`
+	slow, err := NewTriggerFromJson(js, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, 100, slow.BlocksInterval)
+
+	fast, err := NewTriggerFromJson(js, nil)
+	assert.NoError(t, err)
+	fast.BlocksInterval = 1
+
+	// no interval: use the network default
+	deflt, err := NewTriggerFromJson(js, nil)
+	assert.NoError(t, err)
+	deflt.BlocksInterval = 0
+
+	tgs := []*Trigger{slow, fast, deflt}
+
+	assert.Equal(t, []*Trigger{fast}, DueTriggers(tgs, 12000001, 5))
+	assert.Equal(t, []*Trigger{fast, deflt}, DueTriggers(tgs, 12000005, 5))
+	assert.Equal(t, []*Trigger{slow, fast, deflt}, DueTriggers(tgs, 12000100, 5))
+	assert.Equal(t, []*Trigger{fast, deflt}, DueTriggers(tgs, 12000001, 1))
+
+	// all the due triggers share the same key, so we only make one call
+	assert.Len(t, makeDistinctViews(DueTriggers(tgs, 12000100, 5)), 1)
+
+	_, err = NewTriggerFromJson(strings.Replace(js, `"BlocksInterval": 100`, `"BlocksInterval": -1`, 1), nil)
+	assert.Error(t, err)
+}
+
+func TestParseABI(t *testing.T) {
+	tg, err := GetTriggerFromFile("../resources/triggers/ev1.json")
+	assert.NoError(t, err)
+	assert.NoError(t, tg.ParseABI())
+	assert.NotNil(t, tg.parsedABI)
+
+	// the parsed ABI is used from now on
+	tg.ContractABI = "not an ABI"
+	abiObj, err := tg.getABIObj()
+	assert.NoError(t, err)
+	assert.NotEmpty(t, abiObj.Events)
+
+	assert.Error(t, tg.ParseABI())
+	assert.Nil(t, tg.parsedABI)
+}
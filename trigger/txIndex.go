@@ -0,0 +1,131 @@
+package trigger
+
+import (
+	"github.com/HAL-xyz/zoroaster/tokenapi"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"strings"
+)
+
+// A TxIndex finds the WaT triggers a transaction might match, without going through all of them.
+// Since a trigger only matches if all its filters match, every trigger is indexed by one of them:
+// the contract and 4-byte selector of a function filter, or else the To address, or else the From address.
+// Triggers without any of these filters go in a fallback bucket, and are checked against every tx.
+type TxIndex struct {
+	bySelector map[selectorKey][]*Trigger
+	byTo       map[string][]*Trigger
+	byFrom     map[string][]*Trigger
+	fallback   []*Trigger
+	triggers   []*Trigger
+}
+
+type selectorKey struct {
+	to       string
+	selector string // e.g. 0xa9059cbb
+}
+
+func NewTxIndex(tgs []*Trigger) *TxIndex {
+	idx := &TxIndex{
+		bySelector: map[selectorKey][]*Trigger{},
+		byTo:       map[string][]*Trigger{},
+		byFrom:     map[string][]*Trigger{},
+	}
+	for _, tg := range tgs {
+		idx.add(tg)
+	}
+	return idx
+}
+
+func (idx *TxIndex) add(tg *Trigger) {
+	idx.triggers = append(idx.triggers, tg)
+	// same as validateFilter, addresses are compared in lower case
+	if selectors := tg.functionSelectors(); len(selectors) > 0 {
+		for _, s := range selectors {
+			key := selectorKey{to: strings.ToLower(tg.ContractAdd), selector: s}
+			idx.bySelector[key] = append(idx.bySelector[key], tg)
+		}
+		return
+	}
+	for _, f := range tg.Filters {
+		if v, ok := f.Condition.(ConditionTo); ok {
+			to := strings.ToLower(v.Attribute)
+			idx.byTo[to] = append(idx.byTo[to], tg)
+			return
+		}
+	}
+	for _, f := range tg.Filters {
+		switch v := f.Condition.(type) {
+		case ConditionFrom:
+			from := strings.ToLower(v.Attribute)
+			idx.byFrom[from] = append(idx.byFrom[from], tg)
+			return
+		case ConditionCreator:
+			from := strings.ToLower(v.Attribute)
+			idx.byFrom[from] = append(idx.byFrom[from], tg)
+			return
+		}
+	}
+	idx.fallback = append(idx.fallback, tg)
+}
+
+// functionSelectors returns the selectors a tx must call for the trigger's first function filter to match;
+// overloaded functions have more than one. Nothing is returned if the filter can't be indexed.
+func (tg *Trigger) functionSelectors() []string {
+	for _, f := range tg.Filters {
+		switch f.Condition.(type) {
+		case ConditionFunctionCalled, ConditionFunctionParam:
+		default:
+			continue
+		}
+		if tg.ContractABI == "" {
+			return nil
+		}
+		abiObj, err := tg.getABIObj()
+		if err != nil {
+			return nil
+		}
+		var selectors []string
+		for _, method := range abiObj.Methods {
+			if method.Name == f.FunctionName {
+				selectors = append(selectors, hexutil.Encode(method.ID))
+			}
+		}
+		return selectors
+	}
+	return nil
+}
+
+// Len is the number of triggers in the index
+func (idx *TxIndex) Len() int {
+	return len(idx.triggers)
+}
+
+// Triggers are all the indexed triggers, in the order they were given
+func (idx *TxIndex) Triggers() []*Trigger {
+	return idx.triggers
+}
+
+// Candidates returns the triggers that might match tx; all the others certainly won't
+func (idx *TxIndex) Candidates(tx *tokenapi.Transaction) []*Trigger {
+	var candidates []*Trigger
+	if len(tx.Input) >= 10 {
+		key := selectorKey{to: tx.To, selector: strings.ToLower(tx.Input[:10])}
+		candidates = append(candidates, idx.bySelector[key]...)
+	}
+	candidates = append(candidates, idx.byTo[tx.To]...)
+	candidates = append(candidates, idx.byFrom[tx.From]...)
+	return append(candidates, idx.fallback...)
+}
+
+// MatchTransactions returns the same matches MatchTransaction would for every trigger, ordered by tx
+func (idx *TxIndex) MatchTransactions(block *tokenapi.Block, tokenApi tokenapi.ITokenAPI) []*TxMatch {
+	txMatches := make([]*TxMatch, 0)
+	for i := range block.Transactions {
+		tx := &block.Transactions[i]
+		for _, tg := range idx.Candidates(tx) {
+			if validateTrigger(tg, tx, block.BaseFeePerGas, tokenApi) {
+				txMatches = append(txMatches, makeTxMatch(tg, tx, block.Timestamp, block.BaseFeePerGas))
+			}
+		}
+	}
+	return txMatches
+}
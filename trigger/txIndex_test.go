@@ -0,0 +1,126 @@
+package trigger
+
+import (
+	"fmt"
+	"github.com/HAL-xyz/zoroaster/tokenapi"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+// all the WaT fixtures that can be parsed
+func loadTxTriggers(t testing.TB) []*Trigger {
+	var tgs []*Trigger
+	for i := 1; i <= 15; i++ {
+		tg, err := GetTriggerFromFile(fmt.Sprintf("../resources/triggers/t%d.json", i))
+		if err != nil {
+			continue
+		}
+		tg.TriggerUUID = fmt.Sprintf("t%d", i)
+		tgs = append(tgs, tg)
+	}
+	if len(tgs) == 0 {
+		t.Fatal("no tx triggers")
+	}
+	return tgs
+}
+
+// moveTrigger returns a copy of tg watching another address
+func moveTrigger(tg *Trigger, address string) *Trigger {
+	moved := *tg
+	moved.ContractAdd = address
+	moved.Filters = make([]Filter, len(tg.Filters))
+	for i, f := range tg.Filters {
+		switch v := f.Condition.(type) {
+		case ConditionTo:
+			v.Attribute = address
+			f.Condition = v
+		case ConditionFrom:
+			v.Attribute = address
+			f.Condition = v
+		}
+		moved.Filters[i] = f
+	}
+	return &moved
+}
+
+type matchKey struct {
+	triggerUUID string
+	txHash      string
+}
+
+func matchKeys(matches []*TxMatch) []matchKey {
+	keys := make([]matchKey, len(matches))
+	for i, m := range matches {
+		keys[i] = matchKey{m.Tg.TriggerUUID, m.Tx.Hash}
+	}
+	return keys
+}
+
+func TestTxIndex(t *testing.T) {
+	block, err := GetBlockFromFile("../resources/blocks/block1.json")
+	assert.NoError(t, err)
+	tgs := loadTxTriggers(t)
+
+	idx := NewTxIndex(tgs)
+	assert.Equal(t, len(tgs), idx.Len())
+
+	// the index finds the same matches as going through every trigger
+	var expected []*TxMatch
+	for _, tg := range tgs {
+		expected = append(expected, MatchTransaction(tg, block, mockTokenApi)...)
+	}
+	assert.NotEmpty(t, expected)
+	assert.ElementsMatch(t, matchKeys(expected), matchKeys(idx.MatchTransactions(block, mockTokenApi)))
+
+	// t1 calls transfer() on 0xe8663a64a96169ff4d95b4299e7ae9a76b905b31, like the first tx of the block
+	t1 := tgs[0]
+	assert.Equal(t, []string{"0xa9059cbb"}, t1.functionSelectors())
+	assert.Contains(t, idx.Candidates(&block.Transactions[0]), t1)
+	assert.NotContains(t, idx.Candidates(&block.Transactions[1]), t1)
+
+	// triggers without an indexable filter are always candidates
+	assert.Contains(t, idx.Candidates(&tokenapi.Transaction{}), tgs[3])
+	assert.Equal(t, len(idx.fallback), len(idx.Candidates(&tokenapi.Transaction{})))
+}
+
+// 100 copies of every fixture, each watching its own address, plus the originals
+func manyTxTriggers(b *testing.B) []*Trigger {
+	fixtures := loadTxTriggers(b)
+	tgs := append([]*Trigger{}, fixtures...)
+	for i := 0; i < 100; i++ {
+		for j, tg := range fixtures {
+			tgs = append(tgs, moveTrigger(tg, fmt.Sprintf("0x%040x", i*100+j+1)))
+		}
+	}
+	for _, tg := range tgs {
+		_ = tg.ParseABI()
+	}
+	return tgs
+}
+
+func BenchmarkMatchTransaction(b *testing.B) {
+	block, err := GetBlockFromFile("../resources/blocks/block1.json")
+	if err != nil {
+		b.Fatal(err)
+	}
+	tgs := manyTxTriggers(b)
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		for _, tg := range tgs {
+			MatchTransaction(tg, block, mockTokenApi)
+		}
+	}
+}
+
+func BenchmarkTxIndex(b *testing.B) {
+	block, err := GetBlockFromFile("../resources/blocks/block1.json")
+	if err != nil {
+		b.Fatal(err)
+	}
+	// the index is built once, when the triggers are loaded, and reused for every block
+	idx := NewTxIndex(manyTxTriggers(b))
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		idx.MatchTransactions(block, mockTokenApi)
+	}
+}
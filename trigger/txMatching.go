@@ -1,47 +1,63 @@
 package trigger
 
 import (
-	"github.com/HAL-xyz/ethrpc"
 	"github.com/HAL-xyz/zoroaster/tokenapi"
 	"github.com/HAL-xyz/zoroaster/utils"
 	log "github.com/sirupsen/logrus"
+	"math/big"
 	"strings"
 )
 
-func MatchTransaction(trigger *Trigger, block *ethrpc.Block, tokenApi tokenapi.ITokenAPI) []*TxMatch {
+func MatchTransaction(trigger *Trigger, block *tokenapi.Block, tokenApi tokenapi.ITokenAPI) []*TxMatch {
 	txMatches := make([]*TxMatch, 0)
-	for i, tx := range block.Transactions {
-		if validateTrigger(trigger, &tx, tokenApi) {
-			// we discard errors here bc not every match will have input data
-			fnArgsData, _ := decodeInputData(tx.Input, trigger.ContractABI)
-			for k, v := range fnArgsData {
-				fnArgsData[k] = utils.SprintfInterfaces([]interface{}{v})[0]
-			}
-			fnName, _ := decodeInputMethod(&tx.Input, &trigger.ContractABI)
-
-			match := TxMatch{
-				BlockTimestamp: block.Timestamp,
-				DecodedFnArgs:  fnArgsData,
-				DecodedFnName:  fnName,
-				Tx:             &block.Transactions[i],
-				Tg:             trigger,
-			}
-			txMatches = append(txMatches, &match)
+	for i := range block.Transactions {
+		if validateTrigger(trigger, &block.Transactions[i], block.BaseFeePerGas, tokenApi) {
+			txMatches = append(txMatches, makeTxMatch(trigger, &block.Transactions[i], block.Timestamp, block.BaseFeePerGas))
 		}
 	}
 	return txMatches
 }
 
-func validateTrigger(tg *Trigger, transaction *ethrpc.Transaction, tokenApi tokenapi.ITokenAPI) bool {
+// MatchPendingTransaction matches a tx from the mempool, which doesn't belong to any block yet
+func MatchPendingTransaction(trigger *Trigger, tx *tokenapi.Transaction, tokenApi tokenapi.ITokenAPI) *TxMatch {
+	// we can't tell how a tx went until it's mined, nor what the base fee will be
+	if trigger.NeedsReceipt() || !validateTrigger(trigger, tx, nil, tokenApi) {
+		return nil
+	}
+	match := makeTxMatch(trigger, tx, 0, nil)
+	match.Status = TxStatusPending
+	return match
+}
+
+func makeTxMatch(trigger *Trigger, tx *tokenapi.Transaction, blockTimestamp int, baseFee *big.Int) *TxMatch {
+	// we discard errors here bc not every match will have input data
+	fnArgsData, _ := decodeInputData(tx.Input, trigger.ContractABI)
+	for k, v := range fnArgsData {
+		fnArgsData[k] = utils.SprintfInterfaces([]interface{}{v})[0]
+	}
+	fnName, _ := decodeInputMethod(&tx.Input, &trigger.ContractABI)
+
+	return &TxMatch{
+		BlockTimestamp: blockTimestamp,
+		DecodedFnArgs:  fnArgsData,
+		DecodedFnName:  fnName,
+		Tx:             tx,
+		Tg:             trigger,
+		BaseFeePerGas:  baseFee,
+	}
+}
+
+// baseFee is the base fee of the tx's block, nil for pending txs and pre-London blocks
+func validateTrigger(tg *Trigger, transaction *tokenapi.Transaction, baseFee *big.Int, tokenApi tokenapi.ITokenAPI) bool {
 	match := true
 	for _, f := range tg.Filters {
-		filterMatch := validateFilter(transaction, &f, tg.ContractAdd, &tg.ContractABI, tg.TriggerUUID, tokenApi)
+		filterMatch := validateFilter(transaction, baseFee, &f, tg.ContractAdd, &tg.ContractABI, tg.TriggerUUID, tokenApi)
 		match = match && filterMatch // a Trigger matches if all filters match
 	}
 	return match
 }
 
-func validateFilter(ts *ethrpc.Transaction, f *Filter, cnt string, abi *string, tgUUID string, tokenApi tokenapi.ITokenAPI) bool {
+func validateFilter(ts *tokenapi.Transaction, baseFee *big.Int, f *Filter, cnt string, abi *string, tgUUID string, tokenApi tokenapi.ITokenAPI) bool {
 	cxtLog := log.WithFields(log.Fields{
 		"trigger_id": tgUUID,
 		"tx_hash":    ts.Hash,
@@ -92,6 +108,25 @@ func validateFilter(ts *ethrpc.Transaction, f *Filter, cnt string, abi *string,
 		}
 		isValid, _ := ValidateParam(dataParam, f.ParameterType, "", v.Attribute, "", v.Predicate, f.Index, Component{}, tokenApi)
 		return isValid
+	case ConditionTxType:
+		return validatePredInt(v.Predicate, ts.Type, v.Attribute)
+	case ConditionMaxFeePerGas:
+		// legacy txs don't have fee caps
+		return ts.MaxFeePerGas != nil && validatePredBigInt(v.Predicate, ts.MaxFeePerGas, v.Attribute)
+	case ConditionMaxPriorityFeePerGas:
+		return ts.MaxPriorityFeePerGas != nil && validatePredBigInt(v.Predicate, ts.MaxPriorityFeePerGas, v.Attribute)
+	case ConditionBaseFeePerGas:
+		// pending txs and pre-London blocks have no base fee
+		return baseFee != nil && validatePredBigInt(v.Predicate, baseFee, v.Attribute)
+	case ConditionCreator:
+		// contract creations don't have a recipient
+		return ts.To == "" && strings.ToLower(v.Attribute) == ts.From
+	case ConditionBytecodeHash, ConditionContainsSelector, ConditionSupportsInterface:
+		// checked against the deployed code, see ValidateDeployment
+		return ts.To == ""
+	case ConditionStatus, ConditionGasUsed, ConditionEffectiveGasPrice, ConditionFee:
+		// checked against the receipt once the tx is mined, see ValidateReceipt
+		return true
 	case ConditionFunctionCalled:
 		if !isValidContractAbi(abi, cnt, ts.To, tgUUID) {
 			return false
@@ -1,9 +1,13 @@
 package trigger
 
 import (
+	"github.com/HAL-xyz/ethrpc"
+	"github.com/HAL-xyz/zoroaster/tokenapi"
 	"github.com/HAL-xyz/zoroaster/utils"
 	log "github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/assert"
+	"math/big"
+	"strings"
 	"testing"
 )
 
@@ -17,11 +21,11 @@ func TestValidateFilter1(t *testing.T) {
 	tid, abi, cnt := trigger.TriggerUUID, &trigger.ContractABI, trigger.ContractAdd
 
 	// BasicFilter / To
-	assert.Equal(t, validateFilter(&block.Transactions[0], &trigger.Filters[0], cnt, abi, tid, mockTokenApi), true)
-	assert.Equal(t, validateFilter(&block.Transactions[1], &trigger.Filters[0], cnt, abi, tid, mockTokenApi), false)
+	assert.Equal(t, validateFilter(&block.Transactions[0], nil, &trigger.Filters[0], cnt, abi, tid, mockTokenApi), true)
+	assert.Equal(t, validateFilter(&block.Transactions[1], nil, &trigger.Filters[0], cnt, abi, tid, mockTokenApi), false)
 
 	// BasicFilter / Nonce
-	assert.Equal(t, validateFilter(&block.Transactions[0], &trigger.Filters[2], cnt, abi, tid, mockTokenApi), true)
+	assert.Equal(t, validateFilter(&block.Transactions[0], nil, &trigger.Filters[2], cnt, abi, tid, mockTokenApi), true)
 }
 
 func TestValidateFilter2(t *testing.T) {
@@ -30,8 +34,8 @@ func TestValidateFilter2(t *testing.T) {
 	tid, abi, cnt := trigger.TriggerUUID, &trigger.ContractABI, trigger.ContractAdd
 
 	// Address
-	assert.Equal(t, validateFilter(&block.Transactions[0], &trigger.Filters[1], cnt, abi, tid, mockTokenApi), true)
-	assert.Equal(t, validateFilter(&block.Transactions[1], &trigger.Filters[1], cnt, abi, tid, mockTokenApi), false)
+	assert.Equal(t, validateFilter(&block.Transactions[0], nil, &trigger.Filters[1], cnt, abi, tid, mockTokenApi), true)
+	assert.Equal(t, validateFilter(&block.Transactions[1], nil, &trigger.Filters[1], cnt, abi, tid, mockTokenApi), false)
 }
 
 func TestValidateFilter3(t *testing.T) {
@@ -40,8 +44,8 @@ func TestValidateFilter3(t *testing.T) {
 	tid, abi, cnt := trigger.TriggerUUID, &trigger.ContractABI, trigger.ContractAdd
 
 	// From
-	assert.Equal(t, validateFilter(&block.Transactions[0], &trigger.Filters[0], cnt, abi, tid, mockTokenApi), true)
-	assert.Equal(t, validateFilter(&block.Transactions[5], &trigger.Filters[0], cnt, abi, tid, mockTokenApi), false)
+	assert.Equal(t, validateFilter(&block.Transactions[0], nil, &trigger.Filters[0], cnt, abi, tid, mockTokenApi), true)
+	assert.Equal(t, validateFilter(&block.Transactions[5], nil, &trigger.Filters[0], cnt, abi, tid, mockTokenApi), false)
 }
 
 func TestValidateFilter4(t *testing.T) {
@@ -50,19 +54,19 @@ func TestValidateFilter4(t *testing.T) {
 	tid, abi, cnt := trigger.TriggerUUID, &trigger.ContractABI, trigger.ContractAdd
 
 	// Value
-	assert.Equal(t, validateFilter(&block.Transactions[2], &trigger.Filters[0], cnt, abi, tid, mockTokenApi), true)
-	assert.Equal(t, validateFilter(&block.Transactions[0], &trigger.Filters[0], cnt, abi, tid, mockTokenApi), false)
+	assert.Equal(t, validateFilter(&block.Transactions[2], nil, &trigger.Filters[0], cnt, abi, tid, mockTokenApi), true)
+	assert.Equal(t, validateFilter(&block.Transactions[0], nil, &trigger.Filters[0], cnt, abi, tid, mockTokenApi), false)
 
 	// Gas
-	assert.Equal(t, validateFilter(&block.Transactions[0], &trigger.Filters[1], cnt, abi, tid, mockTokenApi), true)
-	assert.Equal(t, validateFilter(&block.Transactions[5], &trigger.Filters[1], cnt, abi, tid, mockTokenApi), false)
+	assert.Equal(t, validateFilter(&block.Transactions[0], nil, &trigger.Filters[1], cnt, abi, tid, mockTokenApi), true)
+	assert.Equal(t, validateFilter(&block.Transactions[5], nil, &trigger.Filters[1], cnt, abi, tid, mockTokenApi), false)
 
 	// GasPrice
-	assert.Equal(t, validateFilter(&block.Transactions[7], &trigger.Filters[2], cnt, abi, tid, mockTokenApi), true)
-	assert.Equal(t, validateFilter(&block.Transactions[4], &trigger.Filters[2], cnt, abi, tid, mockTokenApi), false)
+	assert.Equal(t, validateFilter(&block.Transactions[7], nil, &trigger.Filters[2], cnt, abi, tid, mockTokenApi), true)
+	assert.Equal(t, validateFilter(&block.Transactions[4], nil, &trigger.Filters[2], cnt, abi, tid, mockTokenApi), false)
 
 	// Nonce
-	assert.Equal(t, validateFilter(&block.Transactions[5], &trigger.Filters[3], cnt, abi, tid, mockTokenApi), true)
+	assert.Equal(t, validateFilter(&block.Transactions[5], nil, &trigger.Filters[3], cnt, abi, tid, mockTokenApi), true)
 }
 
 func TestValidateFilter5(t *testing.T) {
@@ -71,19 +75,19 @@ func TestValidateFilter5(t *testing.T) {
 	tid, abi, cnt := trigger.TriggerUUID, &trigger.ContractABI, trigger.ContractAdd
 
 	// uint256[]
-	assert.Equal(t, validateFilter(tx, &trigger.Filters[0], cnt, abi, tid, mockTokenApi), false)
-	assert.Equal(t, validateFilter(tx, &trigger.Filters[1], cnt, abi, tid, mockTokenApi), true)
-	assert.Equal(t, validateFilter(tx, &trigger.Filters[2], cnt, abi, tid, mockTokenApi), false)
+	assert.Equal(t, validateFilter(tx, nil, &trigger.Filters[0], cnt, abi, tid, mockTokenApi), false)
+	assert.Equal(t, validateFilter(tx, nil, &trigger.Filters[1], cnt, abi, tid, mockTokenApi), true)
+	assert.Equal(t, validateFilter(tx, nil, &trigger.Filters[2], cnt, abi, tid, mockTokenApi), false)
 
 	// bytes14[]
-	assert.Equal(t, validateFilter(tx, &trigger.Filters[3], cnt, abi, tid, mockTokenApi), true)
-	assert.Equal(t, validateFilter(tx, &trigger.Filters[4], cnt, abi, tid, mockTokenApi), true)
+	assert.Equal(t, validateFilter(tx, nil, &trigger.Filters[3], cnt, abi, tid, mockTokenApi), true)
+	assert.Equal(t, validateFilter(tx, nil, &trigger.Filters[4], cnt, abi, tid, mockTokenApi), true)
 
 	// Gas
-	assert.Equal(t, validateFilter(tx, &trigger.Filters[5], cnt, abi, tid, mockTokenApi), true)
+	assert.Equal(t, validateFilter(tx, nil, &trigger.Filters[5], cnt, abi, tid, mockTokenApi), true)
 
 	// Nonce
-	assert.Equal(t, validateFilter(tx, &trigger.Filters[6], cnt, abi, tid, mockTokenApi), true)
+	assert.Equal(t, validateFilter(tx, nil, &trigger.Filters[6], cnt, abi, tid, mockTokenApi), true)
 }
 
 func TestValidateFilter6(t *testing.T) {
@@ -92,12 +96,12 @@ func TestValidateFilter6(t *testing.T) {
 	tid, abi, cnt := trigger.TriggerUUID, &trigger.ContractABI, trigger.ContractAdd
 
 	// address[N]
-	assert.Equal(t, validateFilter(tx, &trigger.Filters[0], cnt, abi, tid, mockTokenApi), true)
+	assert.Equal(t, validateFilter(tx, nil, &trigger.Filters[0], cnt, abi, tid, mockTokenApi), true)
 
 	// uint256[N]
-	assert.Equal(t, validateFilter(tx, &trigger.Filters[1], cnt, abi, tid, mockTokenApi), true)
-	assert.Equal(t, validateFilter(tx, &trigger.Filters[2], cnt, abi, tid, mockTokenApi), true)
-	assert.Equal(t, validateFilter(tx, &trigger.Filters[3], cnt, abi, tid, mockTokenApi), true)
+	assert.Equal(t, validateFilter(tx, nil, &trigger.Filters[1], cnt, abi, tid, mockTokenApi), true)
+	assert.Equal(t, validateFilter(tx, nil, &trigger.Filters[2], cnt, abi, tid, mockTokenApi), true)
+	assert.Equal(t, validateFilter(tx, nil, &trigger.Filters[3], cnt, abi, tid, mockTokenApi), true)
 }
 
 func TestValidateFilter7(t *testing.T) {
@@ -106,13 +110,13 @@ func TestValidateFilter7(t *testing.T) {
 	tid, abi, cnt := trigger.TriggerUUID, &trigger.ContractABI, trigger.ContractAdd
 
 	// uint256
-	assert.Equal(t, validateFilter(tx, &trigger.Filters[0], cnt, abi, tid, mockTokenApi), true)
+	assert.Equal(t, validateFilter(tx, nil, &trigger.Filters[0], cnt, abi, tid, mockTokenApi), true)
 
 	// bool
-	assert.Equal(t, validateFilter(tx, &trigger.Filters[1], cnt, abi, tid, mockTokenApi), true)
+	assert.Equal(t, validateFilter(tx, nil, &trigger.Filters[1], cnt, abi, tid, mockTokenApi), true)
 
 	// int128
-	assert.Equal(t, validateFilter(tx, &trigger.Filters[2], cnt, abi, tid, mockTokenApi), true)
+	assert.Equal(t, validateFilter(tx, nil, &trigger.Filters[2], cnt, abi, tid, mockTokenApi), true)
 }
 
 func TestValidateFilter8(t *testing.T) {
@@ -121,13 +125,13 @@ func TestValidateFilter8(t *testing.T) {
 	tid, abi, cnt := trigger.TriggerUUID, &trigger.ContractABI, trigger.ContractAdd
 
 	// int128[N]
-	assert.Equal(t, validateFilter(tx, &trigger.Filters[0], cnt, abi, tid, mockTokenApi), true)
+	assert.Equal(t, validateFilter(tx, nil, &trigger.Filters[0], cnt, abi, tid, mockTokenApi), true)
 
 	// int[N]
-	assert.Equal(t, validateFilter(tx, &trigger.Filters[1], cnt, abi, tid, mockTokenApi), true)
+	assert.Equal(t, validateFilter(tx, nil, &trigger.Filters[1], cnt, abi, tid, mockTokenApi), true)
 
 	// int40
-	assert.Equal(t, validateFilter(tx, &trigger.Filters[2], cnt, abi, tid, mockTokenApi), true)
+	assert.Equal(t, validateFilter(tx, nil, &trigger.Filters[2], cnt, abi, tid, mockTokenApi), true)
 }
 
 func TestValidateFilter9(t *testing.T) {
@@ -136,18 +140,18 @@ func TestValidateFilter9(t *testing.T) {
 	tid, abi, cnt := trigger.TriggerUUID, &trigger.ContractABI, trigger.ContractAdd
 
 	// int32
-	assert.Equal(t, validateFilter(tx, &trigger.Filters[0], cnt, abi, tid, mockTokenApi), true)
+	assert.Equal(t, validateFilter(tx, nil, &trigger.Filters[0], cnt, abi, tid, mockTokenApi), true)
 
 	// int32[]
-	assert.Equal(t, validateFilter(tx, &trigger.Filters[1], cnt, abi, tid, mockTokenApi), true)
+	assert.Equal(t, validateFilter(tx, nil, &trigger.Filters[1], cnt, abi, tid, mockTokenApi), true)
 
 	// int32[6]
-	assert.Equal(t, validateFilter(tx, &trigger.Filters[2], cnt, abi, tid, mockTokenApi), true)
-	assert.Equal(t, validateFilter(tx, &trigger.Filters[10], cnt, abi, tid, mockTokenApi), true)
+	assert.Equal(t, validateFilter(tx, nil, &trigger.Filters[2], cnt, abi, tid, mockTokenApi), true)
+	assert.Equal(t, validateFilter(tx, nil, &trigger.Filters[10], cnt, abi, tid, mockTokenApi), true)
 
 	// Index int32[]
-	assert.Equal(t, validateFilter(tx, &trigger.Filters[7], cnt, abi, tid, mockTokenApi), true)
-	assert.Equal(t, validateFilter(tx, &trigger.Filters[8], cnt, abi, tid, mockTokenApi), false)
+	assert.Equal(t, validateFilter(tx, nil, &trigger.Filters[7], cnt, abi, tid, mockTokenApi), true)
+	assert.Equal(t, validateFilter(tx, nil, &trigger.Filters[8], cnt, abi, tid, mockTokenApi), false)
 }
 
 func TestValidateFilter10(t *testing.T) {
@@ -156,15 +160,15 @@ func TestValidateFilter10(t *testing.T) {
 	tid, abi, cnt := trigger.TriggerUUID, &trigger.ContractABI, trigger.ContractAdd
 
 	// address[]
-	assert.Equal(t, validateFilter(tx, &trigger.Filters[3], cnt, abi, tid, mockTokenApi), true)
-	assert.Equal(t, validateFilter(tx, &trigger.Filters[4], cnt, abi, tid, mockTokenApi), true)
+	assert.Equal(t, validateFilter(tx, nil, &trigger.Filters[3], cnt, abi, tid, mockTokenApi), true)
+	assert.Equal(t, validateFilter(tx, nil, &trigger.Filters[4], cnt, abi, tid, mockTokenApi), true)
 
 	// bytes1[]
-	assert.Equal(t, validateFilter(tx, &trigger.Filters[5], cnt, abi, tid, mockTokenApi), true)
+	assert.Equal(t, validateFilter(tx, nil, &trigger.Filters[5], cnt, abi, tid, mockTokenApi), true)
 
 	// string[]
-	assert.Equal(t, validateFilter(tx, &trigger.Filters[6], cnt, abi, tid, mockTokenApi), true)
-	assert.Equal(t, validateFilter(tx, &trigger.Filters[9], cnt, abi, tid, mockTokenApi), false)
+	assert.Equal(t, validateFilter(tx, nil, &trigger.Filters[6], cnt, abi, tid, mockTokenApi), true)
+	assert.Equal(t, validateFilter(tx, nil, &trigger.Filters[9], cnt, abi, tid, mockTokenApi), false)
 }
 
 func TestValidateFilter11(t *testing.T) {
@@ -176,11 +180,11 @@ func TestValidateFilter11(t *testing.T) {
 	tid, abi, cnt := trigger.TriggerUUID, &trigger.ContractABI, trigger.ContractAdd
 
 	// wrong func param type - for now we're just happy to log and assume the filter didn't match
-	assert.Equal(t, validateFilter(tx, &trigger.Filters[0], cnt, abi, tid, mockTokenApi), false)
-	assert.Equal(t, validateFilter(tx, &trigger.Filters[1], cnt, abi, tid, mockTokenApi), false)
+	assert.Equal(t, validateFilter(tx, nil, &trigger.Filters[0], cnt, abi, tid, mockTokenApi), false)
+	assert.Equal(t, validateFilter(tx, nil, &trigger.Filters[1], cnt, abi, tid, mockTokenApi), false)
 
 	// checkFunctionCalled
-	assert.Equal(t, validateFilter(tx, &trigger.Filters[2], cnt, abi, tid, mockTokenApi), true)
+	assert.Equal(t, validateFilter(tx, nil, &trigger.Filters[2], cnt, abi, tid, mockTokenApi), true)
 
 	log.SetLevel(log.DebugLevel)
 }
@@ -191,19 +195,19 @@ func TestValidateFilter12(t *testing.T) {
 	tid, abi, cnt := trigger.TriggerUUID, &trigger.ContractABI, trigger.ContractAdd
 
 	// Index on bigInt[]
-	assert.Equal(t, validateFilter(tx, &trigger.Filters[1], cnt, abi, tid, mockTokenApi), true)
-	assert.Equal(t, validateFilter(tx, &trigger.Filters[2], cnt, abi, tid, mockTokenApi), false)
+	assert.Equal(t, validateFilter(tx, nil, &trigger.Filters[1], cnt, abi, tid, mockTokenApi), true)
+	assert.Equal(t, validateFilter(tx, nil, &trigger.Filters[2], cnt, abi, tid, mockTokenApi), false)
 
 	// Index on address[]
-	assert.Equal(t, validateFilter(tx, &trigger.Filters[3], cnt, abi, tid, mockTokenApi), true)
-	assert.Equal(t, validateFilter(tx, &trigger.Filters[4], cnt, abi, tid, mockTokenApi), false)
-	assert.Equal(t, validateFilter(tx, &trigger.Filters[5], cnt, abi, tid, mockTokenApi), false)
+	assert.Equal(t, validateFilter(tx, nil, &trigger.Filters[3], cnt, abi, tid, mockTokenApi), true)
+	assert.Equal(t, validateFilter(tx, nil, &trigger.Filters[4], cnt, abi, tid, mockTokenApi), false)
+	assert.Equal(t, validateFilter(tx, nil, &trigger.Filters[5], cnt, abi, tid, mockTokenApi), false)
 
 	// ConditionFunctionCalled
-	assert.Equal(t, validateFilter(tx, &trigger.Filters[6], cnt, abi, tid, mockTokenApi), true)
+	assert.Equal(t, validateFilter(tx, nil, &trigger.Filters[6], cnt, abi, tid, mockTokenApi), true)
 
 	// address[]
-	assert.Equal(t, validateFilter(tx, &trigger.Filters[7], cnt, abi, tid, mockTokenApi), true)
+	assert.Equal(t, validateFilter(tx, nil, &trigger.Filters[7], cnt, abi, tid, mockTokenApi), true)
 }
 
 func TestValidateFilter13(t *testing.T) {
@@ -216,11 +220,11 @@ func TestValidateFilter13(t *testing.T) {
 
 	// CheckFunctionParameter - different method name
 	trigger.Filters[7].FunctionName = "xxx"
-	assert.Equal(t, validateFilter(tx, &trigger.Filters[7], cnt, abi, tid, mockTokenApi), false)
+	assert.Equal(t, validateFilter(tx, nil, &trigger.Filters[7], cnt, abi, tid, mockTokenApi), false)
 
 	// ConditionFunctionCalled - wrong ABI
 	trigger.ContractABI = "xxx"
-	assert.Equal(t, validateFilter(tx, &trigger.Filters[6], cnt, abi, tid, mockTokenApi), false)
+	assert.Equal(t, validateFilter(tx, nil, &trigger.Filters[6], cnt, abi, tid, mockTokenApi), false)
 
 	log.SetLevel(log.DebugLevel)
 }
@@ -231,7 +235,7 @@ func TestValidateFilter14(t *testing.T) {
 	tid, abi, cnt := trigger.TriggerUUID, &trigger.ContractABI, trigger.ContractAdd
 
 	// uint32
-	assert.Equal(t, validateFilter(tx, &trigger.Filters[1], cnt, abi, tid, mockTokenApi), true)
+	assert.Equal(t, validateFilter(tx, nil, &trigger.Filters[1], cnt, abi, tid, mockTokenApi), true)
 }
 
 func TestValidateFilter15(t *testing.T) {
@@ -240,7 +244,7 @@ func TestValidateFilter15(t *testing.T) {
 	tid, abi, cnt := trigger.TriggerUUID, &trigger.ContractABI, trigger.ContractAdd
 
 	// uint16
-	assert.Equal(t, validateFilter(tx, &trigger.Filters[1], cnt, abi, tid, mockTokenApi), true)
+	assert.Equal(t, validateFilter(tx, nil, &trigger.Filters[1], cnt, abi, tid, mockTokenApi), true)
 }
 
 // Testing one Trigger vs one Transaction
@@ -248,20 +252,21 @@ func TestValidateTrigger(t *testing.T) {
 	block, _ := GetBlockFromFile("../resources/blocks/block1.json")
 	trigger, _ := GetTriggerFromFile("../resources/triggers/t1.json")
 
-	assert.Equal(t, validateTrigger(trigger, &block.Transactions[0], mockTokenApi), true)
-	assert.Equal(t, validateTrigger(trigger, &block.Transactions[1], mockTokenApi), false)
+	assert.Equal(t, validateTrigger(trigger, &block.Transactions[0], nil, mockTokenApi), true)
+	assert.Equal(t, validateTrigger(trigger, &block.Transactions[1], nil, mockTokenApi), false)
 }
 
 func TestValidateTrigger2(t *testing.T) {
 	block, _ := GetBlockFromFile("../resources/blocks/block1.json")
 	trigger, _ := GetTriggerFromFile("../resources/triggers/t2.json")
 
-	assert.Equal(t, validateTrigger(trigger, &block.Transactions[6], mockTokenApi), true)
-	assert.Equal(t, validateTrigger(trigger, &block.Transactions[1], mockTokenApi), false)
-	assert.Equal(t, validateTrigger(trigger, &block.Transactions[8], mockTokenApi), true)
+	assert.Equal(t, validateTrigger(trigger, &block.Transactions[6], nil, mockTokenApi), true)
+	assert.Equal(t, validateTrigger(trigger, &block.Transactions[1], nil, mockTokenApi), false)
+	assert.Equal(t, validateTrigger(trigger, &block.Transactions[8], nil, mockTokenApi), true)
 }
 
 func TestValidateTriggerWithNoInputData(t *testing.T) {
+	useLiveNodes(t)
 
 	trigger, err := GetTriggerFromFile("../resources/triggers/t15.json")
 	assert.NoError(t, err)
@@ -338,3 +343,175 @@ func TestJsonToBlock(t *testing.T) {
 	assert.Equal(t, block.Number, 7535077)
 	assert.Equal(t, block.Size, 5392)
 }
+
+func TestMatchPendingTransaction(t *testing.T) {
+	block, _ := GetBlockFromFile("../resources/blocks/block1.json")
+	trigger, _ := GetTriggerFromFile("../resources/triggers/t2.json")
+
+	// pending txs don't belong to any block yet
+	tx := block.Transactions[6]
+	tx.BlockHash, tx.BlockNumber, tx.TransactionIndex = "", nil, nil
+
+	match := MatchPendingTransaction(trigger, &tx, mockTokenApi)
+	assert.NotNil(t, match)
+	assert.Equal(t, TxStatusPending, match.Status)
+	assert.Equal(t, "transfer", *match.DecodedFnName)
+	assert.Equal(t, "pending", match.ToTemplateMatch().Tx.Status)
+
+	assert.Nil(t, MatchPendingTransaction(trigger, &block.Transactions[0], mockTokenApi))
+}
+
+func TestValidateReceipt(t *testing.T) {
+	js := `
+{
+  "TriggerName": "failed txs to the DAO",
+  "TriggerType": "WatchTransactions",
+  "ContractAdd": "0xbb9bc244d798123fde783fcc1c72d3bb8c189413",
+  "Filters": [
+    {
+      "FilterType": "BasicFilter",
+      "ParameterName": "To",
+      "Condition": {"Predicate": "Eq", "Attribute": "0xbb9bc244d798123fde783fcc1c72d3bb8c189413"}
+    },
+    {
+      "FilterType": "BasicFilter",
+      "ParameterName": "Status",
+      "Condition": {"Predicate": "Eq", "Attribute": "0"}
+    },
+    {
+      "FilterType": "BasicFilter",
+      "ParameterName": "Fee",
+      "Condition": {"Predicate": "BiggerThan", "Attribute": "1000000000000000"}
+    }
+  ]
+}`
+	tg, err := NewTriggerFromJson(js, nil)
+	assert.NoError(t, err)
+	assert.True(t, tg.NeedsReceipt())
+
+	tx := tokenapi.Transaction{Transaction: ethrpc.Transaction{To: "0xbb9bc244d798123fde783fcc1c72d3bb8c189413", GasPrice: *big.NewInt(100000000000)}}
+
+	// receipt filters are left for later
+	assert.True(t, validateTrigger(tg, &tx, nil, mockTokenApi))
+	assert.Nil(t, MatchPendingTransaction(tg, &tx, mockTokenApi))
+
+	// no effectiveGasPrice before London: we use the gas price instead
+	reverted := NewReceipt(tokenapi.TxReceipt{Status: 0, GasUsed: 21000}, &tx)
+	assert.Equal(t, "100000000000", reverted.EffectiveGasPrice.String())
+	assert.Equal(t, "2100000000000000", reverted.Fee.String())
+	assert.True(t, tg.ValidateReceipt(reverted))
+
+	succeeded := NewReceipt(tokenapi.TxReceipt{Status: 1, GasUsed: 21000}, &tx)
+	assert.False(t, tg.ValidateReceipt(succeeded))
+
+	cheap := NewReceipt(tokenapi.TxReceipt{Status: 0, GasUsed: 21000, EffectiveGasPrice: big.NewInt(1000000000)}, &tx)
+	assert.Equal(t, "21000000000000", cheap.Fee.String())
+	assert.False(t, tg.ValidateReceipt(cheap))
+
+	assert.False(t, tg.ValidateReceipt(nil))
+
+	// triggers without receipt filters don't care
+	tg.Filters = tg.Filters[:1]
+	assert.False(t, tg.NeedsReceipt())
+	assert.True(t, tg.ValidateReceipt(nil))
+
+	// invalid status
+	_, err = NewTriggerFromJson(strings.Replace(js, `"Attribute": "0"}`, `"Attribute": "2"}`, 1), nil)
+	assert.Error(t, err)
+}
+
+func TestMatchTypedTransactions(t *testing.T) {
+	block, err := tokenapi.DecodeBlock([]byte(`{
+  "number": "0xc5d488",
+  "hash": "0x4a1b8c5b0f36f0e1bd2b9e6f4fd4f6f4a0b3e71c7b54d2d0a3b1c3f0e5a8d911",
+  "timestamp": "0x610bcb05",
+  "baseFeePerGas": "0x1bf08eb000",
+  "transactions": [
+    {
+      "blockHash": "0x4a1b8c5b0f36f0e1bd2b9e6f4fd4f6f4a0b3e71c7b54d2d0a3b1c3f0e5a8d911",
+      "blockNumber": "0xc5d488",
+      "from": "0x0216d5032f356960cd3749c31ab34eeff21b3395",
+      "gas": "0x5208",
+      "gasPrice": "0x1bf08eb000",
+      "hash": "0x6e1c8a9d3b0c7e6c6b7b9fdfc1b8f6e9e3a0a4a4d1e2c3b4a5968778695a4b3c",
+      "input": "0x",
+      "nonce": "0x1",
+      "to": "0x7f69a91a3cf4be60020fb58b893b7cbb65376db8",
+      "value": "0x0"
+    },
+    {
+      "blockHash": "0x4a1b8c5b0f36f0e1bd2b9e6f4fd4f6f4a0b3e71c7b54d2d0a3b1c3f0e5a8d911",
+      "blockNumber": "0xc5d488",
+      "from": "0x0216d5032f356960cd3749c31ab34eeff21b3395",
+      "gas": "0x5208",
+      "gasPrice": "0x1d1a94a200",
+      "maxFeePerGas": "0x2540be4000",
+      "maxPriorityFeePerGas": "0x12a05f200",
+      "type": "0x2",
+      "accessList": [],
+      "hash": "0x8f2d6c5e4b3a29180716f5e4d3c2b1a09f8e7d6c5b4a3928170615f4e3d2c1b0",
+      "input": "0x",
+      "nonce": "0x2",
+      "to": "0x7f69a91a3cf4be60020fb58b893b7cbb65376db8",
+      "value": "0x0"
+    }
+  ]
+}`))
+	assert.NoError(t, err)
+
+	js := `
+{
+  "TriggerName": "overpaying txs",
+  "TriggerType": "WatchTransactions",
+  "Filters": [
+    {
+      "FilterType": "BasicFilter",
+      "ParameterName": "Type",
+      "Condition": {"Predicate": "Eq", "Attribute": "2"}
+    },
+    {
+      "FilterType": "BasicFilter",
+      "ParameterName": "MaxPriorityFeePerGas",
+      "Condition": {"Predicate": "BiggerThan", "Attribute": "2000000000"}
+    },
+    {
+      "FilterType": "BasicFilter",
+      "ParameterName": "BaseFeePerGas",
+      "Condition": {"Predicate": "BiggerThan", "Attribute": "100000000000"}
+    }
+  ]
+}`
+	tg, err := NewTriggerFromJson(js, nil)
+	assert.NoError(t, err)
+
+	matches := MatchTransaction(tg, block, mockTokenApi)
+	assert.Len(t, matches, 1)
+	assert.Equal(t, block.Transactions[1].Hash, matches[0].Tx.Hash)
+
+	tx := matches[0].ToTemplateMatch().Tx
+	assert.Equal(t, 2, tx.Type)
+	assert.Equal(t, "160000000000", tx.MaxFeePerGas.String())
+	assert.Equal(t, "5000000000", tx.MaxPriorityFeePerGas.String())
+	assert.Equal(t, "120000000000", matches[0].ToTemplateMatch().Block.BaseFeePerGas.String())
+
+	persistent := matches[0].ToPersistent().(*PersistentTxMatch)
+	assert.Equal(t, 2, persistent.PTx.Type)
+	assert.Equal(t, "120000000000", persistent.PTx.BaseFeePerGas.String())
+
+	// legacy txs have no fee caps
+	tg.Filters = tg.Filters[1:]
+	assert.Len(t, MatchTransaction(tg, block, mockTokenApi), 1)
+
+	// pending txs have no base fee
+	assert.Nil(t, MatchPendingTransaction(tg, &block.Transactions[1], mockTokenApi))
+
+	// blocks built by hand, rather than fetched, carry their typed fields too
+	handBuilt := &tokenapi.Block{BaseFeePerGas: big.NewInt(120000000000), Transactions: []tokenapi.Transaction{block.Transactions[1]}}
+	assert.Len(t, MatchTransaction(tg, handBuilt, mockTokenApi), 1)
+	handBuilt.BaseFeePerGas = nil
+	assert.Len(t, MatchTransaction(tg, handBuilt, mockTokenApi), 0)
+
+	// only legacy, access list and dynamic fee txs
+	_, err = NewTriggerFromJson(strings.Replace(js, `"Attribute": "2"}`, `"Attribute": "3"}`, 1), nil)
+	assert.Error(t, err)
+}
@@ -3,6 +3,7 @@ package trigger
 import (
 	"encoding/json"
 	"github.com/HAL-xyz/ethrpc"
+	"github.com/HAL-xyz/zoroaster/tokenapi"
 	"math/big"
 )
 
@@ -27,7 +28,7 @@ type IPostablePaylaod interface {
 	isPostablePayload()
 }
 
-// The basic templateble match, shared between WaT/WaC/WaE
+// The basic templateble match, shared between WaT/WaC/WaE/WaB
 // this is the interface users have access to when writing actions.
 type TemplateMatch struct {
 	Block    TemplateBlock
@@ -35,23 +36,34 @@ type TemplateMatch struct {
 	Tx       TemplateTx
 }
 
-// templating Block, shared between WaT/WaC/WaE
+// templating Block, shared between WaT/WaC/WaE/WaB
 type TemplateBlock struct {
-	Hash      string
-	Number    *int
-	Timestamp int
+	Hash          string
+	Number        *int
+	Timestamp     int
+	BaseFeePerGas *big.Int    // nil before London
+	Stats         *BlockStats // WaB only
 }
 
 // templating Transaction
 type TemplateTx struct {
-	From      string
-	Gas       int
-	GasPrice  *big.Int
-	Nonce     int
-	To        string
-	Hash      string
-	Value     *big.Int
-	InputData string
+	From                 string
+	Gas                  int
+	GasPrice             *big.Int
+	Nonce                int
+	To                   string
+	Hash                 string
+	Value                *big.Int
+	InputData            string
+	Status               string   // pending, mined, failed or dropped; empty unless the tx was seen in the mempool
+	CallDepth            int      // 0 for top-level txs
+	TracePath            []int    // internal calls only: the index of the call at every depth
+	Receipt              *Receipt // nil until the tx is mined
+	Type                 int      // 0 legacy, 1 access list, 2 dynamic fee
+	MaxFeePerGas         *big.Int // type 2 only
+	MaxPriorityFeePerGas *big.Int // type 2 only
+	AccessList           []tokenapi.AccessTuple
+	Deployment           *Deployment // contract creations only
 }
 
 // templating Contract, shared between WaT/WaC/WaE
@@ -67,13 +79,33 @@ type TemplateContract struct {
 
 // TX MATCH
 
+// the life cycle of a tx matched while still in the mempool
+const (
+	TxStatusPending = "pending"
+	TxStatusMined   = "mined"
+	TxStatusDropped = "dropped"
+	TxStatusFailed  = "failed" // mined, but it doesn't match anymore
+)
+
 type TxMatch struct {
 	MatchUUID      string
 	Tg             *Trigger
 	BlockTimestamp int
 	DecodedFnArgs  map[string]interface{} `json:"DecodedFnArgs,omitempty"`
 	DecodedFnName  *string                `json:"DecodedFnName,omitempty"`
-	Tx             *ethrpc.Transaction
+	Tx             *tokenapi.Transaction  // with its EIP-2718/EIP-1559 fields
+	// only set for txs we've seen in the mempool;
+	// follow-up matches point back to the pending match
+	Status           string
+	PendingMatchUUID string
+	// internal calls only: how deep the call is, and how we got there from the top-level tx
+	CallDepth int
+	TracePath []int
+	Receipt   *Receipt
+	// the base fee of the tx's block; nil for pending txs and pre-London blocks
+	BaseFeePerGas *big.Int
+	// contract creations only
+	Deployment *Deployment
 }
 
 func (m TxMatch) ToTemplateMatch() TemplateMatch {
@@ -83,9 +115,10 @@ func (m TxMatch) ToTemplateMatch() TemplateMatch {
 	}
 	t := TemplateMatch{
 		Block: TemplateBlock{
-			Hash:      m.Tx.BlockHash,
-			Number:    m.Tx.BlockNumber,
-			Timestamp: m.BlockTimestamp,
+			Hash:          m.Tx.BlockHash,
+			Number:        m.Tx.BlockNumber,
+			Timestamp:     m.BlockTimestamp,
+			BaseFeePerGas: m.BaseFeePerGas,
 		},
 	}
 	c := TemplateContract{
@@ -96,14 +129,23 @@ func (m TxMatch) ToTemplateMatch() TemplateMatch {
 	t.Contract = c
 
 	tx := TemplateTx{
-		From:      m.Tx.From,
-		Gas:       m.Tx.Gas,
-		GasPrice:  &m.Tx.GasPrice,
-		Nonce:     m.Tx.Nonce,
-		To:        m.Tx.To,
-		Hash:      m.Tx.Hash,
-		Value:     &m.Tx.Value,
-		InputData: m.Tx.Input,
+		From:                 m.Tx.From,
+		Gas:                  m.Tx.Gas,
+		GasPrice:             &m.Tx.GasPrice,
+		Nonce:                m.Tx.Nonce,
+		To:                   m.Tx.To,
+		Hash:                 m.Tx.Hash,
+		Value:                &m.Tx.Value,
+		InputData:            m.Tx.Input,
+		Status:               m.Status,
+		CallDepth:            m.CallDepth,
+		TracePath:            m.TracePath,
+		Receipt:              m.Receipt,
+		Type:                 m.Tx.Type,
+		MaxFeePerGas:         m.Tx.MaxFeePerGas,
+		MaxPriorityFeePerGas: m.Tx.MaxPriorityFeePerGas,
+		AccessList:           m.Tx.AccessList,
+		Deployment:           m.Deployment,
 	}
 	t.Tx = tx
 
@@ -111,17 +153,29 @@ func (m TxMatch) ToTemplateMatch() TemplateMatch {
 }
 
 type PersistentTx struct {
-	BlockHash      string
-	BlockNumber    *int
-	BlockTimestamp int
-	From           string
-	Gas            int
-	GasPrice       *big.Int
-	Nonce          int
-	To             string
-	Hash           string
-	Value          *big.Int
-	InputData      string
+	BlockHash        string
+	BlockNumber      *int
+	BlockTimestamp   int
+	From             string
+	Gas              int
+	GasPrice         *big.Int
+	Nonce            int
+	To               string
+	Hash             string
+	Value            *big.Int
+	InputData        string
+	Status           string   `json:"Status,omitempty"`
+	PendingMatchUUID string   `json:"PendingMatchUUID,omitempty"`
+	CallDepth        int      `json:"CallDepth,omitempty"`
+	TracePath        []int    `json:"TracePath,omitempty"`
+	Receipt          *Receipt `json:"Receipt,omitempty"`
+	// EIP-2718/EIP-1559 fields; omitted for legacy txs
+	Type                 int                    `json:"Type,omitempty"`
+	MaxFeePerGas         *big.Int               `json:"MaxFeePerGas,omitempty"`
+	MaxPriorityFeePerGas *big.Int               `json:"MaxPriorityFeePerGas,omitempty"`
+	AccessList           []tokenapi.AccessTuple `json:"AccessList,omitempty"`
+	BaseFeePerGas        *big.Int               `json:"BaseFeePerGas,omitempty"`
+	Deployment           *Deployment            `json:"Deployment,omitempty"`
 }
 
 type PersistentTxMatch struct {
@@ -135,17 +189,28 @@ type PersistentTxMatch struct {
 func (m TxMatch) ToPersistent() IPersistableMatch {
 	return &PersistentTxMatch{
 		PTx: PersistentTx{
-			BlockHash:      m.Tx.BlockHash,
-			BlockNumber:    m.Tx.BlockNumber,
-			BlockTimestamp: m.BlockTimestamp,
-			From:           m.Tx.From,
-			Gas:            m.Tx.Gas,
-			GasPrice:       &m.Tx.GasPrice,
-			Nonce:          m.Tx.Nonce,
-			To:             m.Tx.To,
-			Hash:           m.Tx.Hash,
-			Value:          &m.Tx.Value,
-			InputData:      m.Tx.Input,
+			BlockHash:            m.Tx.BlockHash,
+			BlockNumber:          m.Tx.BlockNumber,
+			BlockTimestamp:       m.BlockTimestamp,
+			From:                 m.Tx.From,
+			Gas:                  m.Tx.Gas,
+			GasPrice:             &m.Tx.GasPrice,
+			Nonce:                m.Tx.Nonce,
+			To:                   m.Tx.To,
+			Hash:                 m.Tx.Hash,
+			Value:                &m.Tx.Value,
+			InputData:            m.Tx.Input,
+			Status:               m.Status,
+			PendingMatchUUID:     m.PendingMatchUUID,
+			CallDepth:            m.CallDepth,
+			TracePath:            m.TracePath,
+			Receipt:              m.Receipt,
+			Type:                 m.Tx.Type,
+			MaxFeePerGas:         m.Tx.MaxFeePerGas,
+			MaxPriorityFeePerGas: m.Tx.MaxPriorityFeePerGas,
+			AccessList:           m.Tx.AccessList,
+			BaseFeePerGas:        m.BaseFeePerGas,
+			Deployment:           m.Deployment,
 		},
 		DecodedData: struct {
 			FunctionArguments map[string]interface{}
@@ -189,17 +254,28 @@ func (TxPostPayload) isPostablePayload() {}
 func (m TxMatch) ToPostPayload() IPostablePaylaod {
 	return TxPostPayload{
 		Transaction: PersistentTx{
-			BlockHash:      m.Tx.BlockHash,
-			BlockNumber:    m.Tx.BlockNumber,
-			BlockTimestamp: m.BlockTimestamp,
-			From:           m.Tx.From,
-			Gas:            m.Tx.Gas,
-			GasPrice:       &m.Tx.GasPrice,
-			Nonce:          m.Tx.Nonce,
-			To:             m.Tx.To,
-			Hash:           m.Tx.Hash,
-			Value:          &m.Tx.Value,
-			InputData:      m.Tx.Input,
+			BlockHash:            m.Tx.BlockHash,
+			BlockNumber:          m.Tx.BlockNumber,
+			BlockTimestamp:       m.BlockTimestamp,
+			From:                 m.Tx.From,
+			Gas:                  m.Tx.Gas,
+			GasPrice:             &m.Tx.GasPrice,
+			Nonce:                m.Tx.Nonce,
+			To:                   m.Tx.To,
+			Hash:                 m.Tx.Hash,
+			Value:                &m.Tx.Value,
+			InputData:            m.Tx.Input,
+			Status:               m.Status,
+			PendingMatchUUID:     m.PendingMatchUUID,
+			CallDepth:            m.CallDepth,
+			TracePath:            m.TracePath,
+			Receipt:              m.Receipt,
+			Type:                 m.Tx.Type,
+			MaxFeePerGas:         m.Tx.MaxFeePerGas,
+			MaxPriorityFeePerGas: m.Tx.MaxPriorityFeePerGas,
+			AccessList:           m.Tx.AccessList,
+			BaseFeePerGas:        m.BaseFeePerGas,
+			Deployment:           m.Deployment,
 		},
 		DecodedData: struct {
 			FunctionArguments map[string]interface{}
@@ -223,6 +299,7 @@ type CnMatch struct {
 	BlockNumber    int
 	BlockTimestamp int
 	BlockHash      string
+	BaseFeePerGas  *big.Int // nil before London
 	MatchUUID      string
 	MatchedValues  []string
 	AllValues      []interface{}
@@ -230,9 +307,10 @@ type CnMatch struct {
 
 func (m CnMatch) ToTemplateMatch() TemplateMatch {
 	b := TemplateBlock{
-		Hash:      m.BlockHash,
-		Number:    &m.BlockNumber,
-		Timestamp: m.BlockTimestamp,
+		Hash:          m.BlockHash,
+		Number:        &m.BlockNumber,
+		Timestamp:     m.BlockTimestamp,
+		BaseFeePerGas: m.BaseFeePerGas,
 	}
 	c := TemplateContract{
 		Address:        m.Trigger.ContractAdd,
@@ -346,13 +424,16 @@ type EventMatch struct {
 	BlockTimestamp int
 	TxTo           string
 	TxFrom         string
+	Receipt        *Receipt // the receipt of the tx that emitted the event
+	BaseFeePerGas  *big.Int // nil before London
 }
 
 func (m EventMatch) ToTemplateMatch() TemplateMatch {
 	b := TemplateBlock{
-		Hash:      m.Log.BlockHash,
-		Number:    &m.Log.BlockNumber,
-		Timestamp: m.BlockTimestamp,
+		Hash:          m.Log.BlockHash,
+		Number:        &m.Log.BlockNumber,
+		Timestamp:     m.BlockTimestamp,
+		BaseFeePerGas: m.BaseFeePerGas,
 	}
 	c := TemplateContract{
 		Address:         m.Log.Address,
@@ -360,9 +441,10 @@ func (m EventMatch) ToTemplateMatch() TemplateMatch {
 		EventParameters: m.EventParams,
 	}
 	tx := TemplateTx{
-		Hash: m.Log.TransactionHash,
-		From: m.TxFrom,
-		To:   m.TxTo,
+		Hash:    m.Log.TransactionHash,
+		From:    m.TxFrom,
+		To:      m.TxTo,
+		Receipt: m.Receipt,
 	}
 
 	t := TemplateMatch{
@@ -387,6 +469,7 @@ type PersistentEventMatch struct {
 		BlockTimestamp int
 		Hash           string
 	} `json:"Transaction"`
+	Receipt *Receipt `json:"Receipt,omitempty"`
 }
 
 func (PersistentEventMatch) isPersistable() {}
@@ -415,6 +498,7 @@ func (m EventMatch) ToPersistent() IPersistableMatch {
 			BlockTimestamp: m.BlockTimestamp,
 			Hash:           m.Log.TransactionHash,
 		},
+		Receipt: m.Receipt,
 	}
 }
 
@@ -432,6 +516,7 @@ type EventPostPayload struct {
 		BlockTimestamp int
 		Hash           string
 	}
+	Receipt     *Receipt `json:"Receipt,omitempty"`
 	TriggerName string
 	TriggerType string
 	TriggerUUID string
@@ -463,6 +548,7 @@ func (m EventMatch) ToPostPayload() IPostablePaylaod {
 			BlockTimestamp: m.BlockTimestamp,
 			Hash:           m.Log.TransactionHash,
 		},
+		Receipt:     m.Receipt,
 		TriggerName: m.Tg.TriggerName,
 		TriggerType: m.Tg.TriggerType,
 		TriggerUUID: m.Tg.TriggerUUID,
@@ -485,6 +571,92 @@ func (m EventMatch) GetUserUUID() string {
 	return m.Tg.UserUUID
 }
 
+// BLOCK MATCH
+
+type BlockMatch struct {
+	MatchUUID      string
+	Tg             *Trigger
+	BlockNumber    int
+	BlockHash      string
+	BlockTimestamp int
+	BaseFeePerGas  *big.Int
+	Stats          *BlockStats
+}
+
+func (m BlockMatch) ToTemplateMatch() TemplateMatch {
+	return TemplateMatch{
+		Block: TemplateBlock{
+			Hash:          m.BlockHash,
+			Number:        &m.BlockNumber,
+			Timestamp:     m.BlockTimestamp,
+			BaseFeePerGas: m.BaseFeePerGas,
+			Stats:         m.Stats,
+		},
+	}
+}
+
+type PersistentBlockMatch struct {
+	BlockNumber    int
+	BlockHash      string
+	BlockTimestamp int
+	BaseFeePerGas  *big.Int `json:"BaseFeePerGas,omitempty"`
+	Stats          BlockStats
+}
+
+func (PersistentBlockMatch) isPersistable() {}
+
+func (m BlockMatch) ToPersistent() IPersistableMatch {
+	return &PersistentBlockMatch{
+		BlockNumber:    m.BlockNumber,
+		BlockHash:      m.BlockHash,
+		BlockTimestamp: m.BlockTimestamp,
+		BaseFeePerGas:  m.BaseFeePerGas,
+		Stats:          *m.Stats,
+	}
+}
+
+type BlockPostPayload struct {
+	BlockNumber    int
+	BlockHash      string
+	BlockTimestamp int
+	BaseFeePerGas  *big.Int `json:"BaseFeePerGas,omitempty"`
+	Stats          BlockStats
+	TriggerName    string
+	TriggerType    string
+	TriggerUUID    string
+}
+
+func (BlockPostPayload) isPostablePayload() {}
+
+func (m BlockMatch) ToPostPayload() IPostablePaylaod {
+	return &BlockPostPayload{
+		BlockNumber:    m.BlockNumber,
+		BlockHash:      m.BlockHash,
+		BlockTimestamp: m.BlockTimestamp,
+		BaseFeePerGas:  m.BaseFeePerGas,
+		Stats:          *m.Stats,
+		TriggerName:    m.Tg.TriggerName,
+		TriggerType:    m.Tg.TriggerType,
+		TriggerUUID:    m.Tg.TriggerUUID,
+	}
+}
+
+func (m BlockMatch) GetTriggerUUID() string {
+	return m.Tg.TriggerUUID
+}
+
+func (m BlockMatch) GetMatchUUID() string {
+	return m.MatchUUID
+}
+
+func (m *BlockMatch) SetMatchUUID(uuid string) {
+	m.MatchUUID = uuid
+}
+
+func (m BlockMatch) GetUserUUID() string {
+	return m.Tg.UserUUID
+}
+
 // Outcome is the result of executing an Action; it includes:
 // - a payload (the body of the action request, as json
 // - the actual outcome of that request, as json
@@ -502,6 +674,7 @@ const (
 	WaC
 	WaE
 	CronT
+	WaB
 )
 
 func TgTypeToString(tgType TgType) string {
@@ -514,6 +687,8 @@ func TgTypeToString(tgType TgType) string {
 		return "WatchEvents"
 	case CronT:
 		return "CronTrigger"
+	case WaB:
+		return "WatchBlocks"
 	default:
 		return ""
 	}
@@ -527,6 +702,8 @@ func TgTypeToPrefix(tgType TgType) string {
 		return "wac"
 	case WaE:
 		return "wae"
+	case WaB:
+		return "wab"
 	default:
 		return ""
 	}